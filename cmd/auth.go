@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"bufio"
-	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -67,23 +66,31 @@ Then run this command and paste the contents of the credentials file.`,
 		jsonData := strings.Join(lines, "\n")
 		if jsonData == "" {
 			fmt.Println("Error: No credentials provided")
-			os.Exit(1)
+			os.Exit(ExitError)
 		}
 
 		// Validate it looks like JSON
 		if !strings.Contains(jsonData, "client_id") || !strings.Contains(jsonData, "client_secret") {
 			fmt.Println("Error: Invalid credentials format. Must contain client_id and client_secret.")
-			os.Exit(1)
+			os.Exit(ExitError)
+		}
+
+		if warning := auth.ClientTypeWarning([]byte(jsonData)); warning != "" {
+			fmt.Printf("\nWarning: %s\n", warning)
 		}
 
 		if err := config.SaveCredentials([]byte(jsonData)); err != nil {
 			fmt.Printf("Error saving credentials: %v\n", err)
-			os.Exit(1)
+			os.Exit(ExitError)
 		}
 
 		configDir, _ := config.GetConfigDir()
 		fmt.Printf("\nCredentials saved to %s/credentials.json\n", configDir)
-		fmt.Println("\nNext, run 'gday auth login' to authenticate with Google.")
+		if a := config.Account(); a != config.DefaultAccount {
+			fmt.Printf("\nNext, run 'gday auth login --account %s' to authenticate with Google.\n", a)
+		} else {
+			fmt.Println("\nNext, run 'gday auth login' to authenticate with Google.")
+		}
 	},
 }
 
@@ -95,29 +102,46 @@ var authLoginCmd = &cobra.Command{
 By default, opens a browser for authentication. Use --device for
 headless environments (SSH, containers) where no browser is available.
 
+By default all scopes gday uses are requested, except full mail access
+(mail), which only permanent deletion needs. Use --scope (repeatable) to
+choose the scopes yourself; commands needing other access will say so.
+
+Scopes: mail, gmail.readonly, gmail.send, gmail.modify, gmail.settings,
+calendar, calendar.readonly, calendar.events, tasks, tasks.readonly
+
 Examples:
   gday auth login           # Browser-based authentication
-  gday auth login --device  # Device flow for headless environments`,
+  gday auth login --device  # Device flow for headless environments
+  gday auth login --scope calendar.events --scope calendar.readonly  # Calendar only
+  gday auth login --scope mail --scope calendar  # Allow permanent deletion
+  gday auth login --account work  # Log in a second account as 'work'`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if !config.CredentialsExist() {
 			fmt.Println("Error: OAuth credentials not configured")
 			fmt.Println("\nRun 'gday auth setup' first to configure credentials")
-			os.Exit(1)
+			os.Exit(ExitAuth)
 		}
 
-		ctx := context.Background()
+		ctx, cancel := newLongContext()
+		defer cancel()
 		device, _ := cmd.Flags().GetBool("device")
+		scopeNames, _ := cmd.Flags().GetStringSlice("scope")
+
+		scopes, err := auth.ResolveScopes(scopeNames)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(ExitUsage)
+		}
 
-		var err error
 		if device {
-			err = auth.LoginDevice(ctx)
+			err = auth.LoginDevice(ctx, scopes)
 		} else {
-			err = auth.Login(ctx)
+			err = auth.Login(ctx, scopes)
 		}
 
 		if err != nil {
 			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			os.Exit(ExitError)
 		}
 	},
 }
@@ -127,9 +151,37 @@ var authLogoutCmd = &cobra.Command{
 	Short: "Logout and clear cached tokens",
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := auth.Logout(); err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			exitError("%v", err)
+		}
+		if isJSONOutput() {
+			outputJSON(StatusJSON{Status: "logged_out", Message: "Logged out successfully"})
+			return
 		}
+		fmt.Println("Logged out successfully")
+	},
+}
+
+var authRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Force a token refresh",
+	Long: `Refresh the cached access token using the stored refresh token.
+
+Useful to check that your refresh token still works, or to pre-warm the
+token before running other commands.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := newContext()
+		defer cancel()
+
+		token, err := auth.Refresh(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+		if isJSONOutput() {
+			outputJSON(TokenRefreshJSON{Status: "refreshed", Expiry: token.Expiry})
+			return
+		}
+		fmt.Println("Token refreshed")
+		fmt.Printf("Expires: %s\n", token.Expiry.Local().Format("Mon Jan 2, 2006 at 3:04 PM"))
 	},
 }
 
@@ -137,17 +189,80 @@ var authStatusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show authentication status",
 	Run: func(cmd *cobra.Command, args []string) {
+		if isJSONOutput() {
+			info := auth.GetStatus()
+			outputJSON(AuthStatusJSON{
+				Account:       info.Account,
+				Configured:    info.Configured,
+				LoggedIn:      info.LoggedIn,
+				Authenticated: info.Authenticated,
+				Email:         info.Email,
+				Problem:       info.Problem,
+				Hint:          info.Hint,
+			})
+			return
+		}
 		auth.Status()
 	},
 }
 
+var authListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List configured accounts",
+	Long: `List the accounts that have credentials or a login, marking the active one.
+
+The default account lives in ~/.gday; others live in ~/.gday/<name> and
+are selected with --account <name> or GDAY_ACCOUNT. Accounts without their
+own credentials.json share the default account's OAuth client.
+
+Examples:
+  gday auth login --account work  # Add a 'work' account
+  gday auth list
+  gday --account work mail list`,
+	Run: func(cmd *cobra.Command, args []string) {
+		accounts, err := config.ListAccounts()
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		active := config.Account()
+		if isJSONOutput() {
+			result := AccountsJSON{Active: active, Accounts: []AccountJSON{}}
+			for _, a := range accounts {
+				result.Accounts = append(result.Accounts, AccountJSON{Name: a.Name, LoggedIn: a.LoggedIn, Active: a.Name == active})
+			}
+			outputJSON(result)
+			return
+		}
+
+		if len(accounts) == 0 {
+			fmt.Println("No accounts configured. Run 'gday auth setup' to get started.")
+			return
+		}
+		for _, a := range accounts {
+			marker := " "
+			if a.Name == active {
+				marker = "*"
+			}
+			status := "logged in"
+			if !a.LoggedIn {
+				status = "not logged in"
+			}
+			fmt.Printf("%s %-20s %s\n", marker, a.Name, status)
+		}
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(authCmd)
 	authCmd.AddCommand(authSetupCmd)
 	authCmd.AddCommand(authLoginCmd)
 	authCmd.AddCommand(authLogoutCmd)
 	authCmd.AddCommand(authStatusCmd)
+	authCmd.AddCommand(authRefreshCmd)
+	authCmd.AddCommand(authListCmd)
 
 	// Login flags
 	authLoginCmd.Flags().Bool("device", false, "Use device flow for headless environments (SSH, containers)")
+	authLoginCmd.Flags().StringSlice("scope", nil, "Scope to request (repeatable; default: all)")
 }
@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/joncooper/gday/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// BatchConfirmThreshold is the default number of items above which batch
+// operations prompt for confirmation unless --yes is given
+const BatchConfirmThreshold = 10
+
+// confirmThreshold returns the batch confirmation threshold from
+// --confirm-threshold, then the confirm_threshold config key, then the default
+func confirmThreshold(cmd *cobra.Command) int {
+	if cmd.Flags().Changed("confirm-threshold") {
+		threshold, _ := cmd.Flags().GetInt("confirm-threshold")
+		return threshold
+	}
+	if settings, err := config.LoadSettings(); err == nil && settings.ConfirmThreshold != nil {
+		return *settings.ConfirmThreshold
+	}
+	return BatchConfirmThreshold
+}
+
+// addBatchFlags registers the --yes, --dry-run, and --progress flags on a
+// batch command
+func addBatchFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolP("yes", "y", false, "Skip confirmation prompt")
+	cmd.Flags().Bool("dry-run", false, "Show what would be done without doing it")
+	cmd.Flags().String("progress", "", "Emit progress to stderr: json for one JSON object per item")
+}
+
+// addIDsFromFlag registers the --ids-from flag on a command that takes IDs
+func addIDsFromFlag(cmd *cobra.Command) {
+	cmd.Flags().String("ids-from", "", "Read whitespace-separated IDs from a file ('-' for stdin)")
+}
+
+// collectIDs returns the IDs given as positional args plus any read via
+// --ids-from. With neither, IDs are read from stdin if it is a pipe.
+func collectIDs(cmd *cobra.Command, args []string) []string {
+	ids := append([]string(nil), args...)
+
+	idsFrom, _ := cmd.Flags().GetString("ids-from")
+	if len(ids) == 0 && idsFrom == "" && stdinIsPipe() {
+		idsFrom = "-"
+		cmd.Flags().Set("ids-from", idsFrom)
+	}
+	if idsFrom != "" {
+		var r io.Reader = os.Stdin
+		if idsFrom != "-" {
+			f, err := os.Open(idsFrom)
+			if err != nil {
+				exitError("failed to read IDs: %v", err)
+			}
+			defer f.Close()
+			r = f
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			exitError("failed to read IDs: %v", err)
+		}
+		ids = append(ids, strings.Fields(string(data))...)
+	}
+
+	if len(ids) == 0 {
+		exitUsage("no IDs given (pass them as arguments or with --ids-from)")
+	}
+	return ids
+}
+
+// stdinIsPipe reports whether stdin is redirected rather than a terminal
+func stdinIsPipe() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice == 0
+}
+
+// runBatch confirms and then applies fn to each ID, printing a summary
+func runBatch(cmd *cobra.Command, action string, ids []string, destructive bool, fn func(id string) error) {
+	if !confirmBatch(cmd, action, ids, destructive) {
+		return
+	}
+
+	applyBatch(cmd, action, ids, fn)
+}
+
+// applyBatch applies fn to each ID, reporting progress and printing a summary
+func applyBatch(cmd *cobra.Command, action string, ids []string, fn func(id string) error) {
+	progress := newProgressReporter(cmd, len(ids))
+	failed := make(map[string]error)
+	for _, id := range ids {
+		err := fn(id)
+		if err != nil {
+			failed[id] = err
+		}
+		progress.report(id, err)
+	}
+	printBatchResult(action, len(ids), failed)
+}
+
+// progressReporter writes newline-delimited JSON progress events for UIs
+// wrapping batch commands. A nil reporter reports nothing.
+type progressReporter struct {
+	enc       *json.Encoder
+	total     int
+	processed int
+}
+
+// newProgressReporter returns a reporter if --progress json was given
+func newProgressReporter(cmd *cobra.Command, total int) *progressReporter {
+	mode, _ := cmd.Flags().GetString("progress")
+	switch mode {
+	case "":
+		return nil
+	case "json":
+		return &progressReporter{enc: json.NewEncoder(os.Stderr), total: total}
+	}
+	exitUsage("invalid --progress %q (use json)", mode)
+	return nil
+}
+
+// report records that one more item has been processed
+func (p *progressReporter) report(id string, err error) {
+	if p == nil {
+		return
+	}
+	p.processed++
+	event := ProgressJSON{
+		Processed: p.processed,
+		Total:     p.total,
+		LastID:    id,
+		Status:    "ok",
+	}
+	if err != nil {
+		event.Status = "failed"
+		event.Error = err.Error()
+	}
+	p.enc.Encode(event)
+}
+
+// confirmBatch asks the user to confirm a batch operation on the given items.
+// Destructive operations always prompt; others only prompt above the
+// confirmation threshold. Returns false if the operation should not proceed.
+func confirmBatch(cmd *cobra.Command, action string, items []string, destructive bool) bool {
+	yes, _ := cmd.Flags().GetBool("yes")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	if dryRun {
+		if isJSONOutput() {
+			outputJSON(BatchResultJSON{Action: action, Total: len(items), DryRun: true, Items: items})
+			return false
+		}
+		fmt.Printf("Would %s %d item(s):\n", action, len(items))
+		for _, item := range items {
+			fmt.Printf("  %s\n", item)
+		}
+		return false
+	}
+
+	if yes {
+		return true
+	}
+	if !destructive {
+		// 0 always prompts; a negative threshold never does
+		threshold := confirmThreshold(cmd)
+		if threshold < 0 || (threshold > 0 && len(items) <= threshold) {
+			return true
+		}
+	}
+
+	if stdinConsumed(cmd) {
+		exitUsage("cannot prompt for confirmation when reading input from stdin; pass --yes to proceed")
+	}
+
+	fmt.Fprintf(os.Stderr, "About to %s %d item(s):\n", action, len(items))
+	for _, item := range items {
+		fmt.Fprintf(os.Stderr, "  %s\n", item)
+	}
+	return confirm("Proceed?")
+}
+
+// confirm asks a yes/no question on stderr, printing "Aborted" unless the
+// answer is yes. Without a terminal to answer on, it exits asking for --yes
+// instead. A command that is declined returns without an error.
+func confirm(prompt string) bool {
+	if stdinIsPipe() {
+		exitUsage("cannot prompt for confirmation when stdin isn't a terminal; pass --yes to proceed")
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", prompt)
+	if !answeredYes(os.Stdin) {
+		fmt.Fprintln(os.Stderr, "Aborted")
+		return false
+	}
+	return true
+}
+
+// answeredYes reads one line and reports whether it is y or yes
+func answeredYes(r io.Reader) bool {
+	answer, _ := bufio.NewReader(r).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// stdinAnnotation lists, comma-separated, a command's boolean flags that
+// make it read its input from stdin
+const stdinAnnotation = "gday/stdin"
+
+// stdinConsumed reports whether the command reads its input from stdin,
+// leaving nothing to answer a confirmation prompt with
+func stdinConsumed(cmd *cobra.Command) bool {
+	if f := cmd.Flags().Lookup("ids-from"); f != nil && f.Value.String() == "-" {
+		return true
+	}
+	if names := cmd.Annotations[stdinAnnotation]; names != "" {
+		for _, name := range strings.Split(names, ",") {
+			if f := cmd.Flags().Lookup(name); f != nil && f.Value.String() == "true" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// printBatchResult prints a summary of a batch operation, exiting with
+// ExitPartial or ExitError if any items failed
+func printBatchResult(action string, total int, failed map[string]error) {
+	if isJSONOutput() {
+		result := BatchResultJSON{
+			Action:    action,
+			Total:     total,
+			Succeeded: total - len(failed),
+		}
+		for id, err := range failed {
+			result.Failed = append(result.Failed, BatchFailureJSON{ID: id, Error: err.Error()})
+		}
+		outputJSON(result)
+		exitForBatchResult(total, failed)
+		return
+	}
+
+	for id, err := range failed {
+		fmt.Fprintf(os.Stderr, "Failed to %s %s: %v\n", action, id, err)
+	}
+	fmt.Printf("%s: %d succeeded, %d failed\n", action, total-len(failed), len(failed))
+	exitForBatchResult(total, failed)
+}
+
+// exitForBatchResult exits with the batch's exit code if any items failed
+func exitForBatchResult(total int, failed map[string]error) {
+	if code := batchExitCode(total, failed); code != ExitOK {
+		os.Exit(code)
+	}
+}
+
+// batchExitCode returns ExitPartial if some items failed, ExitError if all
+// of them did, and ExitOK otherwise
+func batchExitCode(total int, failed map[string]error) int {
+	switch {
+	case len(failed) == 0:
+		return ExitOK
+	case len(failed) < total:
+		return ExitPartial
+	default:
+		return ExitError
+	}
+}
@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestAnsweredYes(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"y\n", true},
+		{"yes\n", true},
+		{"  Y \n", true},
+		{"YES", true},
+		{"n\n", false},
+		{"\n", false},
+		{"", false},
+		{"yep\n", false},
+	}
+	for _, tt := range tests {
+		if got := answeredYes(strings.NewReader(tt.input)); got != tt.want {
+			t.Errorf("answeredYes(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestStdinConsumed(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		set         map[string]string
+		want        bool
+	}{
+		{"nothing from stdin", map[string]string{stdinAnnotation: "body-stdin,raw"}, nil, false},
+		{"ids from stdin", nil, map[string]string{"ids-from": "-"}, true},
+		{"ids from a file", nil, map[string]string{"ids-from": "ids.txt"}, false},
+		{"body from stdin", map[string]string{stdinAnnotation: "body-stdin,raw"}, map[string]string{"body-stdin": "true"}, true},
+		{"raw message from stdin", map[string]string{stdinAnnotation: "body-stdin,raw"}, map[string]string{"raw": "true"}, true},
+		{"raw without the annotation", nil, map[string]string{"raw": "true"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cobra.Command{Use: "renamed", Annotations: tt.annotations}
+			addIDsFromFlag(cmd)
+			cmd.Flags().Bool("body-stdin", false, "")
+			cmd.Flags().Bool("raw", false, "")
+			for name, value := range tt.set {
+				if err := cmd.Flags().Set(name, value); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := stdinConsumed(cmd); got != tt.want {
+				t.Errorf("stdinConsumed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProgressReporter(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []error // One per item, nil for success
+		want    []ProgressJSON
+	}{
+		{
+			name:    "all succeed",
+			results: []error{nil, nil},
+			want: []ProgressJSON{
+				{Processed: 1, Total: 2, LastID: "m1", Status: "ok"},
+				{Processed: 2, Total: 2, LastID: "m2", Status: "ok"},
+			},
+		},
+		{
+			name:    "one fails",
+			results: []error{nil, errors.New("not found"), nil},
+			want: []ProgressJSON{
+				{Processed: 1, Total: 3, LastID: "m1", Status: "ok"},
+				{Processed: 2, Total: 3, LastID: "m2", Status: "failed", Error: "not found"},
+				{Processed: 3, Total: 3, LastID: "m3", Status: "ok"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			p := &progressReporter{enc: json.NewEncoder(&buf), total: len(tt.results)}
+			for i, err := range tt.results {
+				p.report(fmt.Sprintf("m%d", i+1), err)
+			}
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if len(lines) != len(tt.want) {
+				t.Fatalf("got %d events, want %d:\n%s", len(lines), len(tt.want), buf.String())
+			}
+			for i, line := range lines {
+				var got ProgressJSON
+				if err := json.Unmarshal([]byte(line), &got); err != nil {
+					t.Fatalf("event %d isn't JSON: %q", i, line)
+				}
+				if got != tt.want[i] {
+					t.Errorf("event %d = %+v, want %+v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewProgressReporter(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{nil, false},
+		{[]string{"--progress", "json"}, true},
+	}
+	for _, tt := range tests {
+		cmd := &cobra.Command{Use: "archive"}
+		cmd.Flags().String("progress", "", "")
+		if err := cmd.ParseFlags(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		if got := newProgressReporter(cmd, 3) != nil; got != tt.want {
+			t.Errorf("%v: reporting = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+
+	// A nil reporter is quiet
+	var p *progressReporter
+	p.report("m1", nil)
+}
+
+// withStdin replaces os.Stdin with a pipe holding input for a test
+func withStdin(t *testing.T, input string) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		w.WriteString(input)
+		w.Close()
+	}()
+	stdin := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		r.Close()
+	})
+}
+
+func TestCollectIDs(t *testing.T) {
+	idsFile := filepath.Join(t.TempDir(), "ids.txt")
+	if err := os.WriteFile(idsFile, []byte("f1\nf2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		idsFrom string
+		stdin   string
+		want    []string
+	}{
+		{"piped stdin", nil, "", "m1\nm2\n\n  m3\tm4 \n", []string{"m1", "m2", "m3", "m4"}},
+		{"args ignore piped stdin", []string{"a1"}, "", "m1\n", []string{"a1"}},
+		{"args plus --ids-from -", []string{"a1", "a2"}, "-", "m1 m2\n", []string{"a1", "a2", "m1", "m2"}},
+		{"args plus --ids-from a file", []string{"a1"}, idsFile, "m1\n", []string{"a1", "f1", "f2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withStdin(t, tt.stdin)
+			cmd := &cobra.Command{Use: "archive"}
+			addIDsFromFlag(cmd)
+			if tt.idsFrom != "" {
+				cmd.Flags().Set("ids-from", tt.idsFrom)
+			}
+			if got := collectIDs(cmd, tt.args); !slices.Equal(got, tt.want) {
+				t.Errorf("collectIDs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("piped stdin counts as consumed", func(t *testing.T) {
+		withStdin(t, "m1\n")
+		cmd := &cobra.Command{Use: "archive"}
+		addIDsFromFlag(cmd)
+		collectIDs(cmd, nil)
+		if !stdinConsumed(cmd) {
+			t.Error("stdinConsumed = false after reading IDs from stdin, want true so prompts are refused")
+		}
+	})
+
+	t.Run("no IDs", func(t *testing.T) {
+		code := exitCode(t, func() {
+			withStdin(t, " \n")
+			cmd := &cobra.Command{Use: "archive"}
+			addIDsFromFlag(cmd)
+			collectIDs(cmd, nil)
+		})
+		if code != ExitUsage {
+			t.Errorf("exit code = %d, want %d", code, ExitUsage)
+		}
+	})
+}
+
+func TestConfirmBatchThreshold(t *testing.T) {
+	items := func(n int) []string {
+		list := make([]string, n)
+		for i := range list {
+			list[i] = fmt.Sprintf("m%d", i)
+		}
+		return list
+	}
+	tests := []struct {
+		name        string
+		items       int
+		flag        string // --confirm-threshold, if given
+		setting     string // The confirm_threshold config key, if set
+		destructive bool
+		wantPrompt  bool
+	}{
+		{name: "at the default", items: BatchConfirmThreshold},
+		{name: "one over the default", items: BatchConfirmThreshold + 1, wantPrompt: true},
+		{name: "at the flag's threshold", items: 3, flag: "3"},
+		{name: "one over the flag's threshold", items: 4, flag: "3", wantPrompt: true},
+		{name: "zero always prompts", items: 1, flag: "0", wantPrompt: true},
+		{name: "negative never prompts", items: 500, flag: "-1"},
+		{name: "negative still prompts for destructive", items: 1, flag: "-1", destructive: true, wantPrompt: true},
+		{name: "config key", items: 3, setting: `{"confirm_threshold": 2}`, wantPrompt: true},
+		{name: "flag overrides config key", items: 3, flag: "5", setting: `{"confirm_threshold": 2}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := exitCode(t, func() {
+				withSettings(t, cmp.Or(tt.setting, "{}"))
+				// A pipe on stdin makes a prompt exit instead of waiting
+				withStdin(t, "")
+				cmd := &cobra.Command{Use: "archive"}
+				addBatchFlags(cmd)
+				cmd.Flags().Int("confirm-threshold", BatchConfirmThreshold, "")
+				if tt.flag != "" {
+					cmd.Flags().Set("confirm-threshold", tt.flag)
+				}
+				if !confirmBatch(cmd, "archive", items(tt.items), tt.destructive) {
+					os.Exit(ExitError)
+				}
+			})
+			if prompted := code == ExitUsage; prompted != tt.wantPrompt {
+				t.Errorf("prompted = %v (exit code %d), want %v", prompted, code, tt.wantPrompt)
+			}
+			if !tt.wantPrompt && code != ExitOK {
+				t.Errorf("exit code = %d, want confirmBatch to proceed", code)
+			}
+		})
+	}
+}
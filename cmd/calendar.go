@@ -2,12 +2,23 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/mail"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"text/template"
 	"time"
+	"unicode/utf8"
 
 	"github.com/joncooper/gday/internal/auth"
 	gdaycal "github.com/joncooper/gday/internal/calendar"
+	"github.com/joncooper/gday/internal/config"
+	gdaygmail "github.com/joncooper/gday/internal/gmail"
+	gdaytasks "github.com/joncooper/gday/internal/tasks"
 	"github.com/spf13/cobra"
 )
 
@@ -19,8 +30,9 @@ var calCmd = &cobra.Command{
 }
 
 var calListCmd = &cobra.Command{
-	Use:   "list",
-	Short: "List upcoming events",
+	Use:         "list",
+	Short:       "List upcoming events",
+	Annotations: map[string]string{csvAnnotation: "true", scopesAnnotation: "calendar.read"},
 	Long: `List upcoming calendar events.
 
 Examples:
@@ -28,9 +40,14 @@ Examples:
   gday cal list -n 20              # List next 20 events
   gday cal list --days 30          # Events in next 30 days
   gday cal list --calendar work    # Events from specific calendar
-  gday cal list --all-calendars    # Events from all calendars`,
+  gday cal list --all-calendars    # Events from all calendars
+  gday cal list --busy-only        # Skip events shown as free
+  gday cal list --mine-only        # Only events you organize
+  gday cal list --accepted-only    # Only events you've accepted
+  gday cal list --days 30 --format csv > events.csv`,
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
+		ctx, cancel := newContext()
+		defer cancel()
 		client, err := auth.GetClient(ctx)
 		if err != nil {
 			exitError("%v", err)
@@ -43,7 +60,7 @@ Examples:
 
 		n, _ := cmd.Flags().GetInt64("number")
 		days, _ := cmd.Flags().GetInt("days")
-		calID, _ := cmd.Flags().GetString("calendar")
+		calID := resolveCalendarID(ctx, cmd, srv)
 		allCals, _ := cmd.Flags().GetBool("all-calendars")
 
 		now := time.Now()
@@ -52,7 +69,8 @@ Examples:
 
 		var events []*gdaycal.Event
 		if allCals {
-			events, err = srv.ListEventsFromAllCalendars(ctx, timeMin, timeMax, n)
+			noDedup, _ := cmd.Flags().GetBool("no-dedup")
+			events, err = srv.ListEventsFromAllCalendars(ctx, timeMin, timeMax, n, !noDedup)
 		} else {
 			events, err = srv.ListEvents(ctx, calID, timeMin, timeMax, n)
 		}
@@ -60,25 +78,34 @@ Examples:
 			exitError("%v", err)
 		}
 
-		if isJSONOutput() {
-			outputJSON(eventsToJSON(events))
-			return
-		}
+		busyOnly, _ := cmd.Flags().GetBool("busy-only")
+		mineOnly, _ := cmd.Flags().GetBool("mine-only")
+		acceptedOnly, _ := cmd.Flags().GetBool("accepted-only")
+		events = filterEvents(events, busyOnly, mineOnly, acceptedOnly)
 
-		if len(events) == 0 {
+		if len(events) == 0 && outputFormat == formatTable {
 			fmt.Println("No upcoming events")
 			return
 		}
 
-		printEvents(events)
+		output(eventsToJSON(events), func(csv bool) *table { return eventsTable(events, csv) })
 	},
 }
 
 var calTodayCmd = &cobra.Command{
-	Use:   "today",
-	Short: "Show today's events",
+	Use:         "today",
+	Short:       "Show today's events",
+	Annotations: map[string]string{scopesAnnotation: "calendar.read"},
+	Long: `Show today's events.
+
+Examples:
+  gday cal today
+  gday cal today --compact            # 09:00 Standup | 11:00 Review | 14:00 1:1
+  gday cal today --compact --width 40 # Fit a tmux status line
+  gday cal today --include-tasks      # Also show Google Tasks due today or overdue`,
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
+		ctx, cancel := newContext()
+		defer cancel()
 		client, err := auth.GetClient(ctx)
 		if err != nil {
 			exitError("%v", err)
@@ -89,33 +116,64 @@ var calTodayCmd = &cobra.Command{
 			exitError("%v", err)
 		}
 
-		calID, _ := cmd.Flags().GetString("calendar")
+		calID := resolveCalendarID(ctx, cmd, srv)
 		events, err := srv.Today(ctx, calID)
 		if err != nil {
 			exitError("%v", err)
 		}
 
+		var due []*gdaytasks.Task
+		if includeTasks, _ := cmd.Flags().GetBool("include-tasks"); includeTasks {
+			if err := auth.CheckAccess(auth.TasksRead); err != nil {
+				exitError("%v", err)
+			}
+			tasksSrv, err := gdaytasks.NewService(ctx, client)
+			if err != nil {
+				exitError("%v", err)
+			}
+			if due, err = tasksSrv.DueBy(ctx, gdaytasks.DefaultList, time.Now()); err != nil {
+				exitError("%v", err)
+			}
+		}
+
 		if isJSONOutput() {
-			outputJSON(eventsToJSON(events))
+			result := eventsToJSON(events)
+			if len(due) > 0 {
+				result.Tasks = tasksToJSON(due).Tasks
+			}
+			outputJSON(result)
+			return
+		}
+
+		if compact, _ := cmd.Flags().GetBool("compact"); compact {
+			width, _ := cmd.Flags().GetInt("width")
+			fmt.Println(compactEvents(events, width))
 			return
 		}
 
 		if len(events) == 0 {
 			fmt.Println("No events today")
-			return
+		} else {
+			fmt.Println("Today's events:")
+			fmt.Println()
+			printEvents(events)
 		}
 
-		fmt.Println("Today's events:")
-		fmt.Println()
-		printEvents(events)
+		if len(due) > 0 {
+			fmt.Println()
+			fmt.Println("Tasks due:")
+			printTasks(due)
+		}
 	},
 }
 
 var calTomorrowCmd = &cobra.Command{
-	Use:   "tomorrow",
-	Short: "Show tomorrow's events",
+	Use:         "tomorrow",
+	Short:       "Show tomorrow's events",
+	Annotations: map[string]string{scopesAnnotation: "calendar.read"},
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
+		ctx, cancel := newContext()
+		defer cancel()
 		client, err := auth.GetClient(ctx)
 		if err != nil {
 			exitError("%v", err)
@@ -126,7 +184,7 @@ var calTomorrowCmd = &cobra.Command{
 			exitError("%v", err)
 		}
 
-		calID, _ := cmd.Flags().GetString("calendar")
+		calID := resolveCalendarID(ctx, cmd, srv)
 		events, err := srv.Tomorrow(ctx, calID)
 		if err != nil {
 			exitError("%v", err)
@@ -149,10 +207,12 @@ var calTomorrowCmd = &cobra.Command{
 }
 
 var calWeekCmd = &cobra.Command{
-	Use:   "week",
-	Short: "Show this week's events",
+	Use:         "week",
+	Short:       "Show this week's events",
+	Annotations: map[string]string{scopesAnnotation: "calendar.read"},
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
+		ctx, cancel := newContext()
+		defer cancel()
 		client, err := auth.GetClient(ctx)
 		if err != nil {
 			exitError("%v", err)
@@ -163,7 +223,7 @@ var calWeekCmd = &cobra.Command{
 			exitError("%v", err)
 		}
 
-		calID, _ := cmd.Flags().GetString("calendar")
+		calID := resolveCalendarID(ctx, cmd, srv)
 		events, err := srv.Week(ctx, calID)
 		if err != nil {
 			exitError("%v", err)
@@ -185,12 +245,103 @@ var calWeekCmd = &cobra.Command{
 	},
 }
 
+var calAgendaCmd = &cobra.Command{
+	Use:         "agenda",
+	Short:       "Show events between two dates",
+	Annotations: map[string]string{scopesAnnotation: "calendar.read"},
+	Long: `Show events grouped by day between --from and --to, both inclusive.
+--to defaults to a week after --from.
+
+Dates are YYYY-MM-DD or relative: today, tomorrow, yesterday, +14d, -2w,
+a weekday (mon, tuesday, ...), or "next week" (next Monday).
+
+Examples:
+  gday cal agenda                                  # Today through next week
+  gday cal agenda --from 2024-06-01 --to 2024-06-07
+  gday cal agenda --from today --to +14d
+  gday cal agenda --from "next week" --to +2w --all-calendars`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fromStr, _ := cmd.Flags().GetString("from")
+		toStr, _ := cmd.Flags().GetString("to")
+
+		from, err := parseDate(fromStr)
+		if err != nil {
+			exitUsage("invalid --from: %v", err)
+		}
+		to := from.AddDate(0, 0, 7)
+		if toStr != "" {
+			if to, err = parseDate(toStr); err != nil {
+				exitUsage("invalid --to: %v", err)
+			}
+		}
+		if to.Before(from) {
+			exitUsage("--to is before --from")
+		}
+
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaycal.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		calID := resolveCalendarID(ctx, cmd, srv)
+		allCals, _ := cmd.Flags().GetBool("all-calendars")
+
+		// --to is inclusive, so the window runs to midnight after it
+		timeMax := to.AddDate(0, 0, 1)
+
+		var events []*gdaycal.Event
+		if allCals {
+			events, err = srv.ListEventsFromAllCalendars(ctx, from, timeMax, 0, true)
+		} else {
+			events, err = srv.ListEvents(ctx, calID, from, timeMax, 0)
+		}
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		// All-day events head each day
+		sort.SliceStable(events, func(i, j int) bool {
+			di, dj := events[i].Start.Format(time.DateOnly), events[j].Start.Format(time.DateOnly)
+			if di != dj {
+				return di < dj
+			}
+			return events[i].AllDay && !events[j].AllDay
+		})
+
+		if isJSONOutput() {
+			outputJSON(eventsToJSON(events))
+			return
+		}
+
+		if len(events) == 0 {
+			fmt.Printf("No events from %s to %s\n", from.Format("Mon Jan 2"), to.Format("Mon Jan 2"))
+			return
+		}
+
+		printEvents(events)
+	},
+}
+
 var calShowCmd = &cobra.Command{
-	Use:   "show <event-id>",
-	Short: "Show event details",
-	Args:  cobra.ExactArgs(1),
+	Use:         "show <event-id>",
+	Short:       "Show event details",
+	Annotations: map[string]string{scopesAnnotation: "calendar.read"},
+	Long: `Show the details of a calendar event.
+
+Examples:
+  gday cal show abc123                       # Event details
+  gday cal show abc123 --open-attachment 1   # Open the first attachment in a browser`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
+		ctx, cancel := newContext()
+		defer cancel()
 		client, err := auth.GetClient(ctx)
 		if err != nil {
 			exitError("%v", err)
@@ -202,13 +353,29 @@ var calShowCmd = &cobra.Command{
 		}
 
 		eventID := args[0]
-		calID, _ := cmd.Flags().GetString("calendar")
+		calID := resolveCalendarID(ctx, cmd, srv)
+
+		openAttachment, _ := cmd.Flags().GetInt("open-attachment")
 
 		event, err := srv.GetEvent(ctx, calID, eventID)
 		if err != nil {
 			exitError("%v", err)
 		}
 
+		// Instances don't carry the rule; it's on the recurring event
+		if len(event.Recurrence) == 0 && event.RecurrenceID != "" {
+			if series, err := srv.GetEvent(ctx, calID, event.RecurrenceID); err == nil {
+				event.Recurrence = series.Recurrence
+			}
+		}
+
+		if openAttachment > 0 {
+			if openAttachment > len(event.Attachments) {
+				exitUsage("event has %d attachment(s)", len(event.Attachments))
+			}
+			auth.OpenBrowser(event.Attachments[openAttachment-1].FileURL)
+		}
+
 		if isJSONOutput() {
 			outputJSON(eventToJSON(event))
 			return
@@ -219,16 +386,37 @@ var calShowCmd = &cobra.Command{
 }
 
 var calCreateCmd = &cobra.Command{
-	Use:   "create",
-	Short: "Create a new event",
+	Use:         "create",
+	Short:       "Create a new event",
+	Annotations: map[string]string{scopesAnnotation: "calendar.write"},
 	Long: `Create a new calendar event.
 
 Examples:
   gday cal create --title "Meeting" --start "2024-01-15 14:00" --end "2024-01-15 15:00"
   gday cal create --title "Birthday" --date "2024-01-20" --all-day
-  gday cal create --quick "Lunch with John tomorrow at noon"`,
+  gday cal create --title "Standup" --start "2024-01-15 09:00" --recur weekdays
+  gday cal create --title "Gym" --start "2024-01-15 07:00" --recur "FREQ=WEEKLY;BYDAY=MO,WE" --recurrence-count 10
+  gday cal create --title "Planning" --start "2024-01-15 10:00" --attendees @team,guest@example.com
+  gday cal create --title "Sync" --start 15:00 --attendees bob@example.com --meet
+  gday cal create --title "Dentist" --start "2024-01-15 09:00" --remind 1h,email:1d
+  gday cal create --quick "Lunch with John tomorrow at noon"
+
+--recur takes daily, weekdays, weekly, monthly, or yearly, or an RFC 5545
+RRULE for anything else. Weekly, monthly, and yearly repeat on the start
+date's weekday or day.
+
+--attendees accepts @name for an attendee group from config.json, or @file
+for a file of newline- or comma-separated addresses.
+
+--remind replaces the calendar's default reminders with popups at the
+given times before the event (e.g. 10m, 1h, 2d). Prefix a time with
+"email:" for an email reminder instead, or pass "none" for no reminders.
+
+If the new event overlaps a busy event, gday lists the overlap and asks
+before creating it; --yes creates it without asking.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
+		ctx, cancel := newContext()
+		defer cancel()
 		client, err := auth.GetClient(ctx)
 		if err != nil {
 			exitError("%v", err)
@@ -239,7 +427,7 @@ Examples:
 			exitError("%v", err)
 		}
 
-		calID, _ := cmd.Flags().GetString("calendar")
+		calID := resolveWritableCalendarID(ctx, cmd, srv)
 		quick, _ := cmd.Flags().GetString("quick")
 
 		// Quick add mode
@@ -265,67 +453,74 @@ Examples:
 		}
 
 		// Manual event creation
-		title, _ := cmd.Flags().GetString("title")
-		startStr, _ := cmd.Flags().GetString("start")
-		endStr, _ := cmd.Flags().GetString("end")
-		dateStr, _ := cmd.Flags().GetString("date")
-		allDay, _ := cmd.Flags().GetBool("all-day")
-		location, _ := cmd.Flags().GetString("location")
-		description, _ := cmd.Flags().GetString("description")
 		attendees, _ := cmd.Flags().GetStringSlice("attendees")
-
-		if title == "" {
-			exitError("--title or --quick is required")
+		attendees, err = expandAttendees(attendees)
+		if err != nil {
+			exitUsage("%v", err)
 		}
-
-		event := &gdaycal.Event{
-			Summary:     title,
-			Location:    location,
-			Description: description,
-			Attendees:   attendees,
+		recur, _ := cmd.Flags().GetString("recur")
+		recurCount, _ := cmd.Flags().GetInt("recurrence-count")
+		free, _ := cmd.Flags().GetBool("free")
+		meet, _ := cmd.Flags().GetBool("meet")
+
+		event := eventFromFlags(cmd)
+		event.Attendees = attendees
+		event.Busy = !free
+		event.Meet = meet
+		if cmd.Flags().Changed("remind") {
+			event.Reminders = remindersFromFlag(cmd)
 		}
 
-		if allDay || dateStr != "" {
-			event.AllDay = true
-			if dateStr != "" {
-				t, err := parseDate(dateStr)
-				if err != nil {
-					exitError("invalid date format: %v", err)
-				}
-				event.Start = t
-				event.End = t.AddDate(0, 0, 1)
-			} else {
-				exitError("--date is required for all-day events")
-			}
-		} else {
-			if startStr == "" {
-				exitError("--start is required (or use --quick)")
-			}
-			start, err := parseDateTime(startStr)
+		if recurCount < 0 {
+			exitUsage("--recurrence-count must be positive")
+		}
+		if recurCount > 0 && recur == "" {
+			exitUsage("--recurrence-count requires --recur")
+		}
+		if recur != "" {
+			rule, err := recurrenceRule(recur)
 			if err != nil {
-				exitError("invalid start time: %v", err)
+				exitUsage("%v", err)
 			}
-			event.Start = start
-
-			if endStr != "" {
-				end, err := parseDateTime(endStr)
-				if err != nil {
-					exitError("invalid end time: %v", err)
+			if recurCount > 0 {
+				if rule, err = withRecurrenceCount(rule, recurCount); err != nil {
+					exitUsage("%v", err)
 				}
-				event.End = end
-			} else {
-				// Default to 1 hour duration
-				event.End = start.Add(time.Hour)
 			}
+			event.Recurrence = []string{"RRULE:" + rule}
+		}
+
+		if !confirmNoConflicts(ctx, cmd, srv, calID, event) {
+			return
 		}
 
 		created, err := srv.CreateEvent(ctx, calID, event)
 		if err != nil {
 			exitError("%v", err)
 		}
+		if meet && created.MeetLink == "" {
+			fmt.Fprintln(os.Stderr, "Warning: Google Meet isn't available on this calendar; created the event without a Meet link")
+		}
+
+		// Show the first few expanded instances so the user can check the dates
+		var occurrences []*gdaycal.Event
+		if recur != "" {
+			n := maxOccurrencesShown
+			if recurCount > 0 {
+				n = min(recurCount, maxOccurrencesShown)
+			}
+			occurrences, err = srv.Instances(ctx, calID, created.ID, int64(n))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to fetch occurrences: %v\n", err)
+			}
+		}
 
 		if isJSONOutput() {
-			outputJSON(EventCreatedJSON{ID: created.ID, Summary: created.Summary, HtmlLink: created.HtmlLink, Status: "created"})
+			result := EventCreatedJSON{ID: created.ID, Summary: created.Summary, HtmlLink: created.HtmlLink, MeetLink: created.MeetLink, Status: "created"}
+			for _, o := range occurrences {
+				result.Occurrences = append(result.Occurrences, eventToJSON(o))
+			}
+			outputJSON(result)
 			return
 		}
 
@@ -334,15 +529,76 @@ Examples:
 		if created.HtmlLink != "" {
 			fmt.Printf("Link: %s\n", created.HtmlLink)
 		}
+		if created.MeetLink != "" {
+			fmt.Printf("Meet: %s\n", created.MeetLink)
+		}
+		if len(occurrences) > 0 {
+			if recurCount > 0 {
+				fmt.Printf("\nFirst %d of %d occurrences:\n", len(occurrences), recurCount)
+			} else {
+				fmt.Printf("\nFirst %d occurrences:\n", len(occurrences))
+			}
+			for _, o := range occurrences {
+				if o.AllDay {
+					fmt.Printf("  %s\n", o.Start.Format("Mon Jan 2, 2006"))
+				} else {
+					fmt.Printf("  %s\n", o.Start.Format("Mon Jan 2, 2006 at 3:04 PM"))
+				}
+			}
+		}
 	},
 }
 
-var calDeleteCmd = &cobra.Command{
-	Use:   "delete <event-id>",
-	Short: "Delete an event",
-	Args:  cobra.ExactArgs(1),
+// confirmNoConflicts warns about busy events the new event overlaps and
+// asks whether to go ahead, unless --yes is given. Returns false if the
+// user declines.
+func confirmNoConflicts(ctx context.Context, cmd *cobra.Command, srv *gdaycal.Service, calID string, event *gdaycal.Event) bool {
+	if event.AllDay || !event.Busy {
+		return true
+	}
+	existing, err := srv.ListEvents(ctx, calID, event.Start, event.End, 0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: couldn't check for conflicts: %v\n", err)
+		return true
+	}
+	var conflicts []*gdaycal.Event
+	for _, e := range existing {
+		if e.Blocks(false, false) && e.Overlaps(event) {
+			conflicts = append(conflicts, e)
+		}
+	}
+	if len(conflicts) == 0 {
+		return true
+	}
+
+	fmt.Fprintln(os.Stderr, "Warning: this overlaps:")
+	for _, e := range conflicts {
+		fmt.Fprintf(os.Stderr, "  %s - %s  %s\n", e.Start.Local().Format("Mon Jan 2 15:04"), e.End.Local().Format("15:04"), e.Summary)
+	}
+	if yes, _ := cmd.Flags().GetBool("yes"); yes {
+		return true
+	}
+	return confirm("Create anyway?")
+}
+
+var calConflictsCmd = &cobra.Command{
+	Use:         "conflicts",
+	Short:       "List overlapping events",
+	Annotations: map[string]string{scopesAnnotation: "calendar.read"},
+	Long: `List pairs of events that overlap in the next --days.
+
+Only events that take up your time count: free, cancelled, all-day, and
+declined events are skipped unless --include-all-day or --include-declined
+is given. With --all-calendars, copies of the same event on several
+calendars aren't reported.
+
+Examples:
+  gday cal conflicts                  # Next 14 days on your calendar
+  gday cal conflicts --days 30 --all-calendars
+  gday cal conflicts --json`,
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
+		ctx, cancel := newContext()
+		defer cancel()
 		client, err := auth.GetClient(ctx)
 		if err != nil {
 			exitError("%v", err)
@@ -353,33 +609,76 @@ var calDeleteCmd = &cobra.Command{
 			exitError("%v", err)
 		}
 
-		eventID := args[0]
-		calID, _ := cmd.Flags().GetString("calendar")
+		days, _ := cmd.Flags().GetInt("days")
+		allCals, _ := cmd.Flags().GetBool("all-calendars")
+		includeAllDay, _ := cmd.Flags().GetBool("include-all-day")
+		includeDeclined, _ := cmd.Flags().GetBool("include-declined")
 
-		if err := srv.DeleteEvent(ctx, calID, eventID); err != nil {
+		timeMin := time.Now()
+		timeMax := timeMin.AddDate(0, 0, days)
+
+		// No limit, so every event in the window is counted
+		var events []*gdaycal.Event
+		if allCals {
+			events, err = srv.ListEventsFromAllCalendars(ctx, timeMin, timeMax, 0, true)
+		} else {
+			events, err = srv.ListEvents(ctx, resolveCalendarID(ctx, cmd, srv), timeMin, timeMax, 0)
+		}
+		if err != nil {
 			exitError("%v", err)
 		}
 
+		var blocking []*gdaycal.Event
+		for _, e := range events {
+			if e.Blocks(includeAllDay, includeDeclined) {
+				blocking = append(blocking, e)
+			}
+		}
+		conflicts := gdaycal.FindConflicts(blocking)
+
 		if isJSONOutput() {
-			outputJSON(StatusJSON{Status: "deleted", Message: "Event deleted successfully"})
+			result := ConflictsJSON{Count: len(conflicts), Conflicts: []ConflictJSON{}}
+			for _, c := range conflicts {
+				result.Conflicts = append(result.Conflicts, ConflictJSON{
+					Events: [2]EventJSON{eventToJSON(c[0]), eventToJSON(c[1])},
+				})
+			}
+			outputJSON(result)
 			return
 		}
 
-		fmt.Println("Event deleted")
+		if len(conflicts) == 0 {
+			fmt.Printf("No conflicts in the next %d days\n", days)
+			return
+		}
+		for _, c := range conflicts {
+			fmt.Printf("%s\n", c[0].Start.Local().Format("Mon Jan 2"))
+			for _, e := range c {
+				fmt.Printf("  %s - %s  %s  (%s)\n", e.Start.Local().Format("15:04"), e.End.Local().Format("15:04"), e.Summary, e.ID)
+			}
+		}
 	},
 }
 
-var calSearchCmd = &cobra.Command{
-	Use:   "search <query>",
-	Short: "Search for events",
-	Long: `Search for calendar events matching a query.
+// maxOccurrencesShown caps how many instances of a new recurring event are listed
+const maxOccurrencesShown = 5
+
+var calInviteCmd = &cobra.Command{
+	Use:         "invite",
+	Short:       "Create an event and email an .ics invitation",
+	Annotations: map[string]string{scopesAnnotation: "calendar.write,gmail.send"},
+	Long: `Create an event on your calendar and email an iCalendar (.ics)
+invitation to the recipients.
+
+Unlike --attendees on create, the recipients are not added to the event's
+guest list; they receive a standard invitation their mail client can accept.
 
 Examples:
-  gday cal search "meeting"
-  gday cal search "John" --days 90`,
-	Args: cobra.MinimumNArgs(1),
+  gday cal invite --to alice@example.com --title "Coffee" --start "2024-01-15 10:00"
+  gday cal invite --to a@x.com,b@y.com --title "Offsite" --date "2024-02-01" --message "See you there"`,
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
+		ctx, cancel := newContext()
+		defer cancel()
 		client, err := auth.GetClient(ctx)
 		if err != nil {
 			exitError("%v", err)
@@ -390,40 +689,83 @@ Examples:
 			exitError("%v", err)
 		}
 
-		query := strings.Join(args, " ")
-		calID, _ := cmd.Flags().GetString("calendar")
-		days, _ := cmd.Flags().GetInt("days")
-		n, _ := cmd.Flags().GetInt64("number")
+		mailSrv, err := gdaygmail.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
 
-		now := time.Now()
-		timeMin := now.AddDate(0, 0, -30) // Search past 30 days too
-		timeMax := now.AddDate(0, 0, days)
+		to, _ := cmd.Flags().GetStringSlice("to")
+		message, _ := cmd.Flags().GetString("message")
+		if len(to) == 0 {
+			exitUsage("--to is required")
+		}
 
-		events, err := srv.SearchEvents(ctx, calID, query, timeMin, timeMax, n)
+		calID := resolveWritableCalendarID(ctx, cmd, srv)
+		event := eventFromFlags(cmd)
+
+		// Look up the organizer first so a failure leaves no event behind
+		organizer, err := mailSrv.GetProfileEmail(ctx)
 		if err != nil {
 			exitError("%v", err)
 		}
 
-		if isJSONOutput() {
-			outputJSON(eventsToJSON(events))
-			return
+		created, err := srv.CreateEvent(ctx, calID, event)
+		if err != nil {
+			exitError("%v", err)
 		}
 
-		if len(events) == 0 {
-			fmt.Println("No events found")
+		invite := *created
+		invite.Attendees = to
+		ics := gdaycal.ExportEventICS(&invite, "REQUEST", organizer)
+
+		body := inviteBody(&invite)
+		if message != "" {
+			body = message + "\n\n" + body
+		}
+
+		sent, err := mailSrv.SendMessage(ctx, &gdaygmail.OutgoingMessage{
+			To:      strings.Join(to, ", "),
+			Subject: "Invitation: " + created.Summary,
+			Text:    body,
+			Invite:  ics,
+		})
+		if err != nil {
+			// Don't leave an event on the calendar that no one was invited to
+			if delErr := srv.DeleteEvent(ctx, calID, created.ID); delErr != nil {
+				exitError("sending the invitation failed: %v (and deleting event %s failed: %v)", err, created.ID, delErr)
+			}
+			exitError("sending the invitation failed, so the event was deleted: %v", err)
+		}
+
+		if isJSONOutput() {
+			outputJSON(InviteResultJSON{EventID: created.ID, MessageID: sent.ID, Recipients: to, Status: "invited"})
 			return
 		}
 
-		fmt.Printf("Found %d events matching: %s\n\n", len(events), query)
-		printEvents(events)
+		fmt.Printf("Event created: %s\n", created.Summary)
+		fmt.Printf("ID: %s\n", created.ID)
+		fmt.Printf("Invitation sent to: %s\n", strings.Join(to, ", "))
 	},
 }
 
-var calCalendarsCmd = &cobra.Command{
-	Use:   "calendars",
-	Short: "List all calendars",
+var calDeleteCmd = &cobra.Command{
+	Use:         "delete [event-id]",
+	Short:       "Delete an event",
+	Annotations: map[string]string{scopesAnnotation: "calendar.write"},
+	Long: `Delete a calendar event by ID, or all events matching a query.
+
+Deleting by query always asks for confirmation unless --yes is given.
+Matching instances of recurring events are deleted one by one, leaving the
+rest of the series; --include-recurring deletes the whole series instead.
+
+Examples:
+  gday cal delete abc123                          # Delete a single event
+  gday cal delete --query "standup" --days 7      # Delete matching events
+  gday cal delete --query "standup" --dry-run     # Preview what would be deleted`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
+		ctx, cancel := newContext()
+		defer cancel()
 		client, err := auth.GetClient(ctx)
 		if err != nil {
 			exitError("%v", err)
@@ -434,85 +776,2084 @@ var calCalendarsCmd = &cobra.Command{
 			exitError("%v", err)
 		}
 
-		calendars, err := srv.ListCalendars(ctx)
-		if err != nil {
+		calID := resolveWritableCalendarID(ctx, cmd, srv)
+		query, _ := cmd.Flags().GetString("query")
+
+		if query != "" {
+			if len(args) > 0 {
+				exitUsage("cannot use both an event ID and --query")
+			}
+			deleteEventsByQuery(ctx, cmd, srv, calID, query)
+			return
+		}
+
+		if len(args) == 0 {
+			exitUsage("event ID or --query is required")
+		}
+		eventID := args[0]
+
+		if err := srv.DeleteEvent(ctx, calID, eventID); err != nil {
 			exitError("%v", err)
 		}
 
 		if isJSONOutput() {
-			jsonCals := make([]CalendarJSON, 0, len(calendars))
-			for _, c := range calendars {
-				jsonCals = append(jsonCals, CalendarJSON{
-					ID:          c.ID,
-					Summary:     c.Summary,
-					Description: c.Description,
-					Primary:     c.Primary,
-				})
-			}
-			outputJSON(CalendarsListJSON{Calendars: jsonCals})
+			outputJSON(StatusJSON{Status: "deleted", Message: "Event deleted successfully"})
 			return
 		}
 
-		fmt.Println("Calendars:")
-		for _, c := range calendars {
-			primary := ""
-			if c.Primary {
-				primary = " (primary)"
-			}
-			fmt.Printf("  %-40s %s%s\n", c.Summary, c.ID[:min(30, len(c.ID))], primary)
-		}
+		fmt.Println("Event deleted")
 	},
 }
 
-func init() {
-	rootCmd.AddCommand(calCmd)
-
-	// Global calendar flag
-	calCmd.PersistentFlags().StringP("calendar", "c", "", "Calendar ID (default: primary)")
+// eventDeleter is the part of the Calendar service deleting by query uses
+type eventDeleter interface {
+	SearchEvents(ctx context.Context, calendarID, query string, timeMin, timeMax time.Time, maxResults int64) ([]*gdaycal.Event, error)
+	DeleteEvent(ctx context.Context, calendarID, eventID string) error
+}
 
-	// List command
-	calCmd.AddCommand(calListCmd)
-	calListCmd.Flags().Int64P("number", "n", 10, "Maximum number of events")
-	calListCmd.Flags().Int("days", 14, "Number of days to look ahead")
-	calListCmd.Flags().Bool("all-calendars", false, "Include events from all calendars")
+// deleteEventsByQuery deletes all events matching query in the --days
+// window. With --include-recurring, a matching instance of a recurring event
+// deletes its whole series.
+func deleteEventsByQuery(ctx context.Context, cmd *cobra.Command, srv eventDeleter, calID, query string) {
+	days, _ := cmd.Flags().GetInt("days")
+	includeRecurring, _ := cmd.Flags().GetBool("include-recurring")
+
+	now := time.Now()
+	events, err := srv.SearchEvents(ctx, calID, query, now, now.AddDate(0, 0, days), 0)
+	if err != nil {
+		exitError("%v", err)
+	}
 
-	// Today command
-	calCmd.AddCommand(calTodayCmd)
+	var matched []*gdaycal.Event
+	series := make(map[string]bool)
+	for _, e := range events {
+		if e.Recurring && includeRecurring {
+			if series[e.RecurrenceID] {
+				continue
+			}
+			series[e.RecurrenceID] = true
+			master := *e
+			master.ID = e.RecurrenceID
+			matched = append(matched, &master)
+			continue
+		}
+		matched = append(matched, e)
+	}
 
-	// Tomorrow command
-	calCmd.AddCommand(calTomorrowCmd)
+	if len(matched) == 0 {
+		if isJSONOutput() {
+			outputJSON(BatchResultJSON{Action: "delete", Total: 0})
+			return
+		}
+		fmt.Println("No matching events")
+		return
+	}
 
-	// Week command
-	calCmd.AddCommand(calWeekCmd)
+	items := make([]string, 0, len(matched))
+	for _, e := range matched {
+		item := fmt.Sprintf("%s  %s  %s", e.ID, e.Start.Format("Mon Jan 2 15:04"), e.Summary)
+		if series[e.ID] {
+			item += "  (whole series)"
+		}
+		items = append(items, item)
+	}
+	if !confirmBatch(cmd, "delete", items, true) {
+		return
+	}
 
-	// Show command
-	calCmd.AddCommand(calShowCmd)
+	byID := make(map[string]*gdaycal.Event, len(matched))
+	ids := make([]string, 0, len(matched))
+	for _, e := range matched {
+		byID[e.ID] = e
+		ids = append(ids, e.ID)
+	}
+	applyBatch(cmd, "delete", ids, func(id string) error {
+		return srv.DeleteEvent(ctx, byID[id].CalendarID, id)
+	})
+}
 
-	// Create command
-	calCmd.AddCommand(calCreateCmd)
-	calCreateCmd.Flags().StringP("title", "t", "", "Event title")
-	calCreateCmd.Flags().StringP("start", "s", "", "Start time (YYYY-MM-DD HH:MM)")
-	calCreateCmd.Flags().StringP("end", "e", "", "End time (YYYY-MM-DD HH:MM)")
-	calCreateCmd.Flags().String("date", "", "Date for all-day events (YYYY-MM-DD)")
-	calCreateCmd.Flags().Bool("all-day", false, "Create all-day event")
-	calCreateCmd.Flags().StringP("location", "l", "", "Event location")
-	calCreateCmd.Flags().StringP("description", "d", "", "Event description")
-	calCreateCmd.Flags().StringSlice("attendees", nil, "Event attendees (emails)")
-	calCreateCmd.Flags().StringP("quick", "q", "", "Quick add using natural language")
+var calRescheduleCmd = &cobra.Command{
+	Use:         "reschedule <event-id>",
+	Short:       "Move an event to a different time",
+	Annotations: map[string]string{scopesAnnotation: "calendar.write"},
+	Long: `Move an event, keeping its duration.
 
-	// Delete command
-	calCmd.AddCommand(calDeleteCmd)
+--by shifts the event by a duration (30m, 1h30m, -15m, or 1d for days).
+--to sets a new start time. All-day events move by whole days, and --to
+takes a date for them. Rescheduling a recurring event instance moves only
+that instance.
 
-	// Search command
-	calCmd.AddCommand(calSearchCmd)
-	calSearchCmd.Flags().Int("days", 90, "Number of days to search")
-	calSearchCmd.Flags().Int64P("number", "n", 20, "Maximum number of results")
+Examples:
+  gday cal reschedule abc123 --by 30m                  # Push back half an hour
+  gday cal reschedule abc123 --by 1d                   # Same time tomorrow
+  gday cal reschedule abc123 --to "2024-01-16 15:00"   # New start, same length`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		by, _ := cmd.Flags().GetString("by")
+		to, _ := cmd.Flags().GetString("to")
+		if (by == "") == (to == "") {
+			exitUsage("exactly one of --by or --to is required")
+		}
 
-	// Calendars command
-	calCmd.AddCommand(calCalendarsCmd)
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaycal.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		eventID := args[0]
+		calID := resolveWritableCalendarID(ctx, cmd, srv)
+
+		event, err := srv.GetEvent(ctx, calID, eventID)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		start, end, err := rescheduledTimes(event, by, to)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		updated, err := srv.SetEventTime(ctx, calID, eventID, start, end, event.AllDay)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		if isJSONOutput() {
+			outputJSON(eventToJSON(updated))
+			return
+		}
+		if updated.AllDay {
+			fmt.Printf("Rescheduled: %s to %s\n", updated.Summary, updated.Start.Format("Mon Jan 2, 2006"))
+		} else {
+			fmt.Printf("Rescheduled: %s to %s - %s\n", updated.Summary,
+				updated.Start.Local().Format("Mon Jan 2, 3:04 PM"),
+				updated.End.Local().Format("3:04 PM"))
+		}
+	},
+}
+
+var calUpdateCmd = &cobra.Command{
+	Use:         "update <event-id>",
+	Short:       "Edit an existing event",
+	Annotations: map[string]string{scopesAnnotation: "calendar.write"},
+	Long: `Change an event's title, time, place, description, or attendees. Only
+the fields given as flags change; everything else is kept.
+
+A new --start keeps the event's length unless --end is also given. --date
+alone moves a timed event to that day at the same time; with --all-day it
+makes the event an all-day one. --attendees replaces the attendee list.
+
+Examples:
+  gday cal update abc123 --title "Design review"
+  gday cal update abc123 --start 15:00                 # Same day, same length
+  gday cal update abc123 --date 2024-01-20             # Move to another day
+  gday cal update abc123 --location "Room 4" --description "Bring laptops"
+  gday cal update abc123 --attendees @team,guest@example.com
+  gday cal update abc123 --remind 10m,email:1d`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaycal.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		eventID := args[0]
+		calID := resolveWritableCalendarID(ctx, cmd, srv)
+
+		event, err := srv.GetEvent(ctx, calID, eventID)
+		if err != nil {
+			exitError("%v", err)
+		}
+		if !applyEventFlags(cmd, event) && !cmd.Flags().Changed("remind") {
+			exitUsage("nothing to update; give at least one of --title, --start, --end, --date, --all-day, --location, --description, --attendees, or --remind")
+		}
+		// Leave attendees (and their responses) and recurrence alone unless
+		// they were changed
+		if !cmd.Flags().Changed("attendees") {
+			event.Attendees = nil
+		}
+		event.Recurrence = nil
+		if cmd.Flags().Changed("remind") {
+			event.Reminders = remindersFromFlag(cmd)
+		} else {
+			event.Reminders = nil
+		}
+
+		updated, err := srv.UpdateEvent(ctx, calID, eventID, event)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		if isJSONOutput() {
+			outputJSON(eventToJSON(updated))
+			return
+		}
+		fmt.Println("Event updated")
+		printEventDetails(updated)
+	},
+}
+
+var calRsvpCmd = &cobra.Command{
+	Use:         "rsvp <event-id>",
+	Short:       "Accept, decline, or tentatively accept an invitation",
+	Annotations: map[string]string{scopesAnnotation: "calendar.write"},
+	Long: `Set your response to an event you're invited to. The organizer is
+notified of the change.
+
+Examples:
+  gday cal rsvp abc123 --response accepted
+  gday cal rsvp abc123 --response declined
+  gday cal rsvp abc123 --response tentative`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		response, _ := cmd.Flags().GetString("response")
+		switch response {
+		case "accepted", "declined", "tentative":
+		case "":
+			exitUsage("--response is required (accepted, declined, or tentative)")
+		default:
+			exitUsage("invalid --response %q: use accepted, declined, or tentative", response)
+		}
+
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaycal.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		calID := resolveCalendarID(ctx, cmd, srv)
+		event, err := srv.RespondToEvent(ctx, calID, args[0], response)
+		if errors.Is(err, gdaycal.ErrNotInvited) {
+			exitUsage("%v", err)
+		}
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		if isJSONOutput() {
+			outputJSON(eventToJSON(event))
+			return
+		}
+		fmt.Printf("Responded %s: %s\n", event.ResponseStatus, event.Summary)
+	},
+}
+
+var calAttendeesCmd = &cobra.Command{
+	Use:         "attendees <event-id>",
+	Short:       "List, add, or remove an event's attendees",
+	Annotations: map[string]string{scopesAnnotation: "calendar.write"},
+	Long: `Show an event's attendees, or change them with --add and --remove.
+Everyone else stays on the list with their response unchanged. Added and
+removed guests are emailed unless --notify=false.
+
+--add accepts @name for an attendee group from config.json, or @file for a
+file of addresses, like cal create --attendees.
+
+Examples:
+  gday cal attendees abc123
+  gday cal attendees abc123 --add carol@example.com,dan@example.com
+  gday cal attendees abc123 --remove bob@example.com --notify=false
+  gday cal attendees abc123 --add @team --remove eve@example.com`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		addRefs, _ := cmd.Flags().GetStringSlice("add")
+		add, err := expandAttendees(addRefs)
+		if err != nil {
+			exitUsage("%v", err)
+		}
+		removeRefs, _ := cmd.Flags().GetStringSlice("remove")
+		var remove []string
+		for _, ref := range removeRefs {
+			addr, err := mail.ParseAddress(strings.TrimSpace(ref))
+			if err != nil {
+				exitUsage("invalid attendee %q: %v", ref, err)
+			}
+			remove = append(remove, addr.Address)
+		}
+		notify, _ := cmd.Flags().GetBool("notify")
+
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaycal.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		var event *gdaycal.Event
+		if len(add) == 0 && len(remove) == 0 {
+			event, err = srv.GetEvent(ctx, resolveCalendarID(ctx, cmd, srv), args[0])
+		} else {
+			calID := resolveWritableCalendarID(ctx, cmd, srv)
+			event, err = srv.UpdateAttendees(ctx, calID, args[0], add, remove, notify)
+		}
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		if isJSONOutput() {
+			outputJSON(eventToJSON(event))
+			return
+		}
+		if len(event.Attendees) == 0 {
+			fmt.Printf("%s has no attendees\n", event.Summary)
+			return
+		}
+		fmt.Printf("Attendees of %s:\n", event.Summary)
+		for _, a := range event.Attendees {
+			fmt.Printf("  %s\n", a)
+		}
+	},
+}
+
+var calMoveCmd = &cobra.Command{
+	Use:         "move <event-id>",
+	Short:       "Move an event to another calendar",
+	Annotations: map[string]string{scopesAnnotation: "calendar.write"},
+	Long: `Move an event from --calendar (default: primary) to the calendar given
+by --to, an ID or name from 'gday cal calendars'. Handy when an event was
+created on the wrong calendar. Moving an event off or onto a protected
+calendar requires --force.
+
+Examples:
+  gday cal move abc123 --to Work
+  gday cal move abc123 --calendar Work --to primary`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		toRef, _ := cmd.Flags().GetString("to")
+		if toRef == "" {
+			exitUsage("--to is required")
+		}
+
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaycal.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		fromID := resolveWritableCalendarID(ctx, cmd, srv)
+		toID, err := srv.ResolveCalendarID(ctx, toRef)
+		if err != nil {
+			exitError("%v", err)
+		}
+		checkCalendarWritable(ctx, cmd, srv, toID)
+
+		moved, err := srv.MoveEvent(ctx, fromID, args[0], toID)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		if isJSONOutput() {
+			outputJSON(eventToJSON(moved))
+			return
+		}
+		fmt.Printf("Moved: %s to %s\n", moved.Summary, toRef)
+	},
+}
+
+var calExportCmd = &cobra.Command{
+	Use:         "export <event-id>",
+	Short:       "Export an event as an .ics file",
+	Annotations: map[string]string{scopesAnnotation: "calendar.read"},
+	Long: `Export a calendar event as an iCalendar (.ics) file, which other calendar
+apps can import. Without --output the file is written to stdout.
+
+Examples:
+  gday cal export abc123 -o event.ics
+  gday cal export abc123 > event.ics`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaycal.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		calID := resolveCalendarID(ctx, cmd, srv)
+		output, _ := cmd.Flags().GetString("output")
+
+		event, err := srv.GetEvent(ctx, calID, args[0])
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		// Instances don't carry the rule; it's on the recurring event
+		if len(event.Recurrence) == 0 && event.RecurrenceID != "" {
+			if series, err := srv.GetEvent(ctx, calID, event.RecurrenceID); err == nil {
+				event.Recurrence = series.Recurrence
+			}
+		}
+
+		data := gdaycal.ExportEventICS(event, "PUBLISH", event.Organizer)
+		if output == "" {
+			fmt.Print(data)
+			return
+		}
+		if err := os.WriteFile(output, []byte(data), 0644); err != nil {
+			exitError("failed to write %s: %v", output, err)
+		}
+		if isJSONOutput() {
+			outputJSON(StatusJSON{Status: "exported", Message: output})
+			return
+		}
+		fmt.Printf("Exported: %s to %s\n", event.Summary, output)
+	},
+}
+
+var calImportCmd = &cobra.Command{
+	Use:         "import <file.ics>",
+	Short:       "Import events from an .ics file",
+	Annotations: map[string]string{scopesAnnotation: "calendar.write"},
+	Long: `Create an event on --calendar (default: primary) for each VEVENT in an
+iCalendar (.ics) file. Use '-' to read the file from stdin.
+
+Titles, times, locations, descriptions, and recurrence rules are imported.
+Attendees are not, so importing someone else's invitation doesn't invite
+its guests to your copy.
+
+Examples:
+  gday cal import event.ics
+  gday cal import holidays.ics --calendar Family
+  gday cal import meeting.ics --dry-run
+  curl -s https://example.com/event.ics | gday cal import -`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var r io.Reader = os.Stdin
+		if args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				exitError("failed to read %s: %v", args[0], err)
+			}
+			defer f.Close()
+			r = f
+		}
+
+		events, err := gdaycal.ParseICS(r)
+		if err != nil {
+			exitError("failed to parse %s: %v", args[0], err)
+		}
+		if len(events) == 0 {
+			exitUsage("no events found in %s", args[0])
+		}
+
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if dryRun {
+			if isJSONOutput() {
+				outputJSON(eventsToJSON(events))
+				return
+			}
+			fmt.Printf("Would import %d event(s):\n", len(events))
+			printEvents(events)
+			return
+		}
+
+		ctx, cancel := newLongContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaycal.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		calID := resolveWritableCalendarID(ctx, cmd, srv)
+
+		var created []*gdaycal.Event
+		failed := make(map[string]error)
+		for i, e := range events {
+			e.Attendees = nil
+			c, err := srv.CreateEvent(ctx, calID, e)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to import %q: %v\n", e.Summary, err)
+				// Keyed by position, as events in one file can share a title
+				failed[fmt.Sprintf("#%d %s", i+1, e.Summary)] = err
+				continue
+			}
+			created = append(created, c)
+			if !isJSONOutput() {
+				fmt.Printf("Imported: %s (%s)\n", c.Summary, c.ID)
+			}
+		}
+
+		if isJSONOutput() {
+			outputJSON(eventsToJSON(created))
+		}
+		exitForBatchResult(len(events), failed)
+	},
+}
+
+var calSearchCmd = &cobra.Command{
+	Use:         "search <query>",
+	Short:       "Search for events",
+	Annotations: map[string]string{scopesAnnotation: "calendar.read"},
+	Long: `Search for calendar events matching a query.
+
+Examples:
+  gday cal search "meeting"
+  gday cal search "John" --days 90`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaycal.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		query := strings.Join(args, " ")
+		calID := resolveCalendarID(ctx, cmd, srv)
+		days, _ := cmd.Flags().GetInt("days")
+		n, _ := cmd.Flags().GetInt64("number")
+
+		now := time.Now()
+		timeMin := now.AddDate(0, 0, -30) // Search past 30 days too
+		timeMax := now.AddDate(0, 0, days)
+
+		events, err := srv.SearchEvents(ctx, calID, query, timeMin, timeMax, n)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		if isJSONOutput() {
+			outputJSON(eventsToJSON(events))
+			return
+		}
+
+		if len(events) == 0 {
+			fmt.Println("No events found")
+			return
+		}
+
+		fmt.Printf("Found %d events matching: %s\n\n", len(events), query)
+		printEvents(events)
+	},
+}
+
+var calFreeBusyCmd = &cobra.Command{
+	Use:         "freebusy",
+	Short:       "Show busy times",
+	Annotations: map[string]string{scopesAnnotation: "calendar.read"},
+	Long: `Show busy time blocks for one or more calendars.
+
+Calendars default to --calendar (or your primary calendar). Use --attendees
+to query other people's calendars by email.
+
+With --duration, list times when you and every attendee are free for that
+long instead: weekdays within --work-hours over the next --within (default
+--days), best first. Slots with free time either side rank above ones
+squeezed between meetings.
+
+Examples:
+  gday cal freebusy                                  # Your busy blocks this week
+  gday cal freebusy --attendees alice@example.com    # Someone else's busy blocks
+  gday cal freebusy --days 14 --ics -o busy.ics      # Export as iCalendar VFREEBUSY
+  gday cal freebusy --attendees a@x.com,b@y.com --duration 30m --within 5d
+  gday cal freebusy --attendees a@x.com --duration 1h --work-hours 10:00-16:00`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaycal.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		calID := resolveCalendarID(ctx, cmd, srv)
+		attendees, _ := cmd.Flags().GetStringSlice("attendees")
+		days, _ := cmd.Flags().GetInt("days")
+		ics, _ := cmd.Flags().GetBool("ics")
+		output, _ := cmd.Flags().GetString("output")
+
+		calendars := attendees
+		if len(calendars) == 0 {
+			calendars = []string{calID}
+		}
+
+		if duration, _ := cmd.Flags().GetDuration("duration"); duration > 0 {
+			findFreeSlots(ctx, cmd, srv, append([]string{calID}, attendees...), duration)
+			return
+		}
+
+		now := time.Now()
+		timeMin := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		timeMax := timeMin.AddDate(0, 0, days)
+
+		busy, err := srv.FreeBusy(ctx, calendars, timeMin, timeMax)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		if ics {
+			var all []gdaycal.Interval
+			for _, intervals := range busy {
+				all = append(all, intervals...)
+			}
+			data := gdaycal.ExportFreeBusyICS(all, timeMin, timeMax)
+			if output == "" {
+				fmt.Print(data)
+				return
+			}
+			if err := os.WriteFile(output, []byte(data), 0644); err != nil {
+				exitError("failed to write %s: %v", output, err)
+			}
+			if isJSONOutput() {
+				outputJSON(StatusJSON{Status: "exported", Message: output})
+				return
+			}
+			fmt.Printf("Exported busy times to %s\n", output)
+			return
+		}
+
+		if isJSONOutput() {
+			result := FreeBusyJSON{TimeMin: timeMin, TimeMax: timeMax, Calendars: make(map[string][]IntervalJSON)}
+			for id, intervals := range busy {
+				jsonIntervals := make([]IntervalJSON, 0, len(intervals))
+				for _, iv := range intervals {
+					jsonIntervals = append(jsonIntervals, IntervalJSON{Start: iv.Start, End: iv.End})
+				}
+				result.Calendars[id] = jsonIntervals
+			}
+			outputJSON(result)
+			return
+		}
+
+		for _, id := range calendars {
+			fmt.Printf("%s:\n", id)
+			intervals := busy[id]
+			if len(intervals) == 0 {
+				fmt.Println("  Free")
+				continue
+			}
+			for _, iv := range intervals {
+				fmt.Printf("  %s - %s\n",
+					iv.Start.Local().Format("Mon Jan 2 15:04"),
+					iv.End.Local().Format("15:04"))
+			}
+		}
+	},
+}
+
+// findFreeSlots prints the times all calendars are free for duration
+func findFreeSlots(ctx context.Context, cmd *cobra.Command, srv *gdaycal.Service, calendars []string, duration time.Duration) {
+	days, _ := cmd.Flags().GetInt("days")
+	within := time.Duration(days) * 24 * time.Hour
+	if s, _ := cmd.Flags().GetString("within"); s != "" {
+		d, err := parseOffset(s)
+		if err != nil || d <= 0 {
+			exitUsage("invalid --within %q: use a duration such as 5d or 36h", s)
+		}
+		within = d
+	}
+	workHours, _ := cmd.Flags().GetString("work-hours")
+	hours, err := parseWorkingHours(workHours)
+	if err != nil {
+		exitUsage("%v", err)
+	}
+	n, _ := cmd.Flags().GetInt("number")
+
+	timeMin := time.Now()
+	timeMax := timeMin.Add(within)
+	busy, err := srv.FreeBusy(ctx, calendars, timeMin, timeMax)
+	if err != nil {
+		exitError("%v", err)
+	}
+	var all []gdaycal.Interval
+	for _, intervals := range busy {
+		all = append(all, intervals...)
+	}
+
+	slots := gdaycal.FindSlots(all, timeMin, timeMax, duration, hours)
+	if n > 0 && len(slots) > n {
+		slots = slots[:n]
+	}
+
+	if isJSONOutput() {
+		result := FreeSlotsJSON{Calendars: calendars, DurationMinutes: int(duration.Minutes()), Slots: []IntervalJSON{}}
+		for _, s := range slots {
+			result.Slots = append(result.Slots, IntervalJSON{Start: s.Start, End: s.End})
+		}
+		outputJSON(result)
+		return
+	}
+
+	if len(slots) == 0 {
+		fmt.Println("No free slots found. Try a longer --within or wider --work-hours.")
+		return
+	}
+	fmt.Printf("Free for %s (%s):\n", duration, strings.Join(calendars, ", "))
+	for i, s := range slots {
+		fmt.Printf("%3d. %s - %s\n", i+1,
+			s.Start.Local().Format("Mon Jan 2 15:04"),
+			s.End.Local().Format("15:04"))
+	}
+}
+
+// parseWorkingHours parses a range such as "09:00-17:00"
+func parseWorkingHours(s string) (gdaycal.WorkingHours, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if ok {
+		start, err1 := time.Parse("15:04", strings.TrimSpace(from))
+		end, err2 := time.Parse("15:04", strings.TrimSpace(to))
+		if err1 == nil && err2 == nil && end.After(start) {
+			midnight := time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC)
+			return gdaycal.WorkingHours{Start: start.Sub(midnight), End: end.Sub(midnight)}, nil
+		}
+	}
+	return gdaycal.WorkingHours{}, fmt.Errorf("invalid --work-hours %q: use a range such as 09:00-17:00", s)
+}
+
+var calSyncCmd = &cobra.Command{
+	Use:         "sync",
+	Short:       "Show events changed since the last sync",
+	Annotations: map[string]string{scopesAnnotation: "calendar.read"},
+	Long: `Show events that were created, changed, or deleted since the last run.
+
+The first run (or --reset) performs a full sync and records a sync token in
+~/.gday/sync_tokens.json; later runs report only the changes since then.
+
+Examples:
+  gday cal sync           # Changes since the last run
+  gday cal sync --reset   # Start over with a full sync
+  gday cal sync --json    # Machine-readable changes`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaycal.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		calID := resolveCalendarID(ctx, cmd, srv)
+		reset, _ := cmd.Flags().GetBool("reset")
+
+		syncToken := ""
+		if !reset {
+			syncToken, err = config.ReadSyncToken(calID)
+			if err != nil {
+				exitError("failed to read sync token: %v", err)
+			}
+		}
+
+		result, fullSync, err := syncEvents(ctx, srv, calID, syncToken)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		if err := config.SaveSyncToken(calID, result.NextSyncToken); err != nil {
+			exitError("failed to save sync token: %v", err)
+		}
+
+		if isJSONOutput() {
+			changed := make([]EventJSON, 0, len(result.Changed))
+			for _, e := range result.Changed {
+				changed = append(changed, eventToJSON(e))
+			}
+			deleted := make([]string, 0, len(result.Deleted))
+			for _, e := range result.Deleted {
+				deleted = append(deleted, e.ID)
+			}
+			outputJSON(SyncJSON{FullSync: fullSync, Changed: changed, Deleted: deleted})
+			return
+		}
+
+		if fullSync {
+			fmt.Printf("Full sync complete: %d events. Run again to see changes.\n", len(result.Changed))
+			return
+		}
+
+		if len(result.Changed) == 0 && len(result.Deleted) == 0 {
+			fmt.Println("No changes since last sync")
+			return
+		}
+
+		for _, e := range result.Changed {
+			fmt.Printf("  changed  %s  %s\n", e.Start.Format("Mon Jan 2 15:04"), e.Summary)
+		}
+		for _, e := range result.Deleted {
+			fmt.Printf("  deleted  %s\n", e.ID)
+		}
+	},
+}
+
+// eventSyncer is the part of the Calendar service cal sync uses
+type eventSyncer interface {
+	SyncEvents(ctx context.Context, calendarID, syncToken string) (*gdaycal.SyncResult, error)
+}
+
+// syncEvents returns the changes since syncToken, starting over with a full
+// sync when the server has expired the token. It reports whether the result
+// is a full sync.
+func syncEvents(ctx context.Context, srv eventSyncer, calID, syncToken string) (*gdaycal.SyncResult, bool, error) {
+	result, err := srv.SyncEvents(ctx, calID, syncToken)
+	if !errors.Is(err, gdaycal.ErrSyncTokenExpired) {
+		return result, syncToken == "", err
+	}
+	if !isJSONOutput() {
+		fmt.Println("Sync token expired; performing a full resync")
+	}
+	result, err = srv.SyncEvents(ctx, calID, "")
+	return result, true, err
+}
+
+var calCalendarsCmd = &cobra.Command{
+	Use:         "calendars",
+	Short:       "List all calendars",
+	Annotations: map[string]string{csvAnnotation: "true", scopesAnnotation: "calendar.read"},
+	Long: `List all calendars, or create and delete secondary calendars.
+
+Examples:
+  gday cal calendars
+  gday cal calendars create --name "Side Project"
+  gday cal calendars delete "Side Project"`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaycal.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		calendars, err := srv.ListCalendars(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		jsonCals := make([]CalendarJSON, 0, len(calendars))
+		for _, c := range calendars {
+			jsonCals = append(jsonCals, CalendarJSON{
+				ID:          c.ID,
+				Summary:     c.Summary,
+				Description: c.Description,
+				Primary:     c.Primary,
+			})
+		}
+		output(CalendarsListJSON{Calendars: jsonCals}, func(csv bool) *table { return calendarsTable(calendars, csv) })
+	},
+}
+
+var calCalendarsCreateCmd = &cobra.Command{
+	Use:         "create",
+	Short:       "Create a calendar",
+	Annotations: map[string]string{scopesAnnotation: "calendar.manage"},
+	Long: `Create a secondary calendar.
+
+Examples:
+  gday cal calendars create --name "Side Project"
+  gday cal calendars create --name "Trip" --timezone Europe/Paris`,
+	Run: func(cmd *cobra.Command, args []string) {
+		name, _ := cmd.Flags().GetString("name")
+		description, _ := cmd.Flags().GetString("description")
+		timeZone, _ := cmd.Flags().GetString("timezone")
+		if name == "" {
+			exitUsage("--name is required")
+		}
+		if timeZone != "" {
+			if _, err := time.LoadLocation(timeZone); err != nil {
+				exitUsage("invalid --timezone %q (use an IANA name such as America/New_York)", timeZone)
+			}
+		}
+
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaycal.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		created, err := srv.CreateCalendar(ctx, name, description, timeZone)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		if isJSONOutput() {
+			outputJSON(CalendarJSON{ID: created.ID, Summary: created.Summary, Description: created.Description})
+			return
+		}
+		fmt.Printf("Created calendar: %s\n", created.Summary)
+		fmt.Printf("ID: %s\n", created.ID)
+	},
+}
+
+var calCalendarsDeleteCmd = &cobra.Command{
+	Use:         "delete <calendar>",
+	Short:       "Delete a calendar",
+	Annotations: map[string]string{scopesAnnotation: "calendar.manage"},
+	Long: `Delete a secondary calendar, given its ID or name, along with all of its
+events. The primary calendar can't be deleted, and calendars listed in
+protected_calendars need --force.
+
+Examples:
+  gday cal calendars delete "Side Project"
+  gday cal calendars delete abc123@group.calendar.google.com --yes`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaycal.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		calID, err := srv.ResolveCalendarID(ctx, args[0])
+		if err != nil {
+			exitError("%v", err)
+		}
+		if strings.EqualFold(calID, "primary") {
+			exitUsage("%v", gdaycal.ErrPrimaryCalendar)
+		}
+		checkCalendarWritable(ctx, cmd, srv, calID)
+
+		if yes, _ := cmd.Flags().GetBool("yes"); !yes && !confirm(fmt.Sprintf("Delete calendar %q and all of its events?", args[0])) {
+			return
+		}
+
+		if err := srv.DeleteCalendar(ctx, calID); err != nil {
+			exitError("%v", err)
+		}
+
+		if isJSONOutput() {
+			outputJSON(StatusJSON{Status: "deleted", Message: calID})
+			return
+		}
+		fmt.Printf("Deleted calendar: %s\n", args[0])
+	},
+}
+
+var calNextCmd = &cobra.Command{
+	Use:         "next",
+	Short:       "Show the next upcoming event",
+	Annotations: map[string]string{scopesAnnotation: "calendar.read"},
+	Long: `Show the next event that hasn't started yet, with a countdown such as
+"in 2h 15m". All-day and declined events are skipped.
+
+With --watch, the line is refreshed every minute until interrupted, for
+status bars such as tmux or polybar.
+
+Examples:
+  gday cal next
+  gday cal next --watch
+  gday cal next --json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		watch, _ := cmd.Flags().GetBool("watch")
+
+		newCtx := newContext
+		if watch {
+			newCtx = newLongContext
+		}
+		ctx, cancel := newCtx()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaycal.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		calID := resolveCalendarID(ctx, cmd, srv)
+
+		for {
+			next, err := srv.NextEvent(ctx, calID)
+			switch {
+			case ctx.Err() != nil && watch:
+				return
+			case err != nil && !watch:
+				exitError("%v", err)
+			case err != nil:
+				// Keep watching through transient failures
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			default:
+				printNextEvent(next, time.Now())
+			}
+
+			if !watch {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Minute):
+			}
+		}
+	},
+}
+
+var calBusyCmd = &cobra.Command{
+	Use:         "busy",
+	Short:       "Show whether you're in a meeting right now",
+	Annotations: map[string]string{scopesAnnotation: "calendar.read"},
+	Long: `Show whether you're in a meeting right now, and until when.
+
+With --export-slack, print a one-line status for Slack or similar tools,
+e.g. ":calendar: In a meeting until 3:00 PM". The text comes from Go
+templates, set with --busy-template/--free-template or in config.json:
+
+  "slack_status": {
+    "busy": ":calendar: {{.Summary}} until {{.Until}}",
+    "free": ":large_green_circle: Available"
+  }
+
+Templates can use {{.Summary}}, {{.Location}}, {{.Until}} (e.g. 3:00 PM),
+{{.Start}} and {{.End}} (time values).
+
+Examples:
+  gday cal busy
+  gday cal busy --export-slack
+  slack-status set "$(gday cal busy --export-slack)"`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaycal.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		calID := resolveCalendarID(ctx, cmd, srv)
+		now := time.Now()
+		events, err := srv.ListEvents(ctx, calID, now, now.Add(time.Second), 0)
+		if err != nil {
+			exitError("%v", err)
+		}
+		current := gdaycal.CurrentEvent(events, now)
+
+		exportSlack, _ := cmd.Flags().GetBool("export-slack")
+		var status string
+		if exportSlack || isJSONOutput() {
+			busyTmpl, freeTmpl := slackTemplates(cmd)
+			if status, err = slackStatus(current, busyTmpl, freeTmpl); err != nil {
+				exitUsage("%v", err)
+			}
+		}
+
+		if isJSONOutput() {
+			result := BusyJSON{Busy: current != nil, Status: status}
+			if current != nil {
+				ej := eventToJSON(current)
+				result.Event = &ej
+				result.Until = &current.End
+			}
+			outputJSON(result)
+			return
+		}
+
+		if exportSlack {
+			fmt.Println(status)
+			return
+		}
+
+		if current == nil {
+			fmt.Println("Free")
+			return
+		}
+		fmt.Printf("Busy: %s until %s\n", current.Summary, current.End.Local().Format("15:04"))
+	},
+}
+
+var calStatsCmd = &cobra.Command{
+	Use:         "stats",
+	Short:       "Show meeting statistics",
+	Annotations: map[string]string{scopesAnnotation: "calendar.read"},
+	Long: `Summarize how much time went to meetings over the past N days.
+
+Reports total and average meeting time, the busiest day, 1:1 vs group
+meetings (by attendee count), and back-to-back streaks. All-day events and
+events shown as free are not counted.
+
+Examples:
+  gday cal stats                   # Past 30 days
+  gday cal stats --days 90 --all-calendars
+  gday cal stats --json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaycal.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		days, _ := cmd.Flags().GetInt("days")
+		allCals, _ := cmd.Flags().GetBool("all-calendars")
+
+		timeMax := time.Now()
+		timeMin := timeMax.AddDate(0, 0, -days)
+
+		// No limit, so every event in the window is counted
+		var events []*gdaycal.Event
+		if allCals {
+			events, err = srv.ListEventsFromAllCalendars(ctx, timeMin, timeMax, 0, true)
+		} else {
+			events, err = srv.ListEvents(ctx, resolveCalendarID(ctx, cmd, srv), timeMin, timeMax, 0)
+		}
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		stats := gdaycal.ComputeStats(events)
+
+		if isJSONOutput() {
+			result := CalStatsJSON{
+				TimeMin:           timeMin,
+				TimeMax:           timeMax,
+				Meetings:          stats.Meetings,
+				TotalHours:        stats.TotalTime.Hours(),
+				AverageMinutes:    stats.AverageLength.Minutes(),
+				BusiestDayHours:   stats.BusiestDayTime.Hours(),
+				OneOnOnes:         stats.OneOnOnes,
+				GroupMeetings:     stats.GroupMeetings,
+				Solo:              stats.Solo,
+				BackToBackStreaks: stats.BackToBack,
+				LongestStreak:     stats.LongestStreak,
+				DailyHours:        make(map[string]float64),
+			}
+			if !stats.BusiestDay.IsZero() {
+				result.BusiestDay = stats.BusiestDay.Format("2006-01-02")
+			}
+			for day, d := range stats.DailyMeetingTime {
+				result.DailyHours[day] = d.Hours()
+			}
+			outputJSON(result)
+			return
+		}
+
+		fmt.Printf("Meetings over the past %d days (%s - %s)\n\n",
+			days, timeMin.Format("Jan 2"), timeMax.Format("Jan 2"))
+		if stats.Meetings == 0 {
+			fmt.Println("No meetings")
+			return
+		}
+		fmt.Printf("  Meetings:        %d\n", stats.Meetings)
+		fmt.Printf("  Total time:      %.1f hours\n", stats.TotalTime.Hours())
+		fmt.Printf("  Average length:  %.0f min\n", stats.AverageLength.Minutes())
+		fmt.Printf("  Busiest day:     %s (%.1f hours)\n",
+			stats.BusiestDay.Format("Mon Jan 2"), stats.BusiestDayTime.Hours())
+		fmt.Printf("  1:1s:            %d\n", stats.OneOnOnes)
+		fmt.Printf("  Group meetings:  %d\n", stats.GroupMeetings)
+		fmt.Printf("  No attendees:    %d\n", stats.Solo)
+		fmt.Printf("  Back-to-back:    %d streak(s), longest %d meetings\n", stats.BackToBack, stats.LongestStreak)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(calCmd)
+
+	// Global calendar flag
+	calCmd.PersistentFlags().StringP("calendar", "c", "", "Calendar ID, name, or \"primary\" (default: primary)")
+
+	// List command
+	calCmd.AddCommand(calListCmd)
+	calListCmd.Flags().Int64P("number", "n", 10, "Maximum number of events")
+	calListCmd.Flags().Int("days", 14, "Number of days to look ahead")
+	calListCmd.Flags().Bool("all-calendars", false, "Include events from all calendars")
+	calListCmd.Flags().Bool("no-dedup", false, "With --all-calendars, keep duplicate copies of shared events")
+	calListCmd.Flags().Bool("busy-only", false, "Exclude events shown as free")
+	calListCmd.Flags().Bool("mine-only", false, "Only show events you organize")
+	calListCmd.Flags().Bool("accepted-only", false, "Only show events you've accepted")
+
+	// Today command
+	calCmd.AddCommand(calTodayCmd)
+	calTodayCmd.Flags().Bool("compact", false, "Print all events on one line")
+	calTodayCmd.Flags().Int("width", 0, "With --compact, truncate the line to this many characters (0 = no limit)")
+	calTodayCmd.Flags().Bool("include-tasks", false, "Also show Google Tasks due today or overdue (needs the tasks scope)")
+
+	// Tomorrow command
+	calCmd.AddCommand(calTomorrowCmd)
+
+	// Week command
+	calCmd.AddCommand(calWeekCmd)
+
+	// Agenda command
+	calCmd.AddCommand(calAgendaCmd)
+	calAgendaCmd.Flags().String("from", "today", "First day to show (YYYY-MM-DD, today, +3d, mon, ...)")
+	calAgendaCmd.Flags().String("to", "", "Last day to show (default: a week after --from)")
+	calAgendaCmd.Flags().Bool("all-calendars", false, "Show events from all calendars")
+
+	// Show command
+	calCmd.AddCommand(calShowCmd)
+	calShowCmd.Flags().Int("open-attachment", 0, "Open the Nth attachment in a browser")
+
+	// Create command
+	calCmd.AddCommand(calCreateCmd)
+	addEventFlags(calCreateCmd)
+	calCreateCmd.Flags().StringSlice("attendees", nil, "Event attendees (emails, @group, or @file)")
+	calCreateCmd.Flags().StringP("quick", "q", "", "Quick add using natural language")
+	calCreateCmd.Flags().String("recur", "", "Repeat daily, weekdays, weekly, monthly, yearly, or by an RRULE (e.g. FREQ=WEEKLY;BYDAY=MO,WE)")
+	calCreateCmd.Flags().Int("recurrence-count", 0, "Number of occurrences for a recurring event")
+	calCreateCmd.Flags().Bool("free", false, "Show as free (doesn't block time in free/busy)")
+	calCreateCmd.Flags().BoolP("yes", "y", false, "Create the event even if it overlaps others")
+	calCreateCmd.Flags().Bool("meet", false, "Add a Google Meet video conference link")
+	calCreateCmd.Flags().StringSlice("remind", nil, "Reminders before the event, e.g. 10m,1h,email:1d (or none)")
+	calCreateCmd.Flags().Bool("force", false, "Allow creating events on a protected calendar")
+
+	// Invite command
+	calCmd.AddCommand(calInviteCmd)
+	addEventFlags(calInviteCmd)
+	calInviteCmd.Flags().StringSlice("to", nil, "Invitation recipients (emails)")
+	calInviteCmd.Flags().StringP("message", "m", "", "Note to include above the event details")
+	calInviteCmd.Flags().Bool("force", false, "Allow creating events on a protected calendar")
+
+	// Delete command
+	calCmd.AddCommand(calDeleteCmd)
+	calDeleteCmd.Flags().StringP("query", "q", "", "Delete all events matching this query")
+	calDeleteCmd.Flags().Int("days", 30, "Number of days to search when using --query")
+	calDeleteCmd.Flags().Bool("include-recurring", false, "Delete the whole series of matching recurring events")
+	calDeleteCmd.Flags().Bool("force", false, "Allow deleting events from a protected calendar")
+	addBatchFlags(calDeleteCmd)
+
+	// Reschedule command
+	calCmd.AddCommand(calRescheduleCmd)
+	calRescheduleCmd.Flags().String("by", "", "Shift the event by a duration (e.g. 30m, -1h, 1d)")
+	calRescheduleCmd.Flags().String("to", "", "New start time; the duration is kept")
+	calRescheduleCmd.Flags().Bool("force", false, "Allow rescheduling events on a protected calendar")
+
+	// Update command
+	calCmd.AddCommand(calUpdateCmd)
+	addEventFlags(calUpdateCmd)
+	calUpdateCmd.Flags().StringSlice("attendees", nil, "Replace the attendees (emails, @group, or @file)")
+	calUpdateCmd.Flags().StringSlice("remind", nil, "Replace the reminders, e.g. 10m,1h,email:1d (or none)")
+	calUpdateCmd.Flags().Bool("force", false, "Allow updating events on a protected calendar")
+
+	// RSVP command
+	calCmd.AddCommand(calRsvpCmd)
+	calRsvpCmd.Flags().StringP("response", "r", "", "Your response: accepted, declined, or tentative")
+
+	// Conflicts command
+	calCmd.AddCommand(calConflictsCmd)
+	calConflictsCmd.Flags().Int("days", 14, "Number of days to look ahead")
+	calConflictsCmd.Flags().Bool("all-calendars", false, "Check events from all calendars")
+	calConflictsCmd.Flags().Bool("include-all-day", false, "Count all-day events as conflicts")
+	calConflictsCmd.Flags().Bool("include-declined", false, "Count events you've declined")
+
+	// Attendees command
+	calCmd.AddCommand(calAttendeesCmd)
+	calAttendeesCmd.Flags().StringSlice("add", nil, "Attendees to invite (emails, @group, or @file)")
+	calAttendeesCmd.Flags().StringSlice("remove", nil, "Attendees to remove (emails)")
+	calAttendeesCmd.Flags().Bool("notify", true, "Email the added and removed guests")
+	calAttendeesCmd.Flags().Bool("force", false, "Allow changing events on a protected calendar")
+
+	// Move command
+	calCmd.AddCommand(calMoveCmd)
+	calMoveCmd.Flags().String("to", "", "Destination calendar (ID or name)")
+	calMoveCmd.Flags().Bool("force", false, "Allow moving events off or onto a protected calendar")
+
+	// Export command
+	calCmd.AddCommand(calExportCmd)
+	calExportCmd.Flags().StringP("output", "o", "", "Write the .ics file here instead of stdout")
+
+	// Import command
+	calCmd.AddCommand(calImportCmd)
+	calImportCmd.Flags().Bool("dry-run", false, "Show the events that would be imported without creating them")
+	calImportCmd.Flags().Bool("force", false, "Allow importing events to a protected calendar")
+
+	// Search command
+	calCmd.AddCommand(calSearchCmd)
+	calSearchCmd.Flags().Int("days", 90, "Number of days to search")
+	calSearchCmd.Flags().Int64P("number", "n", 20, "Maximum number of results")
+
+	// Free/busy command
+	calCmd.AddCommand(calFreeBusyCmd)
+	calFreeBusyCmd.Flags().StringSlice("attendees", nil, "Calendars or people (emails) to query")
+	calFreeBusyCmd.Flags().Int("days", 7, "Number of days to look ahead")
+	calFreeBusyCmd.Flags().Bool("ics", false, "Output busy times as an iCalendar VFREEBUSY")
+	calFreeBusyCmd.Flags().StringP("output", "o", "", "Write --ics output to a file")
+	calFreeBusyCmd.Flags().Duration("duration", 0, "Find free slots of this length (e.g. 30m, 1h) instead of listing busy times")
+	calFreeBusyCmd.Flags().String("within", "", "With --duration, how far ahead to look (e.g. 5d, 36h; default --days)")
+	calFreeBusyCmd.Flags().String("work-hours", "09:00-17:00", "With --duration, the part of each weekday to schedule in")
+	calFreeBusyCmd.Flags().IntP("number", "n", 10, "With --duration, how many slots to list (0 = all)")
+
+	// Sync command
+	calCmd.AddCommand(calSyncCmd)
+	calSyncCmd.Flags().Bool("reset", false, "Discard the stored sync token and do a full sync")
+
+	// Next command
+	calCmd.AddCommand(calNextCmd)
+	calNextCmd.Flags().BoolP("watch", "w", false, "Refresh every minute")
+
+	// Busy command
+	calCmd.AddCommand(calBusyCmd)
+	calBusyCmd.Flags().Bool("export-slack", false, "Print a status line for Slack or similar tools")
+	calBusyCmd.Flags().String("busy-template", "", "Status template while in a meeting (default: config.json or \""+defaultBusyStatus+"\")")
+	calBusyCmd.Flags().String("free-template", "", "Status template while free (default: config.json or \""+defaultFreeStatus+"\")")
+
+	// Stats command
+	calCmd.AddCommand(calStatsCmd)
+	calStatsCmd.Flags().Int("days", 30, "Number of past days to analyze")
+	calStatsCmd.Flags().Bool("all-calendars", false, "Include events from all calendars")
+
+	// Calendars command
+	calCmd.AddCommand(calCalendarsCmd)
+	calCalendarsCmd.AddCommand(calCalendarsCreateCmd)
+	calCalendarsCreateCmd.Flags().String("name", "", "Calendar name")
+	calCalendarsCreateCmd.Flags().String("description", "", "Calendar description")
+	calCalendarsCreateCmd.Flags().String("timezone", "", "Time zone, e.g. America/New_York (default: your account's)")
+	calCalendarsCmd.AddCommand(calCalendarsDeleteCmd)
+	calCalendarsDeleteCmd.Flags().BoolP("yes", "y", false, "Delete without asking")
+	calCalendarsDeleteCmd.Flags().Bool("force", false, "Allow deleting a protected calendar")
+}
+
+// Helper functions
+
+// addEventFlags registers the flags describing an event's title, time, and place
+func addEventFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("title", "t", "", "Event title")
+	cmd.Flags().StringP("start", "s", "", "Start time (YYYY-MM-DD HH:MM, or HH:MM/3:04pm for today or --date)")
+	cmd.Flags().StringP("end", "e", "", "End time (YYYY-MM-DD HH:MM, or a bare time on the start day)")
+	cmd.Flags().String("date", "", "Date for all-day events, or the day for a bare --start time (YYYY-MM-DD)")
+	cmd.Flags().Bool("all-day", false, "Create all-day event")
+	cmd.Flags().StringP("location", "l", "", "Event location")
+	cmd.Flags().StringP("description", "d", "", "Event description")
+}
+
+// eventFromFlags builds an event from the flags registered by addEventFlags
+func eventFromFlags(cmd *cobra.Command) *gdaycal.Event {
+	title, _ := cmd.Flags().GetString("title")
+	startStr, _ := cmd.Flags().GetString("start")
+	endStr, _ := cmd.Flags().GetString("end")
+	dateStr, _ := cmd.Flags().GetString("date")
+	allDay, _ := cmd.Flags().GetBool("all-day")
+	location, _ := cmd.Flags().GetString("location")
+	description, _ := cmd.Flags().GetString("description")
+
+	// Commands that support --quick mention it as the alternative
+	orQuick := ""
+	if cmd.Flags().Lookup("quick") != nil {
+		orQuick = " (or use --quick)"
+	}
+
+	if title == "" {
+		exitUsage("--title is required%s", orQuick)
+	}
+
+	event := &gdaycal.Event{
+		Summary:     title,
+		Location:    location,
+		Description: description,
+		Busy:        true,
+	}
+
+	// --date alone makes an all-day event; with --start it supplies the day
+	// for bare times
+	day := time.Now()
+	if dateStr != "" {
+		t, err := parseDate(dateStr)
+		if err != nil {
+			exitError("invalid date format: %v", err)
+		}
+		day = t
+	}
+
+	if allDay || (dateStr != "" && startStr == "") {
+		if dateStr == "" {
+			exitUsage("--date is required for all-day events")
+		}
+		event.AllDay = true
+		event.Start = day
+		event.End = day.AddDate(0, 0, 1)
+	} else {
+		if startStr == "" {
+			exitUsage("--start is required%s", orQuick)
+		}
+		start, err := parseDateTime(startStr, day)
+		if err != nil {
+			exitError("invalid start time: %v", err)
+		}
+		event.Start = start
+
+		if endStr != "" {
+			end, err := parseDateTime(endStr, start)
+			if err != nil {
+				exitError("invalid end time: %v", err)
+			}
+			event.End = end
+		} else {
+			// Default to 1 hour duration
+			event.End = start.Add(time.Hour)
+		}
+	}
+
+	return event
 }
 
-// Helper functions
+// recurrenceShortcuts maps --recur shortcuts to RRULEs
+var recurrenceShortcuts = map[string]string{
+	"daily":    "FREQ=DAILY",
+	"weekdays": "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR",
+	"weekly":   "FREQ=WEEKLY",
+	"monthly":  "FREQ=MONTHLY",
+	"yearly":   "FREQ=YEARLY",
+}
+
+// recurrenceRule turns a --recur value, a shortcut or an RRULE with or
+// without the "RRULE:" prefix, into an RRULE without the prefix
+func recurrenceRule(recur string) (string, error) {
+	if rule, ok := recurrenceShortcuts[strings.ToLower(recur)]; ok {
+		return rule, nil
+	}
+	rule := strings.TrimPrefix(recur, "RRULE:")
+	if !strings.HasPrefix(strings.ToUpper(rule), "FREQ=") && !strings.Contains(strings.ToUpper(rule), ";FREQ=") {
+		return "", fmt.Errorf("invalid --recur %q: use daily, weekdays, weekly, monthly, yearly, or an RRULE such as FREQ=WEEKLY;BYDAY=MO", recur)
+	}
+	return rule, nil
+}
+
+// withRecurrenceCount limits an RRULE to count occurrences. A rule can only
+// end one way, so one that already has a COUNT or UNTIL is rejected.
+func withRecurrenceCount(rule string, count int) (string, error) {
+	for _, part := range strings.Split(rule, ";") {
+		name, _, _ := strings.Cut(part, "=")
+		if name = strings.ToUpper(strings.TrimSpace(name)); name == "COUNT" || name == "UNTIL" {
+			return "", fmt.Errorf("--recurrence-count can't be used with a --recur rule that has %s", name)
+		}
+	}
+	return fmt.Sprintf("%s;COUNT=%d", rule, count), nil
+}
+
+// applyEventFlags overrides e's fields with the addEventFlags and
+// --attendees flags that were given, reporting whether any were
+func applyEventFlags(cmd *cobra.Command, e *gdaycal.Event) bool {
+	flags := cmd.Flags()
+	changed := false
+	for _, name := range []string{"title", "location", "description"} {
+		if !flags.Changed(name) {
+			continue
+		}
+		value, _ := flags.GetString(name)
+		switch name {
+		case "title":
+			e.Summary = value
+		case "location":
+			e.Location = value
+		case "description":
+			e.Description = value
+		}
+		changed = true
+	}
+
+	if flags.Changed("attendees") {
+		refs, _ := flags.GetStringSlice("attendees")
+		attendees, err := expandAttendees(refs)
+		if err != nil {
+			exitUsage("%v", err)
+		}
+		e.Attendees = attendees
+		changed = true
+	}
+
+	startStr, _ := flags.GetString("start")
+	endStr, _ := flags.GetString("end")
+	dateStr, _ := flags.GetString("date")
+	allDay, _ := flags.GetBool("all-day")
+	if startStr == "" && endStr == "" && dateStr == "" && !allDay {
+		return changed
+	}
+
+	length := e.End.Sub(e.Start)
+	day := e.Start.Local()
+	if dateStr != "" {
+		d, err := parseDate(dateStr)
+		if err != nil {
+			exitError("invalid date format: %v", err)
+		}
+		day = d
+	}
+
+	switch {
+	case allDay || (e.AllDay && startStr == ""):
+		// All-day: keep the number of days, defaulting to one when converting
+		days := int(length.Round(24*time.Hour) / (24 * time.Hour))
+		if !e.AllDay || days < 1 {
+			days = 1
+		}
+		start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
+		e.AllDay = true
+		e.Start, e.End = start, start.AddDate(0, 0, days)
+		if endStr != "" {
+			end, err := parseDate(endStr)
+			if err != nil {
+				exitError("invalid end date: %v", err)
+			}
+			// --end names the last day; the API's end date is exclusive
+			e.End = end.AddDate(0, 0, 1)
+		}
+	default:
+		if e.AllDay {
+			length = time.Hour
+		}
+		start := time.Date(day.Year(), day.Month(), day.Day(), e.Start.Local().Hour(), e.Start.Local().Minute(), 0, 0, time.Local)
+		if startStr != "" {
+			t, err := parseDateTime(startStr, day)
+			if err != nil {
+				exitError("invalid start time: %v", err)
+			}
+			start = t
+		}
+		e.AllDay = false
+		e.Start, e.End = start, start.Add(length)
+		if endStr != "" {
+			end, err := parseDateTime(endStr, start)
+			if err != nil {
+				exitError("invalid end time: %v", err)
+			}
+			e.End = end
+		}
+	}
+
+	if !e.End.After(e.Start) {
+		exitUsage("the event would end before it starts")
+	}
+	return true
+}
+
+// maxReminders is the most reminder overrides the API accepts per event
+const maxReminders = 5
+
+// remindersFromFlag parses --remind: times before the event such as "10m"
+// or "1d", each optionally prefixed with "popup:" or "email:", or "none"
+func remindersFromFlag(cmd *cobra.Command) []gdaycal.Reminder {
+	values, _ := cmd.Flags().GetStringSlice("remind")
+	reminders := []gdaycal.Reminder{}
+	if len(values) == 1 && strings.EqualFold(values[0], "none") {
+		return reminders
+	}
+	if len(values) > maxReminders {
+		exitUsage("--remind takes at most %d reminders", maxReminders)
+	}
+
+	for _, v := range values {
+		method, offset, ok := strings.Cut(v, ":")
+		if !ok {
+			method, offset = "popup", v
+		}
+		method = strings.ToLower(method)
+		if method != "popup" && method != "email" {
+			exitUsage("invalid --remind %q: the method must be popup or email", v)
+		}
+		d, err := parseOffset(offset)
+		if err != nil {
+			exitUsage("invalid --remind: %v", err)
+		}
+		if d <= 0 || d%time.Minute != 0 {
+			exitUsage("invalid --remind %q: use a positive whole number of minutes", v)
+		}
+		minutes := int64(d / time.Minute)
+		if minutes > gdaycal.MaxReminderMinutes {
+			exitUsage("invalid --remind %q: reminders can be at most 4 weeks (%d minutes) before the event", v, gdaycal.MaxReminderMinutes)
+		}
+		reminders = append(reminders, gdaycal.Reminder{Method: method, Minutes: minutes})
+	}
+	return reminders
+}
+
+// formatReminder describes a reminder, e.g. "1h before (email)"
+func formatReminder(r gdaycal.Reminder) string {
+	var when string
+	switch m := r.Minutes; {
+	case m%(24*60) == 0:
+		when = fmt.Sprintf("%dd", m/(24*60))
+	case m%60 == 0:
+		when = fmt.Sprintf("%dh", m/60)
+	case m > 60:
+		when = fmt.Sprintf("%dh%dm", m/60, m%60)
+	default:
+		when = fmt.Sprintf("%dm", m)
+	}
+	return fmt.Sprintf("%s before (%s)", when, r.Method)
+}
+
+// inviteBody describes an event in plain text for an invitation email
+func inviteBody(e *gdaycal.Event) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "You're invited: %s\n\n", e.Summary)
+	if e.AllDay {
+		fmt.Fprintf(&b, "When: %s (all day)\n", allDayDates(e))
+	} else {
+		fmt.Fprintf(&b, "When: %s - %s\n",
+			e.Start.Format("Mon Jan 2, 2006 at 3:04 PM"),
+			e.End.Format("3:04 PM MST"))
+	}
+	if e.Location != "" {
+		fmt.Fprintf(&b, "Where: %s\n", e.Location)
+	}
+	if e.Description != "" {
+		fmt.Fprintf(&b, "\n%s\n", e.Description)
+	}
+	return b.String()
+}
+
+// resolveCalendarID resolves the --calendar flag to a calendar ID
+func resolveCalendarID(ctx context.Context, cmd *cobra.Command, srv *gdaycal.Service) string {
+	ref, _ := cmd.Flags().GetString("calendar")
+	calID, err := srv.ResolveCalendarID(ctx, ref)
+	if err != nil {
+		exitError("%v", err)
+	}
+	return calID
+}
+
+// resolveWritableCalendarID resolves the --calendar flag for a command that
+// modifies the calendar, refusing protected calendars unless --force is given
+func resolveWritableCalendarID(ctx context.Context, cmd *cobra.Command, srv *gdaycal.Service) string {
+	calID := resolveCalendarID(ctx, cmd, srv)
+	checkCalendarWritable(ctx, cmd, srv, calID)
+	return calID
+}
+
+// checkCalendarWritable exits if calID is a protected calendar and --force
+// isn't given
+func checkCalendarWritable(ctx context.Context, cmd *cobra.Command, srv calendarResolver, calID string) {
+	if force, _ := cmd.Flags().GetBool("force"); force {
+		return
+	}
+	if err := calendarWritable(ctx, srv, calID); errors.Is(err, errCalendarProtected) {
+		exitUsage("%v; pass --force to modify it", err)
+	} else if err != nil {
+		exitError("%v", err)
+	}
+}
+
+// errCalendarProtected is returned for writes to a calendar listed in
+// protected_calendars
+var errCalendarProtected = errors.New("calendar is protected")
+
+// calendarResolver resolves calendar IDs and names to IDs
+type calendarResolver interface {
+	ResolveCalendarID(ctx context.Context, ref string) (string, error)
+	ResolveCalendarIDs(ctx context.Context, refs []string) ([]string, error)
+}
+
+// calendarWritable returns an error wrapping errCalendarProtected if calID
+// is one of the protected_calendars in config.json
+func calendarWritable(ctx context.Context, srv calendarResolver, calID string) error {
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return err
+	}
+	if len(settings.ProtectedCalendars) == 0 {
+		return nil
+	}
+	protectedIDs, err := srv.ResolveCalendarIDs(ctx, settings.ProtectedCalendars)
+	if err != nil {
+		return err
+	}
+	for i, protectedID := range protectedIDs {
+		if strings.EqualFold(protectedID, calID) {
+			return fmt.Errorf("%w: %q is in protected_calendars in config.json", errCalendarProtected, settings.ProtectedCalendars[i])
+		}
+	}
+	return nil
+}
+
+// expandAttendees expands @group and @file references in an attendee list,
+// validating each address and dropping duplicates. A reference names a
+// group from config.json if one exists, and a file otherwise.
+func expandAttendees(refs []string) ([]string, error) {
+	var groups map[string][]string
+	var attendees []string
+	seen := make(map[string]bool)
+
+	add := func(addr string) error {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			return nil
+		}
+		parsed, err := mail.ParseAddress(addr)
+		if err != nil {
+			return fmt.Errorf("invalid attendee %q: %v", addr, err)
+		}
+		key := strings.ToLower(parsed.Address)
+		if !seen[key] {
+			seen[key] = true
+			attendees = append(attendees, parsed.Address)
+		}
+		return nil
+	}
+
+	for _, ref := range refs {
+		name, ok := strings.CutPrefix(strings.TrimSpace(ref), "@")
+		if !ok {
+			if err := add(ref); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if groups == nil {
+			settings, err := config.LoadSettings()
+			if err != nil {
+				return nil, err
+			}
+			groups = settings.AttendeeGroups
+			if groups == nil {
+				groups = map[string][]string{}
+			}
+		}
+
+		members, isGroup := groups[name]
+		if !isGroup {
+			data, err := os.ReadFile(name)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not an attendee group or readable file: %v", ref, err)
+			}
+			members = strings.FieldsFunc(string(data), func(r rune) bool {
+				return r == '\n' || r == '\r' || r == ','
+			})
+		}
+		for _, m := range members {
+			if err := add(m); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return attendees, nil
+}
+
+// rescheduledTimes computes an event's new start and end from --by (an
+// offset) or --to (a new start), preserving its duration
+func rescheduledTimes(e *gdaycal.Event, by, to string) (time.Time, time.Time, error) {
+	length := e.End.Sub(e.Start)
+
+	if by != "" {
+		offset, err := parseOffset(by)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		if e.AllDay {
+			if offset%(24*time.Hour) != 0 {
+				return time.Time{}, time.Time{}, fmt.Errorf("all-day events can only move by whole days (e.g. --by 1d)")
+			}
+			days := int(offset / (24 * time.Hour))
+			return e.Start.AddDate(0, 0, days), e.End.AddDate(0, 0, days), nil
+		}
+		return e.Start.Add(offset), e.End.Add(offset), nil
+	}
+
+	if e.AllDay {
+		start, err := parseDate(to)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		days := int(length.Round(24*time.Hour) / (24 * time.Hour))
+		return start, start.AddDate(0, 0, days), nil
+	}
+	start, err := parseDateTime(to, e.Start.Local())
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return start, start.Add(length), nil
+}
+
+// parseOffset parses a duration, also accepting whole days such as "1d"
+func parseOffset(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid offset %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid offset %q (use e.g. 30m, 1h30m, -15m, or 1d)", s)
+	}
+	return d, nil
+}
+
+// compactEvents renders events on one line, like "09:00 Standup | 11:00
+// Review". If width is positive, events that don't fit are summarized as
+// "+N more".
+func compactEvents(events []*gdaycal.Event, width int) string {
+	parts := make([]string, 0, len(events))
+	for _, e := range events {
+		if e.AllDay {
+			parts = append(parts, "All day "+e.Summary)
+		} else {
+			parts = append(parts, e.Start.Local().Format("15:04")+" "+e.Summary)
+		}
+	}
+
+	line := strings.Join(parts, " | ")
+	if width <= 0 || utf8.RuneCountInString(line) <= width {
+		return line
+	}
+
+	// Keep as many whole events as fit alongside the "+N more" suffix
+	for n := len(parts) - 1; n > 0; n-- {
+		candidate := strings.Join(parts[:n], " | ") + fmt.Sprintf(" | +%d more", len(parts)-n)
+		if utf8.RuneCountInString(candidate) <= width {
+			return candidate
+		}
+	}
+
+	// Not even one event fits; cut the line itself
+	runes := []rune(line)
+	if width <= 1 {
+		return string(runes[:width])
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// printNextEvent prints the next event and how long until it starts, as
+// JSON or a single line
+func printNextEvent(e *gdaycal.Event, now time.Time) {
+	if isJSONOutput() {
+		result := NextEventJSON{}
+		if e != nil {
+			ej := eventToJSON(e)
+			result.Event = &ej
+			result.StartsInSeconds = int64(e.Start.Sub(now).Seconds())
+		}
+		outputJSON(result)
+		return
+	}
+
+	if e == nil {
+		fmt.Println("No upcoming events")
+		return
+	}
+	start := e.Start.Local()
+	when := start.Format("15:04")
+	if startOfToday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local); !start.Before(startOfToday.AddDate(0, 0, 1)) {
+		when = start.Format("Mon Jan 2 15:04")
+	}
+	fmt.Printf("%s at %s (%s)\n", e.Summary, when, formatCountdown(e.Start.Sub(now)))
+}
+
+// formatCountdown renders the time until an event, e.g. "in 2h 15m" or
+// "in 1d 3h"
+func formatCountdown(d time.Duration) string {
+	d = d.Truncate(time.Minute)
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("in %dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("in %dh %dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("in %dm", minutes)
+	default:
+		return "in <1m"
+	}
+}
+
+// Default status templates for 'cal busy --export-slack'
+const (
+	defaultBusyStatus = ":calendar: In a meeting until {{.Until}}"
+	defaultFreeStatus = ":large_green_circle: Available"
+)
+
+// slackTemplates returns the busy and free status templates, taken from the
+// flags, then config.json, then the defaults
+func slackTemplates(cmd *cobra.Command) (string, string) {
+	busyTmpl, freeTmpl := defaultBusyStatus, defaultFreeStatus
+	if settings, err := config.LoadSettings(); err == nil && settings.SlackStatus != nil {
+		if settings.SlackStatus.Busy != "" {
+			busyTmpl = settings.SlackStatus.Busy
+		}
+		if settings.SlackStatus.Free != "" {
+			freeTmpl = settings.SlackStatus.Free
+		}
+	}
+	if cmd.Flags().Changed("busy-template") {
+		busyTmpl, _ = cmd.Flags().GetString("busy-template")
+	}
+	if cmd.Flags().Changed("free-template") {
+		freeTmpl, _ = cmd.Flags().GetString("free-template")
+	}
+	return busyTmpl, freeTmpl
+}
+
+// slackStatus renders the status line for the current meeting, or the free
+// template if there is none
+func slackStatus(current *gdaycal.Event, busyTmpl, freeTmpl string) (string, error) {
+	var data struct {
+		Summary, Location, Until string
+		Start, End               time.Time
+	}
+	text := freeTmpl
+	if current != nil {
+		text = busyTmpl
+		data.Summary = current.Summary
+		data.Location = current.Location
+		data.Start = current.Start.Local()
+		data.End = current.End.Local()
+		data.Until = data.End.Format("3:04 PM")
+	}
+
+	tmpl, err := template.New("status").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid status template: %w", err)
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("invalid status template: %w", err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// filterEvents keeps the events that pass every enabled filter
+func filterEvents(events []*gdaycal.Event, busyOnly, mineOnly, acceptedOnly bool) []*gdaycal.Event {
+	if !busyOnly && !mineOnly && !acceptedOnly {
+		return events
+	}
+	var filtered []*gdaycal.Event
+	for _, e := range events {
+		if busyOnly && !e.Busy {
+			continue
+		}
+		if mineOnly && !e.IsOrganizer {
+			continue
+		}
+		if acceptedOnly && e.ResponseStatus != "accepted" {
+			continue
+		}
+		filtered = append(filtered, e)
+	}
+	return filtered
+}
+
+// eventsTable lays out an event list. The table shows each day's date once,
+// as printEvents does; CSV gets full timestamps, with all-day events as
+// dates.
+func eventsTable(events []*gdaycal.Event, csv bool) *table {
+	if csv {
+		t := &table{columns: []column{
+			{name: "id"}, {name: "calendar_id"}, {name: "summary"}, {name: "start"}, {name: "end"},
+			{name: "all_day"}, {name: "location"},
+		}}
+		for _, e := range events {
+			start, end := e.Start.Format(time.RFC3339), e.End.Format(time.RFC3339)
+			if e.AllDay {
+				start, end = e.Start.Format("2006-01-02"), e.LastDay().Format("2006-01-02")
+			}
+			t.rows = append(t.rows, []string{
+				e.ID, e.CalendarID, e.Summary, start, end, strconv.FormatBool(e.AllDay), e.Location,
+			})
+		}
+		return t
+	}
+
+	t := &table{columns: []column{{name: "date"}, {name: "time"}, {name: "summary", width: 60}}}
+	currentDate := ""
+	for _, e := range events {
+		date := e.Start.Format("Mon Jan 2")
+		if date == currentDate {
+			date = ""
+		} else {
+			currentDate = date
+		}
+		when := "All day"
+		if !e.AllDay {
+			when = e.Start.Format("15:04") + " - " + e.End.Format("15:04")
+		}
+		t.rows = append(t.rows, []string{date, when, e.Summary})
+	}
+	return t
+}
+
+// calendarsTable lays out the calendar list
+func calendarsTable(calendars []*gdaycal.Calendar, csv bool) *table {
+	t := &table{columns: []column{{name: "name", width: 40}, {name: "id"}, {name: "primary"}}}
+	if csv {
+		t.columns = append(t.columns, column{name: "description"})
+	}
+	for _, c := range calendars {
+		primary := ""
+		switch {
+		case csv:
+			primary = strconv.FormatBool(c.Primary)
+		case c.Primary:
+			primary = "yes"
+		}
+		row := []string{c.Summary, c.ID, primary}
+		if csv {
+			row = append(row, c.Description)
+		}
+		t.rows = append(t.rows, row)
+	}
+	return t
+}
 
 func printEvents(events []*gdaycal.Event) {
 	currentDate := ""
@@ -537,35 +2878,82 @@ func printEvents(events []*gdaycal.Event) {
 	}
 }
 
+// allDayDates formats an all-day event's date, or its first and last days
+// if it spans several
+func allDayDates(e *gdaycal.Event) string {
+	last := e.LastDay()
+	if last.Equal(e.Start) {
+		return e.Start.Format("Mon Jan 2, 2006")
+	}
+	if last.Year() == e.Start.Year() {
+		return e.Start.Format("Mon Jan 2") + " - " + last.Format("Mon Jan 2, 2006")
+	}
+	return e.Start.Format("Mon Jan 2, 2006") + " - " + last.Format("Mon Jan 2, 2006")
+}
+
 func printEventDetails(e *gdaycal.Event) {
 	fmt.Printf("Event: %s\n", e.Summary)
 	fmt.Printf("ID: %s\n", e.ID)
 
 	if e.AllDay {
-		fmt.Printf("Date: %s (all day)\n", e.Start.Format("Mon Jan 2, 2006"))
+		fmt.Printf("Date: %s (all day)\n", allDayDates(e))
 	} else {
 		fmt.Printf("Start: %s\n", e.Start.Format("Mon Jan 2, 2006 at 3:04 PM"))
 		fmt.Printf("End: %s\n", e.End.Format("Mon Jan 2, 2006 at 3:04 PM"))
 	}
 
+	if e.Busy {
+		fmt.Println("Show as: Busy")
+	} else {
+		fmt.Println("Show as: Free")
+	}
+
+	for _, r := range e.Recurrence {
+		fmt.Printf("Repeats: %s\n", strings.TrimPrefix(r, "RRULE:"))
+	}
+
 	if e.Location != "" {
 		fmt.Printf("Location: %s\n", e.Location)
 	}
 
+	if e.MeetLink != "" {
+		fmt.Printf("Meet: %s\n", e.MeetLink)
+	}
+
 	if len(e.Attendees) > 0 {
 		fmt.Printf("Attendees: %s\n", strings.Join(e.Attendees, ", "))
 	}
 
+	if e.Reminders != nil {
+		reminders := []string{"none"}
+		if len(e.Reminders) > 0 {
+			reminders = reminders[:0]
+			for _, r := range e.Reminders {
+				reminders = append(reminders, formatReminder(r))
+			}
+		}
+		fmt.Printf("Reminders: %s\n", strings.Join(reminders, ", "))
+	}
+
 	if e.Description != "" {
 		fmt.Printf("\nDescription:\n%s\n", e.Description)
 	}
 
+	if len(e.Attachments) > 0 {
+		fmt.Println("\nAttachments:")
+		for i, a := range e.Attachments {
+			fmt.Printf("  %d. %s\n     %s\n", i+1, a.Title, a.FileURL)
+		}
+	}
+
 	if e.HtmlLink != "" {
 		fmt.Printf("\nLink: %s\n", e.HtmlLink)
 	}
 }
 
-func parseDateTime(s string) (time.Time, error) {
+// parseDateTime parses a date and time. A bare time such as "14:00" or
+// "2:30pm" is taken to be on the same calendar day as day.
+func parseDateTime(s string, day time.Time) (time.Time, error) {
 	formats := []string{
 		"2006-01-02 15:04",
 		"2006-01-02T15:04",
@@ -581,30 +2969,22 @@ func parseDateTime(s string) (time.Time, error) {
 		}
 	}
 
-	return time.Time{}, fmt.Errorf("unable to parse datetime: %s", s)
-}
-
-func parseDate(s string) (time.Time, error) {
-	formats := []string{
-		"2006-01-02",
-		"01/02/2006",
-		"Jan 2, 2006",
+	timeFormats := []string{
+		"15:04",
+		"3:04pm",
+		"3:04 pm",
+		"3pm",
+		"3 pm",
 	}
 
-	for _, format := range formats {
-		if t, err := time.ParseInLocation(format, s, time.Local); err == nil {
-			return t, nil
+	for _, format := range timeFormats {
+		if t, err := time.ParseInLocation(format, strings.ToLower(strings.TrimSpace(s)), time.Local); err == nil {
+			y, m, d := day.In(time.Local).Date()
+			return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, time.Local), nil
 		}
 	}
 
-	return time.Time{}, fmt.Errorf("unable to parse date: %s", s)
-}
-
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
+	return time.Time{}, fmt.Errorf("unable to parse datetime: %s", s)
 }
 
 // eventToJSON converts a calendar.Event to EventJSON
@@ -618,11 +2998,46 @@ func eventToJSON(e *gdaycal.Event) EventJSON {
 		Start:       e.Start,
 		End:         e.End,
 		AllDay:      e.AllDay,
+		LastDay:     lastDayJSON(e),
 		Attendees:   e.Attendees,
 		Status:      e.Status,
 		HtmlLink:    e.HtmlLink,
 		Recurring:   e.Recurring,
+		Recurrence:  e.Recurrence,
+		Busy:        e.Busy,
+		Attachments: eventAttachmentsToJSON(e.Attachments),
+		Organizer:   e.Organizer,
+		Response:    e.ResponseStatus,
+		MeetLink:    e.MeetLink,
+		Reminders:   remindersToJSON(e.Reminders),
+	}
+}
+
+// remindersToJSON converts reminder overrides to JSON format
+func remindersToJSON(reminders []gdaycal.Reminder) []ReminderJSON {
+	var result []ReminderJSON
+	for _, r := range reminders {
+		result = append(result, ReminderJSON{Method: r.Method, Minutes: r.Minutes})
+	}
+	return result
+}
+
+// lastDayJSON returns an all-day event's inclusive last day as YYYY-MM-DD,
+// or "" for timed events
+func lastDayJSON(e *gdaycal.Event) string {
+	if !e.AllDay {
+		return ""
+	}
+	return e.LastDay().Format("2006-01-02")
+}
+
+// eventAttachmentsToJSON converts event attachments to JSON format
+func eventAttachmentsToJSON(attachments []gdaycal.Attachment) []EventAttachmentJSON {
+	var result []EventAttachmentJSON
+	for _, a := range attachments {
+		result = append(result, EventAttachmentJSON{Title: a.Title, FileURL: a.FileURL, MimeType: a.MimeType})
 	}
+	return result
 }
 
 // eventsToJSON converts a slice of events to JSON format
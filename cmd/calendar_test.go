@@ -0,0 +1,522 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	gdaycal "github.com/joncooper/gday/internal/calendar"
+	"github.com/joncooper/gday/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func TestWithRecurrenceCount(t *testing.T) {
+	tests := []struct {
+		rule    string
+		count   int
+		want    string
+		wantErr bool
+	}{
+		{"FREQ=WEEKLY;BYDAY=MO,WE", 5, "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=5", false},
+		{"FREQ=DAILY", 1, "FREQ=DAILY;COUNT=1", false},
+		{"FREQ=DAILY;COUNT=3", 5, "", true},
+		{"FREQ=WEEKLY;UNTIL=20250101T000000Z", 5, "", true},
+		{"freq=daily;count=3", 5, "", true},
+	}
+	for _, tt := range tests {
+		got, err := withRecurrenceCount(tt.rule, tt.count)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("withRecurrenceCount(%q, %d) err = %v, wantErr %v", tt.rule, tt.count, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("withRecurrenceCount(%q, %d) = %q, want %q", tt.rule, tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestRecurrenceRule(t *testing.T) {
+	tests := []struct {
+		recur   string
+		want    string
+		wantErr bool
+	}{
+		{"weekdays", "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", false},
+		{"Weekdays", "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", false},
+		{"daily", "FREQ=DAILY", false},
+		{"monthly", "FREQ=MONTHLY", false},
+		{"FREQ=WEEKLY;BYDAY=MO,WE", "FREQ=WEEKLY;BYDAY=MO,WE", false},
+		{"RRULE:FREQ=DAILY;COUNT=3", "FREQ=DAILY;COUNT=3", false},
+		{"INTERVAL=2;FREQ=WEEKLY", "INTERVAL=2;FREQ=WEEKLY", false},
+		{"fortnightly", "", true},
+		{"BYDAY=MO", "", true},
+	}
+	for _, tt := range tests {
+		got, err := recurrenceRule(tt.recur)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("recurrenceRule(%q) err = %v, wantErr %v", tt.recur, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("recurrenceRule(%q) = %q, want %q", tt.recur, got, tt.want)
+		}
+	}
+}
+
+func TestAllDayDates(t *testing.T) {
+	date := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name        string
+		start, end  time.Time // end is exclusive, as the API gives it
+		want        string
+		wantLastDay string
+	}{
+		{"one day", date(2025, 6, 2), date(2025, 6, 3), "Mon Jun 2, 2025", "2025-06-02"},
+		{"three days", date(2025, 6, 2), date(2025, 6, 5), "Mon Jun 2 - Wed Jun 4, 2025", "2025-06-04"},
+		{"across a year", date(2025, 12, 31), date(2026, 1, 3), "Wed Dec 31, 2025 - Fri Jan 2, 2026", "2026-01-02"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &gdaycal.Event{Start: tt.start, End: tt.end, AllDay: true}
+			if got := allDayDates(e); got != tt.want {
+				t.Errorf("allDayDates = %q, want %q", got, tt.want)
+			}
+			if got := lastDayJSON(e); got != tt.wantLastDay {
+				t.Errorf("lastDayJSON = %q, want %q", got, tt.wantLastDay)
+			}
+		})
+	}
+}
+
+func TestRescheduledTimes(t *testing.T) {
+	at := func(d, h, m int) time.Time { return time.Date(2025, 6, d, h, m, 0, 0, time.Local) }
+	date := func(d int) time.Time { return time.Date(2025, 6, d, 0, 0, 0, 0, time.Local) }
+	meeting := &gdaycal.Event{Start: at(2, 9, 0), End: at(2, 10, 30)}
+	offsite := &gdaycal.Event{Start: date(2), End: date(4), AllDay: true}
+
+	tests := []struct {
+		name      string
+		event     *gdaycal.Event
+		by, to    string
+		wantStart time.Time
+		wantEnd   time.Time
+		wantErr   bool
+	}{
+		{name: "later by minutes", event: meeting, by: "30m", wantStart: at(2, 9, 30), wantEnd: at(2, 11, 0)},
+		{name: "earlier", event: meeting, by: "-1h15m", wantStart: at(2, 7, 45), wantEnd: at(2, 9, 15)},
+		{name: "by a day", event: meeting, by: "1d", wantStart: at(3, 9, 0), wantEnd: at(3, 10, 30)},
+		{name: "to a new start", event: meeting, to: "2025-06-05 15:00", wantStart: at(5, 15, 0), wantEnd: at(5, 16, 30)},
+		{name: "to a time on the same day", event: meeting, to: "14:00", wantStart: at(2, 14, 0), wantEnd: at(2, 15, 30)},
+		{name: "all-day by days", event: offsite, by: "2d", wantStart: date(4), wantEnd: date(6)},
+		{name: "all-day to a date", event: offsite, to: "2025-06-10", wantStart: date(10), wantEnd: date(12)},
+		{name: "all-day by hours", event: offsite, by: "3h", wantErr: true},
+		{name: "bad offset", event: meeting, by: "soon", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := rescheduledTimes(tt.event, tt.by, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("got %v - %v, want %v - %v", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestCompactEvents(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2025, 6, 2, h, m, 0, 0, time.Local) }
+	events := []*gdaycal.Event{
+		{Summary: "Standup", Start: at(9, 0), End: at(9, 15)},
+		{Summary: "Review", Start: at(11, 0), End: at(12, 0)},
+		{Summary: "1:1", Start: at(14, 0), End: at(14, 30)},
+	}
+	full := "09:00 Standup | 11:00 Review | 14:00 1:1"
+
+	tests := []struct {
+		name   string
+		events []*gdaycal.Event
+		width  int
+		want   string
+	}{
+		{"no limit", events, 0, full},
+		{"fits exactly", events, len(full), full},
+		{"keeps one", events, 35, "09:00 Standup | +2 more"},
+		{"keeps two", events, 38, "09:00 Standup | 11:00 Review | +1 more"},
+		{"not even one fits", events, 10, "09:00 Sta…"},
+		{"width one", events, 1, "0"},
+		{"all day", []*gdaycal.Event{{Summary: "Offsite", AllDay: true}, events[0]}, 0, "All day Offsite | 09:00 Standup"},
+		{"no events", nil, 20, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := compactEvents(tt.events, tt.width)
+			if got != tt.want {
+				t.Errorf("compactEvents = %q, want %q", got, tt.want)
+			}
+			if tt.width > 0 && utf8.RuneCountInString(got) > tt.width {
+				t.Errorf("%q is wider than %d", got, tt.width)
+			}
+		})
+	}
+}
+
+func TestSlackStatus(t *testing.T) {
+	meeting := &gdaycal.Event{
+		Summary:  "Design review",
+		Location: "Room 4",
+		Start:    time.Date(2025, 6, 2, 14, 0, 0, 0, time.Local),
+		End:      time.Date(2025, 6, 2, 15, 0, 0, 0, time.Local),
+	}
+
+	tests := []struct {
+		name     string
+		current  *gdaycal.Event
+		busyTmpl string
+		freeTmpl string
+		want     string
+		wantErr  bool
+	}{
+		{"in a meeting", meeting, defaultBusyStatus, defaultFreeStatus, ":calendar: In a meeting until 3:00 PM", false},
+		{"free", nil, defaultBusyStatus, defaultFreeStatus, ":large_green_circle: Available", false},
+		{"custom busy", meeting, ":no_entry: {{.Summary}} in {{.Location}} until {{.End.Format \"15:04\"}}", "", ":no_entry: Design review in Room 4 until 15:00", false},
+		{"custom free", nil, "", "  :palm_tree: Around  ", ":palm_tree: Around", false},
+		{"bad template", meeting, "{{.Until", defaultFreeStatus, "", true},
+		{"unknown field", meeting, "{{.Nope}}", defaultFreeStatus, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := slackStatus(tt.current, tt.busyTmpl, tt.freeTmpl)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("status = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeEventDeleter serves fixed search results and records deletions
+type fakeEventDeleter struct {
+	events  []*gdaycal.Event
+	deleted []string
+}
+
+func (f *fakeEventDeleter) SearchEvents(ctx context.Context, calendarID, query string, timeMin, timeMax time.Time, maxResults int64) ([]*gdaycal.Event, error) {
+	return f.events, nil
+}
+
+func (f *fakeEventDeleter) DeleteEvent(ctx context.Context, calendarID, eventID string) error {
+	f.deleted = append(f.deleted, calendarID+"/"+eventID)
+	return nil
+}
+
+func TestDeleteEventsByQuery(t *testing.T) {
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+	events := []*gdaycal.Event{
+		{ID: "e1", CalendarID: "primary", Summary: "Standup (moved)", Start: start},
+		{ID: "s1_20250603", CalendarID: "primary", Summary: "Standup", Start: start.AddDate(0, 0, 1), Recurring: true, RecurrenceID: "s1"},
+		{ID: "s1_20250604", CalendarID: "primary", Summary: "Standup", Start: start.AddDate(0, 0, 2), Recurring: true, RecurrenceID: "s1"},
+	}
+
+	tests := []struct {
+		name             string
+		includeRecurring bool
+		dryRun           bool
+		want             []string
+	}{
+		{"instances one by one", false, false, []string{"primary/e1", "primary/s1_20250603", "primary/s1_20250604"}},
+		{"whole series", true, false, []string{"primary/e1", "primary/s1"}},
+		{"dry run", false, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			cmd.Flags().Int("days", 7, "")
+			cmd.Flags().Bool("include-recurring", tt.includeRecurring, "")
+			cmd.Flags().Bool("yes", true, "")
+			cmd.Flags().Bool("dry-run", tt.dryRun, "")
+			cmd.Flags().String("progress", "", "")
+
+			srv := &fakeEventDeleter{events: events}
+			deleteEventsByQuery(context.Background(), cmd, srv, "primary", "standup")
+			if !slices.Equal(srv.deleted, tt.want) {
+				t.Errorf("deleted %v, want %v", srv.deleted, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandAttendees(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if err := config.SaveSettings(&config.Settings{AttendeeGroups: map[string][]string{
+		"team": {"ana@example.com", "Bo <bo@example.com>"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	guests := filepath.Join(dir, "guests.txt")
+	if err := os.WriteFile(guests, []byte("cy@example.com\r\n\nANA@example.com, di@example.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bad := filepath.Join(dir, "bad.txt")
+	if err := os.WriteFile(bad, []byte("cy@example.com\nnot an address\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		refs    []string
+		want    []string
+		wantErr bool
+	}{
+		{"plain addresses", []string{"ana@example.com", "bo@example.com"}, []string{"ana@example.com", "bo@example.com"}, false},
+		{"group", []string{"@team"}, []string{"ana@example.com", "bo@example.com"}, false},
+		{"file", []string{"@" + guests}, []string{"cy@example.com", "ANA@example.com", "di@example.com"}, false},
+		{"group and file overlap", []string{"@team", "@" + guests}, []string{"ana@example.com", "bo@example.com", "cy@example.com", "di@example.com"}, false},
+		{"unknown group or file", []string{"@nobody"}, nil, true},
+		{"bad address in a file", []string{"@" + bad}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandAttendees(tt.refs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("attendees = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// exitCode runs fn in a child test process and returns the code it exits
+// with, or 0 if it returns
+func exitCode(t *testing.T, fn func()) int {
+	t.Helper()
+	if os.Getenv("GDAY_TEST_EXIT") == t.Name() {
+		fn()
+		os.Exit(0)
+	}
+	parts := strings.Split(t.Name(), "/")
+	for i, part := range parts {
+		parts[i] = "^" + regexp.QuoteMeta(part) + "$"
+	}
+	child := exec.Command(os.Args[0], "-test.run="+strings.Join(parts, "/"))
+	child.Env = append(os.Environ(), "GDAY_TEST_EXIT="+t.Name())
+	err := child.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return 0
+}
+
+// countingResolver resolves calendar names from a fixed list, counting the
+// lookups it's asked for
+type countingResolver struct {
+	names   map[string]string
+	lookups int
+}
+
+func (f *countingResolver) ResolveCalendarID(ctx context.Context, ref string) (string, error) {
+	ids, err := f.ResolveCalendarIDs(ctx, []string{ref})
+	return ids[0], err
+}
+
+func (f *countingResolver) ResolveCalendarIDs(ctx context.Context, refs []string) ([]string, error) {
+	f.lookups++
+	ids := make([]string, len(refs))
+	for i, ref := range refs {
+		ids[i] = ref
+		if id, ok := f.names[ref]; ok {
+			ids[i] = id
+		}
+	}
+	return ids, nil
+}
+
+func TestCheckCalendarWritable(t *testing.T) {
+	withSettings(t, `{"protected_calendars": ["Family", "team@example.com", "Holidays"]}`)
+	names := map[string]string{"Family": "family-id", "Holidays": "holidays-id"}
+
+	tests := []struct {
+		name     string
+		calID    string
+		force    bool
+		wantCode int
+	}{
+		{"protected by name", "family-id", false, ExitUsage},
+		{"protected by ID", "team@example.com", false, ExitUsage},
+		{"protected with --force", "family-id", true, ExitOK},
+		{"not protected", "work-id", false, ExitOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := exitCode(t, func() {
+				if err := calDeleteCmd.Flags().Set("force", strconv.FormatBool(tt.force)); err != nil {
+					t.Fatal(err)
+				}
+				checkCalendarWritable(context.Background(), calDeleteCmd, &countingResolver{names: names}, tt.calID)
+			})
+			if code != tt.wantCode {
+				t.Errorf("exit code = %d, want %d", code, tt.wantCode)
+			}
+		})
+	}
+
+	// Every protected reference is resolved in one lookup
+	srv := &countingResolver{names: names}
+	if err := calendarWritable(context.Background(), srv, "work-id"); err != nil {
+		t.Fatal(err)
+	}
+	if srv.lookups != 1 {
+		t.Errorf("resolved the protected calendars in %d lookups, want 1", srv.lookups)
+	}
+}
+
+// fakeSyncer answers SyncEvents from a map of results by token, recording
+// the tokens it's given; unknown tokens have expired
+type fakeSyncer struct {
+	results map[string]*gdaycal.SyncResult
+	tokens  []string
+}
+
+func (f *fakeSyncer) SyncEvents(ctx context.Context, calendarID, syncToken string) (*gdaycal.SyncResult, error) {
+	f.tokens = append(f.tokens, syncToken)
+	if result, ok := f.results[syncToken]; ok {
+		return result, nil
+	}
+	return nil, gdaycal.ErrSyncTokenExpired
+}
+
+func TestSyncEvents(t *testing.T) {
+	full := &gdaycal.SyncResult{Changed: []*gdaycal.Event{{ID: "a"}, {ID: "b"}}, NextSyncToken: "t1"}
+	changes := &gdaycal.SyncResult{Deleted: []*gdaycal.Event{{ID: "b"}}, NextSyncToken: "t2"}
+
+	tests := []struct {
+		name         string
+		syncToken    string
+		want         *gdaycal.SyncResult
+		wantFullSync bool
+		wantTokens   []string
+	}{
+		{"first run", "", full, true, []string{""}},
+		{"changes since the last run", "t1", changes, false, []string{"t1"}},
+		{"expired token starts over", "t0", full, true, []string{"t0", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeSyncer{results: map[string]*gdaycal.SyncResult{"": full, "t1": changes}}
+			got, fullSync, err := syncEvents(context.Background(), fake, "primary", tt.syncToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("result has next token %q, want %q", got.NextSyncToken, tt.want.NextSyncToken)
+			}
+			if fullSync != tt.wantFullSync {
+				t.Errorf("fullSync = %v, want %v", fullSync, tt.wantFullSync)
+			}
+			if !slices.Equal(fake.tokens, tt.wantTokens) {
+				t.Errorf("synced with tokens %q, want %q", fake.tokens, tt.wantTokens)
+			}
+		})
+	}
+}
+
+func TestParseDateTimeBareTimes(t *testing.T) {
+	today := time.Now()
+	dated, err := parseDate("2025-06-10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(day time.Time, h, m int) time.Time {
+		y, mo, d := day.Date()
+		return time.Date(y, mo, d, h, m, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		input   string
+		day     time.Time // Today, or the --date given
+		want    time.Time
+		wantErr bool
+	}{
+		{input: "14:30", day: today, want: at(today, 14, 30)},
+		{input: "9am", day: today, want: at(today, 9, 0)},
+		{input: "9 AM", day: today, want: at(today, 9, 0)},
+		{input: "2:30pm", day: today, want: at(today, 14, 30)},
+		{input: " 2:30 PM ", day: today, want: at(today, 14, 30)},
+		{input: "12am", day: today, want: at(today, 0, 0)},
+		{input: "14:30", day: dated, want: at(dated, 14, 30)},
+		{input: "9am", day: dated, want: at(dated, 9, 0)},
+		// A full date wins over --date
+		{input: "2025-07-01 09:00", day: dated, want: at(time.Date(2025, 7, 1, 0, 0, 0, 0, time.Local), 9, 0)},
+		{input: "25:00", day: today, wantErr: true},
+		{input: "9", day: today, wantErr: true},
+		{input: "noon", day: today, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseDateTime(tt.input, tt.day)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseDateTime(%q) err = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !got.Equal(tt.want) {
+			t.Errorf("parseDateTime(%q, %s) = %v, want %v", tt.input, tt.day.Format("2006-01-02"), got, tt.want)
+		}
+	}
+}
+
+func TestFilterEvents(t *testing.T) {
+	events := []*gdaycal.Event{
+		{ID: "mine", Busy: true, IsOrganizer: true, ResponseStatus: "accepted"},
+		{ID: "invited", Busy: true, ResponseStatus: "accepted"},
+		{ID: "tentative", Busy: true, ResponseStatus: "tentative"},
+		{ID: "unanswered", Busy: true, ResponseStatus: "needsAction"},
+		{ID: "declined", Busy: true, ResponseStatus: "declined"},
+		{ID: "free-mine", IsOrganizer: true, ResponseStatus: "accepted"},
+		{ID: "free-invited", ResponseStatus: "needsAction"},
+	}
+	tests := []struct {
+		name                             string
+		busyOnly, mineOnly, acceptedOnly bool
+		want                             string
+	}{
+		{"no filters", false, false, false, "mine,invited,tentative,unanswered,declined,free-mine,free-invited"},
+		{"busy only", true, false, false, "mine,invited,tentative,unanswered,declined"},
+		{"mine only", false, true, false, "mine,free-mine"},
+		{"accepted only", false, false, true, "mine,invited,free-mine"},
+		{"busy and accepted", true, false, true, "mine,invited"},
+		{"all three", true, true, true, "mine"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []string
+			for _, e := range filterEvents(events, tt.busyOnly, tt.mineOnly, tt.acceptedOnly) {
+				ids = append(ids, e.ID)
+			}
+			if got := strings.Join(ids, ","); got != tt.want {
+				t.Errorf("filterEvents = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
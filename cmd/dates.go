@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseDate parses a date such as "2024-06-01", or one relative to today
+// (see parseRelativeDate)
+func parseDate(s string) (time.Time, error) {
+	formats := []string{
+		"2006-01-02",
+		"01/02/2006",
+		"Jan 2, 2006",
+	}
+
+	for _, format := range formats {
+		if t, err := time.ParseInLocation(format, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	if t, ok := parseRelativeDate(s, time.Now()); ok {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("unable to parse date: %s", s)
+}
+
+// relativeOffset matches day and week offsets such as "+14d" and "-2w"
+var relativeOffset = regexp.MustCompile(`^([+-]\d+)([dw])$`)
+
+// agoOffset matches offsets into the past such as "3 days ago" and "1 week ago"
+var agoOffset = regexp.MustCompile(`^(\d+)\s+(day|week|month|year)s?\s+ago$`)
+
+// parseRelativeDate parses "today", "tomorrow", "yesterday", an offset in
+// days or weeks ("+14d", "-2w"), a time ago ("3 days ago", "2 weeks ago",
+// "1 month ago"), a weekday ("mon" or "monday", the next one on or after
+// today; "last monday" for the one before today), or "next week" (next
+// Monday), returning midnight on that day
+func parseRelativeDate(s string, now time.Time) (time.Time, bool) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	switch s {
+	case "today":
+		return today, true
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), true
+	case "yesterday":
+		return today.AddDate(0, 0, -1), true
+	case "next week":
+		days := (int(time.Monday) - int(today.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return today.AddDate(0, 0, days), true
+	}
+
+	if m := relativeOffset.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		if m[2] == "w" {
+			n *= 7
+		}
+		return today.AddDate(0, 0, n), true
+	}
+
+	if m := agoOffset.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		switch m[2] {
+		case "day":
+			return today.AddDate(0, 0, -n), true
+		case "week":
+			return today.AddDate(0, 0, -7*n), true
+		case "month":
+			return today.AddDate(0, -n, 0), true
+		default:
+			return today.AddDate(-n, 0, 0), true
+		}
+	}
+
+	if name, ok := strings.CutPrefix(s, "last "); ok {
+		if wd, ok := parseWeekday(name); ok {
+			back := (int(today.Weekday()) - int(wd) + 7) % 7
+			if back == 0 {
+				back = 7
+			}
+			return today.AddDate(0, 0, -back), true
+		}
+	}
+
+	if wd, ok := parseWeekday(s); ok {
+		return today.AddDate(0, 0, (int(wd)-int(today.Weekday())+7)%7), true
+	}
+
+	return time.Time{}, false
+}
+
+// parsePastDate is parseDate for looking back, as mail date filters do: a
+// bare weekday is the most recent one, today included, rather than the next
+func parsePastDate(s string) (time.Time, error) {
+	if wd, ok := parseWeekday(s); ok {
+		now := time.Now()
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+		return today.AddDate(0, 0, -((int(today.Weekday()) - int(wd) + 7) % 7)), nil
+	}
+	return parseDate(s)
+}
+
+// parseWeekday parses a weekday name, in full or abbreviated to three letters
+func parseWeekday(s string) (time.Weekday, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		name := strings.ToLower(wd.String())
+		if s == name || s == name[:3] {
+			return wd, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/joncooper/gday/internal/auth"
+	gdaycal "github.com/joncooper/gday/internal/calendar"
+	"github.com/joncooper/gday/internal/config"
+	gdaygmail "github.com/joncooper/gday/internal/gmail"
+	"github.com/spf13/cobra"
+	"golang.org/x/oauth2"
+)
+
+// doctorCheck is the outcome of one diagnostic check
+type doctorCheck struct {
+	Name   string
+	OK     bool
+	Detail string
+	Hint   string
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check configuration, authentication, and API access",
+	Long: `Run a series of checks to diagnose setup problems.
+
+Checks that the config directory is private and writable, the OAuth
+credentials parse, the token is valid or refreshable, the required scopes
+are granted, and the Gmail and Calendar APIs respond.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := newContext()
+		defer cancel()
+
+		checks := runDoctorChecks(ctx)
+
+		ok := true
+		for _, c := range checks {
+			ok = ok && c.OK
+		}
+
+		if isJSONOutput() {
+			result := DoctorJSON{OK: ok}
+			for _, c := range checks {
+				result.Checks = append(result.Checks, DoctorCheckJSON{
+					Name:   c.Name,
+					OK:     c.OK,
+					Detail: c.Detail,
+					Hint:   c.Hint,
+				})
+			}
+			outputJSON(result)
+		} else {
+			for _, c := range checks {
+				mark := "✓"
+				if !c.OK {
+					mark = "✗"
+				}
+				fmt.Printf("%s %s", mark, c.Name)
+				if c.Detail != "" {
+					fmt.Printf(": %s", c.Detail)
+				}
+				fmt.Println()
+				if !c.OK && c.Hint != "" {
+					fmt.Printf("    %s\n", c.Hint)
+				}
+			}
+		}
+
+		if !ok {
+			os.Exit(ExitError)
+		}
+	},
+}
+
+// runDoctorChecks runs each check in order, stopping at the first failure
+// that later checks depend on
+func runDoctorChecks(ctx context.Context) []doctorCheck {
+	var checks []doctorCheck
+
+	checks = append(checks, checkConfigDir())
+
+	creds := checkCredentials()
+	checks = append(checks, creds)
+	if !creds.OK {
+		return checks
+	}
+
+	tokenCheck, token, client := checkToken(ctx)
+	checks = append(checks, tokenCheck)
+	if !tokenCheck.OK {
+		return checks
+	}
+
+	checks = append(checks, checkScopes(ctx, token))
+	checks = append(checks, checkGmail(ctx, client))
+	checks = append(checks, checkCalendar(ctx, client))
+	return checks
+}
+
+func checkConfigDir() doctorCheck {
+	check := doctorCheck{Name: "Config directory"}
+	dir, err := config.CheckConfigDir()
+	if err != nil {
+		check.Detail = err.Error()
+		check.Hint = "Run 'chmod 700 ~/.gday' and make sure the directory is owned by you"
+		return check
+	}
+	check.OK = true
+	check.Detail = dir
+	return check
+}
+
+func checkCredentials() doctorCheck {
+	check := doctorCheck{Name: "OAuth credentials"}
+	if err := auth.CheckCredentials(); err != nil {
+		check.Detail = strings.SplitN(err.Error(), "\n", 2)[0]
+		check.Hint = "Run 'gday auth setup' with the JSON downloaded from Google Cloud Console"
+		return check
+	}
+	check.OK = true
+	check.Detail, _ = config.CredentialsSource()
+	return check
+}
+
+func checkToken(ctx context.Context) (doctorCheck, *oauth2.Token, *http.Client) {
+	check := doctorCheck{Name: "Token"}
+	token, err := auth.Token(ctx)
+	if errors.Is(err, config.ErrPassphraseRequired) || errors.Is(err, config.ErrWrongPassphrase) {
+		check.Detail = "encrypted"
+		check.Hint = err.Error()
+		return check, nil, nil
+	}
+	if err != nil {
+		check.Detail = "missing, expired, or not refreshable"
+		check.Hint = "Run 'gday auth login' to authenticate"
+		return check, nil, nil
+	}
+	client, err := auth.GetClient(ctx)
+	if err != nil {
+		check.Detail = err.Error()
+		check.Hint = "Run 'gday auth login' to authenticate"
+		return check, nil, nil
+	}
+	check.OK = true
+	check.Detail = "valid until " + token.Expiry.Local().Format("Jan 2 15:04")
+	if config.TokenEncrypted() {
+		check.Detail += " (encrypted)"
+	}
+	return check, token, client
+}
+
+func checkScopes(ctx context.Context, token *oauth2.Token) doctorCheck {
+	check := doctorCheck{Name: "Scopes"}
+	granted, err := auth.GrantedScopes(ctx, token)
+	if err != nil {
+		check.Detail = err.Error()
+		check.Hint = "Run 'gday auth login' to re-authenticate"
+		return check
+	}
+	if missing := auth.MissingScopes(granted); len(missing) > 0 {
+		check.Detail = "missing " + strings.Join(missing, ", ")
+		check.Hint = "Run 'gday auth login' and grant all requested permissions"
+		return check
+	}
+	check.OK = true
+	check.Detail = fmt.Sprintf("%d granted", len(granted))
+	return check
+}
+
+func checkGmail(ctx context.Context, client *http.Client) doctorCheck {
+	check := doctorCheck{Name: "Gmail API"}
+	srv, err := gdaygmail.NewService(ctx, client)
+	if err == nil {
+		var email string
+		if email, err = srv.GetProfileEmail(ctx); err == nil {
+			check.OK = true
+			check.Detail = email
+			return check
+		}
+	}
+	check.Detail = err.Error()
+	check.Hint = "Make sure the Gmail API is enabled for your Google Cloud project"
+	return check
+}
+
+func checkCalendar(ctx context.Context, client *http.Client) doctorCheck {
+	check := doctorCheck{Name: "Calendar API"}
+	srv, err := gdaycal.NewService(ctx, client)
+	if err == nil {
+		var calendars []*gdaycal.Calendar
+		if calendars, err = srv.ListCalendars(ctx); err == nil {
+			check.OK = true
+			check.Detail = fmt.Sprintf("%d calendars", len(calendars))
+			return check
+		}
+	}
+	check.Detail = err.Error()
+	check.Hint = "Make sure the Google Calendar API is enabled for your Google Cloud project"
+	return check
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
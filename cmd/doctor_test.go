@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joncooper/gday/internal/auth"
+	"github.com/joncooper/gday/internal/config"
+	"golang.org/x/oauth2"
+)
+
+// fakeGoogle answers the token info, Gmail profile, and calendar list
+// requests the doctor checks make. A non-zero status fails that endpoint.
+type fakeGoogle struct {
+	scopes          []string
+	tokenInfoStatus int
+	gmailStatus     int
+	calendarStatus  int
+}
+
+func (f *fakeGoogle) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fail := func(status int) bool {
+		if status == 0 {
+			return false
+		}
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"error":{"code":%d,"message":"API has not been used in this project"}}`, status)
+		return true
+	}
+	switch {
+	case r.URL.Path == "/tokeninfo":
+		if !fail(f.tokenInfoStatus) {
+			json.NewEncoder(w).Encode(map[string]string{"scope": strings.Join(f.scopes, " ")})
+		}
+	case strings.HasSuffix(r.URL.Path, "/users/me/profile"):
+		if !fail(f.gmailStatus) {
+			fmt.Fprint(w, `{"emailAddress":"ana@example.com"}`)
+		}
+	case strings.HasSuffix(r.URL.Path, "/users/me/calendarList"):
+		if !fail(f.calendarStatus) {
+			fmt.Fprint(w, `{"items":[{"id":"ana@example.com","primary":true},{"id":"team@group.calendar.google.com"}]}`)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// redirectTransport sends every request to host instead
+type redirectTransport struct {
+	base http.RoundTripper
+	host string
+}
+
+func (rt redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = "http"
+	req.URL.Host = rt.host
+	return rt.base.RoundTrip(req)
+}
+
+// withFakeGoogle sends requests made through the default transport to fake
+// instead of Google for a test
+func withFakeGoogle(t *testing.T, fake *fakeGoogle) {
+	t.Helper()
+	ts := httptest.NewServer(fake)
+	t.Cleanup(ts.Close)
+	base := http.DefaultTransport
+	http.DefaultTransport = redirectTransport{base: base, host: ts.Listener.Addr().String()}
+	t.Cleanup(func() { http.DefaultTransport = base })
+}
+
+// withCredentials configures OAuth credentials in the environment
+func withCredentials(t *testing.T) {
+	t.Helper()
+	t.Setenv(config.CredentialsEnv, `{"installed":{"client_id":"id","client_secret":"secret",`+
+		`"auth_uri":"https://accounts.google.com/o/oauth2/auth","token_uri":"https://oauth2.googleapis.com/token","redirect_uris":["http://localhost"]}}`)
+}
+
+func TestDoctorChecks(t *testing.T) {
+	ctx := context.Background()
+	token := &oauth2.Token{AccessToken: "access", RefreshToken: "refresh", TokenType: "Bearer"}
+
+	tests := []struct {
+		name       string
+		setup      func(t *testing.T, fake *fakeGoogle)
+		check      func() doctorCheck
+		wantOK     bool
+		wantDetail string
+	}{
+		{
+			name:       "config directory private",
+			check:      checkConfigDir,
+			wantOK:     true,
+			wantDetail: ".gday",
+		},
+		{
+			name: "config directory readable by others",
+			setup: func(t *testing.T, fake *fakeGoogle) {
+				dir, err := config.GetConfigDir()
+				if err != nil {
+					t.Fatal(err)
+				}
+				os.Chmod(dir, 0o755)
+			},
+			check:      checkConfigDir,
+			wantDetail: "permissions 0755",
+		},
+		{
+			name:       "credentials parse",
+			setup:      func(t *testing.T, fake *fakeGoogle) { withCredentials(t) },
+			check:      checkCredentials,
+			wantOK:     true,
+			wantDetail: "$" + config.CredentialsEnv,
+		},
+		{
+			name:       "no credentials",
+			check:      checkCredentials,
+			wantDetail: "unable to read credentials file",
+		},
+		{
+			name: "credentials don't parse",
+			setup: func(t *testing.T, fake *fakeGoogle) {
+				t.Setenv(config.CredentialsEnv, `{"client_id":"id"}`)
+			},
+			check:      checkCredentials,
+			wantDetail: "unable to parse credentials",
+		},
+		{
+			name: "token valid",
+			setup: func(t *testing.T, fake *fakeGoogle) {
+				withCredentials(t)
+				valid := *token
+				valid.Expiry = time.Now().Add(time.Hour)
+				if err := config.SaveToken(&valid); err != nil {
+					t.Fatal(err)
+				}
+			},
+			check: func() doctorCheck {
+				check, _, _ := checkToken(ctx)
+				return check
+			},
+			wantOK:     true,
+			wantDetail: "valid until",
+		},
+		{
+			name:  "no token",
+			setup: func(t *testing.T, fake *fakeGoogle) { withCredentials(t) },
+			check: func() doctorCheck {
+				check, _, _ := checkToken(ctx)
+				return check
+			},
+			wantDetail: "missing, expired, or not refreshable",
+		},
+		{
+			name:       "all scopes granted",
+			setup:      func(t *testing.T, fake *fakeGoogle) { fake.scopes = auth.Scopes },
+			check:      func() doctorCheck { return checkScopes(ctx, token) },
+			wantOK:     true,
+			wantDetail: fmt.Sprintf("%d granted", len(auth.Scopes)),
+		},
+		{
+			name:       "scope missing",
+			setup:      func(t *testing.T, fake *fakeGoogle) { fake.scopes = auth.Scopes[1:] },
+			check:      func() doctorCheck { return checkScopes(ctx, token) },
+			wantDetail: "missing " + auth.Scopes[0],
+		},
+		{
+			name:       "token info rejected",
+			setup:      func(t *testing.T, fake *fakeGoogle) { fake.tokenInfoStatus = http.StatusBadRequest },
+			check:      func() doctorCheck { return checkScopes(ctx, token) },
+			wantDetail: "token info request failed",
+		},
+		{
+			name:       "Gmail responds",
+			check:      func() doctorCheck { return checkGmail(ctx, &http.Client{}) },
+			wantOK:     true,
+			wantDetail: "ana@example.com",
+		},
+		{
+			name:       "Gmail API disabled",
+			setup:      func(t *testing.T, fake *fakeGoogle) { fake.gmailStatus = http.StatusForbidden },
+			check:      func() doctorCheck { return checkGmail(ctx, &http.Client{}) },
+			wantDetail: "has not been used",
+		},
+		{
+			name:       "Calendar responds",
+			check:      func() doctorCheck { return checkCalendar(ctx, &http.Client{}) },
+			wantOK:     true,
+			wantDetail: "2 calendars",
+		},
+		{
+			name:       "Calendar API disabled",
+			setup:      func(t *testing.T, fake *fakeGoogle) { fake.calendarStatus = http.StatusForbidden },
+			check:      func() doctorCheck { return checkCalendar(ctx, &http.Client{}) },
+			wantDetail: "has not been used",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			t.Setenv(config.CredentialsEnv, "")
+			t.Setenv(config.CredentialsFileEnv, "")
+			fake := &fakeGoogle{}
+			withFakeGoogle(t, fake)
+			if tt.setup != nil {
+				tt.setup(t, fake)
+			}
+
+			check := tt.check()
+			if check.OK != tt.wantOK {
+				t.Errorf("OK = %v, want %v (detail %q)", check.OK, tt.wantOK, check.Detail)
+			}
+			if !strings.Contains(check.Detail, tt.wantDetail) {
+				t.Errorf("detail = %q, want it to mention %q", check.Detail, tt.wantDetail)
+			}
+			if !check.OK && check.Hint == "" {
+				t.Error("failed check has no hint")
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// Output formats for --format
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+	formatYAML  = "yaml"
+)
+
+// outputFormat is the --format flag; --json is short for --format json
+var outputFormat string
+
+// csvAnnotation marks the commands that can write CSV, which needs them to
+// lay their results out as a table
+const csvAnnotation = "gday/csv"
+
+// resolveOutputFormat folds --json into --format and checks the format is
+// known
+func resolveOutputFormat() error {
+	switch outputFormat {
+	case formatTable, formatJSON, formatCSV, formatYAML:
+	default:
+		return fmt.Errorf("invalid --format %q (use table, json, csv, or yaml)", outputFormat)
+	}
+	if jsonOutput {
+		if rootCmd.PersistentFlags().Changed("format") && outputFormat != formatJSON {
+			return fmt.Errorf("--json can't be combined with --format %s", outputFormat)
+		}
+		outputFormat = formatJSON
+	}
+	return nil
+}
+
+// checkOutputFormat rejects --format csv for commands that can't produce it,
+// before they do anything
+func checkOutputFormat(cmd *cobra.Command) error {
+	if outputFormat == formatCSV && cmd.Annotations[csvAnnotation] == "" {
+		return fmt.Errorf("--format csv isn't supported by '%s'; use json or yaml", cmd.CommandPath())
+	}
+	return nil
+}
+
+// table is a command's results as rows of cells, for table and CSV output
+type table struct {
+	columns []column
+	rows    [][]string
+}
+
+// column is a table column. Its name is the CSV header, and upper-cased,
+// the table header.
+type column struct {
+	name  string
+	width int // Longest cell shown in a table; 0 for no limit
+}
+
+// output writes v, one of the JSON output types, in the --format given.
+// rows lays the same results out as a table; csv is set when the table is
+// for CSV, which wants complete, machine-readable values rather than the
+// abbreviated ones shown in a terminal.
+func output(v any, rows func(csv bool) *table) {
+	switch outputFormat {
+	case formatJSON, formatYAML:
+		outputJSON(v)
+	case formatCSV:
+		if err := writeCSV(os.Stdout, rows(true)); err != nil {
+			exitError("failed to write CSV: %v", err)
+		}
+	default:
+		writeTable(os.Stdout, rows(false))
+	}
+}
+
+// cellBreaks replaces the characters that would break a table's alignment
+var cellBreaks = strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ", "\r", " ")
+
+// writeTable writes t as aligned columns under an upper-case header
+func writeTable(w io.Writer, t *table) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		header[i] = strings.ToUpper(strings.ReplaceAll(c.name, "_", " "))
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range t.rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cell = cellBreaks.Replace(cell)
+			if i < len(t.columns) && t.columns[i].width > 0 {
+				cell = truncate(cell, t.columns[i].width)
+			}
+			cells[i] = cell
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	tw.Flush()
+}
+
+// writeCSV writes t as CSV with a header row
+func writeCSV(w io.Writer, t *table) error {
+	cw := csv.NewWriter(w)
+	header := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		header[i] = c.name
+	}
+	cw.Write(header)
+	for _, row := range t.rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = csvCell(cell)
+		}
+		cw.Write(cells)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvCell keeps a spreadsheet from reading a cell as a formula. Cells come
+// from mail headers and event titles anyone can set, so one starting with
+// =, +, -, @, a tab, or a carriage return gets a leading quote.
+func csvCell(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// writeYAML writes data as YAML. It goes through the JSON encoding so the
+// JSON output types' field names and order carry over.
+func writeYAML(w io.Writer, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	value, err := decodeOrdered(dec)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	if isYAMLBlock(value) {
+		writeYAMLValue(&b, value, 0)
+	} else {
+		b.WriteString(yamlScalar(value) + "\n")
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// orderedObject is a JSON object with its keys in their original order
+type orderedObject struct {
+	keys   []string
+	values map[string]any
+}
+
+// decodeOrdered decodes the next JSON value, keeping object key order.
+// Scalars are strings, json.Numbers, bools, or nil.
+func decodeOrdered(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := &orderedObject{values: map[string]any{}}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := keyTok.(string)
+			value, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj.keys = append(obj.keys, key)
+			obj.values[key] = value
+		}
+		_, err := dec.Token()
+		return obj, err
+	case json.Delim('['):
+		list := []any{}
+		for dec.More() {
+			value, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err := dec.Token()
+		return list, err
+	}
+	return tok, nil
+}
+
+// writeYAMLValue writes a non-empty object or list in block style
+func writeYAMLValue(b *strings.Builder, value any, indent int) {
+	pad := strings.Repeat("  ", indent)
+	switch v := value.(type) {
+	case *orderedObject:
+		for _, key := range v.keys {
+			child := v.values[key]
+			b.WriteString(pad + yamlScalar(key) + ":")
+			writeYAMLChild(b, child, indent+1)
+		}
+	case []any:
+		for _, item := range v {
+			b.WriteString(pad + "-")
+			if obj, ok := item.(*orderedObject); ok && isYAMLBlock(obj) {
+				// The first key goes on the dash's line, the rest line up with it
+				var nested strings.Builder
+				writeYAMLValue(&nested, obj, indent+1)
+				b.WriteString(" " + strings.TrimPrefix(nested.String(), strings.Repeat("  ", indent+1)))
+				continue
+			}
+			writeYAMLChild(b, item, indent+1)
+		}
+	}
+}
+
+// writeYAMLChild finishes a "key:" or "-" line with a scalar, or starts a
+// nested block below it
+func writeYAMLChild(b *strings.Builder, value any, indent int) {
+	if isYAMLBlock(value) {
+		b.WriteString("\n")
+		writeYAMLValue(b, value, indent)
+		return
+	}
+	b.WriteString(" " + yamlScalar(value) + "\n")
+}
+
+// isYAMLBlock reports whether value is written as a block of lines: a
+// non-empty object or list. Everything else fits on one line.
+func isYAMLBlock(value any) bool {
+	switch v := value.(type) {
+	case *orderedObject:
+		return len(v.keys) > 0
+	case []any:
+		return len(v) > 0
+	}
+	return false
+}
+
+// yamlPlain matches strings that can be written without quotes
+var yamlPlain = regexp.MustCompile(`^[A-Za-z_./][A-Za-z0-9 _./@+()-]*$`)
+
+// yamlReserved are plain words YAML would read as something other than a string
+var yamlReserved = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true, "off": true,
+	"null": true, "y": true, "n": true,
+}
+
+// yamlScalar formats a scalar, or an empty object or list
+func yamlScalar(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case json.Number:
+		return v.String()
+	case string:
+		if yamlPlain.MatchString(v) && !strings.HasSuffix(v, " ") && !yamlReserved[strings.ToLower(v)] {
+			return v
+		}
+		// A JSON string is a valid YAML double-quoted scalar
+		var quoted bytes.Buffer
+		enc := json.NewEncoder(&quoted)
+		enc.SetEscapeHTML(false)
+		enc.Encode(v)
+		return strings.TrimSuffix(quoted.String(), "\n")
+	case *orderedObject:
+		return "{}"
+	case []any:
+		return "[]"
+	}
+	return fmt.Sprint(value)
+}
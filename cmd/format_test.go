@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestWriteCSVEscapesFormulas(t *testing.T) {
+	tests := []struct {
+		cell string
+		want string
+	}{
+		{"Hello", "Hello"},
+		{"", ""},
+		{"=HYPERLINK(\"http://evil\")", "\"'=HYPERLINK(\"\"http://evil\"\")\""},
+		{"+1 555", "'+1 555"},
+		{"-2", "'-2"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"a=b", "a=b"},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := writeCSV(&b, &table{columns: []column{{name: "subject"}}, rows: [][]string{{tt.cell}}}); err != nil {
+			t.Fatalf("writeCSV(%q): %v", tt.cell, err)
+		}
+		want := "subject\n" + tt.want + "\n"
+		if b.String() != want {
+			t.Errorf("writeCSV(%q) = %q, want %q", tt.cell, b.String(), want)
+		}
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestWriteCSVReportsWriteErrors(t *testing.T) {
+	err := writeCSV(failingWriter{}, &table{columns: []column{{name: "id"}}, rows: [][]string{{"1"}}})
+	if err == nil {
+		t.Fatal("writeCSV to a failing writer returned nil")
+	}
+}
+
+func TestWriteYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		data any
+		want string
+	}{
+		{"scalar", "hello", "hello\n"},
+		{"reserved word", "yes", "\"yes\"\n"},
+		{"object keeps order", struct {
+			B string `json:"b"`
+			A int    `json:"a"`
+		}{"x", 1}, "b: x\na: 1\n"},
+		{"list of objects", []struct {
+			ID string `json:"id"`
+			N  int    `json:"count"`
+		}{{"a", 1}, {"b", 2}}, "- id: a\n  count: 1\n- id: b\n  count: 2\n"},
+		{"empty list", map[string][]string{"items": {}}, "items: []\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := writeYAML(&b, tt.data); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("writeYAML = %q, want %q", b.String(), tt.want)
+			}
+		})
+	}
+}
@@ -6,19 +6,40 @@ import "time"
 
 // MessageJSON represents a message in JSON output
 type MessageJSON struct {
-	ID        string           `json:"id"`
-	ThreadID  string           `json:"thread_id"`
-	Date      time.Time        `json:"date"`
-	From      string           `json:"from"`
-	To        string           `json:"to"`
-	Subject   string           `json:"subject"`
-	Snippet   string           `json:"snippet,omitempty"`
-	Body      string           `json:"body,omitempty"`
-	Labels    []string         `json:"labels,omitempty"`
-	IsUnread  bool             `json:"is_unread"`
+	ID          string           `json:"id"`
+	ThreadID    string           `json:"thread_id"`
+	Date        time.Time        `json:"date"`
+	From        string           `json:"from"`
+	To          string           `json:"to"`
+	Subject     string           `json:"subject"`
+	Snippet     string           `json:"snippet,omitempty"`
+	Body        string           `json:"body,omitempty"`
+	BodyHTML    string           `json:"body_html,omitempty"`
+	Labels      []string         `json:"labels,omitempty"`
+	IsUnread    bool             `json:"is_unread"`
+	IsImportant bool             `json:"is_important"`
+	Priority    string           `json:"priority,omitempty"`
 	Attachments []AttachmentJSON `json:"attachments,omitempty"`
 }
 
+// HeadersJSON represents all headers of a message, keyed by header name
+type HeadersJSON struct {
+	ID      string              `json:"id"`
+	Headers map[string][]string `json:"headers"`
+}
+
+// LinkJSON represents a hyperlink found in a message body
+type LinkJSON struct {
+	Text string `json:"text,omitempty"`
+	Href string `json:"href"`
+}
+
+// LinksJSON represents the links extracted from a message
+type LinksJSON struct {
+	Count int        `json:"count"`
+	Links []LinkJSON `json:"links"`
+}
+
 // AttachmentJSON represents an attachment in JSON output
 type AttachmentJSON struct {
 	ID       string `json:"id"`
@@ -27,10 +48,31 @@ type AttachmentJSON struct {
 	Size     int64  `json:"size"`
 }
 
+// AttachmentsListJSON represents the attachments of a message
+type AttachmentsListJSON struct {
+	MessageID   string           `json:"message_id"`
+	Attachments []AttachmentJSON `json:"attachments"`
+}
+
+// DownloadsJSON represents the result of downloading attachments
+type DownloadsJSON struct {
+	MessageID string         `json:"message_id"`
+	Downloads []DownloadJSON `json:"downloads"`
+	Skipped   []string       `json:"skipped,omitempty"`
+}
+
+// DownloadJSON represents a downloaded attachment
+type DownloadJSON struct {
+	Filename string `json:"filename"`
+	Path     string `json:"path,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
 // MessagesListJSON represents a list of messages
 type MessagesListJSON struct {
-	Count    int           `json:"count"`
-	Messages []MessageJSON `json:"messages"`
+	Count         int           `json:"count"`
+	Messages      []MessageJSON `json:"messages"`
+	NextPageToken string        `json:"next_page_token,omitempty"`
 }
 
 // ThreadJSON represents a thread in JSON output
@@ -40,11 +82,48 @@ type ThreadJSON struct {
 	Messages []MessageJSON `json:"messages"`
 }
 
+// ThreadManifestJSON describes a thread saved with mail thread --save
+type ThreadManifestJSON struct {
+	ThreadID string             `json:"thread_id"`
+	Subject  string             `json:"subject"`
+	SavedAt  time.Time          `json:"saved_at"`
+	Messages []SavedMessageJSON `json:"messages"`
+}
+
+// ExportJSON describes messages written by mail export
+type ExportJSON struct {
+	Path     string   `json:"path"`
+	Format   string   `json:"format"`
+	Count    int      `json:"count"`
+	Messages []string `json:"messages"`
+	Failed   []string `json:"failed,omitempty"`
+}
+
+// SavedMessageJSON describes one saved message and its attachments
+type SavedMessageJSON struct {
+	ID          string                `json:"id"`
+	From        string                `json:"from"`
+	Date        time.Time             `json:"date"`
+	Subject     string                `json:"subject"`
+	File        string                `json:"file"`
+	Attachments []SavedAttachmentJSON `json:"attachments,omitempty"`
+}
+
+// SavedAttachmentJSON describes a saved attachment; Path is relative to
+// the save directory
+type SavedAttachmentJSON struct {
+	Filename string `json:"filename"`
+	Path     string `json:"path"`
+	MimeType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+}
+
 // SearchResultJSON represents search results
 type SearchResultJSON struct {
-	Query    string        `json:"query"`
-	Count    int           `json:"count"`
-	Messages []MessageJSON `json:"messages"`
+	Query         string        `json:"query"`
+	Count         int           `json:"count"`
+	Messages      []MessageJSON `json:"messages"`
+	NextPageToken string        `json:"next_page_token,omitempty"`
 }
 
 // SendResultJSON represents the result of sending a message
@@ -53,33 +132,144 @@ type SendResultJSON struct {
 	Status    string `json:"status"`
 }
 
+// DraftJSON represents a draft and its message
+type DraftJSON struct {
+	ID      string       `json:"id"`
+	Message *MessageJSON `json:"message,omitempty"`
+}
+
+// DraftsJSON represents the drafts list
+type DraftsJSON struct {
+	Count  int         `json:"count"`
+	Drafts []DraftJSON `json:"drafts"`
+}
+
 // LabelsJSON represents labels list
 type LabelsJSON struct {
 	Labels []string `json:"labels"`
 }
 
+// LabelJSON represents a label with its type and counts
+type LabelJSON struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	Type            string `json:"type"`
+	MessagesTotal   int64  `json:"messages_total"`
+	MessagesUnread  int64  `json:"messages_unread"`
+	BackgroundColor string `json:"background_color,omitempty"`
+	TextColor       string `json:"text_color,omitempty"`
+}
+
+// LabelsDetailedJSON represents the detailed labels listing
+type LabelsDetailedJSON struct {
+	Labels []LabelJSON `json:"labels"`
+}
+
+// AliasJSON represents a send-as address
+type AliasJSON struct {
+	Email       string `json:"email"`
+	DisplayName string `json:"display_name,omitempty"`
+	Primary     bool   `json:"primary"`
+	Default     bool   `json:"default"`
+	Verified    bool   `json:"verified"`
+}
+
+// AliasesJSON represents the send-as addresses list
+type AliasesJSON struct {
+	Aliases []AliasJSON `json:"aliases"`
+}
+
+// VacationJSON represents the vacation responder setting
+type VacationJSON struct {
+	Enabled      bool   `json:"enabled"`
+	Subject      string `json:"subject"`
+	Body         string `json:"body"`
+	BodyHTML     string `json:"body_html,omitempty"`
+	ContactsOnly bool   `json:"contacts_only"`
+	DomainOnly   bool   `json:"domain_only"`
+	Start        string `json:"start,omitempty"`
+	End          string `json:"end,omitempty"`
+}
+
+// FilterJSON represents a Gmail filter
+type FilterJSON struct {
+	ID            string   `json:"id"`
+	From          string   `json:"from,omitempty"`
+	To            string   `json:"to,omitempty"`
+	Subject       string   `json:"subject,omitempty"`
+	Query         string   `json:"query,omitempty"`
+	NegatedQuery  string   `json:"negated_query,omitempty"`
+	HasAttachment bool     `json:"has_attachment,omitempty"`
+	AddLabels     []string `json:"add_labels,omitempty"`
+	RemoveLabels  []string `json:"remove_labels,omitempty"`
+	Forward       string   `json:"forward,omitempty"`
+}
+
+// FiltersJSON represents the filters list
+type FiltersJSON struct {
+	Filters []FilterJSON `json:"filters"`
+}
+
 // JSON output types for Calendar
 
 // EventJSON represents a calendar event in JSON output
 type EventJSON struct {
-	ID          string    `json:"id"`
-	CalendarID  string    `json:"calendar_id,omitempty"`
-	Summary     string    `json:"summary"`
-	Description string    `json:"description,omitempty"`
-	Location    string    `json:"location,omitempty"`
-	Start       time.Time `json:"start"`
-	End         time.Time `json:"end"`
-	AllDay      bool      `json:"all_day"`
-	Attendees   []string  `json:"attendees,omitempty"`
-	Status      string    `json:"status,omitempty"`
-	HtmlLink    string    `json:"html_link,omitempty"`
-	Recurring   bool      `json:"recurring"`
+	ID          string                `json:"id"`
+	CalendarID  string                `json:"calendar_id,omitempty"`
+	Summary     string                `json:"summary"`
+	Description string                `json:"description,omitempty"`
+	Location    string                `json:"location,omitempty"`
+	Start       time.Time             `json:"start"`
+	End         time.Time             `json:"end"`
+	AllDay      bool                  `json:"all_day"`
+	LastDay     string                `json:"last_day,omitempty"` // All-day events: the last day covered; End is exclusive
+	Attendees   []string              `json:"attendees,omitempty"`
+	Status      string                `json:"status,omitempty"`
+	HtmlLink    string                `json:"html_link,omitempty"`
+	Recurring   bool                  `json:"recurring"`
+	Recurrence  []string              `json:"recurrence,omitempty"`
+	Busy        bool                  `json:"busy"`
+	Attachments []EventAttachmentJSON `json:"attachments,omitempty"`
+	Organizer   string                `json:"organizer,omitempty"`
+	Response    string                `json:"response_status,omitempty"`
+	MeetLink    string                `json:"meet_link,omitempty"`
+	Reminders   []ReminderJSON        `json:"reminders,omitempty"` // Overrides of the calendar's default reminders
+}
+
+// ReminderJSON represents a reminder sent before an event
+type ReminderJSON struct {
+	Method  string `json:"method"`
+	Minutes int64  `json:"minutes"`
+}
+
+// EventAttachmentJSON represents a file attached to an event
+type EventAttachmentJSON struct {
+	Title    string `json:"title"`
+	FileURL  string `json:"file_url"`
+	MimeType string `json:"mime_type,omitempty"`
 }
 
 // EventsListJSON represents a list of events
 type EventsListJSON struct {
 	Count  int         `json:"count"`
 	Events []EventJSON `json:"events"`
+	Tasks  []TaskJSON  `json:"tasks,omitempty"`
+}
+
+// TaskJSON represents a Google Tasks task in JSON output
+type TaskJSON struct {
+	ID        string `json:"id"`
+	ListID    string `json:"list_id"`
+	Title     string `json:"title"`
+	Notes     string `json:"notes,omitempty"`
+	Due       string `json:"due,omitempty"`
+	Completed bool   `json:"completed"`
+}
+
+// TasksListJSON represents a list of tasks
+type TasksListJSON struct {
+	Count int        `json:"count"`
+	Tasks []TaskJSON `json:"tasks"`
 }
 
 // CalendarJSON represents a calendar in JSON output
@@ -97,10 +287,134 @@ type CalendarsListJSON struct {
 
 // EventCreatedJSON represents the result of creating an event
 type EventCreatedJSON struct {
-	ID       string `json:"id"`
-	Summary  string `json:"summary"`
-	HtmlLink string `json:"html_link,omitempty"`
-	Status   string `json:"status"`
+	ID          string      `json:"id"`
+	Summary     string      `json:"summary"`
+	HtmlLink    string      `json:"html_link,omitempty"`
+	MeetLink    string      `json:"meet_link,omitempty"`
+	Status      string      `json:"status"`
+	Occurrences []EventJSON `json:"occurrences,omitempty"`
+}
+
+// AuthStatusJSON represents the authentication status
+type AuthStatusJSON struct {
+	Account       string `json:"account"`
+	Configured    bool   `json:"configured"`
+	LoggedIn      bool   `json:"logged_in"`
+	Authenticated bool   `json:"authenticated"`
+	Email         string `json:"email,omitempty"`
+	Problem       string `json:"problem,omitempty"`
+	Hint          string `json:"hint,omitempty"`
+}
+
+// IntervalJSON represents a busy time block
+type IntervalJSON struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// FreeBusyJSON represents busy blocks per calendar
+type FreeBusyJSON struct {
+	TimeMin   time.Time                 `json:"time_min"`
+	TimeMax   time.Time                 `json:"time_max"`
+	Calendars map[string][]IntervalJSON `json:"calendars"`
+}
+
+// FreeSlotsJSON represents candidate meeting times, best first
+type FreeSlotsJSON struct {
+	Calendars       []string       `json:"calendars"`
+	DurationMinutes int            `json:"duration_minutes"`
+	Slots           []IntervalJSON `json:"slots"`
+}
+
+// ConflictJSON represents a pair of overlapping events
+type ConflictJSON struct {
+	Events [2]EventJSON `json:"events"`
+}
+
+// ConflictsJSON represents the overlapping events in a time range
+type ConflictsJSON struct {
+	Count     int            `json:"count"`
+	Conflicts []ConflictJSON `json:"conflicts"`
+}
+
+// InviteResultJSON represents the result of emailing an event invitation
+type InviteResultJSON struct {
+	EventID    string   `json:"event_id"`
+	MessageID  string   `json:"message_id"`
+	Recipients []string `json:"recipients"`
+	Status     string   `json:"status"`
+}
+
+// SyncJSON represents the changes returned by an incremental sync
+type SyncJSON struct {
+	FullSync bool        `json:"full_sync"`
+	Changed  []EventJSON `json:"changed"`
+	Deleted  []string    `json:"deleted"`
+}
+
+// BusyJSON represents whether the user is in a meeting right now
+type BusyJSON struct {
+	Busy   bool       `json:"busy"`
+	Event  *EventJSON `json:"event,omitempty"`
+	Until  *time.Time `json:"until,omitempty"`
+	Status string     `json:"status,omitempty"`
+}
+
+// NextEventJSON represents the next upcoming event; Event is null if there
+// is none
+type NextEventJSON struct {
+	Event           *EventJSON `json:"event"`
+	StartsInSeconds int64      `json:"starts_in_seconds"`
+}
+
+// CalStatsJSON represents meeting statistics over a time range
+type CalStatsJSON struct {
+	TimeMin           time.Time          `json:"time_min"`
+	TimeMax           time.Time          `json:"time_max"`
+	Meetings          int                `json:"meetings"`
+	TotalHours        float64            `json:"total_hours"`
+	AverageMinutes    float64            `json:"average_minutes"`
+	BusiestDay        string             `json:"busiest_day,omitempty"`
+	BusiestDayHours   float64            `json:"busiest_day_hours"`
+	OneOnOnes         int                `json:"one_on_ones"`
+	GroupMeetings     int                `json:"group_meetings"`
+	Solo              int                `json:"solo"`
+	BackToBackStreaks int                `json:"back_to_back_streaks"`
+	LongestStreak     int                `json:"longest_streak"`
+	DailyHours        map[string]float64 `json:"daily_hours"`
+}
+
+// AccountJSON represents a configured account
+type AccountJSON struct {
+	Name     string `json:"name"`
+	LoggedIn bool   `json:"logged_in"`
+	Active   bool   `json:"active"`
+}
+
+// AccountsJSON represents the list of configured accounts
+type AccountsJSON struct {
+	Active   string        `json:"active"`
+	Accounts []AccountJSON `json:"accounts"`
+}
+
+// TokenRefreshJSON represents the result of a forced token refresh
+type TokenRefreshJSON struct {
+	Status string    `json:"status"`
+	Expiry time.Time `json:"expiry"`
+}
+
+// DoctorJSON represents the results of gday doctor
+type DoctorJSON struct {
+	OK     bool              `json:"ok"`
+	Checks []DoctorCheckJSON `json:"checks"`
+}
+
+// DoctorCheckJSON represents a single diagnostic check
+type DoctorCheckJSON struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+	Hint   string `json:"hint,omitempty"`
 }
 
 // StatusJSON for simple status messages
@@ -108,3 +422,28 @@ type StatusJSON struct {
 	Status  string `json:"status"`
 	Message string `json:"message,omitempty"`
 }
+
+// BatchResultJSON represents the result of a batch operation
+type BatchResultJSON struct {
+	Action    string             `json:"action"`
+	Total     int                `json:"total"`
+	Succeeded int                `json:"succeeded"`
+	Failed    []BatchFailureJSON `json:"failed,omitempty"`
+	DryRun    bool               `json:"dry_run,omitempty"`
+	Items     []string           `json:"items,omitempty"`
+}
+
+// BatchFailureJSON represents a single failed item in a batch operation
+type BatchFailureJSON struct {
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+// ProgressJSON is a progress event emitted by batch commands with --progress json
+type ProgressJSON struct {
+	Processed int    `json:"processed"`
+	Total     int    `json:"total"`
+	LastID    string `json:"last_id"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
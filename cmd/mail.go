@@ -2,15 +2,25 @@ package cmd
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/mail"
 	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/joncooper/gday/internal/auth"
 	gdaygmail "github.com/joncooper/gday/internal/gmail"
 	"github.com/spf13/cobra"
+	"github.com/yuin/goldmark"
 )
 
 var mailCmd = &cobra.Command{
@@ -21,17 +31,29 @@ var mailCmd = &cobra.Command{
 }
 
 var mailListCmd = &cobra.Command{
-	Use:   "list",
-	Short: "List recent emails",
+	Use:         "list",
+	Short:       "List recent emails",
+	Annotations: map[string]string{csvAnnotation: "true", scopesAnnotation: "gmail.read"},
 	Long: `List recent emails from your inbox.
 
 Examples:
   gday mail list              # List 10 recent emails
   gday mail list -n 25        # List 25 recent emails
   gday mail list --unread     # List only unread emails
-  gday mail list --json       # Output as JSON`,
+  gday mail list --important  # List only mail Gmail marked important
+  gday mail list --relative   # Show "2h ago" style timestamps
+  gday mail list --json       # Output as JSON
+  gday mail list --format csv > inbox.csv  # Or yaml
+  gday mail list -n 500       # Fetches as many pages as needed
+  gday mail list --page-token TOKEN  # Continue from next_page_token
+  gday mail list --since "3 days ago"
+  gday mail list --since monday --before yesterday
+
+Markers before each message: * unread, > marked important by Gmail,
+! sent with high priority.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
+		ctx, cancel := newContext()
+		defer cancel()
 		client, err := auth.GetClient(ctx)
 		if err != nil {
 			exitError("%v", err)
@@ -44,59 +66,69 @@ Examples:
 
 		n, _ := cmd.Flags().GetInt64("number")
 		unread, _ := cmd.Flags().GetBool("unread")
+		important, _ := cmd.Flags().GetBool("important")
 		query, _ := cmd.Flags().GetString("query")
+		relative, _ := cmd.Flags().GetBool("relative")
 
 		var labels []string
 		if unread {
 			labels = append(labels, "UNREAD")
 		}
+		if important {
+			labels = append(labels, "IMPORTANT")
+		}
+
+		after, before := messageTimeRange(cmd)
+		query = strings.TrimSpace(query + " " + dayRangeQuery(after, before))
 
-		messages, err := srv.ListMessages(ctx, n, query, labels)
+		pageToken, _ := cmd.Flags().GetString("page-token")
+		messages, nextPageToken, err := srv.ListMessagesPage(ctx, n, query, labels, pageToken)
 		if err != nil {
 			exitError("%v", err)
 		}
-
-		if isJSONOutput() {
-			jsonMsgs := make([]MessageJSON, 0, len(messages))
-			for _, m := range messages {
-				jsonMsgs = append(jsonMsgs, messageToJSON(m))
-			}
-			outputJSON(MessagesListJSON{Count: len(jsonMsgs), Messages: jsonMsgs})
-			return
+		messages = filterMessagesByTime(messages, after, before)
+		sortFlag, _ := cmd.Flags().GetString("sort")
+		if err := sortMessages(messages, sortFlag); err != nil {
+			exitError("%v", err)
 		}
 
-		if len(messages) == 0 {
+		if len(messages) == 0 && outputFormat == formatTable {
 			fmt.Println("No messages found")
 			return
 		}
 
-		for _, m := range messages {
-			unreadMarker := " "
-			if m.IsUnread {
-				unreadMarker = "*"
-			}
-			fmt.Printf("%s %s  %-20s  %-40s  %s\n",
-				unreadMarker,
-				m.ID[:12],
-				truncate(m.From, 20),
-				truncate(m.Subject, 40),
-				formatDate(m.Date))
+		jsonMsgs := messagesToJSON(messages)
+		output(MessagesListJSON{Count: len(jsonMsgs), Messages: jsonMsgs, NextPageToken: nextPageToken},
+			func(csv bool) *table { return messagesTable(messages, relative, csv) })
+		if outputFormat == formatTable {
+			printNextPage(nextPageToken)
 		}
 	},
 }
 
 var mailReadCmd = &cobra.Command{
-	Use:   "read <message-id>",
-	Short: "Read an email",
+	Use:         "read <message-id>",
+	Short:       "Read an email",
+	Annotations: map[string]string{scopesAnnotation: "gmail.read"},
 	Long: `Read the contents of an email.
 
 Examples:
   gday mail read abc123def456     # Read message by ID
   gday mail read abc123 --raw     # Show raw message without formatting
-  gday mail read abc123 --json    # Output as JSON`,
+  gday mail read abc123 --source > message.eml  # Original RFC822 source, byte for byte
+  gday mail read abc123 --headers-only  # Dump every header (for delivery debugging)
+  gday mail read abc123 --extract-links # Numbered list of the links in the body
+  gday mail read abc123 --render  # Show the HTML body as formatted text
+  gday mail read abc123 --html > message.html  # The HTML body as-is
+  gday mail read abc123 --json    # Output as JSON
+
+The plain-text body is shown by default, or text converted from the HTML
+body if there is no plain-text part. --render lays out the HTML body
+instead, keeping paragraphs, list bullets, and links as "text (url)".`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
+		ctx, cancel := newContext()
+		defer cancel()
 		client, err := auth.GetClient(ctx)
 		if err != nil {
 			exitError("%v", err)
@@ -110,12 +142,63 @@ Examples:
 		messageID := args[0]
 		raw, _ := cmd.Flags().GetBool("raw")
 		markRead, _ := cmd.Flags().GetBool("mark-read")
+		if markRead {
+			if err := auth.CheckAccess(auth.GmailModify); err != nil {
+				exitError("%v", err)
+			}
+		}
+		headersOnly, _ := cmd.Flags().GetBool("headers-only")
+
+		if source, _ := cmd.Flags().GetBool("source"); source {
+			data, err := srv.GetRawMessage(ctx, messageID)
+			if err != nil {
+				exitError("%v", err)
+			}
+			os.Stdout.Write(data)
+			return
+		}
+
+		if headersOnly {
+			headers, err := srv.GetHeaders(ctx, messageID)
+			if err != nil {
+				exitError("%v", err)
+			}
+			if isJSONOutput() {
+				headerMap := make(map[string][]string)
+				for _, h := range headers {
+					headerMap[h.Name] = append(headerMap[h.Name], h.Value)
+				}
+				outputJSON(HeadersJSON{ID: messageID, Headers: headerMap})
+				return
+			}
+			for _, h := range headers {
+				fmt.Printf("%s: %s\n", h.Name, h.Value)
+			}
+			return
+		}
 
 		msg, err := srv.GetMessage(ctx, messageID, true)
 		if err != nil {
 			exitError("%v", err)
 		}
 
+		if extractLinks, _ := cmd.Flags().GetBool("extract-links"); extractLinks {
+			printLinks(gdaygmail.ExtractLinks(msg.Body, msg.BodyHTML))
+			return
+		}
+
+		if showHTML, _ := cmd.Flags().GetBool("html"); showHTML {
+			if msg.BodyHTML == "" {
+				exitUsage("message has no HTML body")
+			}
+			fmt.Println(msg.BodyHTML)
+			return
+		}
+
+		if render, _ := cmd.Flags().GetBool("render"); render && msg.BodyHTML != "" {
+			msg.Body = gdaygmail.RenderHTML(msg.BodyHTML)
+		}
+
 		if isJSONOutput() {
 			outputJSON(messageToJSON(msg))
 			if markRead && msg.IsUnread {
@@ -147,16 +230,19 @@ Examples:
 }
 
 var mailThreadCmd = &cobra.Command{
-	Use:   "thread <thread-id>",
-	Short: "Read an email thread",
+	Use:         "thread <thread-id>",
+	Short:       "Read an email thread",
+	Annotations: map[string]string{scopesAnnotation: "gmail.read"},
 	Long: `Read all messages in a thread.
 
 Examples:
   gday mail thread abc123def456   # Read all messages in thread
-  gday mail thread abc123 --json  # Output as JSON`,
+  gday mail thread abc123 --json  # Output as JSON
+  gday mail thread abc123 --save ./thread  # Save messages, attachments, and a manifest`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
+		ctx, cancel := newLongContext()
+		defer cancel()
 		client, err := auth.GetClient(ctx)
 		if err != nil {
 			exitError("%v", err)
@@ -173,11 +259,21 @@ Examples:
 			exitError("%v", err)
 		}
 
-		if isJSONOutput() {
-			jsonMsgs := make([]MessageJSON, 0, len(messages))
-			for _, m := range messages {
-				jsonMsgs = append(jsonMsgs, messageToJSON(m))
+		if saveDir, _ := cmd.Flags().GetString("save"); saveDir != "" {
+			manifest, err := saveThread(ctx, srv, threadID, messages, saveDir)
+			if err != nil {
+				exitError("%v", err)
+			}
+			if isJSONOutput() {
+				outputJSON(manifest)
+				return
 			}
+			fmt.Printf("Saved %d message(s) to %s\n", len(manifest.Messages), saveDir)
+			return
+		}
+
+		if isJSONOutput() {
+			jsonMsgs := messagesToJSON(messages)
 			outputJSON(ThreadJSON{ThreadID: threadID, Count: len(jsonMsgs), Messages: jsonMsgs})
 			return
 		}
@@ -195,8 +291,9 @@ Examples:
 }
 
 var mailSearchCmd = &cobra.Command{
-	Use:   "search <query>",
-	Short: "Search emails",
+	Use:         "search <query>",
+	Short:       "Search emails",
+	Annotations: map[string]string{csvAnnotation: "true", scopesAnnotation: "gmail.read"},
 	Long: `Search emails using Gmail search syntax.
 
 Examples:
@@ -204,10 +301,16 @@ Examples:
   gday mail search "subject:urgent is:unread"
   gday mail search "has:attachment larger:5M"
   gday mail search "after:2024/01/01 before:2024/02/01"
-  gday mail search "from:boss" --json`,
+  gday mail search "from:boss" --json
+  gday mail search "has:attachment" --since "2 weeks ago"
+
+--after (or --since) and --before take a date, a time, or a relative date
+such as today, yesterday, friday (the most recent one), "last friday",
+or "3 days ago".`,
 	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
+		ctx, cancel := newContext()
+		defer cancel()
 		client, err := auth.GetClient(ctx)
 		if err != nil {
 			exitError("%v", err)
@@ -220,38 +323,33 @@ Examples:
 
 		query := strings.Join(args, " ")
 		n, _ := cmd.Flags().GetInt64("number")
+		relative, _ := cmd.Flags().GetBool("relative")
 
-		messages, err := srv.SearchMessages(ctx, query, n)
+		after, before := messageTimeRange(cmd)
+		pageToken, _ := cmd.Flags().GetString("page-token")
+		messages, nextPageToken, err := srv.ListMessagesPage(ctx, n, strings.TrimSpace(query+" "+dayRangeQuery(after, before)), nil, pageToken)
 		if err != nil {
 			exitError("%v", err)
 		}
-
-		if isJSONOutput() {
-			jsonMsgs := make([]MessageJSON, 0, len(messages))
-			for _, m := range messages {
-				jsonMsgs = append(jsonMsgs, messageToJSON(m))
-			}
-			outputJSON(SearchResultJSON{Query: query, Count: len(jsonMsgs), Messages: jsonMsgs})
-			return
+		messages = filterMessagesByTime(messages, after, before)
+		sortFlag, _ := cmd.Flags().GetString("sort")
+		if err := sortMessages(messages, sortFlag); err != nil {
+			exitError("%v", err)
 		}
 
-		if len(messages) == 0 {
-			fmt.Println("No messages found")
-			return
+		if outputFormat == formatTable {
+			if len(messages) == 0 {
+				fmt.Println("No messages found")
+				return
+			}
+			fmt.Printf("Found %d messages matching: %s\n\n", len(messages), query)
 		}
 
-		fmt.Printf("Found %d messages matching: %s\n\n", len(messages), query)
-		for _, m := range messages {
-			unreadMarker := " "
-			if m.IsUnread {
-				unreadMarker = "*"
-			}
-			fmt.Printf("%s %s  %-20s  %-40s  %s\n",
-				unreadMarker,
-				m.ID[:12],
-				truncate(m.From, 20),
-				truncate(m.Subject, 40),
-				formatDate(m.Date))
+		jsonMsgs := messagesToJSON(messages)
+		output(SearchResultJSON{Query: query, Count: len(jsonMsgs), Messages: jsonMsgs, NextPageToken: nextPageToken},
+			func(csv bool) *table { return messagesTable(messages, relative, csv) })
+		if outputFormat == formatTable {
+			printNextPage(nextPageToken)
 		}
 	},
 }
@@ -264,9 +362,44 @@ var mailSendCmd = &cobra.Command{
 Examples:
   gday mail send --to user@example.com --subject "Hello" --body "Hi there"
   gday mail send --to user@example.com --subject "Hello" --body-file message.txt
-  echo "Message" | gday mail send --to user@example.com --subject "Hello" --body-stdin`,
+  echo "Message" | gday mail send --to user@example.com --subject "Hello" --body-stdin
+  gday mail send --to user@example.com --subject "Notes" --body-file notes.md
+  gday mail send --to user@example.com --subject "Welcome" --body-file welcome.md --var name=Ana
+  gday mail send --to user@example.com --subject "News" --body-file news.md --inline-image cid:logo=logo.png
+  gday mail send --to user@example.com --subject "Newsletter" --body-file newsletter.html
+  gday mail send --to user@example.com --subject "Hi" --body "<p>Hello <b>there</b></p>" --html
+  gday mail send --to user@example.com --subject "Outage" --body "..." --priority high
+  gday mail send --to user@example.com --subject "Report" --body "Attached" --attach report.pdf --attach data.csv
+  gday mail send --to user@example.com --subject "Hello" --body "Hi" --from support@example.com
+  render-template | gday mail send --raw
+
+--raw reads a complete RFC822 message (headers and body) from stdin and sends
+it as-is; it must have at least To and Subject headers.
+
+A --body-file ending in .md or .markdown is rendered as Markdown
+automatically; pass --markdown=false to send it as plain text, or --markdown
+to render a body from any source.
+
+--html sends the body as HTML, with a plain-text version generated from it
+for mail clients that don't show HTML. A --body-file ending in .html or
+.htm is sent as HTML automatically.
+
+With one or more --var key=value, the body is treated as a Go template and
+{{.key}} placeholders are filled in before any Markdown rendering. A
+placeholder with no matching --var is an error.
+
+--priority sets the X-Priority, Importance, and Priority headers; whether and
+how the priority is shown depends on the recipient's mail client.
+
+--from sends from one of your verified send-as addresses (see
+"gday mail aliases") instead of your primary address.
+
+Sending to more than 5 recipients (To, Cc, and Bcc combined) asks for
+confirmation unless --yes is given. Use --dry-run to preview the recipients.`,
+	Annotations: map[string]string{stdinAnnotation: "body-stdin,raw", scopesAnnotation: "gmail.send"},
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
+		ctx, cancel := newContext()
+		defer cancel()
 		client, err := auth.GetClient(ctx)
 		if err != nil {
 			exitError("%v", err)
@@ -277,6 +410,13 @@ Examples:
 			exitError("%v", err)
 		}
 
+		showUploadProgress(srv)
+
+		if raw, _ := cmd.Flags().GetBool("raw"); raw {
+			sendRawFromStdin(ctx, cmd, srv)
+			return
+		}
+
 		to, _ := cmd.Flags().GetString("to")
 		subject, _ := cmd.Flags().GetString("subject")
 		body, _ := cmd.Flags().GetString("body")
@@ -285,12 +425,24 @@ Examples:
 		cc, _ := cmd.Flags().GetStringSlice("cc")
 		bcc, _ := cmd.Flags().GetStringSlice("bcc")
 		draft, _ := cmd.Flags().GetBool("draft")
+		markdown, _ := cmd.Flags().GetBool("markdown")
+		html, _ := cmd.Flags().GetBool("html")
+		priority, _ := cmd.Flags().GetString("priority")
+		attachments, _ := cmd.Flags().GetStringArray("attach")
 
 		if to == "" {
-			exitError("--to is required")
+			exitUsage("--to is required")
 		}
 		if subject == "" {
-			exitError("--subject is required")
+			exitUsage("--subject is required")
+		}
+		if !gdaygmail.ValidPriority(priority) {
+			exitUsage("invalid --priority %q (use high, normal, or low)", priority)
+		}
+		for _, path := range attachments {
+			if info, err := os.Stat(path); err != nil || info.IsDir() {
+				exitUsage("cannot attach %s: not a readable file", path)
+			}
 		}
 
 		// Get body from various sources
@@ -310,11 +462,76 @@ Examples:
 		}
 
 		if body == "" {
-			exitError("message body is required (--body, --body-file, or --body-stdin)")
+			exitUsage("message body is required (--body, --body-file, or --body-stdin)")
+		}
+		from := fromHeaderFromFlag(ctx, cmd, srv)
+
+		vars, err := parseVars(cmd)
+		if err != nil {
+			exitUsage("%v", err)
+		}
+		if body, err = renderBodyTemplate(body, vars); err != nil {
+			exitUsage("%v", err)
+		}
+
+		// An explicit --markdown or --html (or =false) wins over detection
+		if !cmd.Flags().Changed("markdown") {
+			markdown = isMarkdownFile(bodyFile)
+		}
+		if !cmd.Flags().Changed("html") {
+			html = isHTMLFile(bodyFile)
+		}
+		if markdown && html {
+			exitUsage("--markdown and --html can't be used together")
+		}
+
+		// Guard against accidentally mailing a huge recipient list
+		recipients := splitAddresses(to)
+		recipients = append(recipients, cc...)
+		recipients = append(recipients, bcc...)
+		if !confirmRecipients(cmd, recipients) {
+			return
+		}
+
+		htmlBody := ""
+		if markdown {
+			htmlBody, err = renderMarkdown(body)
+			if err != nil {
+				exitError("failed to render markdown: %v", err)
+			}
+		}
+		if html {
+			// The plain-text part is generated from the HTML
+			htmlBody, body = body, ""
+		}
+
+		inline, err := inlineImagesFromFlags(cmd)
+		if err != nil {
+			exitUsage("%v", err)
+		}
+		if len(inline) > 0 {
+			if htmlBody == "" {
+				exitUsage("--inline-image needs an HTML body (use --markdown, --html, or a .md or .html --body-file)")
+			}
+			if err := gdaygmail.CheckInlineImages(htmlBody, inline); err != nil {
+				exitUsage("%v", err)
+			}
 		}
 
+		msg := &gdaygmail.OutgoingMessage{
+			From:        from,
+			To:          to,
+			Cc:          cc,
+			Bcc:         bcc,
+			Subject:     subject,
+			Priority:    priority,
+			Text:        body,
+			HTML:        htmlBody,
+			Images:      inline,
+			Attachments: attachments,
+		}
 		if draft {
-			id, err := srv.CreateDraft(ctx, to, subject, body)
+			id, err := srv.CreateDraft(ctx, msg)
 			if err != nil {
 				exitError("%v", err)
 			}
@@ -324,19 +541,33 @@ Examples:
 			}
 			fmt.Printf("Draft created: %s\n", id)
 		} else {
-			msg, err := srv.SendMessage(ctx, to, subject, body, cc, bcc)
+			sent, err := srv.SendMessage(ctx, msg)
 			if err != nil {
 				exitError("%v", err)
 			}
 			if isJSONOutput() {
-				outputJSON(SendResultJSON{MessageID: msg.ID, Status: "sent"})
+				outputJSON(SendResultJSON{MessageID: sent.ID, Status: "sent"})
 				return
 			}
-			fmt.Printf("Message sent: %s\n", msg.ID)
+			fmt.Printf("Message sent: %s\n", sent.ID)
 		}
 	},
 }
 
+// fromHeaderFromFlag returns the From header for --from, checked against the
+// user's verified send-as addresses, or "" to send from the primary address
+func fromHeaderFromFlag(ctx context.Context, cmd *cobra.Command, srv *gdaygmail.Service) string {
+	from, _ := cmd.Flags().GetString("from")
+	if from == "" {
+		return ""
+	}
+	header, err := srv.FromHeader(ctx, from)
+	if err != nil {
+		exitError("%v", err)
+	}
+	return header
+}
+
 var mailReplyCmd = &cobra.Command{
 	Use:   "reply <message-id>",
 	Short: "Reply to an email",
@@ -344,10 +575,20 @@ var mailReplyCmd = &cobra.Command{
 
 Examples:
   gday mail reply abc123 --body "Thanks for your message"
-  gday mail reply abc123 --body-file reply.txt`,
-	Args: cobra.ExactArgs(1),
+  gday mail reply abc123 --body-file reply.txt
+  gday mail reply abc123 --body "On it" --from support@example.com
+  gday mail reply abc123 --body "Adding Sam" --cc sam@example.com
+  gday mail reply abc123 --body "Signed copy attached" --attach contract.pdf
+  gday mail reply abc123 --body-file reply.txt --draft
+
+--from replies from one of your verified send-as addresses instead of your
+primary address. --draft saves the reply as a draft in the thread instead of
+sending it; see 'gday mail drafts'.`,
+	Args:        cobra.ExactArgs(1),
+	Annotations: map[string]string{stdinAnnotation: "body-stdin", scopesAnnotation: "gmail.read,gmail.send"},
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
+		ctx, cancel := newContext()
+		defer cancel()
 		client, err := auth.GetClient(ctx)
 		if err != nil {
 			exitError("%v", err)
@@ -357,11 +598,22 @@ Examples:
 		if err != nil {
 			exitError("%v", err)
 		}
+		showUploadProgress(srv)
 
 		messageID := args[0]
 		body, _ := cmd.Flags().GetString("body")
 		bodyFile, _ := cmd.Flags().GetString("body-file")
 		bodyStdin, _ := cmd.Flags().GetBool("body-stdin")
+		cc, _ := cmd.Flags().GetStringSlice("cc")
+		bcc, _ := cmd.Flags().GetStringSlice("bcc")
+		attachments, _ := cmd.Flags().GetStringArray("attach")
+		draft, _ := cmd.Flags().GetBool("draft")
+
+		for _, path := range attachments {
+			if info, err := os.Stat(path); err != nil || info.IsDir() {
+				exitUsage("cannot attach %s: not a readable file", path)
+			}
+		}
 
 		// Get body from various sources
 		if bodyStdin {
@@ -380,10 +632,24 @@ Examples:
 		}
 
 		if body == "" {
-			exitError("reply body is required (--body, --body-file, or --body-stdin)")
+			exitUsage("reply body is required (--body, --body-file, or --body-stdin)")
+		}
+		from := fromHeaderFromFlag(ctx, cmd, srv)
+
+		if draft {
+			id, err := srv.CreateReplyDraft(ctx, messageID, from, body, cc, bcc, attachments)
+			if err != nil {
+				exitError("%v", err)
+			}
+			if isJSONOutput() {
+				outputJSON(SendResultJSON{MessageID: id, Status: "draft_created"})
+				return
+			}
+			fmt.Printf("Draft created: %s\n", id)
+			return
 		}
 
-		msg, err := srv.ReplyToMessage(ctx, messageID, body)
+		msg, err := srv.ReplyToMessage(ctx, messageID, from, body, cc, bcc, attachments)
 		if err != nil {
 			exitError("%v", err)
 		}
@@ -395,18 +661,69 @@ Examples:
 	},
 }
 
+var mailForwardCmd = &cobra.Command{
+	Use:         "forward <message-id>",
+	Short:       "Forward an email",
+	Annotations: map[string]string{scopesAnnotation: "gmail.read,gmail.send"},
+	Long: `Forward an email, including its attachments.
+
+The original message's From, Date, Subject, and To are quoted above its body.
+
+Examples:
+  gday mail forward abc123 --to colleague@example.com
+  gday mail forward abc123 --to colleague@example.com --body "FYI, see below"`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		to, _ := cmd.Flags().GetString("to")
+		body, _ := cmd.Flags().GetString("body")
+		if to == "" {
+			exitUsage("--to is required")
+		}
+
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaygmail.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+		showUploadProgress(srv)
+
+		msg, err := srv.ForwardMessage(ctx, args[0], to, body)
+		if err != nil {
+			exitError("%v", err)
+		}
+		if isJSONOutput() {
+			outputJSON(SendResultJSON{MessageID: msg.ID, Status: "sent"})
+			return
+		}
+		fmt.Printf("Message forwarded: %s\n", msg.ID)
+	},
+}
+
 var mailAttachmentCmd = &cobra.Command{
-	Use:   "attachment <message-id> [attachment-id]",
-	Short: "Download email attachments",
+	Use:         "attachment <message-id> [attachment-id]",
+	Short:       "Download email attachments",
+	Annotations: map[string]string{scopesAnnotation: "gmail.read"},
 	Long: `Download attachments from an email.
 
 Examples:
   gday mail attachment abc123           # List attachments in message
   gday mail attachment abc123 att456    # Download specific attachment
-  gday mail attachment abc123 --all     # Download all attachments`,
+  gday mail attachment abc123 --all     # Download all attachments
+  gday mail attachment abc123 --all --type application/pdf --type 'image/*'
+  gday mail attachment abc123 --all --overwrite  # Replace files from an earlier download
+
+Files are never overwritten unless --overwrite is given: a download whose
+name is taken is saved as "name (1).ext", "name (2).ext", and so on.`,
 	Args: cobra.RangeArgs(1, 2),
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
+		ctx, cancel := newLongContext()
+		defer cancel()
 		client, err := auth.GetClient(ctx)
 		if err != nil {
 			exitError("%v", err)
@@ -419,6 +736,7 @@ Examples:
 
 		messageID := args[0]
 		outDir, _ := cmd.Flags().GetString("output")
+		overwrite, _ := cmd.Flags().GetBool("overwrite")
 		downloadAll, _ := cmd.Flags().GetBool("all")
 
 		msg, err := srv.GetMessage(ctx, messageID, true)
@@ -427,6 +745,10 @@ Examples:
 		}
 
 		if len(msg.Attachments) == 0 {
+			if isJSONOutput() {
+				outputJSON(AttachmentsListJSON{MessageID: messageID, Attachments: []AttachmentJSON{}})
+				return
+			}
 			fmt.Println("No attachments in this message")
 			return
 		}
@@ -443,16 +765,13 @@ Examples:
 						Size:     att.Size,
 					})
 				}
-				outputJSON(struct {
-					MessageID   string           `json:"message_id"`
-					Attachments []AttachmentJSON `json:"attachments"`
-				}{MessageID: messageID, Attachments: jsonAtts})
+				outputJSON(AttachmentsListJSON{MessageID: messageID, Attachments: jsonAtts})
 				return
 			}
 			fmt.Printf("Attachments in message %s:\n\n", messageID)
 			for _, att := range msg.Attachments {
 				fmt.Printf("  %s  %-30s  %s  %d bytes\n",
-					att.ID[:12],
+					shortID(att.ID, 12),
 					att.Filename,
 					att.MimeType,
 					att.Size)
@@ -474,26 +793,76 @@ Examples:
 				}
 			}
 			if len(toDownload) == 0 {
-				exitError("attachment not found: %s", attachmentID)
+				exitUsage("attachment not found: %s", attachmentID)
+			}
+		}
+
+		var skipped []string
+		if types, _ := cmd.Flags().GetStringSlice("type"); len(types) > 0 {
+			var matched []gdaygmail.Attachment
+			for _, att := range toDownload {
+				if matchMimeType(att.MimeType, types) {
+					matched = append(matched, att)
+				} else {
+					skipped = append(skipped, att.Filename)
+					if !isJSONOutput() {
+						fmt.Printf("Skipped: %s (%s)\n", att.Filename, att.MimeType)
+					}
+				}
 			}
+			toDownload = matched
 		}
 
+		downloads := make([]DownloadJSON, 0, len(toDownload))
 		for _, att := range toDownload {
-			path, err := srv.DownloadAttachment(ctx, messageID, att.ID, att.Filename, outDir)
+			if att.Size > gdaygmail.LargeAttachmentSize {
+				fmt.Fprintf(os.Stderr, "Downloading %s (%.0f MB); this may take a while\n", att.Filename, float64(att.Size)/(1<<20))
+			}
+			path, err := srv.DownloadAttachment(ctx, messageID, att.ID, att.Filename, outDir, overwrite)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to download %s: %v\n", att.Filename, err)
+				downloads = append(downloads, DownloadJSON{Filename: att.Filename, Error: err.Error()})
+				if !isJSONOutput() {
+					fmt.Fprintf(os.Stderr, "Failed to download %s: %v\n", att.Filename, err)
+				}
 				continue
 			}
-			fmt.Printf("Downloaded: %s\n", path)
+			downloads = append(downloads, DownloadJSON{Filename: att.Filename, Path: path})
+			if !isJSONOutput() {
+				fmt.Printf("Downloaded: %s\n", path)
+			}
+		}
+
+		if isJSONOutput() {
+			outputJSON(DownloadsJSON{MessageID: messageID, Downloads: downloads, Skipped: skipped})
 		}
 	},
 }
 
-var mailLabelsCmd = &cobra.Command{
-	Use:   "labels",
-	Short: "List all labels",
+var mailMarkReadCmd = &cobra.Command{
+	Use:         "mark-read [message-id...]",
+	Short:       "Mark emails as read",
+	Annotations: map[string]string{scopesAnnotation: "gmail.modify"},
+	Long: `Mark one or more emails as read.
+
+With --query, every unread message matching a Gmail search (up to
+--number) is marked read in a single batch request.
+
+Examples:
+  gday mail mark-read abc123 def456
+  gday mail search "is:unread from:alerts" --json | jq -r '.messages[].id' | gday mail mark-read --ids-from -
+  gday mail mark-read --query "older_than:30d label:newsletters" --dry-run
+  gday mail mark-read --query "from:alerts@example.com" --yes`,
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
+		query, _ := cmd.Flags().GetString("query")
+		var ids []string
+		if query == "" {
+			ids = collectIDs(cmd, args)
+		} else if len(args) > 0 {
+			exitUsage("give message IDs or --query, not both")
+		}
+
+		ctx, cancel := newContext()
+		defer cancel()
 		client, err := auth.GetClient(ctx)
 		if err != nil {
 			exitError("%v", err)
@@ -504,77 +873,1920 @@ var mailLabelsCmd = &cobra.Command{
 			exitError("%v", err)
 		}
 
-		labels, err := srv.GetLabels(ctx)
+		if query != "" {
+			modifyByQuery(ctx, cmd, srv, "mark read", query+" is:unread", nil, []string{"UNREAD"})
+			return
+		}
+		runBatch(cmd, "mark read", ids, false, func(id string) error {
+			return srv.MarkAsRead(ctx, id)
+		})
+	},
+}
+
+var mailMarkUnreadCmd = &cobra.Command{
+	Use:         "mark-unread [message-id...]",
+	Short:       "Mark emails as unread",
+	Annotations: map[string]string{scopesAnnotation: "gmail.modify"},
+	Long: `Mark one or more emails as unread.
+
+With --query, every read message matching a Gmail search (up to --number)
+is marked unread in a single batch request.
+
+Examples:
+  gday mail mark-unread abc123 def456
+  gday mail mark-unread --ids-from ids.txt
+  gday mail mark-unread --query "label:follow-up newer_than:7d"`,
+	Run: func(cmd *cobra.Command, args []string) {
+		query, _ := cmd.Flags().GetString("query")
+		var ids []string
+		if query == "" {
+			ids = collectIDs(cmd, args)
+		} else if len(args) > 0 {
+			exitUsage("give message IDs or --query, not both")
+		}
+
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
 		if err != nil {
 			exitError("%v", err)
 		}
 
-		if isJSONOutput() {
-			outputJSON(LabelsJSON{Labels: labels})
-			return
+		srv, err := gdaygmail.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
 		}
 
-		fmt.Println("Labels:")
-		for _, l := range labels {
-			fmt.Printf("  %s\n", l)
+		if query != "" {
+			modifyByQuery(ctx, cmd, srv, "mark unread", query+" -is:unread", []string{"UNREAD"}, nil)
+			return
 		}
+		runBatch(cmd, "mark unread", ids, false, func(id string) error {
+			return srv.MarkAsUnread(ctx, id)
+		})
 	},
 }
 
-func init() {
-	rootCmd.AddCommand(mailCmd)
+// modifyByQuery adds and removes labels on the messages matching query (up
+// to --number), confirming like any batch and then changing them all with
+// one batch request
+func modifyByQuery(ctx context.Context, cmd *cobra.Command, srv *gdaygmail.Service, action, query string, add, remove []string) {
+	n, _ := cmd.Flags().GetInt64("number")
+	messages, err := srv.SearchMessages(ctx, query, n)
+	if err != nil {
+		exitError("%v", err)
+	}
 
-	// List command
-	mailCmd.AddCommand(mailListCmd)
-	mailListCmd.Flags().Int64P("number", "n", 10, "Number of messages to list")
-	mailListCmd.Flags().Bool("unread", false, "Show only unread messages")
-	mailListCmd.Flags().StringP("query", "q", "", "Gmail search query")
+	if len(messages) == 0 {
+		if isJSONOutput() {
+			outputJSON(BatchResultJSON{Action: action, Total: 0})
+			return
+		}
+		fmt.Println("No messages found")
+		return
+	}
 
-	// Read command
-	mailCmd.AddCommand(mailReadCmd)
-	mailReadCmd.Flags().Bool("raw", false, "Show raw output without formatting")
-	mailReadCmd.Flags().Bool("mark-read", false, "Mark message as read after viewing")
+	items := make([]string, 0, len(messages))
+	ids := make([]string, 0, len(messages))
+	for _, m := range messages {
+		items = append(items, fmt.Sprintf("%s  %-20s  %s", m.ID, truncate(m.From, 20), truncate(m.Subject, 40)))
+		ids = append(ids, m.ID)
+	}
+	if !confirmBatch(cmd, action, items, false) {
+		return
+	}
 
-	// Thread command
-	mailCmd.AddCommand(mailThreadCmd)
+	// The batch succeeds or fails as a whole
+	err = srv.BatchModify(ctx, ids, add, remove)
+	progress := newProgressReporter(cmd, len(ids))
+	failed := make(map[string]error)
+	for _, id := range ids {
+		if err != nil {
+			failed[id] = err
+		}
+		progress.report(id, err)
+	}
+	printBatchResult(action, len(ids), failed)
+}
 
-	// Search command
-	mailCmd.AddCommand(mailSearchCmd)
-	mailSearchCmd.Flags().Int64P("number", "n", 20, "Maximum number of results")
+var mailExportCmd = &cobra.Command{
+	Use:         "export [message-id]",
+	Short:       "Export emails as .eml files or an mbox",
+	Annotations: map[string]string{scopesAnnotation: "gmail.read"},
+	Long: `Export a message as an .eml file, or every message matching a query as
+an mbox, for backups and migration to other mail clients.
 
-	// Send command
-	mailCmd.AddCommand(mailSendCmd)
-	mailSendCmd.Flags().StringP("to", "t", "", "Recipient email address")
-	mailSendCmd.Flags().StringP("subject", "s", "", "Email subject")
-	mailSendCmd.Flags().StringP("body", "b", "", "Email body text")
-	mailSendCmd.Flags().String("body-file", "", "Read body from file")
-	mailSendCmd.Flags().Bool("body-stdin", false, "Read body from stdin")
-	mailSendCmd.Flags().StringSlice("cc", nil, "CC recipients")
-	mailSendCmd.Flags().StringSlice("bcc", nil, "BCC recipients")
-	mailSendCmd.Flags().Bool("draft", false, "Create draft instead of sending")
+An .eml file holds one message's original RFC822 source, byte for byte.
 
-	// Reply command
-	mailCmd.AddCommand(mailReplyCmd)
-	mailReplyCmd.Flags().StringP("body", "b", "", "Reply body text")
-	mailReplyCmd.Flags().String("body-file", "", "Read body from file")
-	mailReplyCmd.Flags().Bool("body-stdin", false, "Read body from stdin")
+The mbox is in mboxrd format: each message starts with a separator line
+"From <sender> <date>" (date in UTC, asctime format), followed by the
+message with LF line endings and a blank line. Message lines that begin
+with "From " (after any number of '>') get one more leading '>', which
+readers strip again. Exporting to an existing mbox appends to it.
 
-	// Attachment command
-	mailCmd.AddCommand(mailAttachmentCmd)
-	mailAttachmentCmd.Flags().StringP("output", "o", ".", "Output directory for downloads")
-	mailAttachmentCmd.Flags().Bool("all", false, "Download all attachments")
+Examples:
+  gday mail export abc123 -o message.eml
+  gday mail export abc123 > message.eml
+  gday mail export --query "label:receipts" --mbox receipts.mbox
+  gday mail export --query "from:boss older_than:1y" --mbox boss.mbox -n 500`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		query, _ := cmd.Flags().GetString("query")
+		mboxPath, _ := cmd.Flags().GetString("mbox")
+		output, _ := cmd.Flags().GetString("output")
+
+		switch {
+		case len(args) == 1 && (query != "" || mboxPath != ""):
+			exitUsage("give either a message ID or --query with --mbox, not both")
+		case len(args) == 0 && (query == "" || mboxPath == ""):
+			exitUsage("give a message ID, or --query and --mbox")
+		}
 
-	// Labels command
-	mailCmd.AddCommand(mailLabelsCmd)
-}
+		ctx, cancel := newLongContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
 
-// Helper functions
+		srv, err := gdaygmail.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
 
-func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
+		if len(args) == 1 {
+			exportEML(ctx, srv, args[0], output)
+			return
+		}
+
+		n, _ := cmd.Flags().GetInt64("number")
+		exportMbox(ctx, srv, query, n, mboxPath)
+	},
+}
+
+// exportEML writes one message's RFC822 source to path, or stdout if path is ""
+func exportEML(ctx context.Context, srv *gdaygmail.Service, id, path string) {
+	raw, err := srv.GetRawMessage(ctx, id)
+	if err != nil {
+		exitError("%v", err)
+	}
+	if path == "" {
+		os.Stdout.Write(raw)
+		return
+	}
+	if err := os.WriteFile(path, raw, 0644); err != nil {
+		exitError("failed to write %s: %v", path, err)
+	}
+	if isJSONOutput() {
+		outputJSON(ExportJSON{Path: path, Format: "eml", Count: 1, Messages: []string{id}})
+		return
+	}
+	fmt.Printf("Exported %s to %s\n", id, path)
+}
+
+// exportMbox appends every message matching query to the mbox at path
+func exportMbox(ctx context.Context, srv *gdaygmail.Service, query string, n int64, path string) {
+	messages, _, err := srv.ListMessagesPage(ctx, n, query, nil, "")
+	if err != nil {
+		exitError("%v", err)
+	}
+	if len(messages) == 0 {
+		exitUsage("no messages match %q", query)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		exitError("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	result := ExportJSON{Path: path, Format: "mbox"}
+	failed := make(map[string]error)
+	for i, m := range messages {
+		raw, err := srv.GetRawMessage(ctx, m.ID)
+		if err == nil {
+			err = gdaygmail.WriteMbox(f, m.From, m.Date, raw)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to export %s: %v\n", m.ID, err)
+			failed[m.ID] = err
+			result.Failed = append(result.Failed, m.ID)
+			continue
+		}
+		result.Messages = append(result.Messages, m.ID)
+		if !isJSONOutput() {
+			fmt.Fprintf(os.Stderr, "\rExported %d/%d", i+1, len(messages))
+		}
+	}
+	result.Count = len(result.Messages)
+
+	if isJSONOutput() {
+		outputJSON(result)
+	} else {
+		fmt.Fprintln(os.Stderr)
+		fmt.Printf("Exported %d message(s) to %s\n", result.Count, path)
+	}
+	exitForBatchResult(len(messages), failed)
+}
+
+var mailArchiveCmd = &cobra.Command{
+	Use:         "archive [message-id...]",
+	Short:       "Archive emails (remove them from the inbox)",
+	Annotations: map[string]string{scopesAnnotation: "gmail.modify"},
+	Long: `Archive one or more emails by removing the INBOX label. Archived mail
+stays searchable and under its other labels.
+
+Examples:
+  gday mail archive abc123 def456
+  gday mail search "in:inbox older_than:14d" --json | jq -r '.messages[].id' | gday mail archive --yes`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ids := collectIDs(cmd, args)
+
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaygmail.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		runBatch(cmd, "archive", ids, false, func(id string) error {
+			return srv.ArchiveMessage(ctx, id)
+		})
+	},
+}
+
+var mailUnarchiveCmd = &cobra.Command{
+	Use:         "unarchive [message-id...]",
+	Short:       "Move emails back to the inbox",
+	Annotations: map[string]string{scopesAnnotation: "gmail.modify"},
+	Long: `Move one or more emails back to the inbox by adding the INBOX label.
+
+Examples:
+  gday mail unarchive abc123`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ids := collectIDs(cmd, args)
+
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaygmail.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		runBatch(cmd, "unarchive", ids, false, func(id string) error {
+			return srv.UnarchiveMessage(ctx, id)
+		})
+	},
+}
+
+var mailLabelCmd = &cobra.Command{
+	Use:         "label [message-id...]",
+	Short:       "Add or remove labels on emails",
+	Annotations: map[string]string{scopesAnnotation: "gmail.modify"},
+	Long: `Add or remove labels on one or more emails. Labels are given by name
+(case-insensitive) or ID; system labels such as INBOX, STARRED, and
+IMPORTANT work too.
+
+Examples:
+  gday mail label abc123 --add Work --remove INBOX
+  gday mail label abc123 def456 --add "Receipts/2026" --add STARRED`,
+	Run: func(cmd *cobra.Command, args []string) {
+		add, _ := cmd.Flags().GetStringArray("add")
+		remove, _ := cmd.Flags().GetStringArray("remove")
+		if len(add) == 0 && len(remove) == 0 {
+			exitUsage("nothing to do (use --add and/or --remove)")
+		}
+		ids := collectIDs(cmd, args)
+
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaygmail.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		// Fail on unknown labels before touching any message
+		if _, err := srv.ResolveLabelIDs(ctx, append(append([]string(nil), add...), remove...)); err != nil {
+			exitError("%v", err)
+		}
+
+		runBatch(cmd, "relabel", ids, false, func(id string) error {
+			return srv.ModifyLabels(ctx, id, add, remove)
+		})
+	},
+}
+
+var mailTrashCmd = &cobra.Command{
+	Use:         "trash [message-id...]",
+	Short:       "Move emails to the trash",
+	Annotations: map[string]string{scopesAnnotation: "gmail.modify"},
+	Long: `Move one or more emails to the trash. Gmail deletes trashed messages
+after 30 days; use 'gday mail untrash' to restore them before then.
+
+IDs can be given as arguments, with --ids-from, or piped in one per line.
+
+Examples:
+  gday mail trash abc123 def456
+  gday mail search "from:promo@example.com" --json | jq -r '.messages[].id' | gday mail trash --yes`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ids := collectIDs(cmd, args)
+
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaygmail.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		runBatch(cmd, "trash", ids, false, func(id string) error {
+			return srv.TrashMessage(ctx, id)
+		})
+	},
+}
+
+var mailUntrashCmd = &cobra.Command{
+	Use:         "untrash [message-id...]",
+	Short:       "Restore emails from the trash",
+	Annotations: map[string]string{scopesAnnotation: "gmail.modify"},
+	Long: `Move one or more emails out of the trash.
+
+Examples:
+  gday mail untrash abc123
+  gday mail search "in:trash from:boss@example.com" --json | jq -r '.messages[].id' | gday mail untrash --yes`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ids := collectIDs(cmd, args)
+
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaygmail.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		runBatch(cmd, "untrash", ids, false, func(id string) error {
+			return srv.UntrashMessage(ctx, id)
+		})
+	},
+}
+
+var mailDeleteCmd = &cobra.Command{
+	Use:         "delete [message-id...]",
+	Short:       "Permanently delete emails",
+	Annotations: map[string]string{scopesAnnotation: "gmail.delete"},
+	Long: `Permanently delete one or more emails, bypassing the trash. This cannot
+be undone, so it always asks for confirmation unless --yes is given. To
+delete recoverably, use 'gday mail trash' instead.
+
+Requires the full mail scope, which the default login leaves out; grant it
+with 'gday auth login --scope mail --scope calendar'.
+
+Examples:
+  gday mail delete abc123
+  gday mail delete abc123 def456 --dry-run`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ids := collectIDs(cmd, args)
+
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaygmail.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		runBatch(cmd, "permanently delete", ids, true, func(id string) error {
+			return srv.PermanentlyDelete(ctx, id)
+		})
+	},
+}
+
+// cleanupActions maps each mail cleanup action to whether it is destructive
+var cleanupActions = map[string]bool{
+	"archive": false,
+	"trash":   false,
+	"delete":  true,
+}
+
+var mailCleanupCmd = &cobra.Command{
+	Use:         "cleanup",
+	Short:       "Archive, trash, or delete all emails matching a query",
+	Annotations: map[string]string{scopesAnnotation: "gmail.modify"},
+	Long: `Apply one action to every email matching a Gmail search query.
+
+Actions:
+  archive  Remove from the inbox (asks first above the confirmation threshold)
+  trash    Move to the trash (asks first above the confirmation threshold)
+  delete   Permanently delete, bypassing the trash (always asks first; needs
+           the full mail scope, granted with 'gday auth login --scope mail')
+
+Examples:
+  gday mail cleanup --query "label:newsletters older_than:30d" --action archive
+  gday mail cleanup --query "from:noreply@example.com" --action trash --dry-run
+  gday mail cleanup --query "in:spam" --action delete --yes`,
+	Run: func(cmd *cobra.Command, args []string) {
+		query, _ := cmd.Flags().GetString("query")
+		action, _ := cmd.Flags().GetString("action")
+		n, _ := cmd.Flags().GetInt64("number")
+
+		if query == "" {
+			exitUsage("--query is required")
+		}
+		destructive, ok := cleanupActions[action]
+		if !ok {
+			exitUsage("invalid --action %q (use archive, trash, or delete)", action)
+		}
+		if action == "delete" {
+			if err := auth.CheckAccess(auth.GmailDelete); err != nil {
+				exitError("%v", err)
+			}
+		}
+
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaygmail.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		messages, err := srv.SearchMessages(ctx, query, n)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		if len(messages) == 0 {
+			if isJSONOutput() {
+				outputJSON(BatchResultJSON{Action: action, Total: 0})
+				return
+			}
+			fmt.Println("No messages found")
+			return
+		}
+
+		items := make([]string, 0, len(messages))
+		for _, m := range messages {
+			items = append(items, fmt.Sprintf("%s  %-20s  %s", m.ID, truncate(m.From, 20), truncate(m.Subject, 40)))
+		}
+		label := action
+		if action == "delete" {
+			label = "permanently delete"
+		}
+		if !confirmBatch(cmd, label, items, destructive) {
+			return
+		}
+
+		ids := make([]string, 0, len(messages))
+		for _, m := range messages {
+			ids = append(ids, m.ID)
+		}
+		applyBatch(cmd, label, ids, cleanupFunc(ctx, srv, action))
+	},
+}
+
+// messageCleaner is the part of the Gmail service mail cleanup uses
+type messageCleaner interface {
+	ArchiveMessage(ctx context.Context, id string) error
+	TrashMessage(ctx context.Context, id string) error
+	PermanentlyDelete(ctx context.Context, id string) error
+}
+
+// cleanupFunc returns the function applying a cleanup action to a message.
+// Every action is named explicitly, so a value that slips past validation
+// fails rather than deleting anything.
+func cleanupFunc(ctx context.Context, srv messageCleaner, action string) func(id string) error {
+	return func(id string) error {
+		switch action {
+		case "archive":
+			return srv.ArchiveMessage(ctx, id)
+		case "trash":
+			return srv.TrashMessage(ctx, id)
+		case "delete":
+			return srv.PermanentlyDelete(ctx, id)
+		default:
+			return fmt.Errorf("unknown cleanup action %q", action)
+		}
+	}
+}
+
+var mailLabelsCmd = &cobra.Command{
+	Use:         "labels",
+	Short:       "List all labels",
+	Annotations: map[string]string{scopesAnnotation: "gmail.read"},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaygmail.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		if detailed, _ := cmd.Flags().GetBool("detailed"); detailed {
+			printLabelsDetailed(ctx, srv)
+			return
+		}
+
+		labels, err := srv.GetLabels(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		if isJSONOutput() {
+			outputJSON(LabelsJSON{Labels: labels})
+			return
+		}
+
+		fmt.Println("Labels:")
+		for _, l := range labels {
+			fmt.Printf("  %s\n", l)
+		}
+	},
+}
+
+var mailAliasesCmd = &cobra.Command{
+	Use:         "aliases",
+	Short:       "List send-as addresses",
+	Annotations: map[string]string{scopesAnnotation: "gmail.read"},
+	Long: `List the addresses you can send mail from: your primary address and any
+aliases set up in Gmail's "Send mail as" settings. Verified addresses can be
+used with --from on send and reply.
+
+Examples:
+  gday mail aliases
+  gday mail aliases --json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaygmail.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		aliases, err := srv.ListSendAs(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		if isJSONOutput() {
+			result := AliasesJSON{Aliases: make([]AliasJSON, 0, len(aliases))}
+			for _, a := range aliases {
+				result.Aliases = append(result.Aliases, AliasJSON{
+					Email:       a.Email,
+					DisplayName: a.DisplayName,
+					Primary:     a.IsPrimary,
+					Default:     a.IsDefault,
+					Verified:    a.Verified,
+				})
+			}
+			outputJSON(result)
+			return
+		}
+
+		fmt.Println("Send-as addresses:")
+		for _, a := range aliases {
+			addr := a.Email
+			if a.DisplayName != "" {
+				addr = fmt.Sprintf("%s <%s>", a.DisplayName, a.Email)
+			}
+			var notes []string
+			if a.IsPrimary {
+				notes = append(notes, "primary")
+			}
+			if a.IsDefault {
+				notes = append(notes, "default")
+			}
+			if !a.Verified {
+				notes = append(notes, "unverified")
+			}
+			if len(notes) > 0 {
+				addr += " (" + strings.Join(notes, ", ") + ")"
+			}
+			fmt.Printf("  %s\n", addr)
+		}
+	},
+}
+
+var mailVacationCmd = &cobra.Command{
+	Use:         "vacation",
+	Short:       "Show or change the vacation responder",
+	Annotations: map[string]string{scopesAnnotation: "gmail.settings"},
+	Long: `Show or change the vacation responder (auto-reply).
+
+With no flags, prints the current setting. --enable turns the responder on
+and --disable turns it off; the other flags change the reply and when it's
+sent, keeping anything not given as it is.
+
+--start and --end are dates; replies are sent to mail received from the
+start of the first day through the end of the last. Pass an empty value
+(--end "") to remove either end of the window.
+
+Examples:
+  gday mail vacation
+  gday mail vacation --enable --subject "OOO" --body-file ooo.txt --start 2024-07-01 --end 2024-07-14
+  gday mail vacation --enable --subject "Away" --body "Back Monday" --contacts-only
+  gday mail vacation --disable`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaygmail.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		enable, _ := cmd.Flags().GetBool("enable")
+		disable, _ := cmd.Flags().GetBool("disable")
+		if enable && disable {
+			exitUsage("--enable and --disable can't be used together")
+		}
+
+		vacation, err := srv.GetVacation(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		changed := false
+		for _, name := range []string{"enable", "disable", "subject", "body", "body-file", "start", "end", "contacts-only", "domain-only"} {
+			changed = changed || cmd.Flags().Changed(name)
+		}
+		if !changed {
+			printVacation(vacation)
+			return
+		}
+
+		if err := applyVacationFlags(cmd, vacation); err != nil {
+			exitUsage("%v", err)
+		}
+		if enable {
+			vacation.Enabled = true
+		}
+		if disable {
+			vacation.Enabled = false
+		}
+		if vacation.Enabled && vacation.Subject == "" && vacation.Body == "" && vacation.HTMLBody == "" {
+			exitUsage("the vacation responder needs a --subject or a body (--body or --body-file)")
+		}
+
+		if err := srv.SetVacation(ctx, vacation); err != nil {
+			exitError("%v", err)
+		}
+		printVacation(vacation)
+	},
+}
+
+// applyVacationFlags changes the parts of vacation given on the command line
+func applyVacationFlags(cmd *cobra.Command, vacation *gdaygmail.Vacation) error {
+	flags := cmd.Flags()
+	if flags.Changed("subject") {
+		vacation.Subject, _ = flags.GetString("subject")
+	}
+	if flags.Changed("body") || flags.Changed("body-file") {
+		body, _ := flags.GetString("body")
+		if bodyFile, _ := flags.GetString("body-file"); bodyFile != "" {
+			data, err := os.ReadFile(bodyFile)
+			if err != nil {
+				return fmt.Errorf("failed to read body file: %v", err)
+			}
+			body = string(data)
+		}
+		// A new plain-text reply replaces any HTML one set in Gmail
+		vacation.Body, vacation.HTMLBody = body, ""
+	}
+	if flags.Changed("contacts-only") {
+		vacation.ContactsOnly, _ = flags.GetBool("contacts-only")
+	}
+	if flags.Changed("domain-only") {
+		vacation.DomainOnly, _ = flags.GetBool("domain-only")
+	}
+	if flags.Changed("start") {
+		start, _ := flags.GetString("start")
+		vacation.Start = time.Time{}
+		if start != "" {
+			day, err := parseDate(start)
+			if err != nil {
+				return fmt.Errorf("invalid --start: %v", err)
+			}
+			vacation.Start = day
+		}
+	}
+	if flags.Changed("end") {
+		end, _ := flags.GetString("end")
+		vacation.End = time.Time{}
+		if end != "" {
+			day, err := parseDate(end)
+			if err != nil {
+				return fmt.Errorf("invalid --end: %v", err)
+			}
+			// The last day is included
+			vacation.End = day.AddDate(0, 0, 1)
+		}
+	}
+	return nil
+}
+
+// printVacation prints the vacation responder setting
+func printVacation(v *gdaygmail.Vacation) {
+	if isJSONOutput() {
+		result := VacationJSON{
+			Enabled:      v.Enabled,
+			Subject:      v.Subject,
+			Body:         v.Body,
+			BodyHTML:     v.HTMLBody,
+			ContactsOnly: v.ContactsOnly,
+			DomainOnly:   v.DomainOnly,
+		}
+		if !v.Start.IsZero() {
+			result.Start = v.Start.Format(time.RFC3339)
+		}
+		if !v.End.IsZero() {
+			result.End = v.End.Format(time.RFC3339)
+		}
+		outputJSON(result)
+		return
+	}
+
+	if !v.Enabled {
+		fmt.Println("Vacation responder: off")
+		return
+	}
+	fmt.Println("Vacation responder: on")
+	if v.Subject != "" {
+		fmt.Printf("Subject: %s\n", v.Subject)
+	}
+	if !v.Start.IsZero() {
+		fmt.Printf("First day: %s\n", v.Start.Local().Format("Mon Jan 2, 2006"))
+	}
+	if !v.End.IsZero() {
+		// End is the moment replies stop, usually midnight after the last day
+		fmt.Printf("Last day: %s\n", v.End.Add(-time.Millisecond).Local().Format("Mon Jan 2, 2006"))
+	}
+	switch {
+	case v.ContactsOnly && v.DomainOnly:
+		fmt.Println("Replies to: contacts in your domain")
+	case v.ContactsOnly:
+		fmt.Println("Replies to: contacts only")
+	case v.DomainOnly:
+		fmt.Println("Replies to: your domain only")
+	default:
+		fmt.Println("Replies to: everyone")
+	}
+	body := v.Body
+	if body == "" && v.HTMLBody != "" {
+		body = gdaygmail.RenderHTML(v.HTMLBody)
+	}
+	if body != "" {
+		fmt.Printf("\n%s\n", strings.TrimSpace(body))
+	}
+}
+
+var mailFiltersCmd = &cobra.Command{
+	Use:   "filters",
+	Short: "Manage Gmail filters",
+	Long: `List, create, and delete Gmail filters, the rules applied to mail as it
+arrives.`,
+}
+
+var mailFiltersListCmd = &cobra.Command{
+	Use:         "list",
+	Short:       "List filters",
+	Annotations: map[string]string{scopesAnnotation: "gmail.read"},
+	Long: `List your Gmail filters with what each matches and what it does.
+
+Examples:
+  gday mail filters list
+  gday mail filters list --json > filters.json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaygmail.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		filters, err := srv.ListFilters(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		if isJSONOutput() {
+			result := FiltersJSON{Filters: make([]FilterJSON, 0, len(filters))}
+			for _, f := range filters {
+				result.Filters = append(result.Filters, filterToJSON(f))
+			}
+			outputJSON(result)
+			return
+		}
+
+		if len(filters) == 0 {
+			fmt.Println("No filters")
+			return
+		}
+		for i, f := range filters {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Println(f.ID)
+			fmt.Printf("  Matches: %s\n", describeFilterCriteria(f))
+			fmt.Printf("  Actions: %s\n", strings.Join(describeFilterActions(f), ", "))
+		}
+	},
+}
+
+var mailFiltersCreateCmd = &cobra.Command{
+	Use:         "create",
+	Short:       "Create a filter",
+	Annotations: map[string]string{scopesAnnotation: "gmail.settings"},
+	Long: `Create a Gmail filter. Criteria flags say which incoming mail it matches;
+all given criteria must match. Action flags say what happens to it. Labels
+are given by name.
+
+Examples:
+  gday mail filters create --from boss@co --add-label Important --skip-inbox
+  gday mail filters create --query "list:dev.example.com" --add-label Lists/Dev --skip-inbox --mark-read
+  gday mail filters create --subject invoice --has-attachment --add-label Receipts --star
+  gday mail filters create --from noreply@example.com --trash`,
+	Run: func(cmd *cobra.Command, args []string) {
+		filter := &gdaygmail.Filter{}
+		filter.From, _ = cmd.Flags().GetString("from")
+		filter.To, _ = cmd.Flags().GetString("to")
+		filter.Subject, _ = cmd.Flags().GetString("subject")
+		filter.Query, _ = cmd.Flags().GetString("query")
+		filter.NegatedQuery, _ = cmd.Flags().GetString("exclude")
+		filter.HasAttachment, _ = cmd.Flags().GetBool("has-attachment")
+		filter.AddLabels, _ = cmd.Flags().GetStringArray("add-label")
+		filter.RemoveLabels, _ = cmd.Flags().GetStringArray("remove-label")
+
+		// Shortcuts for Gmail's system labels
+		shortcuts := []struct {
+			flag   string
+			label  string
+			remove bool
+		}{
+			{"skip-inbox", "INBOX", true},
+			{"mark-read", "UNREAD", true},
+			{"star", "STARRED", false},
+			{"important", "IMPORTANT", false},
+			{"never-important", "IMPORTANT", true},
+			{"never-spam", "SPAM", true},
+			{"trash", "TRASH", false},
+		}
+		for _, sc := range shortcuts {
+			if on, _ := cmd.Flags().GetBool(sc.flag); !on {
+				continue
+			}
+			if sc.remove {
+				filter.RemoveLabels = append(filter.RemoveLabels, sc.label)
+			} else {
+				filter.AddLabels = append(filter.AddLabels, sc.label)
+			}
+		}
+
+		if filter.From == "" && filter.To == "" && filter.Subject == "" && filter.Query == "" &&
+			filter.NegatedQuery == "" && !filter.HasAttachment {
+			exitUsage("a filter needs at least one criterion (--from, --to, --subject, --query, --exclude, or --has-attachment)")
+		}
+		if len(filter.AddLabels) == 0 && len(filter.RemoveLabels) == 0 {
+			exitUsage("a filter needs at least one action (e.g. --add-label, --skip-inbox, --mark-read)")
+		}
+
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaygmail.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		created, err := srv.CreateFilter(ctx, filter)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		if isJSONOutput() {
+			outputJSON(filterToJSON(created))
+			return
+		}
+		fmt.Printf("Filter created: %s\n", created.ID)
+		fmt.Printf("  Matches: %s\n", describeFilterCriteria(created))
+		fmt.Printf("  Actions: %s\n", strings.Join(describeFilterActions(created), ", "))
+	},
+}
+
+var mailFiltersDeleteCmd = &cobra.Command{
+	Use:         "delete <filter-id>",
+	Short:       "Delete a filter",
+	Annotations: map[string]string{scopesAnnotation: "gmail.settings"},
+	Long: `Delete a Gmail filter by the ID shown by 'gday mail filters list'. Mail it
+already filtered is left as it is.
+
+Examples:
+  gday mail filters delete ANe1Bmj1_gI5kzfVKpSW4JkO9ZbdNO5HR0UWvQ
+  gday mail filters delete ANe1Bmj1_gI5kzfVKpSW4JkO9ZbdNO5HR0UWvQ --yes`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaygmail.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		if yes, _ := cmd.Flags().GetBool("yes"); !yes && !confirm(fmt.Sprintf("Delete filter %s?", args[0])) {
+			return
+		}
+
+		if err := srv.DeleteFilter(ctx, args[0]); err != nil {
+			exitError("%v", err)
+		}
+
+		if isJSONOutput() {
+			outputJSON(StatusJSON{Status: "deleted", Message: args[0]})
+			return
+		}
+		fmt.Printf("Deleted filter: %s\n", args[0])
+	},
+}
+
+// describeFilterCriteria formats a filter's criteria as search terms
+func describeFilterCriteria(f *gdaygmail.Filter) string {
+	var terms []string
+	quote := func(s string) string {
+		if strings.ContainsAny(s, " \t") {
+			return `"` + s + `"`
+		}
+		return s
+	}
+	if f.From != "" {
+		terms = append(terms, "from:"+quote(f.From))
+	}
+	if f.To != "" {
+		terms = append(terms, "to:"+quote(f.To))
+	}
+	if f.Subject != "" {
+		terms = append(terms, "subject:"+quote(f.Subject))
+	}
+	if f.HasAttachment {
+		terms = append(terms, "has:attachment")
+	}
+	if f.Query != "" {
+		terms = append(terms, f.Query)
+	}
+	if f.NegatedQuery != "" {
+		terms = append(terms, "-("+f.NegatedQuery+")")
+	}
+	if len(terms) == 0 {
+		return "(everything)"
+	}
+	return strings.Join(terms, " ")
+}
+
+// describeFilterActions lists a filter's actions, naming the system-label
+// changes the way Gmail's settings do
+func describeFilterActions(f *gdaygmail.Filter) []string {
+	var actions []string
+	for _, l := range f.AddLabels {
+		switch l {
+		case "STARRED":
+			actions = append(actions, "star")
+		case "IMPORTANT":
+			actions = append(actions, "mark important")
+		case "TRASH":
+			actions = append(actions, "delete")
+		default:
+			actions = append(actions, "label "+l)
+		}
+	}
+	for _, l := range f.RemoveLabels {
+		switch l {
+		case "INBOX":
+			actions = append(actions, "skip inbox")
+		case "UNREAD":
+			actions = append(actions, "mark read")
+		case "IMPORTANT":
+			actions = append(actions, "never important")
+		case "SPAM":
+			actions = append(actions, "never spam")
+		default:
+			actions = append(actions, "remove label "+l)
+		}
+	}
+	if f.Forward != "" {
+		actions = append(actions, "forward to "+f.Forward)
+	}
+	if len(actions) == 0 {
+		actions = append(actions, "(none)")
+	}
+	return actions
+}
+
+func filterToJSON(f *gdaygmail.Filter) FilterJSON {
+	return FilterJSON{
+		ID:            f.ID,
+		From:          f.From,
+		To:            f.To,
+		Subject:       f.Subject,
+		Query:         f.Query,
+		NegatedQuery:  f.NegatedQuery,
+		HasAttachment: f.HasAttachment,
+		AddLabels:     f.AddLabels,
+		RemoveLabels:  f.RemoveLabels,
+		Forward:       f.Forward,
+	}
+}
+
+var mailDraftsCmd = &cobra.Command{
+	Use:   "drafts",
+	Short: "Manage drafts",
+	Long: `List, review, send, and delete drafts, such as those saved with
+'gday mail send --draft'.`,
+}
+
+var mailDraftsListCmd = &cobra.Command{
+	Use:         "list",
+	Short:       "List drafts",
+	Annotations: map[string]string{scopesAnnotation: "gmail.read"},
+	Long: `List drafts with their IDs, recipients, and subjects.
+
+Examples:
+  gday mail drafts list
+  gday mail drafts list -n 50 --json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaygmail.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		n, _ := cmd.Flags().GetInt64("number")
+		drafts, err := srv.ListDrafts(ctx, n)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		if isJSONOutput() {
+			result := DraftsJSON{Count: len(drafts), Drafts: make([]DraftJSON, 0, len(drafts))}
+			for _, d := range drafts {
+				result.Drafts = append(result.Drafts, draftToJSON(d))
+			}
+			outputJSON(result)
+			return
+		}
+
+		if len(drafts) == 0 {
+			fmt.Println("No drafts")
+			return
+		}
+		for _, d := range drafts {
+			to := d.Message.To
+			if to == "" {
+				to = "(no recipient)"
+			}
+			subject := d.Message.Subject
+			if subject == "" {
+				subject = "(no subject)"
+			}
+			fmt.Printf("%-22s  %-25s  %s\n", d.ID, truncate(to, 25), truncate(subject, 50))
+		}
+	},
+}
+
+var mailDraftsShowCmd = &cobra.Command{
+	Use:         "show <draft-id>",
+	Short:       "Show a draft",
+	Annotations: map[string]string{scopesAnnotation: "gmail.read"},
+	Long: `Show a draft's headers and body.
+
+Examples:
+  gday mail drafts show r-1234567890123456789
+  gday mail drafts show r-1234567890123456789 --json`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaygmail.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		draft, err := srv.GetDraft(ctx, args[0])
+		if err != nil {
+			exitError("%v", err)
+		}
+		if draft.Message == nil {
+			exitUsage("draft %s has no message", args[0])
+		}
+
+		if isJSONOutput() {
+			outputJSON(draftToJSON(draft))
+			return
+		}
+		fmt.Printf("Draft: %s\n", draft.ID)
+		printFormattedMessage(draft.Message)
+	},
+}
+
+var mailDraftsSendCmd = &cobra.Command{
+	Use:         "send <draft-id>",
+	Short:       "Send a draft",
+	Annotations: map[string]string{scopesAnnotation: "gmail.send"},
+	Long: `Send a draft as it is. Once sent, it's no longer a draft.
+
+Examples:
+  gday mail drafts send r-1234567890123456789`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaygmail.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		msg, err := srv.SendDraft(ctx, args[0])
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		if isJSONOutput() {
+			outputJSON(SendResultJSON{MessageID: msg.ID, Status: "sent"})
+			return
+		}
+		fmt.Printf("Message sent: %s\n", msg.ID)
+	},
+}
+
+var mailDraftsDeleteCmd = &cobra.Command{
+	Use:         "delete <draft-id>",
+	Short:       "Delete a draft",
+	Annotations: map[string]string{scopesAnnotation: "gmail.modify"},
+	Long: `Permanently delete a draft. Deleted drafts don't go to the trash.
+
+Examples:
+  gday mail drafts delete r-1234567890123456789
+  gday mail drafts delete r-1234567890123456789 --yes`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := newContext()
+		defer cancel()
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		srv, err := gdaygmail.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		if yes, _ := cmd.Flags().GetBool("yes"); !yes && !confirm(fmt.Sprintf("Permanently delete draft %s?", args[0])) {
+			return
+		}
+
+		if err := srv.DeleteDraft(ctx, args[0]); err != nil {
+			exitError("%v", err)
+		}
+
+		if isJSONOutput() {
+			outputJSON(StatusJSON{Status: "deleted", Message: args[0]})
+			return
+		}
+		fmt.Printf("Deleted draft: %s\n", args[0])
+	},
+}
+
+func draftToJSON(d *gdaygmail.Draft) DraftJSON {
+	result := DraftJSON{ID: d.ID}
+	if d.Message != nil {
+		msg := messageToJSON(d.Message)
+		result.Message = &msg
+	}
+	return result
+}
+
+func init() {
+	rootCmd.AddCommand(mailCmd)
+
+	// List command
+	mailCmd.AddCommand(mailListCmd)
+	mailListCmd.Flags().Int64P("number", "n", 10, "Number of messages to list")
+	mailListCmd.Flags().Bool("unread", false, "Show only unread messages")
+	mailListCmd.Flags().Bool("important", false, "Show only messages marked important")
+	mailListCmd.Flags().StringP("query", "q", "", "Gmail search query")
+	mailListCmd.Flags().Bool("relative", false, "Show relative timestamps for recent messages")
+	mailListCmd.Flags().String("sort", "", "Sort by date, from, or subject (prefix with - for descending)")
+	mailListCmd.Flags().String("after", "", "Only messages at or after this time (e.g. \"2024-01-15 14:30\" or 14:30 for today)")
+	mailListCmd.Flags().String("since", "", "Same as --after (e.g. \"3 days ago\", yesterday, or monday)")
+	mailListCmd.Flags().String("before", "", "Only messages before this time")
+	mailListCmd.Flags().String("page-token", "", "Resume a listing from a previous next_page_token")
+
+	// Read command
+	mailCmd.AddCommand(mailReadCmd)
+	mailReadCmd.Flags().Bool("raw", false, "Show raw output without formatting")
+	mailReadCmd.Flags().Bool("source", false, "Write the message's original RFC822 source to stdout")
+	mailReadCmd.Flags().Bool("mark-read", false, "Mark message as read after viewing")
+	mailReadCmd.Flags().Bool("headers-only", false, "Show all message headers without the body")
+	mailReadCmd.Flags().Bool("extract-links", false, "List the links in the message body")
+	mailReadCmd.Flags().Bool("html", false, "Print the HTML body as-is")
+	mailReadCmd.Flags().Bool("render", false, "Show the HTML body as formatted text, with links and list bullets")
+
+	// Thread command
+	mailCmd.AddCommand(mailThreadCmd)
+	mailThreadCmd.Flags().String("save", "", "Save the thread to this directory: one text file per message, attachments, and manifest.json")
+
+	// Search command
+	mailCmd.AddCommand(mailSearchCmd)
+	mailSearchCmd.Flags().Int64P("number", "n", 20, "Maximum number of results")
+	mailSearchCmd.Flags().Bool("relative", false, "Show relative timestamps for recent messages")
+	mailSearchCmd.Flags().String("sort", "", "Sort by date, from, or subject (prefix with - for descending)")
+	mailSearchCmd.Flags().String("after", "", "Only messages at or after this time (e.g. \"2024-01-15 14:30\" or 14:30 for today)")
+	mailSearchCmd.Flags().String("since", "", "Same as --after (e.g. \"3 days ago\", yesterday, or monday)")
+	mailSearchCmd.Flags().String("before", "", "Only messages before this time")
+	mailSearchCmd.Flags().String("page-token", "", "Resume a search from a previous next_page_token")
+
+	// Send command
+	mailCmd.AddCommand(mailSendCmd)
+	mailSendCmd.Flags().StringP("to", "t", "", "Recipient email address")
+	mailSendCmd.Flags().StringP("subject", "s", "", "Email subject")
+	mailSendCmd.Flags().StringP("body", "b", "", "Email body text")
+	mailSendCmd.Flags().String("body-file", "", "Read body from file")
+	mailSendCmd.Flags().Bool("body-stdin", false, "Read body from stdin")
+	mailSendCmd.Flags().StringSlice("cc", nil, "CC recipients")
+	mailSendCmd.Flags().StringSlice("bcc", nil, "BCC recipients")
+	mailSendCmd.Flags().Bool("draft", false, "Create draft instead of sending")
+	mailSendCmd.Flags().Bool("markdown", false, "Render the body as Markdown and send it as HTML with a plain-text fallback (default: on for .md body files)")
+	mailSendCmd.Flags().Bool("html", false, "Send the body as HTML with a generated plain-text fallback (default: on for .html body files)")
+	mailSendCmd.Flags().StringArrayP("attach", "a", nil, "Attach a file (repeatable)")
+	mailSendCmd.Flags().StringArray("inline-image", nil, "Embed an image the HTML body refers to as cid:name (cid:name=path, repeatable)")
+	mailSendCmd.Flags().StringArray("var", nil, "Fill a {{.key}} placeholder in the body (key=value, repeatable)")
+	mailSendCmd.Flags().String("priority", "", "Mark the message as high, normal, or low priority")
+	mailSendCmd.Flags().Bool("raw", false, "Send a complete RFC822 message read from stdin")
+	mailSendCmd.Flags().String("from", "", "Send from a verified send-as address (see 'gday mail aliases')")
+	addBatchFlags(mailSendCmd)
+
+	// Reply command
+	mailCmd.AddCommand(mailReplyCmd)
+	mailReplyCmd.Flags().StringP("body", "b", "", "Reply body text")
+	mailReplyCmd.Flags().String("body-file", "", "Read body from file")
+	mailReplyCmd.Flags().Bool("body-stdin", false, "Read body from stdin")
+	mailReplyCmd.Flags().String("from", "", "Reply from a verified send-as address (see 'gday mail aliases')")
+	mailReplyCmd.Flags().StringSlice("cc", nil, "CC recipients")
+	mailReplyCmd.Flags().StringSlice("bcc", nil, "BCC recipients")
+	mailReplyCmd.Flags().StringArrayP("attach", "a", nil, "Attach a file (repeatable)")
+	mailReplyCmd.Flags().Bool("draft", false, "Save the reply as a draft instead of sending")
+
+	// Forward command
+	mailCmd.AddCommand(mailForwardCmd)
+	mailForwardCmd.Flags().StringP("to", "t", "", "Recipient email address")
+	mailForwardCmd.Flags().StringP("body", "b", "", "Note to add above the forwarded message")
+
+	// Attachment command
+	mailCmd.AddCommand(mailAttachmentCmd)
+	mailAttachmentCmd.Flags().StringP("output", "o", ".", "Output directory for downloads")
+	mailAttachmentCmd.Flags().Bool("all", false, "Download all attachments")
+	mailAttachmentCmd.Flags().StringSlice("type", nil, "Only download attachments whose MIME type matches (glob, e.g. image/*; repeatable)")
+	mailAttachmentCmd.Flags().Bool("overwrite", false, "Replace existing files instead of saving as \"name (1).ext\"")
+
+	// Mark read/unread commands
+	mailCmd.AddCommand(mailMarkReadCmd)
+	addIDsFromFlag(mailMarkReadCmd)
+	addBatchFlags(mailMarkReadCmd)
+	mailMarkReadCmd.Flags().StringP("query", "q", "", "Mark every unread message matching this Gmail search")
+	mailMarkReadCmd.Flags().Int64P("number", "n", 500, "Maximum number of messages to mark with --query")
+	mailCmd.AddCommand(mailMarkUnreadCmd)
+	addIDsFromFlag(mailMarkUnreadCmd)
+	addBatchFlags(mailMarkUnreadCmd)
+	mailMarkUnreadCmd.Flags().StringP("query", "q", "", "Mark every read message matching this Gmail search")
+	mailMarkUnreadCmd.Flags().Int64P("number", "n", 500, "Maximum number of messages to mark with --query")
+
+	// Label command
+	mailCmd.AddCommand(mailLabelCmd)
+	mailLabelCmd.Flags().StringArray("add", nil, "Label to add (repeatable)")
+	mailLabelCmd.Flags().StringArray("remove", nil, "Label to remove (repeatable)")
+	addIDsFromFlag(mailLabelCmd)
+	addBatchFlags(mailLabelCmd)
+
+	// Export command
+	mailCmd.AddCommand(mailExportCmd)
+	mailExportCmd.Flags().StringP("output", "o", "", "File to write a single message to (default: stdout)")
+	mailExportCmd.Flags().StringP("query", "q", "", "Gmail search query selecting messages for --mbox")
+	mailExportCmd.Flags().String("mbox", "", "Append the messages matching --query to this mbox file")
+	mailExportCmd.Flags().Int64P("number", "n", 0, "With --query, export at most this many messages (0 = all)")
+
+	// Archive/unarchive commands
+	mailCmd.AddCommand(mailArchiveCmd)
+	addIDsFromFlag(mailArchiveCmd)
+	addBatchFlags(mailArchiveCmd)
+	mailCmd.AddCommand(mailUnarchiveCmd)
+	addIDsFromFlag(mailUnarchiveCmd)
+	addBatchFlags(mailUnarchiveCmd)
+
+	// Trash/untrash commands
+	mailCmd.AddCommand(mailTrashCmd)
+	addIDsFromFlag(mailTrashCmd)
+	addBatchFlags(mailTrashCmd)
+	mailCmd.AddCommand(mailUntrashCmd)
+	addIDsFromFlag(mailUntrashCmd)
+	addBatchFlags(mailUntrashCmd)
+
+	// Delete command
+	mailCmd.AddCommand(mailDeleteCmd)
+	addIDsFromFlag(mailDeleteCmd)
+	addBatchFlags(mailDeleteCmd)
+
+	// Cleanup command
+	mailCmd.AddCommand(mailCleanupCmd)
+	mailCleanupCmd.Flags().StringP("query", "q", "", "Gmail search query selecting the messages")
+	mailCleanupCmd.Flags().String("action", "archive", "Action to apply: archive, trash, or delete")
+	mailCleanupCmd.Flags().Int64P("number", "n", 100, "Maximum number of messages to process")
+	addBatchFlags(mailCleanupCmd)
+
+	// Labels command
+	mailCmd.AddCommand(mailLabelsCmd)
+	mailLabelsCmd.Flags().Bool("detailed", false, "Show label type and message counts")
+
+	// Aliases command
+	mailCmd.AddCommand(mailAliasesCmd)
+
+	// Vacation command
+	mailCmd.AddCommand(mailVacationCmd)
+	mailVacationCmd.Flags().Bool("enable", false, "Turn the vacation responder on")
+	mailVacationCmd.Flags().Bool("disable", false, "Turn the vacation responder off")
+	mailVacationCmd.Flags().StringP("subject", "s", "", "Reply subject")
+	mailVacationCmd.Flags().StringP("body", "b", "", "Reply text")
+	mailVacationCmd.Flags().String("body-file", "", "Read the reply text from a file")
+	mailVacationCmd.Flags().String("start", "", "First day to send replies (e.g. 2024-07-01)")
+	mailVacationCmd.Flags().String("end", "", "Last day to send replies")
+	mailVacationCmd.Flags().Bool("contacts-only", false, "Only reply to people in your contacts")
+	mailVacationCmd.Flags().Bool("domain-only", false, "Only reply to people in your domain (Google Workspace)")
+
+	// Filters commands
+	mailCmd.AddCommand(mailFiltersCmd)
+	mailFiltersCmd.AddCommand(mailFiltersListCmd)
+	mailFiltersCmd.AddCommand(mailFiltersCreateCmd)
+	mailFiltersCreateCmd.Flags().String("from", "", "Match mail from this sender")
+	mailFiltersCreateCmd.Flags().String("to", "", "Match mail to this recipient")
+	mailFiltersCreateCmd.Flags().String("subject", "", "Match mail with this in the subject")
+	mailFiltersCreateCmd.Flags().StringP("query", "q", "", "Match mail matching this Gmail search query")
+	mailFiltersCreateCmd.Flags().String("exclude", "", "Don't match mail matching this Gmail search query")
+	mailFiltersCreateCmd.Flags().Bool("has-attachment", false, "Match mail with attachments")
+	mailFiltersCreateCmd.Flags().StringArray("add-label", nil, "Apply this label (repeatable)")
+	mailFiltersCreateCmd.Flags().StringArray("remove-label", nil, "Remove this label (repeatable)")
+	mailFiltersCreateCmd.Flags().Bool("skip-inbox", false, "Skip the inbox (archive it)")
+	mailFiltersCreateCmd.Flags().Bool("mark-read", false, "Mark it as read")
+	mailFiltersCreateCmd.Flags().Bool("star", false, "Star it")
+	mailFiltersCreateCmd.Flags().Bool("important", false, "Mark it as important")
+	mailFiltersCreateCmd.Flags().Bool("never-important", false, "Never mark it as important")
+	mailFiltersCreateCmd.Flags().Bool("never-spam", false, "Never send it to spam")
+	mailFiltersCreateCmd.Flags().Bool("trash", false, "Delete it (move to trash)")
+	mailFiltersCmd.AddCommand(mailFiltersDeleteCmd)
+	mailFiltersDeleteCmd.Flags().BoolP("yes", "y", false, "Delete without asking")
+
+	// Drafts commands
+	mailCmd.AddCommand(mailDraftsCmd)
+	mailDraftsCmd.AddCommand(mailDraftsListCmd)
+	mailDraftsListCmd.Flags().Int64P("number", "n", 20, "Number of drafts to list")
+	mailDraftsCmd.AddCommand(mailDraftsShowCmd)
+	mailDraftsCmd.AddCommand(mailDraftsSendCmd)
+	mailDraftsCmd.AddCommand(mailDraftsDeleteCmd)
+	mailDraftsDeleteCmd.Flags().BoolP("yes", "y", false, "Delete without asking")
+}
+
+// Helper functions
+
+// messagesTable lays out a message list. The table shows status markers
+// and abbreviated values; CSV gets full values and a column per status.
+func messagesTable(messages []*gdaygmail.Message, relative, csv bool) *table {
+	if csv {
+		t := &table{columns: []column{
+			{name: "id"}, {name: "thread_id"}, {name: "date"}, {name: "from"}, {name: "to"},
+			{name: "subject"}, {name: "unread"}, {name: "important"}, {name: "priority"},
+		}}
+		for _, m := range messages {
+			t.rows = append(t.rows, []string{
+				m.ID, m.ThreadID, m.Date.Format(time.RFC3339), m.From, m.To, m.Subject,
+				strconv.FormatBool(m.IsUnread), strconv.FormatBool(m.IsImportant), m.Priority,
+			})
+		}
+		return t
+	}
+
+	t := &table{columns: []column{
+		{name: ""}, {name: "id"}, {name: "from", width: 20}, {name: "subject", width: 40}, {name: "date"},
+	}}
+	for _, m := range messages {
+		t.rows = append(t.rows, []string{
+			messageMarkers(m), m.ID, m.From, m.Subject, formatMessageDate(m.Date, relative),
+		})
+	}
+	return t
+}
+
+// messageMarkers returns the status column for a message list: * if it's
+// unread, > if Gmail marked it important, and ! if it has high priority
+func messageMarkers(m *gdaygmail.Message) string {
+	markers := []byte("   ")
+	if m.IsUnread {
+		markers[0] = '*'
+	}
+	if m.IsImportant {
+		markers[1] = '>'
+	}
+	if m.Priority == "high" {
+		markers[2] = '!'
+	}
+	return string(markers)
+}
+
+// RecipientConfirmThreshold is the number of recipients above which
+// mail send asks for confirmation unless --yes is given
+const RecipientConfirmThreshold = 5
+
+// confirmRecipients asks before sending to more than
+// RecipientConfirmThreshold recipients, and lists them under --dry-run.
+// Returns false if the message should not be sent.
+func confirmRecipients(cmd *cobra.Command, recipients []string) bool {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	if !dryRun && len(recipients) <= RecipientConfirmThreshold {
+		return true
+	}
+	return confirmBatch(cmd, "send to", recipients, true)
+}
+
+// splitAddresses splits a comma-separated address list
+func splitAddresses(s string) []string {
+	var addrs []string
+	for _, a := range strings.Split(s, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			addrs = append(addrs, a)
+		}
+	}
+	return addrs
+}
+
+// printLabelsDetailed prints labels with their type and message counts
+func printLabelsDetailed(ctx context.Context, srv *gdaygmail.Service) {
+	labels, err := srv.ListLabelsDetailed(ctx)
+	if err != nil {
+		exitError("%v", err)
+	}
+
+	if isJSONOutput() {
+		jsonLabels := make([]LabelJSON, 0, len(labels))
+		for _, l := range labels {
+			jsonLabels = append(jsonLabels, LabelJSON{
+				ID:              l.ID,
+				Name:            l.Name,
+				Type:            l.Type,
+				MessagesTotal:   l.MessagesTotal,
+				MessagesUnread:  l.MessagesUnread,
+				BackgroundColor: l.BackgroundColor,
+				TextColor:       l.TextColor,
+			})
+		}
+		outputJSON(LabelsDetailedJSON{Labels: jsonLabels})
+		return
+	}
+
+	fmt.Printf("%-30s  %-6s  %8s  %8s\n", "LABEL", "TYPE", "TOTAL", "UNREAD")
+	for _, l := range labels {
+		fmt.Printf("%-30s  %-6s  %8d  %8d\n", truncate(l.Name, 30), l.Type, l.MessagesTotal, l.MessagesUnread)
+	}
+}
+
+// showUploadProgress warns about large outgoing messages and reports their
+// upload progress on stderr
+func showUploadProgress(srv *gdaygmail.Service) {
+	if isJSONOutput() {
+		return
+	}
+	srv.UploadProgress = func(sent, total int64) {
+		mb := func(n int64) float64 { return float64(n) / (1 << 20) }
+		if sent == 0 {
+			fmt.Fprintf(os.Stderr, "Warning: message is %.1f MB; large messages are slow to send and may be rejected by recipients' servers\n", mb(total))
+		}
+		fmt.Fprintf(os.Stderr, "\rUploading: %.1f / %.1f MB", mb(sent), mb(total))
+	}
+	// End the progress line before anything else is printed
+	srv.UploadDone = func() {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// sendRawFromStdin sends a pre-formatted RFC822 message read from stdin,
+// confirming first if it has many recipients
+func sendRawFromStdin(ctx context.Context, cmd *cobra.Command, srv *gdaygmail.Service) {
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		exitError("failed to read message from stdin: %v", err)
+	}
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		exitError("invalid message: %v", err)
+	}
+	var recipients []string
+	for _, h := range []string{"To", "Cc", "Bcc"} {
+		recipients = append(recipients, splitAddresses(parsed.Header.Get(h))...)
+	}
+	if !confirmRecipients(cmd, recipients) {
+		return
+	}
+
+	msg, err := srv.SendRawMessage(ctx, data)
+	if err != nil {
+		exitError("%v", err)
+	}
+	if isJSONOutput() {
+		outputJSON(SendResultJSON{MessageID: msg.ID, Status: "sent"})
+		return
+	}
+	fmt.Printf("Message sent: %s\n", msg.ID)
+}
+
+// matchMimeType reports whether a MIME type matches any of the glob
+// patterns, ignoring case and parameters
+func matchMimeType(mimeType string, patterns []string) bool {
+	mimeType, _, _ = strings.Cut(strings.ToLower(mimeType), ";")
+	mimeType = strings.TrimSpace(mimeType)
+	for _, p := range patterns {
+		if ok, err := path.Match(strings.ToLower(strings.TrimSpace(p)), mimeType); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// attachmentDownloader is the part of the Gmail service saveThread uses
+type attachmentDownloader interface {
+	DownloadAttachment(ctx context.Context, messageID, attachmentID, filename, outDir string, overwrite bool) (string, error)
+}
+
+// saveThread writes each message in a thread to dir as a text file, with its
+// attachments in a subdirectory, plus a manifest.json describing it all
+func saveThread(ctx context.Context, srv attachmentDownloader, threadID string, messages []*gdaygmail.Message, dir string) (*ThreadManifestJSON, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	manifest := &ThreadManifestJSON{ThreadID: threadID, SavedAt: time.Now()}
+	if len(messages) > 0 {
+		manifest.Subject = messages[0].Subject
+	}
+
+	for i, msg := range messages {
+		prefix := fmt.Sprintf("%02d-%s", i+1, msg.Date.Format("20060102-1504"))
+		saved := SavedMessageJSON{
+			ID:      msg.ID,
+			From:    msg.From,
+			Date:    msg.Date,
+			Subject: msg.Subject,
+			File:    prefix + ".txt",
+		}
+
+		var text strings.Builder
+		fmt.Fprintf(&text, "From: %s\n", msg.From)
+		fmt.Fprintf(&text, "To: %s\n", msg.To)
+		fmt.Fprintf(&text, "Date: %s\n", msg.Date.Format(time.RFC1123Z))
+		fmt.Fprintf(&text, "Subject: %s\n\n", msg.Subject)
+		text.WriteString(msg.Body)
+		if err := os.WriteFile(filepath.Join(dir, saved.File), []byte(text.String()), 0644); err != nil {
+			return nil, fmt.Errorf("failed to save message: %w", err)
+		}
+
+		if len(msg.Attachments) > 0 {
+			attDir := filepath.Join(dir, prefix+"-attachments")
+			if err := os.MkdirAll(attDir, 0755); err != nil {
+				return nil, fmt.Errorf("failed to create directory: %w", err)
+			}
+			for _, att := range msg.Attachments {
+				path, err := srv.DownloadAttachment(ctx, msg.ID, att.ID, att.Filename, attDir, false)
+				if err != nil {
+					return nil, err
+				}
+				rel, _ := filepath.Rel(dir, path)
+				saved.Attachments = append(saved.Attachments, SavedAttachmentJSON{
+					Filename: att.Filename,
+					Path:     rel,
+					MimeType: att.MimeType,
+					Size:     att.Size,
+				})
+			}
+		}
+
+		manifest.Messages = append(manifest.Messages, saved)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "manifest.json"), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to save manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// printLinks prints a numbered list of links
+func printLinks(links []gdaygmail.Link) {
+	if isJSONOutput() {
+		jsonLinks := make([]LinkJSON, 0, len(links))
+		for _, l := range links {
+			jsonLinks = append(jsonLinks, LinkJSON{Text: l.Text, Href: l.Href})
+		}
+		outputJSON(LinksJSON{Count: len(jsonLinks), Links: jsonLinks})
+		return
+	}
+
+	if len(links) == 0 {
+		fmt.Println("No links found")
+		return
+	}
+	for i, l := range links {
+		if l.Text != "" && l.Text != l.Href {
+			fmt.Printf("%3d. %s\n     %s\n", i+1, truncate(l.Text, 70), l.Href)
+		} else {
+			fmt.Printf("%3d. %s\n", i+1, l.Href)
+		}
+	}
+}
+
+// inlineImagesFromFlags loads the --inline-image cid:name=path mappings
+func inlineImagesFromFlags(cmd *cobra.Command) ([]gdaygmail.InlineImage, error) {
+	mappings, _ := cmd.Flags().GetStringArray("inline-image")
+	var images []gdaygmail.InlineImage
+	for _, m := range mappings {
+		cid, path, ok := strings.Cut(m, "=")
+		if !ok || path == "" {
+			return nil, fmt.Errorf("invalid --inline-image %q (use cid:name=path)", m)
+		}
+		img, err := gdaygmail.LoadInlineImage(cid, path)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+	return images, nil
+}
+
+// isMarkdownFile reports whether a body file's extension marks it as Markdown
+func isMarkdownFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return true
+	}
+	return false
+}
+
+// isHTMLFile reports whether a body file's extension marks it as HTML
+func isHTMLFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm":
+		return true
+	}
+	return false
+}
+
+// parseVars parses the --var key=value flags
+func parseVars(cmd *cobra.Command) (map[string]string, error) {
+	pairs, _ := cmd.Flags().GetStringArray("var")
+	vars := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --var %q (use key=value)", pair)
+		}
+		vars[key] = value
+	}
+	return vars, nil
+}
+
+// renderBodyTemplate fills {{.key}} placeholders in body from vars. Without
+// vars the body is returned unchanged, so literal braces are safe.
+func renderBodyTemplate(body string, vars map[string]string) (string, error) {
+	if len(vars) == 0 {
+		return body, nil
+	}
+	tmpl, err := template.New("body").Option("missingkey=error").Parse(body)
+	if err != nil {
+		return "", fmt.Errorf("invalid body template: %w", err)
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("failed to fill body template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// renderMarkdown converts a Markdown body to HTML
+func renderMarkdown(body string) (string, error) {
+	var buf bytes.Buffer
+	if err := goldmark.Convert([]byte(body), &buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// messageTimeRange parses --after (or --since) and --before. Either may be
+// zero. Dates may be relative ("3 days ago", "yesterday"); a weekday is the
+// most recent one.
+func messageTimeRange(cmd *cobra.Command) (after, before time.Time) {
+	if cmd.Flags().Changed("after") && cmd.Flags().Changed("since") {
+		exitUsage("--since is another name for --after; use one")
+	}
+	parse := func(flag string) time.Time {
+		s, _ := cmd.Flags().GetString(flag)
+		if s == "" {
+			return time.Time{}
+		}
+		if t, err := parseDateTime(s, time.Now()); err == nil {
+			return t
+		}
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t
+		}
+		if t, err := parsePastDate(s); err == nil {
+			return t
+		}
+		exitUsage("invalid --%s %q (use YYYY-MM-DD, YYYY-MM-DD HH:MM, HH:MM, or a date such as yesterday, friday, or \"3 days ago\")", flag, s)
+		return time.Time{}
+	}
+	after = parse("after")
+	if cmd.Flags().Changed("since") {
+		after = parse("since")
+	}
+	return after, parse("before")
+}
+
+// dayRangeQuery returns Gmail after:/before: terms selecting the whole days
+// around a time range. Gmail only filters by day, so the results still need
+// filterMessagesByTime; a day of slack on each side covers time zone
+// differences.
+func dayRangeQuery(after, before time.Time) string {
+	var terms []string
+	if !after.IsZero() {
+		terms = append(terms, "after:"+after.AddDate(0, 0, -1).Format("2006/01/02"))
+	}
+	if !before.IsZero() {
+		terms = append(terms, "before:"+before.AddDate(0, 0, 1).Format("2006/01/02"))
+	}
+	return strings.Join(terms, " ")
+}
+
+// filterMessagesByTime keeps messages dated within [after, before). Zero
+// bounds are ignored.
+func filterMessagesByTime(messages []*gdaygmail.Message, after, before time.Time) []*gdaygmail.Message {
+	if after.IsZero() && before.IsZero() {
+		return messages
+	}
+	var filtered []*gdaygmail.Message
+	for _, m := range messages {
+		if !after.IsZero() && m.Date.Before(after) {
+			continue
+		}
+		if !before.IsZero() && !m.Date.Before(before) {
+			continue
+		}
+		filtered = append(filtered, m)
+	}
+	return filtered
+}
+
+// sortMessages sorts messages in place by the given key ("date", "from", or
+// "subject", prefixed with "-" for descending). An empty key keeps API order.
+func sortMessages(messages []*gdaygmail.Message, key string) error {
+	if key == "" {
+		return nil
+	}
+	desc := strings.HasPrefix(key, "-")
+	key = strings.TrimPrefix(key, "-")
+
+	var less func(a, b *gdaygmail.Message) bool
+	switch key {
+	case "date":
+		less = func(a, b *gdaygmail.Message) bool { return a.Date.Before(b.Date) }
+	case "from":
+		less = func(a, b *gdaygmail.Message) bool { return strings.ToLower(a.From) < strings.ToLower(b.From) }
+	case "subject":
+		less = func(a, b *gdaygmail.Message) bool { return strings.ToLower(a.Subject) < strings.ToLower(b.Subject) }
+	default:
+		return fmt.Errorf("invalid --sort %q (use date, from, or subject, with - for descending)", key)
+	}
+
+	sort.SliceStable(messages, func(i, j int) bool {
+		if desc {
+			return less(messages[j], messages[i])
+		}
+		return less(messages[i], messages[j])
+	})
+	return nil
+}
+
+// printNextPage tells the user how to see the next page of results, if any
+func printNextPage(token string) {
+	if token != "" {
+		fmt.Printf("\nMore results: rerun with --page-token %s\n", token)
+	}
+}
+
+// shortID returns the first n characters of an ID for display, or the whole
+// ID if it is shorter
+func shortID(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
+
+// truncate shortens s to at most maxLen runes, ending in "..." when there's
+// room for it
+func truncate(s string, maxLen int) string {
+	if maxLen <= 0 {
+		return ""
+	}
+	runes := []rune(s)
+	if len(runes) <= maxLen {
 		return s
 	}
-	return s[:maxLen-3] + "..."
+	if maxLen <= 3 {
+		return string(runes[:maxLen])
+	}
+	return string(runes[:maxLen-3]) + "..."
 }
 
 func formatDate(t time.Time) string {
@@ -588,11 +2800,53 @@ func formatDate(t time.Time) string {
 	return t.Format("Jan 2, 2006")
 }
 
+// relativeDateThreshold is how far back timestamps are shown as relative
+// times; older messages fall back to formatDate
+const relativeDateThreshold = 7 * 24 * time.Hour
+
+// formatMessageDate formats a message date for list output
+func formatMessageDate(t time.Time, relative bool) string {
+	if relative {
+		return formatRelativeDate(t, time.Now())
+	}
+	return formatDate(t)
+}
+
+// formatRelativeDate renders t relative to now ("5m ago", "yesterday",
+// "3 days ago") if it is within relativeDateThreshold
+func formatRelativeDate(t, now time.Time) string {
+	d := now.Sub(t)
+	if d < 0 || d >= relativeDateThreshold {
+		return formatDate(t)
+	}
+
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour && t.YearDay() == now.YearDay():
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	}
+
+	// Count calendar days rather than 24h periods
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, now.Location())
+	days := int(today.Sub(day).Hours()/24 + 0.5)
+	if days <= 1 {
+		return "yesterday"
+	}
+	return fmt.Sprintf("%d days ago", days)
+}
+
 func printFormattedMessage(msg *gdaygmail.Message) {
 	fmt.Printf("From: %s\n", msg.From)
 	fmt.Printf("To: %s\n", msg.To)
 	fmt.Printf("Date: %s\n", msg.Date.Format("Mon, Jan 2, 2006 at 3:04 PM"))
 	fmt.Printf("Subject: %s\n", msg.Subject)
+	if msg.Priority != "" && msg.Priority != "normal" {
+		fmt.Printf("Priority: %s\n", msg.Priority)
+	}
 
 	if len(msg.Attachments) > 0 {
 		fmt.Printf("Attachments: %d\n", len(msg.Attachments))
@@ -626,8 +2880,21 @@ func messageToJSON(m *gdaygmail.Message) MessageJSON {
 		Subject:     m.Subject,
 		Snippet:     m.Snippet,
 		Body:        m.Body,
+		BodyHTML:    m.BodyHTML,
 		Labels:      m.Labels,
 		IsUnread:    m.IsUnread,
+		IsImportant: m.IsImportant,
+		Priority:    m.Priority,
 		Attachments: attachments,
 	}
 }
+
+// messagesToJSON converts messages to JSON format, giving an empty rather
+// than nil slice so no messages encode as []
+func messagesToJSON(messages []*gdaygmail.Message) []MessageJSON {
+	jsonMsgs := make([]MessageJSON, 0, len(messages))
+	for _, m := range messages {
+		jsonMsgs = append(jsonMsgs, messageToJSON(m))
+	}
+	return jsonMsgs
+}
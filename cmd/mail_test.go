@@ -0,0 +1,492 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	gdaygmail "github.com/joncooper/gday/internal/gmail"
+	"github.com/spf13/cobra"
+)
+
+// fakeCleaner records which cleanup call each message got
+type fakeCleaner struct {
+	calls map[string]string
+}
+
+func (f *fakeCleaner) ArchiveMessage(ctx context.Context, id string) error {
+	f.calls[id] = "archive"
+	return nil
+}
+
+func (f *fakeCleaner) TrashMessage(ctx context.Context, id string) error {
+	f.calls[id] = "trash"
+	return nil
+}
+
+func (f *fakeCleaner) PermanentlyDelete(ctx context.Context, id string) error {
+	f.calls[id] = "delete"
+	return nil
+}
+
+func TestCleanupFunc(t *testing.T) {
+	tests := []struct {
+		action   string
+		wantCall string
+		wantErr  bool
+	}{
+		{"archive", "archive", false},
+		{"trash", "trash", false},
+		{"delete", "delete", false},
+		{"", "", true},
+		{"purge", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			srv := &fakeCleaner{calls: map[string]string{}}
+			err := cleanupFunc(context.Background(), srv, tt.action)("m1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := srv.calls["m1"]; got != tt.wantCall {
+				t.Errorf("call = %q, want %q", got, tt.wantCall)
+			}
+		})
+	}
+}
+
+func TestShortID(t *testing.T) {
+	tests := []struct {
+		id   string
+		n    int
+		want string
+	}{
+		{"18c2f4a9b7e3d1f0", 12, "18c2f4a9b7e3"},
+		{"18c2f4a9b7e3", 12, "18c2f4a9b7e3"},
+		{"a1b2", 12, "a1b2"},
+		{"", 12, ""},
+	}
+	for _, tt := range tests {
+		if got := shortID(tt.id, tt.n); got != tt.want {
+			t.Errorf("shortID(%q, %d) = %q, want %q", tt.id, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s      string
+		maxLen int
+		want   string
+	}{
+		{"Quarterly report", 0, ""},
+		{"Quarterly report", 1, "Q"},
+		{"Quarterly report", 3, "Qua"},
+		{"Quarterly report", 4, "Q..."},
+		{"Quarterly report", 16, "Quarterly report"},
+		{"Quarterly report", 40, "Quarterly report"},
+		{"🎉🎂 Party time", 1, "🎉"},
+		{"🎉🎂 Party time", 3, "🎉🎂 "},
+		{"🎉🎂 Party time", 5, "🎉🎂..."},
+		{"Café déjà vu", 7, "Café..."},
+		{"", 3, ""},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.s, tt.maxLen); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.maxLen, got, tt.want)
+		}
+	}
+}
+
+func TestMatchMimeType(t *testing.T) {
+	attachments := []string{"image/png", "image/jpeg", "application/pdf", "text/plain; charset=utf-8", "IMAGE/GIF", "application/vnd.ms-excel"}
+
+	tests := []struct {
+		patterns []string
+		want     []string
+	}{
+		{[]string{"image/*"}, []string{"image/png", "image/jpeg", "IMAGE/GIF"}},
+		{[]string{"application/pdf"}, []string{"application/pdf"}},
+		{[]string{"image/png", "application/pdf"}, []string{"image/png", "application/pdf"}},
+		{[]string{"text/*"}, []string{"text/plain; charset=utf-8"}},
+		{[]string{"application/vnd.*"}, []string{"application/vnd.ms-excel"}},
+		{[]string{"*/*"}, attachments},
+		{[]string{"video/*"}, nil},
+		{[]string{"image/["}, nil}, // Malformed patterns match nothing
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, mimeType := range attachments {
+			if matchMimeType(mimeType, tt.patterns) {
+				got = append(got, mimeType)
+			}
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%v matched %v, want %v", tt.patterns, got, tt.want)
+		}
+	}
+}
+
+func TestFilterMessagesByTime(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2025, 6, 2, h, m, 0, 0, time.UTC) }
+	messages := []*gdaygmail.Message{
+		{ID: "early", Date: at(9, 0)},
+		{ID: "just-before", Date: at(14, 29)},
+		{ID: "on-the-minute", Date: at(14, 30)},
+		{ID: "after", Date: at(14, 31)},
+		{ID: "late", Date: at(18, 0)},
+	}
+
+	tests := []struct {
+		name          string
+		after, before time.Time
+		want          string
+	}{
+		{"no bounds", time.Time{}, time.Time{}, "early,just-before,on-the-minute,after,late"},
+		{"after is inclusive", at(14, 30), time.Time{}, "on-the-minute,after,late"},
+		{"before is exclusive", time.Time{}, at(14, 30), "early,just-before"},
+		{"window", at(14, 0), at(15, 0), "just-before,on-the-minute,after"},
+		{"other zone", at(14, 30).In(time.FixedZone("", 2*60*60)), time.Time{}, "on-the-minute,after,late"},
+		{"empty window", at(15, 0), at(16, 0), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []string
+			for _, m := range filterMessagesByTime(messages, tt.after, tt.before) {
+				ids = append(ids, m.ID)
+			}
+			if got := strings.Join(ids, ","); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDayRangeQuery(t *testing.T) {
+	at := time.Date(2025, 6, 2, 14, 30, 0, 0, time.UTC)
+	tests := []struct {
+		after, before time.Time
+		want          string
+	}{
+		{at, time.Time{}, "after:2025/06/01"},
+		{time.Time{}, at, "before:2025/06/03"},
+		{at, at.Add(time.Hour), "after:2025/06/01 before:2025/06/03"},
+		{time.Time{}, time.Time{}, ""},
+	}
+	for _, tt := range tests {
+		if got := dayRangeQuery(tt.after, tt.before); got != tt.want {
+			t.Errorf("dayRangeQuery(%v, %v) = %q, want %q", tt.after, tt.before, got, tt.want)
+		}
+	}
+}
+
+func TestFormatRelativeDate(t *testing.T) {
+	// A June "now" in the current year, so formatDate's fallback shows no year
+	now := time.Date(time.Now().Year(), 6, 10, 15, 0, 0, 0, time.Local)
+	tests := []struct {
+		offset time.Duration
+		want   string
+	}{
+		{-30 * time.Second, "just now"},
+		{-5 * time.Minute, "5m ago"},
+		{-2 * time.Hour, "2h ago"},
+		{-14 * time.Hour, "14h ago"},
+		{-16 * time.Hour, "yesterday"}, // 23:00 the day before
+		{-24 * time.Hour, "yesterday"},
+		{-3 * 24 * time.Hour, "3 days ago"},
+		{-(7*24 - 1) * time.Hour, "7 days ago"},
+		{-7 * 24 * time.Hour, "Jun 3"},
+		{24 * time.Hour, "Jun 11"}, // Future dates are shown as they are
+	}
+	for _, tt := range tests {
+		if got := formatRelativeDate(now.Add(tt.offset), now); got != tt.want {
+			t.Errorf("formatRelativeDate(now%+v) = %q, want %q", tt.offset, got, tt.want)
+		}
+	}
+}
+
+// fakeDownloader saves each attachment as a file holding its ID
+type fakeDownloader struct{}
+
+func (fakeDownloader) DownloadAttachment(ctx context.Context, messageID, attachmentID, filename, outDir string, overwrite bool) (string, error) {
+	path := gdaygmail.UniquePath(outDir, gdaygmail.SanitizeFilename(filename))
+	return path, os.WriteFile(path, []byte(attachmentID), 0644)
+}
+
+func TestSaveThread(t *testing.T) {
+	first := time.Date(2025, 6, 2, 9, 15, 0, 0, time.UTC)
+	messages := []*gdaygmail.Message{
+		{ID: "m1", From: "ana@example.com", To: "bo@example.com", Subject: "Plans", Date: first, Body: "Draft attached soon"},
+		{ID: "m2", From: "bo@example.com", To: "ana@example.com", Subject: "Re: Plans", Date: first.Add(26 * time.Hour), Body: "Here it is",
+			Attachments: []gdaygmail.Attachment{
+				{ID: "a1", Filename: "plan.pdf", MimeType: "application/pdf", Size: 100},
+				{ID: "a2", Filename: "plan.pdf", MimeType: "application/pdf", Size: 200},
+				{ID: "a3", Filename: "../../evil.sh", MimeType: "text/x-sh", Size: 10},
+			}},
+	}
+	dir := filepath.Join(t.TempDir(), "thread")
+
+	manifest, err := saveThread(context.Background(), fakeDownloader{}, "t1", messages, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The directory holds exactly the expected layout
+	var files []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	wantFiles := []string{
+		"01-20250602-0915.txt",
+		"02-20250603-1115-attachments/_.._evil.sh",
+		"02-20250603-1115-attachments/plan (1).pdf",
+		"02-20250603-1115-attachments/plan.pdf",
+		"02-20250603-1115.txt",
+		"manifest.json",
+	}
+	if strings.Join(files, "\n") != strings.Join(wantFiles, "\n") {
+		t.Errorf("saved files:\n%s\nwant:\n%s", strings.Join(files, "\n"), strings.Join(wantFiles, "\n"))
+	}
+
+	text, _ := os.ReadFile(filepath.Join(dir, "01-20250602-0915.txt"))
+	for _, want := range []string{"From: ana@example.com\n", "Subject: Plans\n", "\n\nDraft attached soon"} {
+		if !strings.Contains(string(text), want) {
+			t.Errorf("message file is missing %q:\n%s", want, text)
+		}
+	}
+
+	// The manifest on disk matches the one returned
+	var saved ThreadManifestJSON
+	data, _ := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.ThreadID != "t1" || saved.Subject != "Plans" || len(saved.Messages) != 2 {
+		t.Fatalf("manifest = %+v", saved)
+	}
+	tests := []struct {
+		msg      int
+		file     string
+		attached []string
+	}{
+		{0, "01-20250602-0915.txt", nil},
+		{1, "02-20250603-1115.txt", []string{
+			"02-20250603-1115-attachments/plan.pdf",
+			"02-20250603-1115-attachments/plan (1).pdf",
+			"02-20250603-1115-attachments/_.._evil.sh",
+		}},
+	}
+	for _, tt := range tests {
+		for _, m := range []SavedMessageJSON{saved.Messages[tt.msg], manifest.Messages[tt.msg]} {
+			if m.File != tt.file {
+				t.Errorf("message %d file = %q, want %q", tt.msg, m.File, tt.file)
+			}
+			var paths []string
+			for _, a := range m.Attachments {
+				paths = append(paths, filepath.ToSlash(a.Path))
+			}
+			if strings.Join(paths, ",") != strings.Join(tt.attached, ",") {
+				t.Errorf("message %d attachments = %v, want %v", tt.msg, paths, tt.attached)
+			}
+		}
+	}
+}
+
+func TestBodyFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		vars     map[string]string
+		wantHTML bool
+		want     string // Text the rendered body must contain
+		wantErr  bool
+	}{
+		{name: "markdown", file: "notes.md", content: "# Hi\n\nSee *this*.", wantHTML: true, want: "<h1>Hi</h1>"},
+		{name: "markdown extension case", file: "NOTES.Markdown", content: "**bold**", wantHTML: true, want: "<strong>bold</strong>"},
+		{name: "plain text", file: "notes.txt", content: "# Hi", want: "# Hi"},
+		{name: "template", file: "invite.txt", content: "Hi {{.name}}, see you {{.day}}.", vars: map[string]string{"name": "Ann", "day": "Monday"}, want: "Hi Ann, see you Monday."},
+		{name: "template then markdown", file: "invite.md", content: "# Hi {{.name}}", vars: map[string]string{"name": "Ann"}, wantHTML: true, want: "<h1>Hi Ann</h1>"},
+		{name: "braces without vars", file: "code.txt", content: "use {{.x}} here", want: "use {{.x}} here"},
+		{name: "missing var", file: "invite.txt", content: "Hi {{.name}}", vars: map[string]string{"day": "Monday"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The same steps mail send takes with --body-file
+			body, err := renderBodyTemplate(string(data), tt.vars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := isMarkdownFile(path); got != tt.wantHTML {
+				t.Fatalf("isMarkdownFile = %v, want %v", got, tt.wantHTML)
+			}
+			if tt.wantHTML {
+				if body, err = renderMarkdown(body); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !strings.Contains(body, tt.want) {
+				t.Errorf("body %q doesn't contain %q", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfirmRecipients(t *testing.T) {
+	addresses := func(n int) []string {
+		var list []string
+		for i := range n {
+			list = append(list, fmt.Sprintf("user%d@example.com", i))
+		}
+		return list
+	}
+	tests := []struct {
+		name       string
+		recipients int
+		flags      []string
+		want       bool
+		wantPrompt bool
+	}{
+		{"at the threshold", RecipientConfirmThreshold, nil, true, false},
+		{"one over", RecipientConfirmThreshold + 1, nil, false, true},
+		{"over with --yes", RecipientConfirmThreshold + 1, []string{"yes"}, true, false},
+		{"dry run previews", 1, []string{"dry-run"}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := func() bool {
+				// A pipe on stdin makes a prompt exit instead of waiting
+				withStdin(t, "")
+				cmd := &cobra.Command{Use: "send"}
+				addBatchFlags(cmd)
+				for _, flag := range tt.flags {
+					cmd.Flags().Set(flag, "true")
+				}
+				return confirmRecipients(cmd, addresses(tt.recipients))
+			}
+			code := exitCode(t, func() { run() })
+			if prompted := code == ExitUsage; prompted != tt.wantPrompt {
+				t.Fatalf("prompted = %v (exit code %d), want %v", prompted, code, tt.wantPrompt)
+			}
+			if !tt.wantPrompt {
+				if got := run(); got != tt.want {
+					t.Errorf("confirmRecipients = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{
+			name: "bulleted list",
+			body: "Agenda:\n\n- Budget\n- Hiring\n",
+			want: []string{"<ul>", "<li>Budget</li>", "<li>Hiring</li>", "</ul>"},
+		},
+		{
+			name: "numbered list",
+			body: "1. Draft\n2. Review\n",
+			want: []string{"<ol>", "<li>Draft</li>", "<li>Review</li>", "</ol>"},
+		},
+		{
+			name: "links",
+			body: "See [the doc](https://example.com/doc) or <https://example.com/raw>.",
+			want: []string{`<a href="https://example.com/doc">the doc</a>`, `<a href="https://example.com/raw">https://example.com/raw</a>`},
+		},
+		{
+			name: "link in a list item",
+			body: "- [Notes](https://example.com/notes)\n- *Soon*\n",
+			want: []string{`<li><a href="https://example.com/notes">Notes</a></li>`, "<li><em>Soon</em></li>"},
+		},
+		{
+			name: "headings and code",
+			body: "# Status\n\nRun `make test`.\n",
+			want: []string{"<h1>Status</h1>", "<code>make test</code>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderMarkdown(tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("renderMarkdown(%q) = %q, missing %q", tt.body, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSortMessages(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 6, d, 9, 0, 0, 0, time.UTC) }
+	// In API order: roughly newest first
+	api := []*gdaygmail.Message{
+		{ID: "m1", From: "Cy <cy@example.com>", Subject: "budget", Date: day(4)},
+		{ID: "m2", From: "ana@example.com", Subject: "Agenda", Date: day(2)},
+		{ID: "m3", From: "Bo <bo@example.com>", Subject: "Lunch", Date: day(3)},
+		{ID: "m4", From: "ana@example.com", Subject: "agenda", Date: day(1)},
+	}
+	tests := []struct {
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{"", "m1,m2,m3,m4", false},
+		{"date", "m4,m2,m3,m1", false},
+		{"-date", "m1,m3,m2,m4", false},
+		{"subject", "m2,m4,m1,m3", false}, // Case-insensitive; ties keep API order
+		{"-subject", "m3,m1,m2,m4", false},
+		{"from", "m2,m4,m3,m1", false},
+		{"size", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(cmp.Or(tt.key, "unsorted"), func(t *testing.T) {
+			messages := slices.Clone(api)
+			err := sortMessages(messages, tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var ids []string
+			for _, m := range messages {
+				ids = append(ids, m.ID)
+			}
+			if got := strings.Join(ids, ","); got != tt.want {
+				t.Errorf("sortMessages(%q) = %s, want %s", tt.key, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,15 +1,42 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/joncooper/gday/internal/auth"
+	"github.com/joncooper/gday/internal/config"
 	"github.com/spf13/cobra"
+	"google.golang.org/api/googleapi"
 )
 
 // Global flags
-var jsonOutput bool
+var (
+	jsonOutput  bool
+	timeout     time.Duration
+	account     string
+	credentials string
+)
+
+// Exit codes, so scripts can tell failures apart
+const (
+	ExitOK      = 0 // Success
+	ExitError   = 1 // Any other failure
+	ExitUsage   = 2 // Invalid arguments, or the requested item wasn't found
+	ExitPartial = 3 // Some items in a batch operation failed
+	ExitAuth    = 4 // Credentials or login required
+)
+
+// defaultTimeout bounds how long a single command may run
+const defaultTimeout = 2 * time.Minute
 
 var rootCmd = &cobra.Command{
 	Use:   "gday",
@@ -33,7 +60,8 @@ Calendar commands:
   gday cal create    # Create an event
   gday cal delete    # Delete an event
 
-Use --json flag with any command for machine-readable output.`,
+Use --json flag with any command for machine-readable output, or --format
+yaml; list commands also support --format csv.`,
 }
 
 func Execute() error {
@@ -41,30 +69,167 @@ func Execute() error {
 }
 
 func init() {
+	cobra.OnInitialize(func() {
+		if err := resolveOutputFormat(); err != nil {
+			exitUsage("%v", err)
+		}
+		if err := config.SetAccount(account); err != nil {
+			exitUsage("%v", err)
+		}
+		config.SetCredentialsFile(credentials)
+	})
+
+	// Run the root's checks as well as the command groups' own
+	cobra.EnableTraverseRunHooks = true
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		if err := checkOutputFormat(cmd); err != nil {
+			exitUsage("%v", err)
+		}
+		if err := checkCommandAccess(cmd); err != nil {
+			exitError("%v", err)
+		}
+	}
+
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
-	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "Output in JSON format")
+	rootCmd.PersistentFlags().StringVar(&account, "account", os.Getenv("GDAY_ACCOUNT"), "Account to use, from 'gday auth list' (env: GDAY_ACCOUNT)")
+	rootCmd.PersistentFlags().StringVar(&credentials, "credentials", "", "OAuth client credentials JSON file (env: GDAY_CREDENTIALS, GDAY_CREDENTIALS_FILE)")
+	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "Output in JSON format (same as --format json)")
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "format", formatTable, "Output format: table, json, csv, or yaml (csv for list commands only)")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", defaultTimeout, "Maximum time a command may run (0 = no limit)")
+	rootCmd.PersistentFlags().Int("confirm-threshold", BatchConfirmThreshold, "Prompt before batch operations on more than this many items (0 = always, negative = never)")
+}
+
+// scopesAnnotation holds the comma-separated access a command needs, as
+// names from auth.AccessNames
+const scopesAnnotation = "gday/scopes"
+
+// checkCommandAccess returns an error if the login didn't grant the access
+// the command's scopesAnnotation names, so it fails before calling the API
+// rather than with a 403 partway through
+func checkCommandAccess(cmd *cobra.Command) error {
+	names := cmd.Annotations[scopesAnnotation]
+	if names == "" {
+		return nil
+	}
+	for _, name := range strings.Split(names, ",") {
+		if err := auth.CheckAccess(auth.AccessNames[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newContext returns a context that is canceled on Ctrl-C or SIGTERM, or
+// once --timeout elapses
+func newContext() (context.Context, context.CancelFunc) {
+	return contextWithTimeout(timeout)
 }
 
-// Helper to print errors and exit
+// newLongContext is newContext for commands that can legitimately run for a
+// long time, such as bulk downloads: it has no deadline unless --timeout is
+// given explicitly
+func newLongContext() (context.Context, context.CancelFunc) {
+	if rootCmd.PersistentFlags().Changed("timeout") {
+		return contextWithTimeout(timeout)
+	}
+	return contextWithTimeout(0)
+}
+
+// contextWithTimeout returns a signal-canceled context with a deadline d
+// from now, or no deadline if d is 0
+func contextWithTimeout(d time.Duration) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	if d <= 0 {
+		return ctx, stop
+	}
+	ctx, cancel := context.WithTimeout(ctx, d)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// exitError prints an error and exits. The exit code comes from the first
+// error among args (see exitCodeFor), or is ExitError if there is none.
 func exitError(msg string, args ...interface{}) {
-	if jsonOutput {
+	code := ExitError
+	for _, arg := range args {
+		if err, ok := arg.(error); ok {
+			code = exitCodeFor(err)
+			break
+		}
+	}
+	exitWithCode(code, msg, args...)
+}
+
+// exitUsage prints an error and exits with ExitUsage, for invalid arguments
+// and items that don't exist
+func exitUsage(msg string, args ...interface{}) {
+	exitWithCode(ExitUsage, msg, args...)
+}
+
+// exitWithCode prints an error, as JSON with --json, and exits with code
+func exitWithCode(code int, msg string, args ...interface{}) {
+	// Explain API errors whose messages are cryptic on their own
+	for _, arg := range args {
+		if err, ok := arg.(error); ok {
+			if hint := errorHint(err); hint != "" {
+				msg += "\n\n" + strings.ReplaceAll(hint, "%", "%%")
+				break
+			}
+		}
+	}
+
+	if isJSONOutput() {
 		outputJSON(map[string]interface{}{
 			"error": fmt.Sprintf(msg, args...),
 		})
 	} else {
 		fmt.Fprintf(os.Stderr, "Error: "+msg+"\n", args...)
 	}
-	os.Exit(1)
+	os.Exit(code)
+}
+
+// exitCodeFor maps an error to an exit code
+func exitCodeFor(err error) int {
+	var apiErr *googleapi.Error
+	switch {
+	case errors.Is(err, auth.ErrNotAuthenticated), errors.Is(err, auth.ErrNoCredentials),
+		errors.Is(err, auth.ErrScopeNotGranted), auth.IsInsufficientScope(err):
+		return ExitAuth
+	case errors.As(err, &apiErr) && apiErr.Code == http.StatusUnauthorized:
+		return ExitAuth
+	case errors.As(err, &apiErr) && (apiErr.Code == http.StatusNotFound || apiErr.Code == http.StatusGone):
+		return ExitUsage
+	}
+	return ExitError
 }
 
-// outputJSON prints data as JSON
+// errorHint returns guidance for errors the user can act on: a token with
+// too few scopes, or an exhausted API quota
+func errorHint(err error) string {
+	switch {
+	case auth.IsInsufficientScope(err):
+		return auth.ScopeHint(context.Background())
+	case auth.IsQuotaExhausted(err):
+		return auth.QuotaHint()
+	}
+	return ""
+}
+
+// outputJSON prints data as JSON, or as YAML with --format yaml
 func outputJSON(data interface{}) {
+	if outputFormat == formatYAML {
+		writeYAML(os.Stdout, data)
+		return
+	}
 	enc := json.NewEncoder(os.Stdout)
 	enc.SetIndent("", "  ")
 	enc.Encode(data)
 }
 
-// isJSONOutput returns true if JSON output is enabled
+// isJSONOutput returns true if output should be the JSON output types:
+// --json, --format json, or --format yaml
 func isJSONOutput() bool {
-	return jsonOutput
+	return outputFormat == formatJSON || outputFormat == formatYAML
 }
@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joncooper/gday/internal/auth"
+	"github.com/joncooper/gday/internal/config"
+	"github.com/spf13/cobra"
+	"google.golang.org/api/googleapi"
+)
+
+func TestExitCodeFor(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	insufficientScope := &googleapi.Error{
+		Code:    http.StatusForbidden,
+		Message: "Request had insufficient authentication scopes.",
+		Errors:  []googleapi.ErrorItem{{Reason: "insufficientPermissions"}},
+	}
+	quota := &googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "dailyLimitExceeded"}}}
+
+	tests := []struct {
+		name     string
+		err      error
+		want     int
+		wantHint string // A phrase the hint must contain; "" for no hint
+	}{
+		{"not logged in", fmt.Errorf("failed to load token: %w", auth.ErrNotAuthenticated), ExitAuth, ""},
+		{"no credentials", auth.ErrNoCredentials, ExitAuth, ""},
+		{"scope not granted", fmt.Errorf("mail delete: %w", auth.ErrScopeNotGranted), ExitAuth, ""},
+		{"token revoked", fmt.Errorf("failed to list: %w", &googleapi.Error{Code: http.StatusUnauthorized}), ExitAuth, ""},
+		{"insufficient scope", fmt.Errorf("failed to create label: %w", insufficientScope), ExitAuth, "gday auth login"},
+		{"quota exhausted", fmt.Errorf("failed to list: %w", quota), ExitError, "quota"},
+		{"not found", fmt.Errorf("failed to get message: %w", &googleapi.Error{Code: http.StatusNotFound}), ExitUsage, ""},
+		{"gone", &googleapi.Error{Code: http.StatusGone}, ExitUsage, ""},
+		{"server error", &googleapi.Error{Code: http.StatusInternalServerError}, ExitError, ""},
+		{"other", errors.New("connection reset"), ExitError, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCodeFor(tt.err); got != tt.want {
+				t.Errorf("exitCodeFor(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+			hint := errorHint(tt.err)
+			if tt.wantHint == "" {
+				if hint != "" {
+					t.Errorf("unexpected hint %q", hint)
+				}
+				return
+			}
+			if !strings.Contains(hint, tt.wantHint) {
+				t.Errorf("hint %q doesn't mention %q", hint, tt.wantHint)
+			}
+		})
+	}
+}
+
+func TestBatchExitCode(t *testing.T) {
+	failure := errors.New("failed")
+	tests := []struct {
+		name   string
+		total  int
+		failed map[string]error
+		want   int
+	}{
+		{"all succeeded", 3, nil, ExitOK},
+		{"partial failure", 3, map[string]error{"a": failure}, ExitPartial},
+		{"all failed", 2, map[string]error{"a": failure, "b": failure}, ExitError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := batchExitCode(tt.total, tt.failed); got != tt.want {
+				t.Errorf("batchExitCode = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandsDeclareAccess(t *testing.T) {
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		for _, sub := range cmd.Commands() {
+			walk(sub)
+		}
+		if !cmd.Runnable() {
+			return
+		}
+		names := cmd.Annotations[scopesAnnotation]
+		if names == "" {
+			t.Errorf("%s doesn't declare the access it needs", cmd.CommandPath())
+			return
+		}
+		for _, name := range strings.Split(names, ",") {
+			if _, ok := auth.AccessNames[name]; !ok {
+				t.Errorf("%s needs unknown access %q", cmd.CommandPath(), name)
+			}
+		}
+	}
+	// Managing and checking the login itself needs no Google access
+	exempt := map[*cobra.Command]bool{authCmd: true, doctorCmd: true}
+	for _, cmd := range rootCmd.Commands() {
+		if !exempt[cmd] {
+			walk(cmd)
+		}
+	}
+}
+
+func TestCheckCommandAccess(t *testing.T) {
+	readonly := []string{"gmail.readonly", "calendar.readonly"}
+
+	tests := []struct {
+		name    string
+		login   []string // Scopes given to 'auth login --scope'; nil for the default
+		cmd     *cobra.Command
+		wantErr bool
+	}{
+		{name: "default login lists mail", cmd: mailListCmd},
+		{name: "default login archives", cmd: mailArchiveCmd},
+		{name: "default login can't delete", cmd: mailDeleteCmd, wantErr: true},
+		{name: "mail scope deletes", login: []string{"mail"}, cmd: mailDeleteCmd},
+		{name: "default login creates events", cmd: calCreateCmd},
+		{name: "default login can't create calendars", cmd: calCalendarsCreateCmd, wantErr: true},
+		{name: "default login has no tasks", cmd: tasksListCmd, wantErr: true},
+		{name: "readonly lists mail", login: readonly, cmd: mailListCmd},
+		{name: "readonly can't archive", login: readonly, cmd: mailArchiveCmd, wantErr: true},
+		{name: "readonly lists events", login: readonly, cmd: calListCmd},
+		{name: "readonly can't create events", login: readonly, cmd: calCreateCmd, wantErr: true},
+		{name: "readonly tasks can't add", login: []string{"tasks.readonly"}, cmd: tasksAddCmd, wantErr: true},
+		{name: "send only sends", login: []string{"gmail.send"}, cmd: mailSendCmd},
+		{name: "send only can't reply", login: []string{"gmail.send"}, cmd: mailReplyCmd, wantErr: true},
+		{name: "invite needs mail too", login: []string{"calendar.events"}, cmd: calInviteCmd, wantErr: true},
+		{name: "default login serves", cmd: serveCmd},
+		{name: "readonly can't serve", login: readonly, cmd: serveCmd, wantErr: true},
+		{name: "auth commands need nothing", login: []string{"tasks"}, cmd: authStatusCmd},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			scopes, err := auth.ResolveScopes(tt.login)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := config.SaveScopes(scopes); err != nil {
+				t.Fatal(err)
+			}
+
+			err = checkCommandAccess(tt.cmd)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && exitCodeFor(err) != ExitAuth {
+				t.Errorf("exit code = %d, want %d", exitCodeFor(err), ExitAuth)
+			}
+		})
+	}
+}
+
+func TestNewContext(t *testing.T) {
+	tests := []struct {
+		name     string
+		flag     string // --timeout, or "" when not given
+		want     time.Duration
+		wantLong time.Duration // For newLongContext; 0 for no deadline
+	}{
+		{"default", "", defaultTimeout, 0},
+		{"no limit", "0", 0, 0},
+		{"explicit", "10m", 10 * time.Minute, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flag := rootCmd.PersistentFlags().Lookup("timeout")
+			t.Cleanup(func() {
+				flag.Value.Set(flag.DefValue)
+				flag.Changed = false
+			})
+			if tt.flag != "" {
+				if err := rootCmd.PersistentFlags().Set("timeout", tt.flag); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, c := range []struct {
+				name       string
+				newContext func() (context.Context, context.CancelFunc)
+				want       time.Duration
+			}{
+				{"newContext", newContext, tt.want},
+				{"newLongContext", newLongContext, tt.wantLong},
+			} {
+				ctx, cancel := c.newContext()
+				deadline, ok := ctx.Deadline()
+				cancel()
+				if c.want == 0 {
+					if ok {
+						t.Errorf("%s has a deadline in %v, want none", c.name, time.Until(deadline))
+					}
+					continue
+				}
+				if !ok {
+					t.Errorf("%s has no deadline, want one in %v", c.name, c.want)
+				} else if left := time.Until(deadline); left > c.want || left < c.want-time.Minute {
+					t.Errorf("%s deadline in %v, want %v", c.name, left, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestEmptyResultsJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{"mail list", MessagesListJSON{Messages: messagesToJSON(nil)}, `{"count":0,"messages":[]}`},
+		{"mail search", SearchResultJSON{Query: "from:nobody", Messages: messagesToJSON(nil)}, `{"query":"from:nobody","count":0,"messages":[]}`},
+		{"mail thread", ThreadJSON{ThreadID: "t1", Messages: messagesToJSON(nil)}, `{"thread_id":"t1","count":0,"messages":[]}`},
+		{"cal list", eventsToJSON(nil), `{"count":0,"events":[]}`},
+		{"tasks list", tasksToJSON(nil), `{"count":0,"tasks":[]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("JSON = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
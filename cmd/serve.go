@@ -0,0 +1,389 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joncooper/gday/internal/auth"
+	gdaycal "github.com/joncooper/gday/internal/calendar"
+	"github.com/joncooper/gday/internal/config"
+	gdaygmail "github.com/joncooper/gday/internal/gmail"
+	"github.com/spf13/cobra"
+	"google.golang.org/api/googleapi"
+)
+
+var serveCmd = &cobra.Command{
+	Use:         "serve",
+	Short:       "Serve a local JSON HTTP API",
+	Annotations: map[string]string{scopesAnnotation: "gmail.read,gmail.send,calendar.read,calendar.write"},
+	Long: `Run a long-lived local HTTP server exposing gday operations as JSON, so
+editor plugins and scripts can make many calls without starting gday and
+refreshing the token each time.
+
+The server only listens on loopback addresses. Every request must carry the
+token printed at startup (also written to ~/.gday/serve_token):
+
+  Authorization: Bearer <token>
+
+Endpoints (responses use the same shapes as --json):
+  GET  /mail/messages?q=QUERY&n=10&page_token=TOKEN
+                                     List or search messages
+  GET  /mail/messages/{id}           Read a message
+  POST /mail/send                    Send {"to", "subject", "body", "cc", "bcc"}
+  GET  /calendar/events?calendar=ID&days=14&n=10
+                                     List upcoming events
+  POST /calendar/events?calendar=ID  Create {"summary", "start", "end", ...};
+                                     protected calendars are refused
+
+Examples:
+  gday serve
+  gday serve --addr 127.0.0.1:9000
+  curl -H "Authorization: Bearer $(cat ~/.gday/serve_token)" localhost:8765/mail/messages`,
+	Run: func(cmd *cobra.Command, args []string) {
+		addr, _ := cmd.Flags().GetString("addr")
+		if err := checkLoopback(addr); err != nil {
+			exitUsage("%v", err)
+		}
+
+		ctx, cancel := newLongContext()
+		defer cancel()
+
+		client, err := auth.GetClient(ctx)
+		if err != nil {
+			exitError("%v", err)
+		}
+		mailSrv, err := gdaygmail.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+		calSrv, err := gdaycal.NewService(ctx, client)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		token, err := newServeToken()
+		if err != nil {
+			exitError("%v", err)
+		}
+		tokenPath, err := config.SaveServeToken(token)
+		if err != nil {
+			exitError("failed to save serve token: %v", err)
+		}
+		defer config.SaveServeToken("")
+
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		api := &apiServer{mail: mailSrv, cal: calSrv}
+		server := &http.Server{
+			Handler:           requireToken(token, api.routes()),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		fmt.Fprintf(os.Stderr, "Serving on http://%s\n", listener.Addr())
+		fmt.Fprintf(os.Stderr, "Token: %s (saved to %s)\n", token, tokenPath)
+
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+		}()
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			exitError("%v", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().String("addr", "127.0.0.1:8765", "Loopback address and port to listen on")
+}
+
+// apiServer serves the local JSON API over authenticated services
+type apiServer struct {
+	mail mailBackend
+	cal  calendarBackend
+}
+
+// mailBackend is the part of the Gmail service the API uses
+type mailBackend interface {
+	ListMessagesPage(ctx context.Context, maxResults int64, query string, labelIDs []string, pageToken string) ([]*gdaygmail.Message, string, error)
+	GetMessage(ctx context.Context, id string, includeBody bool) (*gdaygmail.Message, error)
+	SendMessage(ctx context.Context, m *gdaygmail.OutgoingMessage) (*gdaygmail.Message, error)
+}
+
+// calendarBackend is the part of the Calendar service the API uses
+type calendarBackend interface {
+	calendarResolver
+	ListEvents(ctx context.Context, calendarID string, timeMin, timeMax time.Time, maxResults int64) ([]*gdaycal.Event, error)
+	CreateEvent(ctx context.Context, calendarID string, event *gdaycal.Event) (*gdaycal.Event, error)
+}
+
+// routes returns the API's request multiplexer
+func (a *apiServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /mail/messages", a.listMessages)
+	mux.HandleFunc("GET /mail/messages/{id}", a.getMessage)
+	mux.HandleFunc("POST /mail/send", a.sendMessage)
+	mux.HandleFunc("GET /calendar/events", a.listEvents)
+	mux.HandleFunc("POST /calendar/events", a.createEvent)
+	return mux
+}
+
+func (a *apiServer) listMessages(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := requestContext(r)
+	defer cancel()
+
+	n, err := intParam(r, "n", 10)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	query := r.URL.Query()
+	messages, nextPageToken, err := a.mail.ListMessagesPage(ctx, int64(n), query.Get("q"), nil, query.Get("page_token"))
+	if err != nil {
+		writeAPIError(w, apiStatus(err), err)
+		return
+	}
+	jsonMsgs := messagesToJSON(messages)
+	writeAPIJSON(w, http.StatusOK, MessagesListJSON{Count: len(jsonMsgs), Messages: jsonMsgs, NextPageToken: nextPageToken})
+}
+
+func (a *apiServer) getMessage(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := requestContext(r)
+	defer cancel()
+
+	msg, err := a.mail.GetMessage(ctx, r.PathValue("id"), true)
+	if err != nil {
+		writeAPIError(w, apiStatus(err), err)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, messageToJSON(msg))
+}
+
+// sendRequest is the body of POST /mail/send
+type sendRequest struct {
+	To      string   `json:"to"`
+	Subject string   `json:"subject"`
+	Body    string   `json:"body"`
+	Cc      []string `json:"cc"`
+	Bcc     []string `json:"bcc"`
+}
+
+func (a *apiServer) sendMessage(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := requestContext(r)
+	defer cancel()
+
+	var req sendRequest
+	if err := decodeAPIBody(r, &req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.To == "" || req.Subject == "" || req.Body == "" {
+		writeAPIError(w, http.StatusBadRequest, errors.New("to, subject, and body are required"))
+		return
+	}
+
+	msg, err := a.mail.SendMessage(ctx, &gdaygmail.OutgoingMessage{
+		To:      req.To,
+		Cc:      req.Cc,
+		Bcc:     req.Bcc,
+		Subject: req.Subject,
+		Text:    req.Body,
+	})
+	if err != nil {
+		writeAPIError(w, apiStatus(err), err)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, SendResultJSON{MessageID: msg.ID, Status: "sent"})
+}
+
+func (a *apiServer) listEvents(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := requestContext(r)
+	defer cancel()
+
+	n, err := intParam(r, "n", 10)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	days, err := intParam(r, "days", 14)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	calID, err := a.cal.ResolveCalendarID(ctx, r.URL.Query().Get("calendar"))
+	if err != nil {
+		writeAPIError(w, apiStatus(err), err)
+		return
+	}
+
+	now := time.Now()
+	events, err := a.cal.ListEvents(ctx, calID, now, now.AddDate(0, 0, days), int64(n))
+	if err != nil {
+		writeAPIError(w, apiStatus(err), err)
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, eventsToJSON(events))
+}
+
+func (a *apiServer) createEvent(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := requestContext(r)
+	defer cancel()
+
+	var req EventJSON
+	if err := decodeAPIBody(r, &req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Summary == "" || req.Start.IsZero() {
+		writeAPIError(w, http.StatusBadRequest, errors.New("summary and start are required"))
+		return
+	}
+	if req.End.IsZero() {
+		req.End = req.Start.Add(time.Hour)
+	}
+	calID, err := a.cal.ResolveCalendarID(ctx, r.URL.Query().Get("calendar"))
+	if err != nil {
+		writeAPIError(w, apiStatus(err), err)
+		return
+	}
+	// The API has no --force: protected calendars are read-only here
+	if err := calendarWritable(ctx, a.cal, calID); err != nil {
+		writeAPIError(w, apiStatus(err), err)
+		return
+	}
+
+	created, err := a.cal.CreateEvent(ctx, calID, &gdaycal.Event{
+		Summary:     req.Summary,
+		Description: req.Description,
+		Location:    req.Location,
+		Start:       req.Start,
+		End:         req.End,
+		AllDay:      req.AllDay,
+		Attendees:   req.Attendees,
+		Busy:        true,
+	})
+	if err != nil {
+		writeAPIError(w, apiStatus(err), err)
+		return
+	}
+	writeAPIJSON(w, http.StatusCreated, eventToJSON(created))
+}
+
+// requireToken rejects requests without the bearer token, or addressed to a
+// non-loopback host (which would mean DNS rebinding)
+func requireToken(token string, next http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if checkLoopback(r.Host) != nil {
+			writeAPIError(w, http.StatusForbidden, errors.New("requests must be addressed to a loopback host"))
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			writeAPIError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkLoopback returns an error unless addr's host is localhost or a
+// loopback IP
+func checkLoopback(addr string) error {
+	host := addr
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		host = h
+	}
+	if strings.EqualFold(host, "localhost") {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("%q is not a loopback address; gday serve only listens locally", addr)
+}
+
+// newServeToken returns a random bearer token
+func newServeToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// requestContext bounds an API request by --timeout
+func requestContext(r *http.Request) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(r.Context())
+	}
+	return context.WithTimeout(r.Context(), timeout)
+}
+
+// intParam reads a positive integer query parameter
+func intParam(r *http.Request, name string, def int) (int, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid %s: %q", name, s)
+	}
+	return n, nil
+}
+
+// decodeAPIBody decodes a JSON request body into v
+func decodeAPIBody(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, gdaygmail.MaxMessageSize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+// apiStatus maps an error from the Google APIs to an HTTP status
+func apiStatus(err error) int {
+	var apiErr *googleapi.Error
+	switch {
+	case errors.As(err, &apiErr) && apiErr.Code >= 400:
+		return apiErr.Code
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, gdaygmail.ErrMessageTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, errCalendarProtected):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// writeAPIJSON writes v as a JSON response
+func writeAPIJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// writeAPIError writes an error response shaped like --json errors
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeAPIJSON(w, status, map[string]interface{}{"error": err.Error()})
+}
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	gdaycal "github.com/joncooper/gday/internal/calendar"
+	gdaygmail "github.com/joncooper/gday/internal/gmail"
+	"google.golang.org/api/googleapi"
+)
+
+// fakeMail is an in-memory mailBackend
+type fakeMail struct {
+	messages []*gdaygmail.Message
+	sent     []string // To addresses of sent messages
+}
+
+func (f *fakeMail) ListMessagesPage(ctx context.Context, maxResults int64, query string, labelIDs []string, pageToken string) ([]*gdaygmail.Message, string, error) {
+	return f.messages, "next", nil
+}
+
+func (f *fakeMail) GetMessage(ctx context.Context, id string, includeBody bool) (*gdaygmail.Message, error) {
+	for _, m := range f.messages {
+		if m.ID == id {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to get message: %w", &googleapi.Error{Code: http.StatusNotFound})
+}
+
+func (f *fakeMail) SendMessage(ctx context.Context, m *gdaygmail.OutgoingMessage) (*gdaygmail.Message, error) {
+	f.sent = append(f.sent, m.To)
+	return &gdaygmail.Message{ID: "sent1"}, nil
+}
+
+// fakeCalendar is an in-memory calendarBackend. Calendar names resolve to
+// "<name>-id".
+type fakeCalendar struct {
+	events  []*gdaycal.Event
+	created map[string][]*gdaycal.Event
+}
+
+func (f *fakeCalendar) ResolveCalendarID(ctx context.Context, ref string) (string, error) {
+	if ref == "" {
+		return "primary", nil
+	}
+	return ref + "-id", nil
+}
+
+func (f *fakeCalendar) ResolveCalendarIDs(ctx context.Context, refs []string) ([]string, error) {
+	ids := make([]string, len(refs))
+	for i, ref := range refs {
+		ids[i], _ = f.ResolveCalendarID(ctx, ref)
+	}
+	return ids, nil
+}
+
+func (f *fakeCalendar) ListEvents(ctx context.Context, calendarID string, timeMin, timeMax time.Time, maxResults int64) ([]*gdaycal.Event, error) {
+	return f.events, nil
+}
+
+func (f *fakeCalendar) CreateEvent(ctx context.Context, calendarID string, event *gdaycal.Event) (*gdaycal.Event, error) {
+	created := *event
+	created.ID = "new1"
+	f.created[calendarID] = append(f.created[calendarID], &created)
+	return &created, nil
+}
+
+// withSettings points the config dir at a temporary one holding settings
+func withSettings(t *testing.T, settings string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, ".gday")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(settings), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServeAPI(t *testing.T) {
+	withSettings(t, `{"protected_calendars": ["Family"]}`)
+
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+	mail := &fakeMail{messages: []*gdaygmail.Message{
+		{ID: "m1", Subject: "Hello", From: "a@example.com"},
+		{ID: "m2", Subject: "Lunch?", From: "b@example.com"},
+	}}
+	cal := &fakeCalendar{
+		events:  []*gdaycal.Event{{ID: "e1", Summary: "Standup", Start: start, End: start.Add(15 * time.Minute)}},
+		created: map[string][]*gdaycal.Event{},
+	}
+	const token = "secret"
+	ts := httptest.NewServer(requireToken(token, (&apiServer{mail: mail, cal: cal}).routes()))
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		noToken    bool
+		wantStatus int
+		wantBody   string // Substring of the response
+	}{
+		{"missing token", "GET", "/mail/messages", "", true, http.StatusUnauthorized, "invalid token"},
+		{"list messages", "GET", "/mail/messages?n=2", "", false, http.StatusOK, `"next_page_token": "next"`},
+		{"bad count", "GET", "/mail/messages?n=-1", "", false, http.StatusBadRequest, "invalid n"},
+		{"read message", "GET", "/mail/messages/m2", "", false, http.StatusOK, `"subject": "Lunch?"`},
+		{"read missing message", "GET", "/mail/messages/nope", "", false, http.StatusNotFound, "error"},
+		{"send without subject", "POST", "/mail/send", `{"to": "c@example.com", "body": "hi"}`, false, http.StatusBadRequest, "required"},
+		{"send", "POST", "/mail/send", `{"to": "c@example.com", "subject": "Hi", "body": "hi"}`, false, http.StatusOK, `"message_id": "sent1"`},
+		{"send unknown field", "POST", "/mail/send", `{"to": "c@example.com", "subject": "Hi", "body": "hi", "from": "x"}`, false, http.StatusBadRequest, "unknown field"},
+		{"list events", "GET", "/calendar/events", "", false, http.StatusOK, `"summary": "Standup"`},
+		{"create event", "POST", "/calendar/events?calendar=Work", `{"summary": "Review", "start": "2025-06-03T10:00:00Z"}`, false, http.StatusCreated, `"id": "new1"`},
+		{"create event on protected calendar", "POST", "/calendar/events?calendar=Family", `{"summary": "Party", "start": "2025-06-03T10:00:00Z"}`, false, http.StatusForbidden, "protected"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.noToken {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			resp, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			var body json.RawMessage
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("response isn't JSON: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", resp.StatusCode, tt.wantStatus, body)
+			}
+			if !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("body %s doesn't contain %q", body, tt.wantBody)
+			}
+		})
+	}
+
+	if len(mail.sent) != 1 || mail.sent[0] != "c@example.com" {
+		t.Errorf("sent = %v, want one message to c@example.com", mail.sent)
+	}
+	if len(cal.created["Family-id"]) != 0 {
+		t.Error("created an event on the protected calendar")
+	}
+	if got := cal.created["Work-id"]; len(got) != 1 || !got[0].End.Equal(got[0].Start.Add(time.Hour)) {
+		t.Errorf("created on Work = %v, want one hour-long event", got)
+	}
+}
+
+func TestRequireTokenRejectsForeignHosts(t *testing.T) {
+	handler := requireToken("secret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	tests := []struct {
+		host string
+		want int
+	}{
+		{"127.0.0.1:8765", http.StatusOK},
+		{"localhost:8765", http.StatusOK},
+		{"[::1]:8765", http.StatusOK},
+		{"evil.example.com:8765", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/mail/messages", nil)
+		req.Host = tt.host
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("Host %s: status = %d, want %d", tt.host, rec.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/joncooper/gday/internal/auth"
+	gdaytasks "github.com/joncooper/gday/internal/tasks"
+	"github.com/spf13/cobra"
+)
+
+var tasksCmd = &cobra.Command{
+	Use:     "tasks",
+	Aliases: []string{"t", "task"},
+	Short:   "Google Tasks commands",
+	Long: `Commands for interacting with Google Tasks.
+
+Tasks access isn't requested by default. Grant it with:
+  gday auth login --scope tasks (plus any other scopes you use)`,
+}
+
+var tasksListCmd = &cobra.Command{
+	Use:         "list",
+	Short:       "List tasks",
+	Annotations: map[string]string{scopesAnnotation: "tasks.read"},
+	Long: `List tasks, soonest due first.
+
+Examples:
+  gday tasks list
+  gday tasks list --list Work
+  gday tasks list --completed`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := newContext()
+		defer cancel()
+		srv := newTasksService(ctx)
+		listID := resolveTaskListID(ctx, cmd, srv)
+		showCompleted, _ := cmd.Flags().GetBool("completed")
+
+		list, err := srv.ListTasks(ctx, listID, showCompleted)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		if isJSONOutput() {
+			outputJSON(tasksToJSON(list))
+			return
+		}
+
+		if len(list) == 0 {
+			fmt.Println("No tasks")
+			return
+		}
+		printTasks(list)
+	},
+}
+
+var tasksAddCmd = &cobra.Command{
+	Use:         "add TITLE",
+	Short:       "Add a task",
+	Annotations: map[string]string{scopesAnnotation: "tasks.write"},
+	Long: `Add a task.
+
+Examples:
+  gday tasks add "Renew passport"
+  gday tasks add "Send report" --due 2026-03-15 --notes "Q1 numbers"`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var due time.Time
+		if dueStr, _ := cmd.Flags().GetString("due"); dueStr != "" {
+			var err error
+			if due, err = parseDate(dueStr); err != nil {
+				exitUsage("%v", err)
+			}
+		}
+		notes, _ := cmd.Flags().GetString("notes")
+
+		ctx, cancel := newContext()
+		defer cancel()
+		srv := newTasksService(ctx)
+		listID := resolveTaskListID(ctx, cmd, srv)
+
+		task, err := srv.AddTask(ctx, listID, args[0], notes, due)
+		if err != nil {
+			exitError("%v", err)
+		}
+
+		if isJSONOutput() {
+			outputJSON(taskToJSON(task))
+			return
+		}
+		fmt.Printf("Task added: %s\n", task.Title)
+		fmt.Printf("ID: %s\n", task.ID)
+	},
+}
+
+var tasksDoneCmd = &cobra.Command{
+	Use:         "done ID...",
+	Short:       "Mark tasks as done",
+	Annotations: map[string]string{scopesAnnotation: "tasks.write"},
+	Args:        cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := newContext()
+		defer cancel()
+		srv := newTasksService(ctx)
+		listID := resolveTaskListID(ctx, cmd, srv)
+
+		var completed []TaskJSON
+		for _, id := range args {
+			task, err := srv.CompleteTask(ctx, listID, id)
+			if err != nil {
+				exitError("%v", err)
+			}
+			completed = append(completed, taskToJSON(task))
+			if !isJSONOutput() {
+				fmt.Printf("Done: %s\n", task.Title)
+			}
+		}
+
+		if isJSONOutput() {
+			outputJSON(TasksListJSON{Count: len(completed), Tasks: completed})
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tasksCmd)
+
+	tasksCmd.PersistentFlags().String("list", "", "Task list ID or name (default: your default list)")
+
+	tasksCmd.AddCommand(tasksListCmd)
+	tasksListCmd.Flags().Bool("completed", false, "Include completed tasks")
+
+	tasksCmd.AddCommand(tasksAddCmd)
+	tasksAddCmd.Flags().String("due", "", "Due date (YYYY-MM-DD)")
+	tasksAddCmd.Flags().String("notes", "", "Task notes")
+
+	tasksCmd.AddCommand(tasksDoneCmd)
+}
+
+// newTasksService creates a Tasks service, exiting on error
+func newTasksService(ctx context.Context) *gdaytasks.Service {
+	client, err := auth.GetClient(ctx)
+	if err != nil {
+		exitError("%v", err)
+	}
+	srv, err := gdaytasks.NewService(ctx, client)
+	if err != nil {
+		exitError("%v", err)
+	}
+	return srv
+}
+
+// resolveTaskListID resolves --list, matching either a list ID or its title
+func resolveTaskListID(ctx context.Context, cmd *cobra.Command, srv *gdaytasks.Service) string {
+	ref, _ := cmd.Flags().GetString("list")
+	if ref == "" {
+		return gdaytasks.DefaultList
+	}
+	lists, err := srv.ListTaskLists(ctx)
+	if err != nil {
+		exitError("%v", err)
+	}
+	for _, l := range lists {
+		if l.ID == ref || strings.EqualFold(l.Title, ref) {
+			return l.ID
+		}
+	}
+	exitUsage("task list not found: %s", ref)
+	return ""
+}
+
+func printTasks(list []*gdaytasks.Task) {
+	today := time.Now()
+	for _, t := range list {
+		check := "[ ]"
+		if t.Completed {
+			check = "[x]"
+		}
+		due := ""
+		switch {
+		case t.Due.IsZero():
+		case !t.Completed && t.Due.Before(startOfDay(today)):
+			due = "  (overdue: " + t.Due.Format("Mon Jan 2") + ")"
+		default:
+			due = "  (due " + t.Due.Format("Mon Jan 2") + ")"
+		}
+		fmt.Printf("  %s %s%s\n", check, t.Title, due)
+		fmt.Printf("      ID: %s\n", t.ID)
+	}
+}
+
+// startOfDay returns local midnight of t's day
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.In(time.Local).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+func taskToJSON(t *gdaytasks.Task) TaskJSON {
+	j := TaskJSON{
+		ID:        t.ID,
+		ListID:    t.ListID,
+		Title:     t.Title,
+		Notes:     t.Notes,
+		Completed: t.Completed,
+	}
+	if !t.Due.IsZero() {
+		j.Due = t.Due.Format("2006-01-02")
+	}
+	return j
+}
+
+func tasksToJSON(list []*gdaytasks.Task) TasksListJSON {
+	jsonTasks := make([]TaskJSON, 0, len(list))
+	for _, t := range list {
+		jsonTasks = append(jsonTasks, taskToJSON(t))
+	}
+	return TasksListJSON{Count: len(jsonTasks), Tasks: jsonTasks}
+}
@@ -2,13 +2,18 @@ package auth
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/joncooper/gday/internal/config"
@@ -16,21 +21,160 @@ import (
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/calendar/v3"
 	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/tasks/v1"
 )
 
-// Scopes required for Gmail and Calendar access
+// Scopes required for Gmail and Calendar access. Full mail access, needed
+// only for permanent deletion, is left out; it has to be requested with
+// --scope mail.
 var Scopes = []string{
 	gmail.GmailReadonlyScope,
 	gmail.GmailSendScope,
 	gmail.GmailModifyScope,
+	gmail.GmailSettingsBasicScope, // needed for the vacation responder and filters
 	calendar.CalendarReadonlyScope,
 	calendar.CalendarEventsScope,
 }
 
+// ScopeNames maps the short names accepted by 'gday auth login --scope' to
+// OAuth scopes
+var ScopeNames = map[string]string{
+	"mail":              gmail.MailGoogleComScope,
+	"gmail.readonly":    gmail.GmailReadonlyScope,
+	"gmail.send":        gmail.GmailSendScope,
+	"gmail.modify":      gmail.GmailModifyScope,
+	"gmail.settings":    gmail.GmailSettingsBasicScope,
+	"calendar":          calendar.CalendarScope,
+	"calendar.readonly": calendar.CalendarReadonlyScope,
+	"calendar.events":   calendar.CalendarEventsScope,
+	"tasks":             tasks.TasksScope,
+	"tasks.readonly":    tasks.TasksReadonlyScope,
+}
+
+// Each access level lists the scopes, any one of which allows what it names.
+// Tasks and permanent deletion aren't in the default set; they have to be
+// requested with --scope.
+var (
+	GmailRead = []string{
+		gmail.MailGoogleComScope,
+		gmail.GmailReadonlyScope,
+		gmail.GmailModifyScope,
+	}
+	GmailModify = []string{
+		gmail.MailGoogleComScope,
+		gmail.GmailModifyScope,
+	}
+	GmailSend = []string{
+		gmail.MailGoogleComScope,
+		gmail.GmailModifyScope,
+		gmail.GmailSendScope,
+	}
+	GmailSettings = []string{
+		gmail.MailGoogleComScope,
+		gmail.GmailSettingsBasicScope,
+	}
+	GmailDelete = []string{
+		gmail.MailGoogleComScope,
+	}
+	CalendarRead = []string{
+		calendar.CalendarScope,
+		calendar.CalendarReadonlyScope,
+		calendar.CalendarEventsScope,
+	}
+	CalendarWrite = []string{
+		calendar.CalendarScope,
+		calendar.CalendarEventsScope,
+	}
+	CalendarManage = []string{
+		calendar.CalendarScope,
+	}
+	TasksRead = []string{
+		tasks.TasksScope,
+		tasks.TasksReadonlyScope,
+	}
+	TasksWrite = []string{
+		tasks.TasksScope,
+	}
+)
+
+// AccessNames maps the names commands declare their access needs with to
+// access levels
+var AccessNames = map[string][]string{
+	"gmail.read":      GmailRead,
+	"gmail.modify":    GmailModify,
+	"gmail.send":      GmailSend,
+	"gmail.settings":  GmailSettings,
+	"gmail.delete":    GmailDelete,
+	"calendar.read":   CalendarRead,
+	"calendar.write":  CalendarWrite,
+	"calendar.manage": CalendarManage,
+	"tasks.read":      TasksRead,
+	"tasks.write":     TasksWrite,
+}
+
+// ResolveScopes maps short scope names (or full scope URLs) to scopes.
+// No names means the default full set.
+func ResolveScopes(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return Scopes, nil
+	}
+	var scopes []string
+	for _, name := range names {
+		if scope, ok := ScopeNames[name]; ok {
+			scopes = append(scopes, scope)
+			continue
+		}
+		known := false
+		for _, scope := range ScopeNames {
+			known = known || scope == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown scope %q", name)
+		}
+		scopes = append(scopes, name)
+	}
+	return scopes, nil
+}
+
+// RequestedScopes returns the scopes granted at the last login, or the
+// default set if they weren't recorded
+func RequestedScopes() []string {
+	if scopes, err := config.ReadScopes(); err == nil && scopes != nil {
+		return scopes
+	}
+	return Scopes
+}
+
+// CheckAccess returns an error if the recorded login granted none of the
+// given scopes
+func CheckAccess(anyOf []string) error {
+	granted := RequestedScopes()
+	for _, want := range anyOf {
+		for _, have := range granted {
+			if want == have {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: this command needs one of %s\n\nRun 'gday auth login' with a --scope that includes it",
+		ErrScopeNotGranted, strings.Join(anyOf, ", "))
+}
+
 // Google's device authorization endpoint
 const deviceAuthURL = "https://oauth2.googleapis.com/device/code"
 const tokenURL = "https://oauth2.googleapis.com/token"
 
+// ErrNotAuthenticated is returned when there is no usable token
+var ErrNotAuthenticated = errors.New("not authenticated. Run 'gday auth login' to authenticate")
+
+// ErrNoCredentials is returned when OAuth credentials haven't been set up
+var ErrNoCredentials = errors.New("OAuth credentials not configured")
+
+// ErrScopeNotGranted is returned when the login doesn't include the access a
+// command needs
+var ErrScopeNotGranted = errors.New("access not granted at login")
+
 // DeviceAuthResponse represents the response from device authorization request
 type DeviceAuthResponse struct {
 	DeviceCode      string `json:"device_code"`
@@ -52,19 +196,61 @@ func GetClient(ctx context.Context) (*http.Client, error) {
 		return nil, fmt.Errorf("failed to get token: %w", err)
 	}
 
-	return cfg.Client(ctx, token), nil
+	// Retry rate-limited and failed requests, including token refreshes
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{
+		Transport: &retryTransport{base: http.DefaultTransport},
+	})
+
+	// Refresh ahead of expiry during long-running commands too, saving each
+	// new token so the next run doesn't refresh again
+	src := oauth2.ReuseTokenSourceWithExpiry(token, newPersistingTokenSource(ctx, cfg, token), refreshMargin)
+	return oauth2.NewClient(ctx, src), nil
+}
+
+// refreshMargin is how long before expiry a token is refreshed, so requests
+// never go out with a token that is about to lapse
+const refreshMargin = 2 * time.Minute
+
+// persistingTokenSource mints a new access token from the refresh token on
+// every call and saves it
+type persistingTokenSource struct {
+	ctx          context.Context
+	cfg          *oauth2.Config
+	refreshToken string
+}
+
+func newPersistingTokenSource(ctx context.Context, cfg *oauth2.Config, token *oauth2.Token) *persistingTokenSource {
+	return &persistingTokenSource{ctx: ctx, cfg: cfg, refreshToken: token.RefreshToken}
+}
+
+// Token refreshes the access token and saves the result
+func (s *persistingTokenSource) Token() (*oauth2.Token, error) {
+	if s.refreshToken == "" {
+		return nil, fmt.Errorf("no refresh token stored: %w", ErrNotAuthenticated)
+	}
+	// With no access token the config's source always refreshes
+	token, err := s.cfg.TokenSource(s.ctx, &oauth2.Token{RefreshToken: s.refreshToken}).Token()
+	if err != nil {
+		return nil, err
+	}
+	if token.RefreshToken != "" {
+		s.refreshToken = token.RefreshToken
+	}
+	config.SaveToken(token)
+	return token, nil
 }
 
 // getOAuthConfig returns the OAuth2 configuration
 func getOAuthConfig() (*oauth2.Config, error) {
 	credBytes, err := config.ReadCredentials()
 	if err != nil {
-		return nil, fmt.Errorf("unable to read credentials file: %w\n\nRun 'gday auth setup' to configure credentials", err)
+		return nil, fmt.Errorf("%w: unable to read credentials file: %v\n\nRun 'gday auth setup' to configure credentials", ErrNoCredentials, err)
 	}
 
 	cfg, err := google.ConfigFromJSON(credBytes, Scopes...)
 	if err != nil {
-		return nil, fmt.Errorf("unable to parse credentials: %w", err)
+		source, _ := config.CredentialsSource()
+		return nil, fmt.Errorf("unable to parse credentials from %s: %w", source, err)
 	}
 
 	return cfg, nil
@@ -72,60 +258,224 @@ func getOAuthConfig() (*oauth2.Config, error) {
 
 // getToken retrieves a token from cache or initiates OAuth flow
 func getToken(ctx context.Context, cfg *oauth2.Config) (*oauth2.Token, error) {
-	tokenBytes, err := config.ReadToken()
+	token, err := readToken()
+	if errors.Is(err, config.ErrPassphraseRequired) || errors.Is(err, config.ErrWrongPassphrase) {
+		return nil, fmt.Errorf("%w: %w", ErrNotAuthenticated, err)
+	}
 	if err == nil {
-		var token oauth2.Token
-		if err := json.Unmarshal(tokenBytes, &token); err == nil {
-			// Check if token is still valid or can be refreshed
-			if token.Valid() {
-				return &token, nil
-			}
-			// Try to refresh
-			tokenSource := cfg.TokenSource(ctx, &token)
-			newToken, err := tokenSource.Token()
-			if err == nil {
-				config.SaveToken(newToken)
-				return newToken, nil
-			}
+		// Use the token unless it expires soon; refresh it otherwise, falling
+		// back to it if the refresh fails but it hasn't expired yet
+		if token.Valid() && (token.Expiry.IsZero() || time.Until(token.Expiry) > refreshMargin) {
+			return token, nil
+		}
+		if newToken, err := refreshToken(ctx, cfg, token); err == nil {
+			return newToken, nil
+		}
+		if token.Valid() {
+			return token, nil
 		}
 	}
 
-	return nil, fmt.Errorf("not authenticated. Run 'gday auth login' to authenticate")
+	return nil, ErrNotAuthenticated
 }
 
-// Login performs the OAuth2 login flow (browser-based)
-func Login(ctx context.Context) error {
+// readToken loads the cached token
+func readToken() (*oauth2.Token, error) {
+	tokenBytes, err := config.ReadToken()
+	if err != nil {
+		return nil, err
+	}
+	var token oauth2.Token
+	if err := json.Unmarshal(tokenBytes, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// refreshToken exchanges the token's refresh token for a new access token
+// and saves the result
+func refreshToken(ctx context.Context, cfg *oauth2.Config, token *oauth2.Token) (*oauth2.Token, error) {
+	return newPersistingTokenSource(ctx, cfg, token).Token()
+}
+
+// Refresh forces a refresh of the cached token, even if it is still valid
+func Refresh(ctx context.Context) (*oauth2.Token, error) {
 	cfg, err := getOAuthConfig()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Start local server for OAuth callback
-	codeChan := make(chan string, 1)
-	errChan := make(chan error, 1)
+	token, err := readToken()
+	if errors.Is(err, config.ErrPassphraseRequired) || errors.Is(err, config.ErrWrongPassphrase) {
+		return nil, fmt.Errorf("%w: %w", ErrNotAuthenticated, err)
+	}
+	if err != nil {
+		return nil, ErrNotAuthenticated
+	}
+	if token.RefreshToken == "" {
+		return nil, fmt.Errorf("no refresh token stored: %w", ErrNotAuthenticated)
+	}
 
-	server := &http.Server{Addr: ":8089"}
+	newToken, err := refreshToken(ctx, cfg, token)
+	if err != nil {
+		return nil, fmt.Errorf("token refresh failed: %w", err)
+	}
+	return newToken, nil
+}
 
-	http.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
-		code := r.URL.Query().Get("code")
-		if code == "" {
-			errChan <- fmt.Errorf("no code in callback")
-			fmt.Fprintf(w, "<html><body><h1>Error</h1><p>No authorization code received.</p></body></html>")
-			return
+// CheckCredentials verifies that the credentials file exists and parses
+func CheckCredentials() error {
+	_, err := getOAuthConfig()
+	return err
+}
+
+// ClientType returns the kind of OAuth client credentials describe:
+// "installed" for Desktop app clients, "web" for Web application clients,
+// or "" if neither key is present
+func ClientType(credJSON []byte) string {
+	var creds map[string]json.RawMessage
+	if err := json.Unmarshal(credJSON, &creds); err != nil {
+		return ""
+	}
+	for _, t := range []string{"installed", "web"} {
+		if _, ok := creds[t]; ok {
+			return t
 		}
-		codeChan <- code
-		fmt.Fprintf(w, "<html><body><h1>Success!</h1><p>You can close this window and return to the terminal.</p></body></html>")
-	})
+	}
+	return ""
+}
 
-	go func() {
-		if err := server.ListenAndServe(); err != http.ErrServerClosed {
-			errChan <- err
+// ClientTypeWarning explains why credentials that aren't for a Desktop app
+// client may not work, or returns "" if they are
+func ClientTypeWarning(credJSON []byte) string {
+	switch ClientType(credJSON) {
+	case "installed":
+		return ""
+	case "web":
+		return "These credentials are for a \"Web application\" OAuth client. Browser login\n" +
+			"will fail with redirect_uri_mismatch, because gday listens on a random local\n" +
+			"port that can't be registered in advance. Create a \"Desktop app\" client instead."
+	default:
+		return "Could not tell what kind of OAuth client these credentials are for.\n" +
+			"gday expects the JSON downloaded for a \"Desktop app\" client."
+	}
+}
+
+// Token returns the cached token, refreshing it if it has expired
+func Token(ctx context.Context) (*oauth2.Token, error) {
+	cfg, err := getOAuthConfig()
+	if err != nil {
+		return nil, err
+	}
+	return getToken(ctx, cfg)
+}
+
+// tokenInfoURL reports the scopes granted to an access token
+const tokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+
+// GrantedScopes asks Google which scopes an access token carries
+func GrantedScopes(ctx context.Context, token *oauth2.Token) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		tokenInfoURL+"?access_token="+url.QueryEscape(token.AccessToken), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token info request failed: %s", string(body))
+	}
+
+	var info struct {
+		Scope string `json:"scope"`
+	}
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, err
+	}
+	return strings.Fields(info.Scope), nil
+}
+
+// MissingScopes returns the scopes requested at login that are not
+// present in granted
+func MissingScopes(granted []string) []string {
+	have := make(map[string]bool, len(granted))
+	for _, s := range granted {
+		have[s] = true
+	}
+	var missing []string
+	for _, s := range RequestedScopes() {
+		if !have[s] {
+			missing = append(missing, s)
 		}
-	}()
+	}
+	return missing
+}
+
+// IsInsufficientScope reports whether err is the 403 the APIs return when
+// the token lacks a scope the request needs
+func IsInsufficientScope(err error) bool {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusForbidden {
+		return false
+	}
+	for _, e := range apiErr.Errors {
+		if e.Reason == "insufficientPermissions" {
+			return true
+		}
+	}
+	return strings.Contains(apiErr.Message, "insufficient authentication scopes") ||
+		strings.Contains(string(apiErr.Body), "ACCESS_TOKEN_SCOPE_INSUFFICIENT")
+}
+
+// ScopeHint explains how to grant the scopes the cached token is missing
+func ScopeHint(ctx context.Context) string {
+	hint := "Run 'gday auth login' to grant the missing permissions"
+	token, err := Token(ctx)
+	if err != nil {
+		return hint
+	}
+	granted, err := GrantedScopes(ctx, token)
+	if err != nil {
+		return hint
+	}
+	if missing := MissingScopes(granted); len(missing) > 0 {
+		return fmt.Sprintf("Your login is missing %s.\n%s", strings.Join(missing, ", "), hint)
+	}
+	return hint
+}
+
+// Login performs the OAuth2 login flow (browser-based), requesting the
+// given scopes
+func Login(ctx context.Context, scopes []string) error {
+	cfg, err := getOAuthConfig()
+	if err != nil {
+		return err
+	}
+	cfg.Scopes = scopes
+
+	// Start a local server for the OAuth callback. Each login gets its own
+	// mux and an ephemeral port, so logins can run back to back in one process.
+	state, err := randomState()
+	if err != nil {
+		return err
+	}
+	cb, err := startCallbackServer(state)
+	if err != nil {
+		return fmt.Errorf("failed to start callback server: %w", err)
+	}
+	defer cb.shutdown()
 
 	// Generate auth URL
-	cfg.RedirectURL = "http://localhost:8089/callback"
-	authURL := cfg.AuthCodeURL("state-token", oauth2.AccessTypeOffline, oauth2.ApprovalForce)
+	cfg.RedirectURL = cb.redirectURL
+	authURL := cfg.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.ApprovalForce)
 
 	fmt.Println("\nOpening browser for Google authentication...")
 	fmt.Println("\nIf the browser doesn't open, visit this URL:")
@@ -137,16 +487,15 @@ func Login(ctx context.Context) error {
 	// Wait for callback
 	var code string
 	select {
-	case code = <-codeChan:
-	case err := <-errChan:
-		server.Shutdown(ctx)
+	case code = <-cb.codes:
+	case err := <-cb.errs:
 		return fmt.Errorf("OAuth callback error: %w", err)
+	case <-ctx.Done():
+		return ctx.Err()
 	case <-time.After(5 * time.Minute):
-		server.Shutdown(ctx)
 		return fmt.Errorf("authentication timeout")
 	}
-
-	server.Shutdown(ctx)
+	cb.shutdown()
 
 	// Exchange code for token
 	token, err := cfg.Exchange(ctx, code)
@@ -158,20 +507,101 @@ func Login(ctx context.Context) error {
 	if err := config.SaveToken(token); err != nil {
 		return fmt.Errorf("failed to save token: %w", err)
 	}
+	granted, _ := token.Extra("scope").(string)
+	saveGrantedScopes(granted, scopes)
 
 	fmt.Println("Authentication successful!")
 	return nil
 }
 
-// LoginDevice performs the OAuth2 device flow (for headless/SSH environments)
-func LoginDevice(ctx context.Context) error {
+// callbackServer receives the authorization code from the browser redirect
+type callbackServer struct {
+	redirectURL string
+	codes       chan string
+	errs        chan error
+	server      *http.Server
+	once        sync.Once
+}
+
+// startCallbackServer listens on an ephemeral loopback port and serves the
+// OAuth redirect, accepting only callbacks carrying state
+func startCallbackServer(state string) (*callbackServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	cb := &callbackServer{
+		redirectURL: fmt.Sprintf("http://127.0.0.1:%d/callback", listener.Addr().(*net.TCPAddr).Port),
+		codes:       make(chan string, 1),
+		errs:        make(chan error, 1),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("state") != state {
+			http.Error(w, "Invalid state", http.StatusBadRequest)
+			return
+		}
+		code := query.Get("code")
+		if code == "" {
+			cb.sendErr(fmt.Errorf("no code in callback"))
+			fmt.Fprintf(w, "<html><body><h1>Error</h1><p>No authorization code received.</p></body></html>")
+			return
+		}
+		select {
+		case cb.codes <- code:
+		default:
+		}
+		fmt.Fprintf(w, "<html><body><h1>Success!</h1><p>You can close this window and return to the terminal.</p></body></html>")
+	})
+
+	cb.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := cb.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			cb.sendErr(err)
+		}
+	}()
+	return cb, nil
+}
+
+// sendErr reports an error without blocking if one is already pending
+func (cb *callbackServer) sendErr(err error) {
+	select {
+	case cb.errs <- err:
+	default:
+	}
+}
+
+// shutdown stops the server; it is safe to call more than once
+func (cb *callbackServer) shutdown() {
+	cb.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		cb.server.Shutdown(ctx)
+	})
+}
+
+// randomState returns an unguessable OAuth state parameter
+func randomState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate state: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// LoginDevice performs the OAuth2 device flow (for headless/SSH
+// environments), requesting the given scopes
+func LoginDevice(ctx context.Context, scopes []string) error {
 	cfg, err := getOAuthConfig()
 	if err != nil {
 		return err
 	}
 
 	// Request device code
-	deviceAuth, err := requestDeviceCode(cfg.ClientID)
+	deviceAuth, err := requestDeviceCode(cfg.ClientID, scopes)
 	if err != nil {
 		return fmt.Errorf("failed to get device code: %w", err)
 	}
@@ -199,16 +629,28 @@ func LoginDevice(ctx context.Context) error {
 	if err := config.SaveToken(token); err != nil {
 		return fmt.Errorf("failed to save token: %w", err)
 	}
+	granted, _ := token.Extra("scope").(string)
+	saveGrantedScopes(granted, scopes)
 
 	fmt.Println("\nAuthentication successful!")
 	return nil
 }
 
+// saveGrantedScopes records the scopes Google reports granting, falling back
+// to the requested ones if it didn't say
+func saveGrantedScopes(granted string, requested []string) {
+	scopes := strings.Fields(granted)
+	if len(scopes) == 0 {
+		scopes = requested
+	}
+	config.SaveScopes(scopes)
+}
+
 // requestDeviceCode requests a device code from Google
-func requestDeviceCode(clientID string) (*DeviceAuthResponse, error) {
+func requestDeviceCode(clientID string, scopes []string) (*DeviceAuthResponse, error) {
 	data := url.Values{}
 	data.Set("client_id", clientID)
-	data.Set("scope", strings.Join(Scopes, " "))
+	data.Set("scope", strings.Join(scopes, " "))
 
 	resp, err := http.PostForm(deviceAuthURL, data)
 	if err != nil {
@@ -327,6 +769,7 @@ func requestToken(clientID, clientSecret, deviceCode string) (*oauth2.Token, err
 		TokenType:    tokenResp.TokenType,
 		Expiry:       time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second),
 	}
+	token = token.WithExtra(map[string]interface{}{"scope": tokenResp.Scope})
 
 	return token, nil
 }
@@ -336,53 +779,94 @@ func Logout() error {
 	if err := config.DeleteToken(); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to delete token: %w", err)
 	}
-	fmt.Println("Logged out successfully")
-	return nil
+	return config.SaveScopes(nil)
 }
 
-// Status prints the current authentication status
-func Status() {
+// StatusInfo describes the current authentication state
+type StatusInfo struct {
+	Account       string
+	Configured    bool
+	LoggedIn      bool
+	Authenticated bool
+	Email         string
+	Problem       string
+	Hint          string
+}
+
+// GetStatus checks credentials, the cached token, and the Gmail profile
+func GetStatus() *StatusInfo {
+	info := &StatusInfo{Account: config.Account()}
 	if !config.CredentialsExist() {
-		fmt.Println("Status: Not configured")
-		fmt.Println("\nRun 'gday auth setup' to configure OAuth credentials")
-		return
+		info.Problem = "Not configured"
+		info.Hint = "Run 'gday auth setup' to configure OAuth credentials"
+		return info
 	}
+	info.Configured = true
 
 	if !config.TokenExists() {
-		fmt.Println("Status: Credentials configured, not logged in")
-		fmt.Println("\nRun 'gday auth login' to authenticate")
-		return
+		info.Problem = "Credentials configured, not logged in"
+		info.Hint = "Run 'gday auth login' to authenticate"
+		return info
 	}
+	info.LoggedIn = true
 
 	// Try to verify token
 	ctx := context.Background()
 	client, err := GetClient(ctx)
+	if errors.Is(err, config.ErrPassphraseRequired) || errors.Is(err, config.ErrWrongPassphrase) {
+		info.Problem = "Token is encrypted"
+		info.Hint = err.Error()
+		return info
+	}
 	if err != nil {
-		fmt.Println("Status: Token expired or invalid")
-		fmt.Println("\nRun 'gday auth login' to re-authenticate")
-		return
+		info.Problem = "Token expired or invalid"
+		info.Hint = "Run 'gday auth login' to re-authenticate"
+		return info
 	}
 
 	// Quick check with Gmail API
 	srv, err := gmail.New(client)
 	if err != nil {
-		fmt.Println("Status: Error creating Gmail client")
-		return
+		info.Problem = "Error creating Gmail client"
+		return info
 	}
 
-	profile, err := srv.Users.GetProfile("me").Do()
+	profile, err := srv.Users.GetProfile("me").Context(ctx).Do()
 	if err != nil {
-		fmt.Println("Status: Token invalid")
-		fmt.Println("\nRun 'gday auth login' to re-authenticate")
+		info.Problem = "Token invalid"
+		info.Hint = "Run 'gday auth login' to re-authenticate"
+		return info
+	}
+
+	info.Authenticated = true
+	info.Email = profile.EmailAddress
+	return info
+}
+
+// Status prints the current authentication status
+func Status() {
+	info := GetStatus()
+	if info.Account != config.DefaultAccount {
+		fmt.Printf("Account: %s\n", info.Account)
+	}
+	if !info.Authenticated {
+		fmt.Printf("Status: %s\n", info.Problem)
+		if info.Hint != "" {
+			fmt.Printf("\n%s\n", info.Hint)
+		}
 		return
 	}
 
 	fmt.Println("Status: Authenticated")
-	fmt.Printf("Email: %s\n", profile.EmailAddress)
+	fmt.Printf("Email: %s\n", info.Email)
 }
 
-// openBrowser attempts to open the URL in the default browser
-func openBrowser(url string) {
+// openBrowser is OpenBrowser, replaced in tests to follow the login
+// redirect without a browser
+var openBrowser = OpenBrowser
+
+// OpenBrowser attempts to open the URL in the default browser
+func OpenBrowser(url string) {
 	// Try common browser open commands
 	commands := [][]string{
 		{"xdg-open", url},
@@ -0,0 +1,280 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joncooper/gday/internal/config"
+	"github.com/zalando/go-keyring"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestDefaultScopesLeaveOutFullMailAccess(t *testing.T) {
+	scopes, err := ResolveScopes(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Contains(scopes, gmail.MailGoogleComScope) {
+		t.Errorf("default scopes include %s", gmail.MailGoogleComScope)
+	}
+}
+
+func TestCheckAccess(t *testing.T) {
+	tests := []struct {
+		name    string
+		login   []string // Scopes given to 'auth login --scope'; nil for the default
+		anyOf   []string
+		wantErr bool
+	}{
+		{name: "default login reads mail", anyOf: GmailRead},
+		{name: "default login can't delete", anyOf: GmailDelete, wantErr: true},
+		{name: "mail scope can delete", login: []string{"mail"}, anyOf: GmailDelete},
+		{name: "mail scope reads mail", login: []string{"mail"}, anyOf: GmailRead},
+		{name: "calendar only can't read mail", login: []string{"calendar.events"}, anyOf: GmailRead, wantErr: true},
+		{name: "tasks need asking for", anyOf: TasksRead, wantErr: true},
+		{name: "tasks scope", login: []string{"tasks"}, anyOf: TasksRead},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			scopes, err := ResolveScopes(tt.login)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := config.SaveScopes(scopes); err != nil {
+				t.Fatal(err)
+			}
+
+			err = CheckAccess(tt.anyOf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrScopeNotGranted) {
+				t.Errorf("err = %v, want ErrScopeNotGranted", err)
+			}
+		})
+	}
+}
+
+// fakeLogin stands in for Google's side of the browser login: it issues a
+// token named after the authorization code, and its follow method plays the
+// browser, sending the redirect back to the callback server
+type fakeLogin struct {
+	t      *testing.T
+	logins int
+}
+
+func (f *fakeLogin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"access_token":"token-for-%s","token_type":"Bearer","expires_in":3600}`, r.FormValue("code"))
+}
+
+func (f *fakeLogin) follow(authURL string) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		f.t.Error(err)
+		return
+	}
+	f.logins++
+	query := u.Query()
+	callback := url.Values{"code": {fmt.Sprint(f.logins)}, "state": {query.Get("state")}}
+	go func() {
+		resp, err := http.Get(query.Get("redirect_uri") + "?" + callback.Encode())
+		if err != nil {
+			f.t.Error(err)
+			return
+		}
+		resp.Body.Close()
+	}()
+}
+
+func TestLoginTwice(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	fake := &fakeLogin{t: t}
+	ts := httptest.NewServer(fake)
+	t.Cleanup(ts.Close)
+	t.Setenv(config.CredentialsEnv, fmt.Sprintf(`{"installed":{"client_id":"id","client_secret":"secret",`+
+		`"auth_uri":"https://accounts.example.com/auth","token_uri":%q,"redirect_uris":["http://localhost"]}}`, ts.URL+"/token"))
+	saved := openBrowser
+	openBrowser = fake.follow
+	t.Cleanup(func() { openBrowser = saved })
+
+	// Each login needs its own callback server; reusing a mux or port would
+	// panic or fail on the second
+	for _, want := range []string{"token-for-1", "token-for-2", "token-for-3"} {
+		if err := Login(context.Background(), Scopes); err != nil {
+			t.Fatal(err)
+		}
+		token, err := readToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.AccessToken != want {
+			t.Errorf("access token = %q, want %q", token.AccessToken, want)
+		}
+	}
+}
+
+func TestClientTypeWarning(t *testing.T) {
+	tests := []struct {
+		name        string
+		json        string
+		wantType    string
+		wantWarning string // A phrase the warning must contain; "" for none
+	}{
+		{"desktop app", `{"installed":{"client_id":"id","client_secret":"s"}}`, "installed", ""},
+		{"web application", `{"web":{"client_id":"id","client_secret":"s","redirect_uris":["https://example.com/cb"]}}`, "web", "Web application"},
+		{"unknown shape", `{"client_id":"id"}`, "", "Desktop app"},
+		{"not JSON", `client_id=id`, "", "Desktop app"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClientType([]byte(tt.json)); got != tt.wantType {
+				t.Errorf("ClientType = %q, want %q", got, tt.wantType)
+			}
+			warning := ClientTypeWarning([]byte(tt.json))
+			if tt.wantWarning == "" {
+				if warning != "" {
+					t.Errorf("unexpected warning %q", warning)
+				}
+				return
+			}
+			if !strings.Contains(warning, tt.wantWarning) {
+				t.Errorf("warning %q doesn't mention %q", warning, tt.wantWarning)
+			}
+		})
+	}
+}
+
+// fakeTokenEndpoint exchanges the refresh token "r1" for a new access
+// token, counting the tokens it issues
+type fakeTokenEndpoint struct {
+	issued int
+}
+
+func (f *fakeTokenEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.FormValue("grant_type") != "refresh_token" || r.FormValue("refresh_token") != "r1" {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"invalid_grant","error_description":"Token has been expired or revoked."}`)
+		return
+	}
+	f.issued++
+	fmt.Fprintf(w, `{"access_token":"fresh-%d","token_type":"Bearer","expires_in":3600}`, f.issued)
+}
+
+func TestRefresh(t *testing.T) {
+	keyring.MockInit()
+	tests := []struct {
+		name          string
+		stored        *oauth2.Token
+		wantErr       bool
+		wantErrIs     error
+		wantRefreshes int
+		wantAccess    string // The stored access token afterwards
+	}{
+		{
+			name:          "valid token is refreshed anyway",
+			stored:        &oauth2.Token{AccessToken: "old", RefreshToken: "r1", Expiry: time.Now().Add(time.Hour)},
+			wantRefreshes: 1,
+			wantAccess:    "fresh-1",
+		},
+		{
+			name:          "expired token",
+			stored:        &oauth2.Token{AccessToken: "old", RefreshToken: "r1", Expiry: time.Now().Add(-time.Hour)},
+			wantRefreshes: 1,
+			wantAccess:    "fresh-1",
+		},
+		{
+			name:       "no refresh token",
+			stored:     &oauth2.Token{AccessToken: "old", Expiry: time.Now().Add(time.Hour)},
+			wantErr:    true,
+			wantErrIs:  ErrNotAuthenticated,
+			wantAccess: "old",
+		},
+		{
+			name:       "revoked refresh token",
+			stored:     &oauth2.Token{AccessToken: "old", RefreshToken: "revoked"},
+			wantErr:    true,
+			wantAccess: "old",
+		},
+		{
+			name:      "no token",
+			wantErr:   true,
+			wantErrIs: ErrNotAuthenticated,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			fake := &fakeTokenEndpoint{}
+			ts := httptest.NewServer(fake)
+			t.Cleanup(ts.Close)
+			t.Setenv(config.CredentialsEnv, fmt.Sprintf(`{"installed":{"client_id":"id","client_secret":"secret",`+
+				`"auth_uri":"https://accounts.example.com/auth","token_uri":%q,"redirect_uris":["http://localhost"]}}`, ts.URL+"/token"))
+			if err := config.SaveSettings(&config.Settings{TokenStore: config.TokenStoreKeyring}); err != nil {
+				t.Fatal(err)
+			}
+			store, err := config.GetTokenStore()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := store.(config.KeyringStore); !ok {
+				t.Fatalf("token store = %T, want the keyring", store)
+			}
+			store.Delete()
+			if tt.stored != nil {
+				if err := config.SaveToken(tt.stored); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			token, err := Refresh(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErrIs != nil && !errors.Is(err, tt.wantErrIs) {
+				t.Errorf("err = %v, want %v", err, tt.wantErrIs)
+			}
+			if fake.issued != tt.wantRefreshes {
+				t.Errorf("token endpoint issued %d tokens, want %d", fake.issued, tt.wantRefreshes)
+			}
+			if err == nil && token.AccessToken != tt.wantAccess {
+				t.Errorf("returned access token = %q, want %q", token.AccessToken, tt.wantAccess)
+			}
+
+			saved, readErr := readToken()
+			if tt.stored == nil {
+				return
+			}
+			if readErr != nil {
+				t.Fatal(readErr)
+			}
+			if saved.AccessToken != tt.wantAccess {
+				t.Errorf("keyring holds access token %q, want %q", saved.AccessToken, tt.wantAccess)
+			}
+			if saved.RefreshToken != tt.stored.RefreshToken {
+				t.Errorf("keyring holds refresh token %q, want it kept as %q", saved.RefreshToken, tt.stored.RefreshToken)
+			}
+			if path, _ := config.GetTokenPath(); fileExists(path) {
+				t.Errorf("token written to %s, want only the keyring", path)
+			}
+		})
+	}
+}
+
+// fileExists reports whether path exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// maxRetries is the number of times a rate-limited or failed request is
+// retried before giving up
+const maxRetries = 4
+
+// retryBaseDelay is the wait before the first retry; each later one waits
+// twice as long
+var retryBaseDelay = time.Second
+
+// quotaReasons are the 403 reasons meaning a quota is used up for the day;
+// retrying before the quota resets can't succeed
+var quotaReasons = map[string]bool{
+	"dailyLimitExceeded":      true,
+	"userRateLimitExceeded":   true,
+	"quotaExceeded":           true,
+	"dailyLimitExceededUnreg": true,
+}
+
+// retryTransport retries rate-limited requests and failed reads, backing off
+// exponentially between attempts. Quota exhaustion is returned immediately.
+type retryTransport struct {
+	base http.RoundTripper
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if err != nil || attempt == maxRetries || !shouldRetry(req, resp) {
+			return resp, err
+		}
+		if req.Body != nil && req.GetBody == nil {
+			// The body can't be replayed
+			return resp, nil
+		}
+
+		wait := retryAfter(resp, attempt)
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// shouldRetry reports whether a response is worth retrying. Server errors
+// are only retried for reads, since a failed send may still have gone out.
+func shouldRetry(req *http.Request, resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return req.Method == http.MethodGet || req.Method == http.MethodHead
+	case http.StatusForbidden:
+		reasons := errorReasons(resp)
+		for _, r := range reasons {
+			if quotaReasons[r] {
+				return false
+			}
+		}
+		for _, r := range reasons {
+			if r == "rateLimitExceeded" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// errorReasons returns the reasons in a Google API error response, leaving
+// the body readable for the caller
+func errorReasons(resp *http.Response) []string {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+
+	var errResp struct {
+		Error struct {
+			Errors []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &errResp) != nil {
+		return nil
+	}
+	var reasons []string
+	for _, e := range errResp.Error.Errors {
+		reasons = append(reasons, e.Reason)
+	}
+	return reasons
+}
+
+// retryAfter returns how long to wait before the next attempt, honoring a
+// Retry-After header in seconds
+func retryAfter(resp *http.Response, attempt int) time.Duration {
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	return retryBaseDelay << attempt
+}
+
+// IsQuotaExhausted reports whether err means a daily or per-user API quota
+// is used up
+func IsQuotaExhausted(err error) bool {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusForbidden {
+		return false
+	}
+	for _, e := range apiErr.Errors {
+		if quotaReasons[e.Reason] {
+			return true
+		}
+	}
+	return false
+}
+
+// QuotaHint explains a quota exhaustion error and when the quota resets.
+// Google API quotas reset at midnight Pacific Time.
+func QuotaHint() string {
+	hint := "The API quota for this project is used up; retrying won't help until it resets"
+	pacific, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		return hint + " at midnight Pacific Time."
+	}
+	now := time.Now().In(pacific)
+	reset := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, pacific)
+	return hint + " at " + reset.Local().Format("Mon 15:04 MST") +
+		" (in " + reset.Sub(now).Round(time.Minute).String() + ")."
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRetryTransport(t *testing.T) {
+	saved := retryBaseDelay
+	retryBaseDelay = 0
+	t.Cleanup(func() { retryBaseDelay = saved })
+
+	reason := func(r string) string {
+		return fmt.Sprintf(`{"error":{"code":403,"errors":[{"reason":%q}]}}`, r)
+	}
+	tests := []struct {
+		name         string
+		method       string
+		status       int
+		body         string
+		wantRequests int
+		wantStatus   int
+	}{
+		{"daily limit isn't retried", http.MethodGet, http.StatusForbidden, reason("dailyLimitExceeded"), 1, http.StatusForbidden},
+		{"per-user quota isn't retried", http.MethodGet, http.StatusForbidden, reason("userRateLimitExceeded"), 1, http.StatusForbidden},
+		{"rate limit is retried", http.MethodGet, http.StatusForbidden, reason("rateLimitExceeded"), 2, http.StatusOK},
+		{"other 403 isn't retried", http.MethodGet, http.StatusForbidden, reason("insufficientPermissions"), 1, http.StatusForbidden},
+		{"too many requests is retried", http.MethodPost, http.StatusTooManyRequests, "{}", 2, http.StatusOK},
+		{"read server error is retried", http.MethodGet, http.StatusServiceUnavailable, "{}", 2, http.StatusOK},
+		{"send server error isn't retried", http.MethodPost, http.StatusServiceUnavailable, "{}", 1, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The first request fails; any retry succeeds
+			requests := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if requests == 1 {
+					w.WriteHeader(tt.status)
+					fmt.Fprint(w, tt.body)
+					return
+				}
+				fmt.Fprint(w, "{}")
+			}))
+			t.Cleanup(ts.Close)
+
+			client := &http.Client{Transport: &retryTransport{base: http.DefaultTransport}}
+			req, err := http.NewRequest(tt.method, ts.URL, strings.NewReader("{}"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if requests != tt.wantRequests {
+				t.Errorf("made %d requests, want %d", requests, tt.wantRequests)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package calendar
+
+import (
+	"sort"
+	"time"
+)
+
+// CurrentEvent returns the meeting in progress at now, or nil if the user is
+// free. All-day, free, cancelled, and declined events don't count; when
+// meetings overlap, the one ending last is returned.
+func CurrentEvent(events []*Event, now time.Time) *Event {
+	var current *Event
+	for _, e := range events {
+		if e.AllDay || !e.Busy || e.Status == "cancelled" || e.ResponseStatus == "declined" {
+			continue
+		}
+		if now.Before(e.Start) || !now.Before(e.End) {
+			continue
+		}
+		if current == nil || e.End.After(current.End) {
+			current = e
+		}
+	}
+	return current
+}
+
+// LastDay returns the last day an all-day event covers. The API's end date
+// is exclusive, so a one-day event ends the day after it starts; LastDay
+// is that day minus one, and never before Start.
+func (e *Event) LastDay() time.Time {
+	last := e.End.AddDate(0, 0, -1)
+	if last.Before(e.Start) {
+		return e.Start
+	}
+	return last
+}
+
+// Overlaps reports whether e and other share any time. Events that merely
+// touch, one ending as the other starts, don't overlap.
+func (e *Event) Overlaps(other *Event) bool {
+	return e.Start.Before(other.End) && other.Start.Before(e.End)
+}
+
+// Blocks reports whether e takes up the user's time, so that overlapping it
+// is a conflict: it is busy, not cancelled, and not declined. All-day and
+// declined events only count when asked for.
+func (e *Event) Blocks(includeAllDay, includeDeclined bool) bool {
+	if !e.Busy || e.Status == "cancelled" {
+		return false
+	}
+	if e.AllDay && !includeAllDay {
+		return false
+	}
+	if e.ResponseStatus == "declined" && !includeDeclined {
+		return false
+	}
+	return true
+}
+
+// FindConflicts returns every pair of overlapping events, ordered by the
+// first event's start. An event and its copy on another calendar (the same
+// iCalUID) aren't a conflict.
+func FindConflicts(events []*Event) [][2]*Event {
+	sorted := append([]*Event(nil), events...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Start.Before(sorted[j].Start)
+	})
+
+	var conflicts [][2]*Event
+	for i, a := range sorted {
+		for _, b := range sorted[i+1:] {
+			if !b.Start.Before(a.End) {
+				break
+			}
+			if a.ICalUID != "" && a.ICalUID == b.ICalUID {
+				continue
+			}
+			if a.Overlaps(b) {
+				conflicts = append(conflicts, [2]*Event{a, b})
+			}
+		}
+	}
+	return conflicts
+}
@@ -0,0 +1,37 @@
+package calendar
+
+import (
+	"testing"
+
+	"google.golang.org/api/calendar/v3"
+)
+
+func TestAllDayLastDay(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end string // The API's dates; end is exclusive
+		want       string
+	}{
+		{"one day", "2025-06-02", "2025-06-03", "2025-06-02"},
+		{"three days", "2025-06-02", "2025-06-05", "2025-06-04"},
+		{"across a month", "2025-06-30", "2025-07-02", "2025-07-01"},
+		{"no end", "2025-06-02", "2025-06-02", "2025-06-02"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := parseEvent(&calendar.Event{
+				Start: &calendar.EventDateTime{Date: tt.start},
+				End:   &calendar.EventDateTime{Date: tt.end},
+			}, "primary")
+			if !e.AllDay {
+				t.Fatal("not parsed as an all-day event")
+			}
+			if got := e.Start.Format("2006-01-02"); got != tt.start {
+				t.Errorf("start = %s, want %s", got, tt.start)
+			}
+			if got := e.LastDay().Format("2006-01-02"); got != tt.want {
+				t.Errorf("last day = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,12 +2,22 @@ package calendar
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/joncooper/gday/internal/config"
 	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 )
 
@@ -24,13 +34,52 @@ type Event struct {
 	Description  string
 	Location     string
 	Start        time.Time
-	End          time.Time
+	End          time.Time // Exclusive: for all-day events, the day after the last (see LastDay)
 	AllDay       bool
 	Attendees    []string
 	Status       string
 	HtmlLink     string
 	Recurring    bool
 	RecurrenceID string
+	Recurrence   []string
+	TimeZone     string // The IANA zone the event is scheduled in, if the API gives one
+	Busy         bool
+	ICalUID      string
+	Attachments  []Attachment
+
+	Organizer      string
+	IsOrganizer    bool   // The authenticated user organizes the event
+	ResponseStatus string // The user's RSVP: accepted, declined, tentative, or needsAction
+
+	Meet     bool   // When creating, ask for a Google Meet link
+	MeetLink string // The event's Google Meet URL, if it has one
+
+	// Reminders overrides the calendar's default reminders. nil keeps the
+	// defaults; an empty, non-nil slice turns reminders off.
+	Reminders []Reminder
+}
+
+// Reminder is a notification sent Minutes before an event starts
+type Reminder struct {
+	Method  string // "popup" or "email"
+	Minutes int64
+}
+
+// MaxReminderMinutes is the furthest ahead of an event (four weeks) the API
+// allows a reminder
+const MaxReminderMinutes = 40320
+
+// Attachment represents a file (usually a Google Drive link) attached to an event
+type Attachment struct {
+	Title    string
+	FileURL  string
+	MimeType string
+}
+
+// Interval represents a span of time, such as a busy block
+type Interval struct {
+	Start time.Time
+	End   time.Time
 }
 
 // Calendar represents a calendar
@@ -53,7 +102,7 @@ func NewService(ctx context.Context, client *http.Client) (*Service, error) {
 
 // ListCalendars returns all calendars the user has access to
 func (s *Service) ListCalendars(ctx context.Context) ([]*Calendar, error) {
-	resp, err := s.srv.CalendarList.List().Do()
+	resp, err := s.srv.CalendarList.List().Context(ctx).Do()
 	if err != nil {
 		return nil, fmt.Errorf("failed to list calendars: %w", err)
 	}
@@ -72,54 +121,233 @@ func (s *Service) ListCalendars(ctx context.Context) ([]*Calendar, error) {
 	return calendars, nil
 }
 
+// CreateCalendar creates a secondary calendar. timeZone is an IANA name
+// such as "Europe/London"; if empty, the API uses the user's time zone.
+func (s *Service) CreateCalendar(ctx context.Context, name, description, timeZone string) (*Calendar, error) {
+	c, err := s.srv.Calendars.Insert(&calendar.Calendar{
+		Summary:     name,
+		Description: description,
+		TimeZone:    timeZone,
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create calendar: %w", err)
+	}
+	return &Calendar{ID: c.Id, Summary: c.Summary, Description: c.Description}, nil
+}
+
+// ErrPrimaryCalendar is returned when asked to delete the primary calendar
+var ErrPrimaryCalendar = errors.New("the primary calendar can't be deleted")
+
+// DeleteCalendar deletes a secondary calendar and all of its events. The
+// primary calendar can't be deleted.
+func (s *Service) DeleteCalendar(ctx context.Context, calendarID string) error {
+	if calendarID == "" || strings.EqualFold(calendarID, "primary") {
+		return ErrPrimaryCalendar
+	}
+	calendars, err := s.ListCalendars(ctx)
+	if err != nil {
+		return err
+	}
+	for _, c := range calendars {
+		if c.Primary && strings.EqualFold(c.ID, calendarID) {
+			return ErrPrimaryCalendar
+		}
+	}
+
+	if err := s.srv.Calendars.Delete(calendarID).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to delete calendar: %w", err)
+	}
+	return nil
+}
+
+// ResolveCalendarID maps a calendar reference to a calendar ID. It accepts
+// "" or "primary", the ID of any calendar in the user's list (including the
+// user's own email for the primary calendar), or a calendar name. Unknown
+// references are returned unchanged so other people's calendars still work.
+func (s *Service) ResolveCalendarID(ctx context.Context, ref string) (string, error) {
+	ids, err := s.ResolveCalendarIDs(ctx, []string{ref})
+	if err != nil {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+// ResolveCalendarIDs resolves several references as ResolveCalendarID
+// does, listing the user's calendars at most once
+func (s *Service) ResolveCalendarIDs(ctx context.Context, refs []string) ([]string, error) {
+	var calendars []*Calendar
+	ids := make([]string, len(refs))
+	for i, ref := range refs {
+		if ref == "" || strings.EqualFold(ref, "primary") {
+			ids[i] = "primary"
+			continue
+		}
+		if calendars == nil {
+			var err error
+			if calendars, err = s.ListCalendars(ctx); err != nil {
+				return nil, err
+			}
+		}
+		ids[i] = matchCalendar(calendars, ref)
+	}
+	return ids, nil
+}
+
+// matchCalendar returns the ID of the calendar ref names, by ID and then by
+// name, or ref itself if none does
+func matchCalendar(calendars []*Calendar, ref string) string {
+	resolve := func(c *Calendar) string {
+		if c.Primary {
+			return "primary"
+		}
+		return c.ID
+	}
+	for _, c := range calendars {
+		if strings.EqualFold(c.ID, ref) {
+			return resolve(c)
+		}
+	}
+	for _, c := range calendars {
+		if strings.EqualFold(c.Summary, ref) {
+			return resolve(c)
+		}
+	}
+	return ref
+}
+
 // ListEvents lists events from a calendar
 func (s *Service) ListEvents(ctx context.Context, calendarID string, timeMin, timeMax time.Time, maxResults int64) ([]*Event, error) {
 	if calendarID == "" {
 		calendarID = "primary"
 	}
 
+	if maxResults <= 0 {
+		req := s.srv.Events.List(calendarID).
+			SingleEvents(true).
+			OrderBy("startTime").
+			TimeMin(timeMin.Format(time.RFC3339)).
+			TimeMax(timeMax.Format(time.RFC3339))
+		return s.listAllEvents(ctx, req, calendarID)
+	}
+
+	// Pollers ask for "from now", which changes with every call. Asking the
+	// API for whole days instead keeps the request, and so its cache entry
+	// and ETag, the same all day; events outside the window are dropped
+	// here. The first page has room for the events earlier in the day.
+	dayMin := startOfDay(timeMin)
+	dayMax := startOfDay(timeMax)
+	if dayMax.Before(timeMax) {
+		dayMax = dayMax.AddDate(0, 0, 1)
+	}
 	req := s.srv.Events.List(calendarID).
 		SingleEvents(true).
 		OrderBy("startTime").
-		TimeMin(timeMin.Format(time.RFC3339)).
-		TimeMax(timeMax.Format(time.RFC3339))
+		TimeMin(dayMin.Format(time.RFC3339)).
+		TimeMax(dayMax.Format(time.RFC3339)).
+		MaxResults(min(maxResults+earlierEventsRoom, maxEventsPage))
 
-	if maxResults > 0 {
-		req = req.MaxResults(maxResults)
+	// Send the cached ETag so an unchanged listing comes back as 304
+	cacheKey := fmt.Sprintf("events.list|%s|%s|%s|%d", calendarID,
+		dayMin.Format(time.RFC3339), dayMax.Format(time.RFC3339), maxResults)
+	var cached calendar.Events
+	if data, err := config.ReadCache(cacheKey); err == nil && json.Unmarshal(data, &cached) == nil && cached.Etag != "" {
+		req = req.IfNoneMatch(cached.Etag)
 	}
 
-	resp, err := req.Do()
-	if err != nil {
+	resp, err := req.Context(ctx).Do()
+	if googleapi.IsNotModified(err) && cached.Etag != "" {
+		resp = &cached
+	} else if err != nil {
 		return nil, fmt.Errorf("failed to list events: %w", err)
+	} else if data, err := json.Marshal(resp); err == nil {
+		config.SaveCache(cacheKey, data)
 	}
 
-	events := make([]*Event, 0, len(resp.Items))
-	for _, e := range resp.Items {
-		events = append(events, parseEvent(e, calendarID))
+	events := make([]*Event, 0, maxResults)
+	for {
+		for _, e := range resp.Items {
+			event := parseEvent(e, calendarID)
+			if event.End.After(timeMin) && event.Start.Before(timeMax) {
+				events = append(events, event)
+			}
+		}
+		if int64(len(events)) >= maxResults || resp.NextPageToken == "" {
+			break
+		}
+		// A busy day can push events in the window past the first page
+		resp, err = req.IfNoneMatch("").PageToken(resp.NextPageToken).Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list events: %w", err)
+		}
 	}
+	if int64(len(events)) > maxResults {
+		events = events[:maxResults]
+	}
+
+	return events, nil
+}
+
+// earlierEventsRoom is how many more events than asked for ListEvents
+// fetches, for the events between the start of the day and the window
+const earlierEventsRoom = 25
 
+// maxEventsPage is the most events the API returns in one page
+const maxEventsPage = 2500
+
+// listAllEvents pages through every event matched by req. The per-page
+// ETags don't describe the whole listing, so these requests aren't cached.
+func (s *Service) listAllEvents(ctx context.Context, req *calendar.EventsListCall, calendarID string) ([]*Event, error) {
+	var events []*Event
+	err := req.MaxResults(2500).Pages(ctx, func(resp *calendar.Events) error {
+		for _, e := range resp.Items {
+			events = append(events, parseEvent(e, calendarID))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
 	return events, nil
 }
 
-// ListEventsFromAllCalendars lists events from all calendars
-func (s *Service) ListEventsFromAllCalendars(ctx context.Context, timeMin, timeMax time.Time, maxResults int64) ([]*Event, error) {
+// ListEventsFromAllCalendars lists events from all calendars. If dedup is
+// set, an event that appears on several calendars is listed once, preferring
+// the primary calendar's copy.
+func (s *Service) ListEventsFromAllCalendars(ctx context.Context, timeMin, timeMax time.Time, maxResults int64, dedup bool) ([]*Event, error) {
 	calendars, err := s.ListCalendars(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	// Visit the primary calendar first so its copies win deduplication
+	sort.SliceStable(calendars, func(i, j int) bool {
+		return calendars[i].Primary && !calendars[j].Primary
+	})
+
 	var allEvents []*Event
+	seen := make(map[string]bool)
 	for _, cal := range calendars {
+		// Fetch every event in the window; trimming to maxResults has to
+		// wait until all calendars are merged and sorted
 		events, err := s.ListEvents(ctx, cal.ID, timeMin, timeMax, 0)
 		if err != nil {
 			// Skip calendars that fail (e.g., no access)
 			continue
 		}
-		allEvents = append(allEvents, events...)
+		for _, e := range events {
+			if dedup {
+				key := e.dedupKey()
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+			}
+			allEvents = append(allEvents, e)
+		}
 	}
 
 	// Sort by start time
-	sort.Slice(allEvents, func(i, j int) bool {
+	sort.SliceStable(allEvents, func(i, j int) bool {
 		return allEvents[i].Start.Before(allEvents[j].Start)
 	})
 
@@ -131,13 +359,23 @@ func (s *Service) ListEventsFromAllCalendars(ctx context.Context, timeMin, timeM
 	return allEvents, nil
 }
 
+// dedupKey identifies the same event across calendars. Instances of a
+// recurring event share an iCalUID, so the start time is always included.
+func (e *Event) dedupKey() string {
+	start := e.Start.UTC().Format(time.RFC3339)
+	if e.ICalUID != "" {
+		return e.ICalUID + "|" + start
+	}
+	return e.Summary + "|" + start + "|" + e.End.UTC().Format(time.RFC3339)
+}
+
 // GetEvent retrieves a single event
 func (s *Service) GetEvent(ctx context.Context, calendarID, eventID string) (*Event, error) {
 	if calendarID == "" {
 		calendarID = "primary"
 	}
 
-	e, err := s.srv.Events.Get(calendarID, eventID).Do()
+	e, err := s.srv.Events.Get(calendarID, eventID).Context(ctx).Do()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get event: %w", err)
 	}
@@ -167,11 +405,11 @@ func (s *Service) CreateEvent(ctx context.Context, calendarID string, event *Eve
 	} else {
 		e.Start = &calendar.EventDateTime{
 			DateTime: event.Start.Format(time.RFC3339),
-			TimeZone: event.Start.Location().String(),
+			TimeZone: timeZoneName(event.Start),
 		}
 		e.End = &calendar.EventDateTime{
 			DateTime: event.End.Format(time.RFC3339),
-			TimeZone: event.End.Location().String(),
+			TimeZone: timeZoneName(event.End),
 		}
 	}
 
@@ -182,7 +420,36 @@ func (s *Service) CreateEvent(ctx context.Context, calendarID string, event *Eve
 		})
 	}
 
-	created, err := s.srv.Events.Insert(calendarID, e).Do()
+	if err := s.fillTimeZone(ctx, calendarID, e.Start, e.End); err != nil {
+		return nil, err
+	}
+	e.Recurrence = event.Recurrence
+	e.Transparency = transparency(event.Busy)
+	e.Reminders = eventReminders(event.Reminders)
+
+	req := s.srv.Events.Insert(calendarID, e)
+	if event.Meet {
+		requestID, err := meetRequestID()
+		if err != nil {
+			return nil, err
+		}
+		e.ConferenceData = &calendar.ConferenceData{
+			CreateRequest: &calendar.CreateConferenceRequest{
+				RequestId:             requestID,
+				ConferenceSolutionKey: &calendar.ConferenceSolutionKey{Type: "hangoutsMeet"},
+			},
+		}
+		req = req.ConferenceDataVersion(1)
+	}
+
+	created, err := req.Context(ctx).Do()
+	// Calendars that don't offer Meet reject the request outright; create
+	// the event without it and leave MeetLink empty
+	var apiErr *googleapi.Error
+	if event.Meet && errors.As(err, &apiErr) && apiErr.Code == http.StatusBadRequest {
+		e.ConferenceData = nil
+		created, err = s.srv.Events.Insert(calendarID, e).Context(ctx).Do()
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create event: %w", err)
 	}
@@ -190,7 +457,19 @@ func (s *Service) CreateEvent(ctx context.Context, calendarID string, event *Eve
 	return parseEvent(created, calendarID), nil
 }
 
-// UpdateEvent updates an existing event
+// meetRequestID returns a unique ID for a conference create request
+func meetRequestID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate conference request ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// UpdateEvent sets an event's title, description, location, time, and
+// busy status to event's. It patches rather than replaces the event, so
+// fields Event doesn't model (reminders, conferencing, attendee responses)
+// are kept; nil Attendees or Recurrence also leave those unchanged.
 func (s *Service) UpdateEvent(ctx context.Context, calendarID, eventID string, event *Event) (*Event, error) {
 	if calendarID == "" {
 		calendarID = "primary"
@@ -200,30 +479,297 @@ func (s *Service) UpdateEvent(ctx context.Context, calendarID, eventID string, e
 		Summary:     event.Summary,
 		Description: event.Description,
 		Location:    event.Location,
+		// Send empty strings too, so a field can be cleared
+		ForceSendFields: []string{"Summary", "Description", "Location"},
+	}
+	e.Start, e.End = eventTimes(event.Start, event.End, event.AllDay)
+	if err := s.fillTimeZone(ctx, calendarID, e.Start, e.End); err != nil {
+		return nil, err
 	}
 
-	if event.AllDay {
-		e.Start = &calendar.EventDateTime{
-			Date: event.Start.Format("2006-01-02"),
+	if event.Attendees != nil {
+		e.Attendees = []*calendar.EventAttendee{}
+		for _, email := range event.Attendees {
+			e.Attendees = append(e.Attendees, &calendar.EventAttendee{
+				Email: email,
+			})
 		}
-		e.End = &calendar.EventDateTime{
-			Date: event.End.Format("2006-01-02"),
+		e.ForceSendFields = append(e.ForceSendFields, "Attendees")
+	}
+	if event.Recurrence != nil {
+		e.Recurrence = event.Recurrence
+	}
+	e.Transparency = transparency(event.Busy)
+	e.Reminders = eventReminders(event.Reminders)
+
+	updated, err := s.srv.Events.Patch(calendarID, eventID, e).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to update event: %w", err)
+	}
+
+	return parseEvent(updated, calendarID), nil
+}
+
+// SetEventTime moves an event to a new start and end, leaving everything
+// else about it untouched. For a recurring event instance only that
+// instance moves.
+func (s *Service) SetEventTime(ctx context.Context, calendarID, eventID string, start, end time.Time, allDay bool) (*Event, error) {
+	if calendarID == "" {
+		calendarID = "primary"
+	}
+
+	e := &calendar.Event{}
+	e.Start, e.End = eventTimes(start, end, allDay)
+	if err := s.fillTimeZone(ctx, calendarID, e.Start, e.End); err != nil {
+		return nil, err
+	}
+
+	patched, err := s.srv.Events.Patch(calendarID, eventID, e).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to reschedule event: %w", err)
+	}
+
+	return parseEvent(patched, calendarID), nil
+}
+
+// eventTimes returns the start and end to patch onto an event. Each clears
+// the other kind of time, so an event can switch between all-day and timed.
+func eventTimes(start, end time.Time, allDay bool) (*calendar.EventDateTime, *calendar.EventDateTime) {
+	if allDay {
+		s := &calendar.EventDateTime{Date: start.Format("2006-01-02"), NullFields: []string{"DateTime"}}
+		e := &calendar.EventDateTime{Date: end.Format("2006-01-02"), NullFields: []string{"DateTime"}}
+		return s, e
+	}
+	s := &calendar.EventDateTime{
+		DateTime:   start.Format(time.RFC3339),
+		TimeZone:   timeZoneName(start),
+		NullFields: []string{"Date"},
+	}
+	e := &calendar.EventDateTime{
+		DateTime:   end.Format(time.RFC3339),
+		TimeZone:   timeZoneName(end),
+		NullFields: []string{"Date"},
+	}
+	return s, e
+}
+
+// eventReminders converts reminder overrides for the API, or returns nil to
+// leave the calendar's defaults in place
+func eventReminders(reminders []Reminder) *calendar.EventReminders {
+	if reminders == nil {
+		return nil
+	}
+	r := &calendar.EventReminders{
+		Overrides: []*calendar.EventReminder{},
+		// UseDefault must be sent as false, and an empty list must be sent
+		// to clear the overrides
+		ForceSendFields: []string{"UseDefault", "Overrides"},
+	}
+	for _, rem := range reminders {
+		r.Overrides = append(r.Overrides, &calendar.EventReminder{
+			Method:  rem.Method,
+			Minutes: rem.Minutes,
+		})
+	}
+	return r
+}
+
+// timeZoneName returns the IANA name of t's time zone, or "" if it isn't
+// known, leaving the API to use the offset. time.Local is named "Local", so
+// its name comes from localZoneName.
+func timeZoneName(t time.Time) string {
+	if name := t.Location().String(); name != "Local" {
+		return name
+	}
+	return localZoneName()
+}
+
+// localZoneName returns the IANA name of the local time zone: $TZ if set,
+// otherwise the zoneinfo file /etc/localtime links to, as on Linux and
+// macOS. It returns "" if neither gives a zone Go can load.
+var localZoneName = sync.OnceValue(func() string {
+	if tz, ok := os.LookupEnv("TZ"); ok {
+		return validZoneName(strings.TrimPrefix(tz, ":"))
+	}
+	target, err := os.Readlink("/etc/localtime")
+	if err != nil {
+		return ""
+	}
+	return validZoneName(zoneNameFromPath(target))
+})
+
+// zoneNameFromPath returns the zone name at the end of a zoneinfo path, such
+// as America/New_York for /usr/share/zoneinfo/America/New_York, or ""
+func zoneNameFromPath(path string) string {
+	_, name, ok := strings.Cut(filepath.ToSlash(path), "zoneinfo/")
+	if !ok {
+		return ""
+	}
+	// Some systems link to a copy under posix/ or right/
+	name = strings.TrimPrefix(name, "posix/")
+	return strings.TrimPrefix(name, "right/")
+}
+
+// validZoneName returns name if it's a zone Go can load, other than the
+// ambiguous "Local", or ""
+func validZoneName(name string) string {
+	if name == "" || name == "Local" {
+		return ""
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return ""
+	}
+	return name
+}
+
+// fillTimeZone gives a timed event's start and end the calendar's own time
+// zone if the local one has no name. The API needs a named zone to expand
+// recurring events in.
+func (s *Service) fillTimeZone(ctx context.Context, calendarID string, start, end *calendar.EventDateTime) error {
+	if start.DateTime == "" || start.TimeZone != "" {
+		return nil
+	}
+	c, err := s.srv.CalendarList.Get(calendarID).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to get calendar time zone: %w", err)
+	}
+	start.TimeZone, end.TimeZone = c.TimeZone, c.TimeZone
+	return nil
+}
+
+// UpdateAttendees adds and removes attendees by email, leaving everyone
+// else (and their responses) as they were. With notify, added and removed
+// guests are emailed.
+func (s *Service) UpdateAttendees(ctx context.Context, calendarID, eventID string, add, remove []string, notify bool) (*Event, error) {
+	if calendarID == "" {
+		calendarID = "primary"
+	}
+
+	e, err := s.srv.Events.Get(calendarID, eventID).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event: %w", err)
+	}
+
+	removed := make(map[string]bool, len(remove))
+	for _, email := range remove {
+		removed[strings.ToLower(email)] = true
+	}
+	attendees := []*calendar.EventAttendee{}
+	present := make(map[string]bool)
+	for _, a := range e.Attendees {
+		key := strings.ToLower(a.Email)
+		if removed[key] || present[key] {
+			continue
 		}
-	} else {
-		e.Start = &calendar.EventDateTime{
-			DateTime: event.Start.Format(time.RFC3339),
+		present[key] = true
+		attendees = append(attendees, a)
+	}
+	for _, email := range add {
+		key := strings.ToLower(email)
+		if removed[key] || present[key] {
+			continue
 		}
-		e.End = &calendar.EventDateTime{
-			DateTime: event.End.Format(time.RFC3339),
+		present[key] = true
+		attendees = append(attendees, &calendar.EventAttendee{Email: email})
+	}
+
+	sendUpdates := "none"
+	if notify {
+		sendUpdates = "all"
+	}
+	patch := &calendar.Event{Attendees: attendees, ForceSendFields: []string{"Attendees"}}
+	patched, err := s.srv.Events.Patch(calendarID, eventID, patch).SendUpdates(sendUpdates).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to update attendees: %w", err)
+	}
+
+	return parseEvent(patched, calendarID), nil
+}
+
+// AddAttendees invites more people to an event
+func (s *Service) AddAttendees(ctx context.Context, calendarID, eventID string, emails []string, notify bool) (*Event, error) {
+	return s.UpdateAttendees(ctx, calendarID, eventID, emails, nil, notify)
+}
+
+// RemoveAttendees takes people off an event's guest list
+func (s *Service) RemoveAttendees(ctx context.Context, calendarID, eventID string, emails []string, notify bool) (*Event, error) {
+	return s.UpdateAttendees(ctx, calendarID, eventID, nil, emails, notify)
+}
+
+// MoveEvent moves an event to another calendar, changing its organizer to
+// that calendar. Both calendars must be in the user's calendar list.
+func (s *Service) MoveEvent(ctx context.Context, fromCal, eventID, toCal string) (*Event, error) {
+	calendars, err := s.ListCalendars(ctx)
+	if err != nil {
+		return nil, err
+	}
+	find := func(ref string) (string, error) {
+		if ref == "" {
+			ref = "primary"
 		}
+		for _, c := range calendars {
+			if strings.EqualFold(c.ID, ref) || (c.Primary && strings.EqualFold(ref, "primary")) {
+				return c.ID, nil
+			}
+		}
+		return "", fmt.Errorf("calendar %q not found in your calendar list", ref)
 	}
 
-	updated, err := s.srv.Events.Update(calendarID, eventID, e).Do()
+	from, err := find(fromCal)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update event: %w", err)
+		return nil, err
+	}
+	to, err := find(toCal)
+	if err != nil {
+		return nil, err
+	}
+	if from == to {
+		return nil, fmt.Errorf("event is already on %s", to)
 	}
 
-	return parseEvent(updated, calendarID), nil
+	moved, err := s.srv.Events.Move(from, eventID, to).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to move event: %w", err)
+	}
+
+	return parseEvent(moved, to), nil
+}
+
+// ErrNotInvited is returned when responding to an event the user isn't an
+// attendee of
+var ErrNotInvited = errors.New("you aren't on this event's guest list")
+
+// RespondToEvent sets the user's RSVP to response (accepted, declined, or
+// tentative) and notifies the organizer
+func (s *Service) RespondToEvent(ctx context.Context, calendarID, eventID, response string) (*Event, error) {
+	if calendarID == "" {
+		calendarID = "primary"
+	}
+
+	e, err := s.srv.Events.Get(calendarID, eventID).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event: %w", err)
+	}
+
+	// The API marks the authenticated user's own attendee entry with Self
+	found := false
+	for _, a := range e.Attendees {
+		if a.Self {
+			a.ResponseStatus = response
+			found = true
+		}
+	}
+	if !found {
+		return nil, ErrNotInvited
+	}
+
+	patched, err := s.srv.Events.Patch(calendarID, eventID, &calendar.Event{Attendees: e.Attendees}).
+		SendUpdates("all").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to respond to event: %w", err)
+	}
+
+	return parseEvent(patched, calendarID), nil
 }
 
 // DeleteEvent deletes an event
@@ -232,7 +778,7 @@ func (s *Service) DeleteEvent(ctx context.Context, calendarID, eventID string) e
 		calendarID = "primary"
 	}
 
-	if err := s.srv.Events.Delete(calendarID, eventID).Do(); err != nil {
+	if err := s.srv.Events.Delete(calendarID, eventID).Context(ctx).Do(); err != nil {
 		return fmt.Errorf("failed to delete event: %w", err)
 	}
 
@@ -252,11 +798,11 @@ func (s *Service) SearchEvents(ctx context.Context, calendarID, query string, ti
 		TimeMin(timeMin.Format(time.RFC3339)).
 		TimeMax(timeMax.Format(time.RFC3339))
 
-	if maxResults > 0 {
-		req = req.MaxResults(maxResults)
+	if maxResults <= 0 {
+		return s.listAllEvents(ctx, req, calendarID)
 	}
 
-	resp, err := req.Do()
+	resp, err := req.MaxResults(maxResults).Context(ctx).Do()
 	if err != nil {
 		return nil, fmt.Errorf("failed to search events: %w", err)
 	}
@@ -275,7 +821,7 @@ func (s *Service) QuickAdd(ctx context.Context, calendarID, text string) (*Event
 		calendarID = "primary"
 	}
 
-	created, err := s.srv.Events.QuickAdd(calendarID, text).Do()
+	created, err := s.srv.Events.QuickAdd(calendarID, text).Context(ctx).Do()
 	if err != nil {
 		return nil, fmt.Errorf("failed to quick add event: %w", err)
 	}
@@ -283,6 +829,148 @@ func (s *Service) QuickAdd(ctx context.Context, calendarID, text string) (*Event
 	return parseEvent(created, calendarID), nil
 }
 
+// Instances returns the first n expanded occurrences of a recurring event
+func (s *Service) Instances(ctx context.Context, calendarID, eventID string, n int64) ([]*Event, error) {
+	if calendarID == "" {
+		calendarID = "primary"
+	}
+
+	req := s.srv.Events.Instances(calendarID, eventID)
+	if n > 0 {
+		req = req.MaxResults(n)
+	}
+
+	resp, err := req.Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list event instances: %w", err)
+	}
+
+	events := make([]*Event, 0, len(resp.Items))
+	for _, e := range resp.Items {
+		events = append(events, parseEvent(e, calendarID))
+	}
+	if n > 0 && int64(len(events)) > n {
+		events = events[:n]
+	}
+
+	return events, nil
+}
+
+// ErrSyncTokenExpired is returned by SyncEvents when the server has
+// invalidated the sync token and a full resync is required
+var ErrSyncTokenExpired = errors.New("sync token expired; full resync required")
+
+// SyncResult holds the changes returned by an incremental sync
+type SyncResult struct {
+	Changed       []*Event
+	Deleted       []*Event
+	NextSyncToken string
+}
+
+// SyncEvents returns events changed since syncToken was issued. An empty
+// syncToken performs a full sync, returning every event as changed.
+func (s *Service) SyncEvents(ctx context.Context, calendarID, syncToken string) (*SyncResult, error) {
+	if calendarID == "" {
+		calendarID = "primary"
+	}
+
+	result := &SyncResult{}
+	pageToken := ""
+	for {
+		req := s.srv.Events.List(calendarID).ShowDeleted(true)
+		if syncToken != "" {
+			req = req.SyncToken(syncToken)
+		}
+		if pageToken != "" {
+			req = req.PageToken(pageToken)
+		}
+
+		resp, err := req.Context(ctx).Do()
+		if err != nil {
+			var apiErr *googleapi.Error
+			if errors.As(err, &apiErr) && apiErr.Code == http.StatusGone {
+				return nil, ErrSyncTokenExpired
+			}
+			return nil, fmt.Errorf("failed to sync events: %w", err)
+		}
+
+		for _, e := range resp.Items {
+			event := parseEvent(e, calendarID)
+			if e.Status == "cancelled" {
+				result.Deleted = append(result.Deleted, event)
+			} else {
+				result.Changed = append(result.Changed, event)
+			}
+		}
+
+		if resp.NextPageToken == "" {
+			result.NextSyncToken = resp.NextSyncToken
+			break
+		}
+		pageToken = resp.NextPageToken
+	}
+
+	return result, nil
+}
+
+// FreeBusy returns the busy intervals for each calendar between timeMin and timeMax
+func (s *Service) FreeBusy(ctx context.Context, calendars []string, timeMin, timeMax time.Time) (map[string][]Interval, error) {
+	req := &calendar.FreeBusyRequest{
+		TimeMin: timeMin.Format(time.RFC3339),
+		TimeMax: timeMax.Format(time.RFC3339),
+	}
+	for _, id := range calendars {
+		req.Items = append(req.Items, &calendar.FreeBusyRequestItem{Id: id})
+	}
+
+	resp, err := s.srv.Freebusy.Query(req).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query free/busy: %w", err)
+	}
+
+	busy := make(map[string][]Interval, len(resp.Calendars))
+	for id, cal := range resp.Calendars {
+		if len(cal.Errors) > 0 {
+			return nil, fmt.Errorf("failed to query free/busy for %s: %s", id, cal.Errors[0].Reason)
+		}
+		intervals := make([]Interval, 0, len(cal.Busy))
+		for _, p := range cal.Busy {
+			start, err := time.Parse(time.RFC3339, p.Start)
+			if err != nil {
+				continue
+			}
+			end, err := time.Parse(time.RFC3339, p.End)
+			if err != nil {
+				continue
+			}
+			intervals = append(intervals, Interval{Start: start, End: end})
+		}
+		busy[id] = intervals
+	}
+
+	return busy, nil
+}
+
+// MergeIntervals combines overlapping or adjacent intervals into a sorted list
+func MergeIntervals(intervals []Interval) []Interval {
+	sorted := append([]Interval(nil), intervals...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Start.Before(sorted[j].Start)
+	})
+
+	var merged []Interval
+	for _, iv := range sorted {
+		if n := len(merged); n > 0 && !iv.Start.After(merged[n-1].End) {
+			if iv.End.After(merged[n-1].End) {
+				merged[n-1].End = iv.End
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+	return merged
+}
+
 // Today returns events for today
 func (s *Service) Today(ctx context.Context, calendarID string) ([]*Event, error) {
 	now := time.Now()
@@ -307,6 +995,29 @@ func (s *Service) Week(ctx context.Context, calendarID string) ([]*Event, error)
 	return s.ListEvents(ctx, calendarID, startOfDay, endOfWeek, 0)
 }
 
+// nextEventHorizon is how far ahead NextEvent looks
+const nextEventHorizon = 30 * 24 * time.Hour
+
+// NextEvent returns the earliest timed event that hasn't started yet, or
+// nil if there is none in the next 30 days. All-day, cancelled, and
+// declined events are skipped.
+func (s *Service) NextEvent(ctx context.Context, calendarID string) (*Event, error) {
+	now := time.Now()
+	events, err := s.ListEvents(ctx, calendarID, now, now.Add(nextEventHorizon), 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range events {
+		if e.AllDay || e.Status == "cancelled" || e.ResponseStatus == "declined" {
+			continue
+		}
+		if e.Start.After(now) {
+			return e, nil
+		}
+	}
+	return nil, nil
+}
+
 // parseEvent converts a calendar.Event to our Event type
 func parseEvent(e *calendar.Event, calendarID string) *Event {
 	event := &Event{
@@ -317,6 +1028,8 @@ func parseEvent(e *calendar.Event, calendarID string) *Event {
 		Location:    e.Location,
 		Status:      e.Status,
 		HtmlLink:    e.HtmlLink,
+		Busy:        e.Transparency != "transparent",
+		ICalUID:     e.ICalUID,
 	}
 
 	// Parse start time
@@ -329,6 +1042,7 @@ func parseEvent(e *calendar.Event, calendarID string) *Event {
 		} else {
 			t, _ := time.Parse(time.RFC3339, e.Start.DateTime)
 			event.Start = t
+			event.TimeZone = e.Start.TimeZone
 		}
 	}
 
@@ -343,9 +1057,41 @@ func parseEvent(e *calendar.Event, calendarID string) *Event {
 		}
 	}
 
+	// Parse attachments
+	for _, a := range e.Attachments {
+		event.Attachments = append(event.Attachments, Attachment{
+			Title:    a.Title,
+			FileURL:  a.FileUrl,
+			MimeType: a.MimeType,
+		})
+	}
+
+	// Parse organizer
+	if e.Organizer != nil {
+		event.Organizer = e.Organizer.Email
+		event.IsOrganizer = e.Organizer.Self
+	}
+
 	// Parse attendees
 	for _, a := range e.Attendees {
 		event.Attendees = append(event.Attendees, a.Email)
+		if a.Self {
+			event.ResponseStatus = a.ResponseStatus
+		}
+	}
+	// Events without guests are implicitly accepted by their organizer
+	if event.ResponseStatus == "" && event.IsOrganizer {
+		event.ResponseStatus = "accepted"
+	}
+
+	event.Recurrence = e.Recurrence
+	event.MeetLink = meetLink(e)
+
+	if e.Reminders != nil && !e.Reminders.UseDefault {
+		event.Reminders = []Reminder{}
+		for _, r := range e.Reminders.Overrides {
+			event.Reminders = append(event.Reminders, Reminder{Method: r.Method, Minutes: r.Minutes})
+		}
 	}
 
 	// Check if recurring
@@ -356,3 +1102,26 @@ func parseEvent(e *calendar.Event, calendarID string) *Event {
 
 	return event
 }
+
+// meetLink returns the event's Google Meet URL, or "" if it has none
+func meetLink(e *calendar.Event) string {
+	if e.HangoutLink != "" {
+		return e.HangoutLink
+	}
+	if e.ConferenceData != nil {
+		for _, ep := range e.ConferenceData.EntryPoints {
+			if ep.EntryPointType == "video" {
+				return ep.Uri
+			}
+		}
+	}
+	return ""
+}
+
+// transparency maps the Busy flag to the API's transparency value
+func transparency(busy bool) string {
+	if busy {
+		return "opaque"
+	}
+	return "transparent"
+}
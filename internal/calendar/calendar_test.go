@@ -0,0 +1,958 @@
+package calendar
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/option"
+)
+
+// newTestService returns a Service whose requests go to handler
+func newTestService(t *testing.T, handler http.Handler) *Service {
+	t.Helper()
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	srv, err := calendar.NewService(context.Background(),
+		option.WithHTTPClient(ts.Client()),
+		option.WithEndpoint(ts.URL+"/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Service{srv: srv}
+}
+
+// withoutLocalZoneName makes the local time zone nameless for a test
+func withoutLocalZoneName(t *testing.T) {
+	saved := localZoneName
+	localZoneName = func() string { return "" }
+	t.Cleanup(func() { localZoneName = saved })
+}
+
+func TestZoneNameFromPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/usr/share/zoneinfo/America/New_York", "America/New_York"},
+		{"../usr/share/zoneinfo/Europe/London", "Europe/London"},
+		{"/var/db/timezone/zoneinfo/Australia/Sydney", "Australia/Sydney"},
+		{"/usr/share/zoneinfo/posix/Asia/Tokyo", "Asia/Tokyo"},
+		{"/usr/share/zoneinfo/UTC", "UTC"},
+		{"/etc/custom-zone", ""},
+	}
+	for _, tt := range tests {
+		if got := zoneNameFromPath(tt.path); got != tt.want {
+			t.Errorf("zoneNameFromPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestTimeZoneName(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		t    time.Time
+		want string
+	}{
+		{"named zone", time.Date(2025, 1, 1, 9, 0, 0, 0, newYork), "America/New_York"},
+		{"UTC", time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC), "UTC"},
+		{"local", time.Date(2025, 1, 1, 9, 0, 0, 0, time.Local), localZoneName()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := timeZoneName(tt.t)
+			if got != tt.want {
+				t.Errorf("timeZoneName = %q, want %q", got, tt.want)
+			}
+			if got == "Local" {
+				t.Error(`timeZoneName returned "Local", which the API rejects`)
+			}
+		})
+	}
+}
+
+func TestValidZoneName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Europe/Paris", "Europe/Paris"},
+		{"Local", ""},
+		{"", ""},
+		{"Not/AZone", ""},
+	}
+	for _, tt := range tests {
+		if got := validZoneName(tt.name); got != tt.want {
+			t.Errorf("validZoneName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// eventRecorder is a fake Calendar API that records the event written by
+// an insert or patch and echoes it back
+type eventRecorder struct {
+	calendarTimeZone string
+	written          *calendar.Event
+}
+
+func (f *eventRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.Contains(r.URL.Path, "/calendarList/"):
+		json.NewEncoder(w).Encode(&calendar.CalendarListEntry{Id: "primary", TimeZone: f.calendarTimeZone})
+	case r.Method == http.MethodPost || r.Method == http.MethodPatch:
+		f.written = &calendar.Event{}
+		json.NewDecoder(r.Body).Decode(f.written)
+		f.written.Id = "e1"
+		json.NewEncoder(w).Encode(f.written)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestWritesFallBackToCalendarTimeZone(t *testing.T) {
+	withoutLocalZoneName(t)
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.Local)
+	end := start.Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		write func(s *Service) error
+	}{
+		{"create recurring", func(s *Service) error {
+			_, err := s.CreateEvent(context.Background(), "", &Event{Summary: "Standup", Start: start, End: end, Recurrence: []string{"RRULE:FREQ=WEEKLY"}})
+			return err
+		}},
+		{"update", func(s *Service) error {
+			_, err := s.UpdateEvent(context.Background(), "", "e1", &Event{Summary: "Standup", Start: start, End: end})
+			return err
+		}},
+		{"reschedule", func(s *Service) error {
+			_, err := s.SetEventTime(context.Background(), "", "e1", start, end, false)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &eventRecorder{calendarTimeZone: "Europe/Paris"}
+			if err := tt.write(newTestService(t, fake)); err != nil {
+				t.Fatal(err)
+			}
+			if got := fake.written.Start.TimeZone; got != "Europe/Paris" {
+				t.Errorf("start time zone = %q, want the calendar's", got)
+			}
+			if got := fake.written.End.TimeZone; got != "Europe/Paris" {
+				t.Errorf("end time zone = %q, want the calendar's", got)
+			}
+		})
+	}
+}
+
+// eventsLister is a fake Calendar API that lists the same events with an
+// ETag, answering 304 when the client already has them
+type eventsLister struct {
+	items    []*calendar.Event
+	requests []*http.Request
+}
+
+func (f *eventsLister) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.requests = append(f.requests, r)
+	const etag = `"v1"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	json.NewEncoder(w).Encode(&calendar.Events{Etag: etag, Items: f.items})
+}
+
+func TestListEventsPollsShareCache(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	at := func(h, m int) time.Time { return time.Date(2025, 6, 2, h, m, 0, 0, time.UTC) }
+	event := func(id string, start, end time.Time) *calendar.Event {
+		return &calendar.Event{
+			Id:    id,
+			Start: &calendar.EventDateTime{DateTime: start.Format(time.RFC3339)},
+			End:   &calendar.EventDateTime{DateTime: end.Format(time.RFC3339)},
+		}
+	}
+	fake := &eventsLister{items: []*calendar.Event{
+		event("standup", at(9, 0), at(9, 30)),
+		event("review", at(11, 0), at(12, 0)),
+		event("retro", at(16, 0), at(17, 0)),
+	}}
+	srv := newTestService(t, fake)
+
+	// Each poll asks for the next few hours from a different minute
+	tests := []struct {
+		name       string
+		now        time.Time
+		max        int64
+		want       string
+		wantCached bool
+	}{
+		{name: "first poll", now: at(8, 47), max: 10, want: "standup,review"},
+		{name: "a minute later", now: at(8, 48), max: 10, want: "standup,review", wantCached: true},
+		{name: "standup has ended", now: at(9, 31), max: 10, want: "review", wantCached: true},
+		{name: "during the review", now: at(11, 59), max: 10, want: "review,retro", wantCached: true},
+		{name: "fewer results", now: at(11, 59), max: 1, want: "review"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake.requests = nil
+			events, err := srv.ListEvents(context.Background(), "", tt.now, tt.now.Add(5*time.Hour), tt.max)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, e := range events {
+				ids = append(ids, e.ID)
+			}
+			if got := strings.Join(ids, ","); got != tt.want {
+				t.Errorf("events = %s, want %s", got, tt.want)
+			}
+			if len(fake.requests) != 1 {
+				t.Fatalf("made %d requests, want 1", len(fake.requests))
+			}
+			r := fake.requests[0]
+			if got := r.Header.Get("If-None-Match") != ""; got != tt.wantCached {
+				t.Errorf("sent ETag = %v, want %v", got, tt.wantCached)
+			}
+			if got := r.URL.Query().Get("timeMin"); got != at(0, 0).Format(time.RFC3339) {
+				t.Errorf("timeMin = %s, want the start of the day", got)
+			}
+		})
+	}
+}
+
+func TestCanceledContext(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	called := false
+	srv := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"list", func() error { _, err := srv.ListEvents(ctx, "", now, now.Add(time.Hour), 10); return err }},
+		{"list all", func() error { _, err := srv.ListEvents(ctx, "", now, now.Add(time.Hour), 0); return err }},
+		{"get", func() error { _, err := srv.GetEvent(ctx, "", "e1"); return err }},
+		{"calendars", func() error { _, err := srv.ListCalendars(ctx); return err }},
+		{"delete", func() error { return srv.DeleteEvent(ctx, "", "e1") }},
+		{"free/busy", func() error { _, err := srv.FreeBusy(ctx, []string{"primary"}, now, now.Add(time.Hour)); return err }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, context.Canceled) {
+				t.Errorf("err = %v, want context.Canceled", err)
+			}
+		})
+	}
+	if called {
+		t.Error("a request reached the server")
+	}
+}
+
+// eventStore is a fake Calendar API holding one event, which patches update
+// the way the real API does: fields left out of the request are kept
+type eventStore struct {
+	event *calendar.Event
+}
+
+func (f *eventStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.Contains(r.URL.Path, "/calendarList/"):
+		json.NewEncoder(w).Encode(&calendar.CalendarListEntry{Id: "primary", TimeZone: "Europe/Paris"})
+	case r.Method == http.MethodPatch:
+		// Decoding over the stored event replaces only the fields sent
+		json.NewDecoder(r.Body).Decode(f.event)
+		json.NewEncoder(w).Encode(f.event)
+	default:
+		json.NewEncoder(w).Encode(f.event)
+	}
+}
+
+func TestUpdateEventKeepsAttendees(t *testing.T) {
+	withoutLocalZoneName(t)
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	tests := []struct {
+		name          string
+		update        func(s *Service) (*Event, error)
+		wantAttendees string
+	}{
+		{"summary only", func(s *Service) (*Event, error) {
+			return s.UpdateEvent(context.Background(), "", "e1", &Event{Summary: "Renamed", Start: start, End: end})
+		}, "ana@example.com,bo@example.com"},
+		{"fetched, then renamed", func(s *Service) (*Event, error) {
+			e, err := s.GetEvent(context.Background(), "", "e1")
+			if err != nil {
+				return nil, err
+			}
+			e.Summary = "Renamed"
+			return s.UpdateEvent(context.Background(), "", "e1", e)
+		}, "ana@example.com,bo@example.com"},
+		{"attendees cleared", func(s *Service) (*Event, error) {
+			return s.UpdateEvent(context.Background(), "", "e1", &Event{Summary: "Renamed", Start: start, End: end, Attendees: []string{}})
+		}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &eventStore{event: &calendar.Event{
+				Id:        "e1",
+				Summary:   "Planning",
+				Start:     &calendar.EventDateTime{DateTime: start.Format(time.RFC3339)},
+				End:       &calendar.EventDateTime{DateTime: end.Format(time.RFC3339)},
+				Attendees: []*calendar.EventAttendee{{Email: "ana@example.com"}, {Email: "bo@example.com"}},
+			}}
+			updated, err := tt.update(newTestService(t, fake))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if updated.Summary != "Renamed" {
+				t.Errorf("summary = %q, want Renamed", updated.Summary)
+			}
+			if got := strings.Join(updated.Attendees, ","); got != tt.wantAttendees {
+				t.Errorf("attendees = %q, want %q", got, tt.wantAttendees)
+			}
+			if fake.event.Start.TimeZone == "" {
+				t.Error("start has no time zone")
+			}
+		})
+	}
+}
+
+// recurringCalendar is a fake Calendar API that stores inserted events and
+// lists them with singleEvents expanded, for weekly rules with BYDAY only
+type recurringCalendar struct {
+	events       []*calendar.Event
+	singleEvents string
+}
+
+func (f *recurringCalendar) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.Contains(r.URL.Path, "/calendarList/"):
+		json.NewEncoder(w).Encode(&calendar.CalendarListEntry{Id: "primary", TimeZone: "UTC"})
+	case r.Method == http.MethodPost:
+		e := &calendar.Event{}
+		json.NewDecoder(r.Body).Decode(e)
+		e.Id = fmt.Sprintf("e%d", len(f.events)+1)
+		f.events = append(f.events, e)
+		json.NewEncoder(w).Encode(e)
+	default:
+		f.singleEvents = r.URL.Query().Get("singleEvents")
+		timeMin, _ := time.Parse(time.RFC3339, r.URL.Query().Get("timeMin"))
+		timeMax, _ := time.Parse(time.RFC3339, r.URL.Query().Get("timeMax"))
+		var items []*calendar.Event
+		for _, e := range f.events {
+			items = append(items, expandWeekly(e, timeMin, timeMax)...)
+		}
+		json.NewEncoder(w).Encode(&calendar.Events{Items: items})
+	}
+}
+
+// expandWeekly returns the instances of e's "RRULE:FREQ=WEEKLY;BYDAY=..."
+// rule that start between timeMin and timeMax
+func expandWeekly(e *calendar.Event, timeMin, timeMax time.Time) []*calendar.Event {
+	days := map[string]time.Weekday{"SU": 0, "MO": 1, "TU": 2, "WE": 3, "TH": 4, "FR": 5, "SA": 6}
+	start, _ := time.Parse(time.RFC3339, e.Start.DateTime)
+	end, _ := time.Parse(time.RFC3339, e.End.DateTime)
+	_, byDay, _ := strings.Cut(e.Recurrence[0], "BYDAY=")
+	repeats := map[time.Weekday]bool{}
+	for _, d := range strings.Split(byDay, ",") {
+		repeats[days[d]] = true
+	}
+	var instances []*calendar.Event
+	for t := start; t.Before(timeMax); t = t.AddDate(0, 0, 1) {
+		if !repeats[t.Weekday()] || t.Before(timeMin) {
+			continue
+		}
+		instances = append(instances, &calendar.Event{
+			Id:               e.Id + "_" + t.Format("20060102"),
+			RecurringEventId: e.Id,
+			Summary:          e.Summary,
+			Start:            &calendar.EventDateTime{DateTime: t.Format(time.RFC3339)},
+			End:              &calendar.EventDateTime{DateTime: t.Add(end.Sub(start)).Format(time.RFC3339)},
+		})
+	}
+	return instances
+}
+
+func TestRecurringEventListsInstances(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	monday := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+	day := func(d int) time.Time { return time.Date(2025, 6, d, 9, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name     string
+		rule     string
+		from, to time.Time
+		want     []time.Time
+	}{
+		{
+			name: "weekdays skip the weekend",
+			rule: "RRULE:FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR",
+			from: day(4), to: day(11),
+			want: []time.Time{day(4), day(5), day(6), day(9), day(10)},
+		},
+		{
+			name: "two days a week",
+			rule: "RRULE:FREQ=WEEKLY;BYDAY=MO,WE",
+			from: day(1), to: day(15),
+			want: []time.Time{day(2), day(4), day(9), day(11)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &recurringCalendar{}
+			srv := newTestService(t, fake)
+			created, err := srv.CreateEvent(context.Background(), "", &Event{
+				Summary: "Standup", Start: monday, End: monday.Add(15 * time.Minute), Recurrence: []string{tt.rule},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(fake.events[0].Recurrence, ","); got != tt.rule {
+				t.Errorf("sent recurrence %q, want %q", got, tt.rule)
+			}
+
+			events, err := srv.ListEvents(context.Background(), "", tt.from, tt.to, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fake.singleEvents != "true" {
+				t.Errorf("singleEvents = %q, want true", fake.singleEvents)
+			}
+			if len(events) != len(tt.want) {
+				t.Fatalf("got %d instances, want %d", len(events), len(tt.want))
+			}
+			for i, e := range events {
+				if !e.Start.Equal(tt.want[i]) || e.End.Sub(e.Start) != 15*time.Minute {
+					t.Errorf("instance %d = %v-%v, want a 15 minute event at %v", i, e.Start, e.End, tt.want[i])
+				}
+				if e.RecurrenceID != created.ID {
+					t.Errorf("instance %d recurs from %q, want %q", i, e.RecurrenceID, created.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestInstances(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 6, d, 9, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		name           string
+		calendarID     string
+		n              int64
+		wantPath       string
+		wantMaxResults string
+		want           []time.Time
+	}{
+		{"all instances", "", 0, "/calendars/primary/events/standup/instances", "", []time.Time{day(2), day(9), day(16), day(23)}},
+		{"next two", "", 2, "/calendars/primary/events/standup/instances", "2", []time.Time{day(2), day(9)}},
+		{"other calendar", "team@example.com", 3, "/calendars/team@example.com/events/standup/instances", "3", []time.Time{day(2), day(9), day(16)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path, maxResults string
+			srv := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path, maxResults = r.URL.Path, r.URL.Query().Get("maxResults")
+				// Ignore maxResults, as the API may when it pages
+				var items []*calendar.Event
+				for d := 2; d <= 23; d += 7 {
+					items = append(items, &calendar.Event{
+						Id:               fmt.Sprintf("standup_%d", d),
+						RecurringEventId: "standup",
+						Start:            &calendar.EventDateTime{DateTime: day(d).Format(time.RFC3339)},
+						End:              &calendar.EventDateTime{DateTime: day(d).Add(15 * time.Minute).Format(time.RFC3339)},
+					})
+				}
+				json.NewEncoder(w).Encode(&calendar.Events{Items: items})
+			}))
+
+			events, err := srv.Instances(context.Background(), tt.calendarID, "standup", tt.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != tt.wantPath {
+				t.Errorf("path = %q, want %q", path, tt.wantPath)
+			}
+			if maxResults != tt.wantMaxResults {
+				t.Errorf("maxResults = %q, want %q", maxResults, tt.wantMaxResults)
+			}
+			if len(events) != len(tt.want) {
+				t.Fatalf("got %d instances, want %d", len(events), len(tt.want))
+			}
+			for i, e := range events {
+				if !e.Start.Equal(tt.want[i]) {
+					t.Errorf("instance %d starts %v, want %v", i, e.Start, tt.want[i])
+				}
+				if e.RecurrenceID != "standup" {
+					t.Errorf("instance %d recurs from %q, want standup", i, e.RecurrenceID)
+				}
+			}
+		})
+	}
+}
+
+// pagedEvents is a fake Calendar API that lists events a page at a time,
+// recording each request's query
+type pagedEvents struct {
+	pages   [][]*calendar.Event
+	queries []url.Values
+}
+
+func (f *pagedEvents) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	f.queries = append(f.queries, query)
+	page, _ := strconv.Atoi(query.Get("pageToken"))
+	resp := &calendar.Events{Items: f.pages[page]}
+	if page+1 < len(f.pages) {
+		resp.NextPageToken = strconv.Itoa(page + 1)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// timedEvent returns an API event from start lasting an hour
+func timedEvent(id string, start time.Time) *calendar.Event {
+	return &calendar.Event{
+		Id:    id,
+		Start: &calendar.EventDateTime{DateTime: start.Format(time.RFC3339)},
+		End:   &calendar.EventDateTime{DateTime: start.Add(time.Hour).Format(time.RFC3339)},
+	}
+}
+
+func TestSearchEventsPages(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 6, d, 9, 0, 0, 0, time.UTC) }
+	pages := [][]*calendar.Event{
+		{timedEvent("a", day(2)), timedEvent("b", day(3))},
+		{timedEvent("c", day(4))},
+	}
+
+	tests := []struct {
+		name         string
+		max          int64
+		want         string
+		wantRequests int
+	}{
+		{"every match", 0, "a,b,c", 2},
+		{"a limit takes one page", 2, "a,b", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &pagedEvents{pages: pages}
+			srv := newTestService(t, fake)
+			events, err := srv.SearchEvents(context.Background(), "", "standup", day(1), day(8), tt.max)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, e := range events {
+				ids = append(ids, e.ID)
+			}
+			if got := strings.Join(ids, ","); got != tt.want {
+				t.Errorf("events = %s, want %s", got, tt.want)
+			}
+			if len(fake.queries) != tt.wantRequests {
+				t.Fatalf("made %d requests, want %d", len(fake.queries), tt.wantRequests)
+			}
+			for _, q := range fake.queries {
+				if q.Get("q") != "standup" {
+					t.Errorf("q = %q, want standup", q.Get("q"))
+				}
+			}
+		})
+	}
+}
+
+func TestBusyTransparency(t *testing.T) {
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		transparency string
+		wantBusy     bool
+		written      string // What a write of the parsed event sends back
+	}{
+		{"opaque", true, "opaque"},
+		{"transparent", false, "transparent"},
+		{"", true, "opaque"}, // The API leaves out the default
+	}
+	for _, tt := range tests {
+		t.Run(cmp.Or(tt.transparency, "unset"), func(t *testing.T) {
+			api := timedEvent("e1", start)
+			api.Transparency = tt.transparency
+			event := parseEvent(api, "primary")
+			if event.Busy != tt.wantBusy {
+				t.Errorf("Busy = %v, want %v", event.Busy, tt.wantBusy)
+			}
+
+			fake := &eventRecorder{calendarTimeZone: "UTC"}
+			srv := newTestService(t, fake)
+			if _, err := srv.CreateEvent(context.Background(), "", event); err != nil {
+				t.Fatal(err)
+			}
+			if fake.written.Transparency != tt.written {
+				t.Errorf("create sent transparency %q, want %q", fake.written.Transparency, tt.written)
+			}
+			if _, err := srv.UpdateEvent(context.Background(), "", "e1", event); err != nil {
+				t.Fatal(err)
+			}
+			if fake.written.Transparency != tt.written {
+				t.Errorf("update sent transparency %q, want %q", fake.written.Transparency, tt.written)
+			}
+		})
+	}
+}
+
+func TestParseEventAttachments(t *testing.T) {
+	tests := []struct {
+		name        string
+		attachments []*calendar.EventAttachment
+		want        []Attachment
+	}{
+		{
+			name: "Drive files",
+			attachments: []*calendar.EventAttachment{
+				{Title: "Agenda", FileUrl: "https://docs.google.com/document/d/abc/edit", MimeType: "application/vnd.google-apps.document", FileId: "abc"},
+				{Title: "Budget.xlsx", FileUrl: "https://drive.google.com/file/d/def/view", MimeType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", FileId: "def"},
+			},
+			want: []Attachment{
+				{Title: "Agenda", FileURL: "https://docs.google.com/document/d/abc/edit", MimeType: "application/vnd.google-apps.document"},
+				{Title: "Budget.xlsx", FileURL: "https://drive.google.com/file/d/def/view", MimeType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+			},
+		},
+		{
+			name: "no attachments",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := timedEvent("e1", time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC))
+			api.Attachments = tt.attachments
+			event := parseEvent(api, "primary")
+			if !slices.Equal(event.Attachments, tt.want) {
+				t.Errorf("Attachments = %+v, want %+v", event.Attachments, tt.want)
+			}
+		})
+	}
+}
+
+// syncingCalendar is a fake Calendar API answering sync requests: a full
+// listing without a token, the changes since "t1", and 410 Gone otherwise
+type syncingCalendar struct {
+	queries []url.Values
+}
+
+func (f *syncingCalendar) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	f.queries = append(f.queries, query)
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+	var resp *calendar.Events
+	switch query.Get("syncToken") {
+	case "":
+		resp = &calendar.Events{Items: []*calendar.Event{timedEvent("a", start), timedEvent("b", start)}, NextSyncToken: "t1"}
+	case "t1":
+		// The changes come a page at a time; only the last has the new token
+		if query.Get("pageToken") == "" {
+			resp = &calendar.Events{Items: []*calendar.Event{timedEvent("a", start.Add(time.Hour))}, NextPageToken: "p2"}
+		} else {
+			resp = &calendar.Events{Items: []*calendar.Event{{Id: "b", Status: "cancelled"}}, NextSyncToken: "t2"}
+		}
+	default:
+		w.WriteHeader(http.StatusGone)
+		fmt.Fprint(w, `{"error":{"code":410,"message":"Sync token is no longer valid, a full sync is required."}}`)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func TestSyncEvents(t *testing.T) {
+	tests := []struct {
+		name         string
+		syncToken    string
+		wantChanged  string
+		wantDeleted  string
+		wantToken    string
+		wantRequests int
+		wantErr      error
+	}{
+		{name: "full sync", wantChanged: "a,b", wantToken: "t1", wantRequests: 1},
+		{name: "incremental with a deletion", syncToken: "t1", wantChanged: "a", wantDeleted: "b", wantToken: "t2", wantRequests: 2},
+		{name: "expired token", syncToken: "t0", wantRequests: 1, wantErr: ErrSyncTokenExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &syncingCalendar{}
+			srv := newTestService(t, fake)
+			result, err := srv.SyncEvents(context.Background(), "", tt.syncToken)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if len(fake.queries) != tt.wantRequests {
+				t.Errorf("made %d requests, want %d", len(fake.queries), tt.wantRequests)
+			}
+			for _, q := range fake.queries {
+				if q.Get("showDeleted") != "true" {
+					t.Errorf("showDeleted = %q, want true so deletions are reported", q.Get("showDeleted"))
+				}
+				if q.Get("syncToken") != tt.syncToken {
+					t.Errorf("syncToken = %q, want %q", q.Get("syncToken"), tt.syncToken)
+				}
+			}
+			if err != nil {
+				return
+			}
+
+			ids := func(events []*Event) string {
+				var ids []string
+				for _, e := range events {
+					ids = append(ids, e.ID)
+				}
+				return strings.Join(ids, ",")
+			}
+			if got := ids(result.Changed); got != tt.wantChanged {
+				t.Errorf("changed = %s, want %s", got, tt.wantChanged)
+			}
+			if got := ids(result.Deleted); got != tt.wantDeleted {
+				t.Errorf("deleted = %s, want %s", got, tt.wantDeleted)
+			}
+			if result.NextSyncToken != tt.wantToken {
+				t.Errorf("next sync token = %q, want %q", result.NextSyncToken, tt.wantToken)
+			}
+		})
+	}
+}
+
+// calendarSet is a fake Calendar API with several calendars, each listing
+// its events in pages
+type calendarSet struct {
+	calendars []*calendar.CalendarListEntry
+	events    map[string][][]*calendar.Event // Pages of events by calendar ID
+	requests  map[string]int                 // Events requests by calendar ID
+}
+
+func (f *calendarSet) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/calendarList") {
+		json.NewEncoder(w).Encode(&calendar.CalendarList{Items: f.calendars})
+		return
+	}
+	calID, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/calendars/"), "/")
+	calID, _ = url.PathUnescape(calID)
+	f.requests[calID]++
+	pages := f.events[calID]
+	page, _ := strconv.Atoi(r.URL.Query().Get("pageToken"))
+	resp := &calendar.Events{Items: pages[page]}
+	if page+1 < len(pages) {
+		resp.NextPageToken = strconv.Itoa(page + 1)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// eventIDs joins the calendar and event IDs of events, in order
+func eventIDs(events []*Event) string {
+	var ids []string
+	for _, e := range events {
+		ids = append(ids, e.CalendarID+"/"+e.ID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestListEventsFromAllCalendarsDedup(t *testing.T) {
+	start := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+	shared := func(id, uid string, start time.Time) *calendar.Event {
+		e := timedEvent(id, start)
+		e.ICalUID = uid
+		return e
+	}
+	untitled := func(id, summary string, start time.Time) *calendar.Event {
+		e := timedEvent(id, start)
+		e.Summary = summary
+		return e
+	}
+	calendars := []*calendar.CalendarListEntry{
+		{Id: "team@group.calendar.google.com"},
+		{Id: "ana@example.com", Primary: true},
+		{Id: "Ana@Example.com"}, // The primary calendar subscribed again
+	}
+	events := map[string][][]*calendar.Event{
+		"team@group.calendar.google.com": {{
+			shared("t1", "launch@example.com", start),
+			untitled("t2", "Lunch", start.Add(3*time.Hour)),
+		}},
+		"ana@example.com": {{
+			shared("p1", "launch@example.com", start),
+			// Instances of a recurring event share an iCalUID
+			shared("p2", "standup@example.com", start.Add(time.Hour)),
+			shared("p3", "standup@example.com", start.Add(25*time.Hour)),
+		}},
+		"Ana@Example.com": {{
+			shared("p1", "launch@example.com", start),
+			untitled("s2", "Lunch", start.Add(3*time.Hour)),
+		}},
+	}
+
+	tests := []struct {
+		name  string
+		dedup bool
+		want  string
+	}{
+		{
+			name:  "dedup keeps the primary copy",
+			dedup: true,
+			want:  "ana@example.com/p1,ana@example.com/p2,team@group.calendar.google.com/t2,ana@example.com/p3",
+		},
+		{
+			name: "without dedup",
+			want: "ana@example.com/p1,team@group.calendar.google.com/t1,Ana@Example.com/p1,ana@example.com/p2," +
+				"team@group.calendar.google.com/t2,Ana@Example.com/s2,ana@example.com/p3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &calendarSet{calendars: calendars, events: events, requests: map[string]int{}}
+			srv := newTestService(t, fake)
+			got, err := srv.ListEventsFromAllCalendars(context.Background(), start, start.AddDate(0, 0, 7), 0, tt.dedup)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ids := eventIDs(got); ids != tt.want {
+				t.Errorf("events = %s, want %s", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestListEventsAllPages(t *testing.T) {
+	day := func(d, h int) time.Time { return time.Date(2025, 6, d, h, 0, 0, 0, time.UTC) }
+	calendars := []*calendar.CalendarListEntry{{Id: "busy"}, {Id: "primary", Primary: true}}
+	events := map[string][][]*calendar.Event{
+		// The busy calendar's earliest event is on its second page
+		"busy":    {{timedEvent("b1", day(3, 9)), timedEvent("b2", day(4, 9))}, {timedEvent("b3", day(2, 8))}},
+		"primary": {{timedEvent("p1", day(2, 10)), timedEvent("p2", day(5, 9))}},
+	}
+
+	t.Run("one calendar", func(t *testing.T) {
+		fake := &pagedEvents{pages: events["busy"]}
+		srv := newTestService(t, fake)
+		got, err := srv.ListEvents(context.Background(), "busy", day(1, 0), day(8, 0), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids := eventIDs(got); ids != "busy/b1,busy/b2,busy/b3" {
+			t.Errorf("events = %s, want every page's", ids)
+		}
+		if len(fake.queries) != 2 {
+			t.Fatalf("made %d requests, want 2", len(fake.queries))
+		}
+		if q := fake.queries[0]; q.Get("maxResults") != "2500" || q.Get("singleEvents") != "true" {
+			t.Errorf("query = %v, want the largest pages of single events", q)
+		}
+	})
+
+	tests := []struct {
+		max  int64
+		want string
+	}{
+		{2, "busy/b3,primary/p1"},
+		{0, "busy/b3,primary/p1,busy/b1,busy/b2,primary/p2"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("all calendars, max %d", tt.max), func(t *testing.T) {
+			fake := &calendarSet{calendars: calendars, events: events, requests: map[string]int{}}
+			srv := newTestService(t, fake)
+			got, err := srv.ListEventsFromAllCalendars(context.Background(), day(1, 0), day(8, 0), tt.max, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ids := eventIDs(got); ids != tt.want {
+				t.Errorf("events = %s, want %s", ids, tt.want)
+			}
+			if fake.requests["busy"] != 2 {
+				t.Errorf("fetched %d pages of the busy calendar, want 2", fake.requests["busy"])
+			}
+		})
+	}
+}
+
+func TestResolveCalendarIDs(t *testing.T) {
+	calendars := []*calendar.CalendarListEntry{
+		{Id: "ana@example.com", Summary: "Ana Smith", Primary: true},
+		{Id: "team@group.calendar.google.com", Summary: "Team"},
+		{Id: "holidays@group.v.calendar.google.com", Summary: "Holidays"},
+	}
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"", "primary"},
+		{"primary", "primary"},
+		{"Primary", "primary"},
+		{"ana@example.com", "primary"},
+		{"ANA@example.com", "primary"},
+		{"Ana Smith", "primary"},
+		{"team", "team@group.calendar.google.com"},
+		{"team@group.calendar.google.com", "team@group.calendar.google.com"},
+		{"bo@example.com", "bo@example.com"}, // Someone else's calendar
+	}
+
+	var lists int
+	fake := &calendarSet{calendars: calendars}
+	srv := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lists++
+		fake.ServeHTTP(w, r)
+	}))
+	for _, tt := range tests {
+		lists = 0
+		got, err := srv.ResolveCalendarID(context.Background(), tt.ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("ResolveCalendarID(%q) = %q, want %q", tt.ref, got, tt.want)
+		}
+		// The primary aliases need no lookup
+		wantLists := 1
+		if tt.ref == "" || strings.EqualFold(tt.ref, "primary") {
+			wantLists = 0
+		}
+		if lists != wantLists {
+			t.Errorf("ResolveCalendarID(%q) listed calendars %d times, want %d", tt.ref, lists, wantLists)
+		}
+	}
+
+	lists = 0
+	refs := make([]string, 0, len(tests))
+	for _, tt := range tests {
+		refs = append(refs, tt.ref)
+	}
+	ids, err := srv.ResolveCalendarIDs(context.Background(), refs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, tt := range tests {
+		if ids[i] != tt.want {
+			t.Errorf("ResolveCalendarIDs resolved %q to %q, want %q", tt.ref, ids[i], tt.want)
+		}
+	}
+	if lists != 1 {
+		t.Errorf("ResolveCalendarIDs listed calendars %d times, want 1", lists)
+	}
+}
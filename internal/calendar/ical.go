@@ -0,0 +1,378 @@
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// icalTimeFormat is the RFC5545 UTC date-time format
+const icalTimeFormat = "20060102T150405Z"
+
+// icalLocalTimeFormat is the RFC5545 date-time format for times given with
+// a TZID
+const icalLocalTimeFormat = "20060102T150405"
+
+// icalDateFormat is the RFC5545 DATE format used for all-day events
+const icalDateFormat = "20060102"
+
+// icalWriter accumulates CRLF-terminated, folded iCalendar content lines
+type icalWriter struct {
+	b strings.Builder
+}
+
+// line writes a content line, folding it at 75 octets per RFC5545
+func (w *icalWriter) line(s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		// Don't split a multi-byte UTF-8 sequence
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.b.WriteString(s[:cut])
+		w.b.WriteString("\r\n ")
+		s = s[cut:]
+		// Continuation lines start with a space, which counts toward the limit
+		limit = 74
+	}
+	w.b.WriteString(s)
+	w.b.WriteString("\r\n")
+}
+
+// String returns the accumulated iCalendar text
+func (w *icalWriter) String() string {
+	return w.b.String()
+}
+
+// escapeText escapes a TEXT property value per RFC5545
+func escapeText(s string) string {
+	r := strings.NewReplacer(
+		"\\", "\\\\",
+		";", "\\;",
+		",", "\\,",
+		"\r\n", "\\n",
+		"\n", "\\n",
+	)
+	return r.Replace(s)
+}
+
+// ExportEventICS renders an event as a VCALENDAR with a single VEVENT.
+// method is the iTIP method (e.g. "REQUEST" for invitations, "PUBLISH" for
+// plain exports) and organizer, if set, is the organizer's email address.
+func ExportEventICS(e *Event, method, organizer string) string {
+	w := &icalWriter{}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:-//gday//gday CLI//EN")
+	if method != "" {
+		w.line("METHOD:" + method)
+	}
+	w.line("BEGIN:VEVENT")
+
+	uid := e.ICalUID
+	if uid == "" {
+		uid = e.ID + "@gday"
+	}
+	w.line("UID:" + uid)
+	w.line("DTSTAMP:" + time.Now().UTC().Format(icalTimeFormat))
+	if e.AllDay {
+		w.line("DTSTART;VALUE=DATE:" + e.Start.Format(icalDateFormat))
+		w.line("DTEND;VALUE=DATE:" + e.End.Format(icalDateFormat))
+	} else if loc := recurrenceZone(e); loc != nil {
+		// A recurring event repeats at the same wall-clock time, so it needs
+		// its zone: in UTC it would shift by an hour across DST changes
+		w.line("DTSTART;TZID=" + loc.String() + ":" + e.Start.In(loc).Format(icalLocalTimeFormat))
+		w.line("DTEND;TZID=" + loc.String() + ":" + e.End.In(loc).Format(icalLocalTimeFormat))
+	} else {
+		w.line("DTSTART:" + e.Start.UTC().Format(icalTimeFormat))
+		w.line("DTEND:" + e.End.UTC().Format(icalTimeFormat))
+	}
+	w.line("SUMMARY:" + escapeText(e.Summary))
+	if e.Location != "" {
+		w.line("LOCATION:" + escapeText(e.Location))
+	}
+	if e.Description != "" {
+		w.line("DESCRIPTION:" + escapeText(e.Description))
+	}
+	for _, r := range e.Recurrence {
+		w.line(r)
+	}
+	if organizer != "" {
+		w.line("ORGANIZER:mailto:" + organizer)
+	}
+	for _, a := range e.Attendees {
+		w.line("ATTENDEE;ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mailto:" + a)
+	}
+	if !e.Busy {
+		w.line("TRANSP:TRANSPARENT")
+	}
+	w.line("SEQUENCE:0")
+	w.line("STATUS:CONFIRMED")
+	w.line("END:VEVENT")
+	w.line("END:VCALENDAR")
+
+	return w.String()
+}
+
+// recurrenceZone returns the zone a timed recurring event repeats in: the
+// one the API gave, or else the zone of its start time. It returns nil for
+// one-off events and when no zone name is known.
+func recurrenceZone(e *Event) *time.Location {
+	if len(e.Recurrence) == 0 {
+		return nil
+	}
+	name := e.TimeZone
+	if name == "" {
+		name = timeZoneName(e.Start)
+	}
+	if name == "" {
+		return nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil
+	}
+	return loc
+}
+
+// ExportFreeBusyICS renders busy intervals as a VCALENDAR containing a
+// single VFREEBUSY component covering start to end
+func ExportFreeBusyICS(intervals []Interval, start, end time.Time) string {
+	w := &icalWriter{}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:-//gday//gday CLI//EN")
+	w.line("METHOD:PUBLISH")
+	w.line("BEGIN:VFREEBUSY")
+	w.line("DTSTAMP:" + time.Now().UTC().Format(icalTimeFormat))
+	w.line("DTSTART:" + start.UTC().Format(icalTimeFormat))
+	w.line("DTEND:" + end.UTC().Format(icalTimeFormat))
+	for _, iv := range MergeIntervals(intervals) {
+		w.line(fmt.Sprintf("FREEBUSY;FBTYPE=BUSY:%s/%s",
+			iv.Start.UTC().Format(icalTimeFormat),
+			iv.End.UTC().Format(icalTimeFormat)))
+	}
+	w.line("END:VFREEBUSY")
+	w.line("END:VCALENDAR")
+
+	return w.String()
+}
+
+// ParseICS reads the VEVENTs in an iCalendar file. Folded lines are
+// unfolded, and DTSTART/DTEND may be UTC, floating (taken as local time),
+// in a TZID, or dates for all-day events. Components other than VEVENT,
+// such as VTIMEZONE and VALARM, are skipped.
+func ParseICS(r io.Reader) ([]*Event, error) {
+	lines, err := unfoldLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []*Event
+	var cur *Event
+	var duration time.Duration
+	hasEnd := false
+	depth := 0 // Nesting inside the current VEVENT, for VALARM and the like
+
+	for n, raw := range lines {
+		name, params, value := parseContentLine(raw)
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT") && cur == nil:
+			cur = &Event{Busy: true}
+			duration, hasEnd, depth = 0, false, 0
+			continue
+		case cur == nil:
+			continue
+		case name == "BEGIN":
+			depth++
+			continue
+		case name == "END" && depth > 0:
+			depth--
+			continue
+		case depth > 0:
+			continue
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			if cur.Start.IsZero() {
+				return nil, fmt.Errorf("line %d: VEVENT has no DTSTART", n+1)
+			}
+			if !hasEnd {
+				switch {
+				case duration > 0:
+					cur.End = cur.Start.Add(duration)
+				case cur.AllDay:
+					cur.End = cur.Start.AddDate(0, 0, 1)
+				default:
+					cur.End = cur.Start
+				}
+			}
+			events = append(events, cur)
+			cur = nil
+			continue
+		}
+
+		switch name {
+		case "UID":
+			cur.ICalUID = value
+		case "SUMMARY":
+			cur.Summary = unescapeText(value)
+		case "LOCATION":
+			cur.Location = unescapeText(value)
+		case "DESCRIPTION":
+			cur.Description = unescapeText(value)
+		case "DTSTART", "DTEND":
+			t, allDay, err := parseICSTime(value, params)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid %s: %w", n+1, name, err)
+			}
+			if name == "DTSTART" {
+				cur.Start, cur.AllDay = t, allDay
+			} else {
+				cur.End, hasEnd = t, true
+			}
+		case "DURATION":
+			d, err := parseICSDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid DURATION: %w", n+1, err)
+			}
+			duration = d
+		case "RRULE", "EXRULE", "RDATE", "EXDATE":
+			cur.Recurrence = append(cur.Recurrence, raw)
+		case "TRANSP":
+			cur.Busy = !strings.EqualFold(value, "TRANSPARENT")
+		case "ATTENDEE":
+			if addr, ok := strings.CutPrefix(strings.ToLower(value), "mailto:"); ok {
+				cur.Attendees = append(cur.Attendees, addr)
+			}
+		}
+	}
+
+	if cur != nil {
+		return nil, fmt.Errorf("unterminated VEVENT")
+	}
+	return events, nil
+}
+
+// unfoldLines splits iCalendar text into content lines, joining folded
+// continuation lines (those starting with a space or tab) back together
+func unfoldLines(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// parseContentLine splits "NAME;PARAM=x;PARAM2=y:value" into its upper-cased
+// name, parameters, and value. Colons inside quoted parameter values don't
+// end the parameters.
+func parseContentLine(line string) (string, map[string]string, string) {
+	inQuote := false
+	colon := -1
+	for i, c := range line {
+		if c == '"' {
+			inQuote = !inQuote
+		} else if c == ':' && !inQuote {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return strings.ToUpper(line), nil, ""
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	params := make(map[string]string, len(parts)-1)
+	for _, p := range parts[1:] {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, line[colon+1:]
+}
+
+// parseICSTime parses a DTSTART or DTEND value, reporting whether it is a
+// date (an all-day event) rather than a date-time
+func parseICSTime(value string, params map[string]string) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == len(icalDateFormat) {
+		t, err := time.ParseInLocation(icalDateFormat, value, time.Local)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(icalTimeFormat, value)
+		return t, false, err
+	}
+
+	// Floating times are local; TZIDs that aren't IANA names (such as
+	// Outlook's Windows zone names) fall back to local time too
+	loc := time.Local
+	if tzid := params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation(icalLocalTimeFormat, value, loc)
+	return t, false, err
+}
+
+// parseICSDuration parses an RFC5545 duration such as "PT1H30M" or "P1D"
+func parseICSDuration(s string) (time.Duration, error) {
+	rest, ok := strings.CutPrefix(strings.TrimPrefix(s, "+"), "P")
+	if !ok {
+		return 0, fmt.Errorf("%q", s)
+	}
+	var d time.Duration
+	inTime := false
+	num := 0
+	for _, c := range rest {
+		switch {
+		case c >= '0' && c <= '9':
+			num = num*10 + int(c-'0')
+			continue
+		case c == 'T':
+			inTime = true
+			continue
+		}
+		unit := map[rune]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour}
+		if inTime {
+			unit = map[rune]time.Duration{'H': time.Hour, 'M': time.Minute, 'S': time.Second}
+		}
+		u, ok := unit[c]
+		if !ok {
+			return 0, fmt.Errorf("%q", s)
+		}
+		d += time.Duration(num) * u
+		num = 0
+	}
+	return d, nil
+}
+
+// unescapeText reverses escapeText
+func unescapeText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
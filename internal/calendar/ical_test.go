@@ -0,0 +1,131 @@
+package calendar
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportEventICSRecurringZone(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 9:00 in New York, as the API returns it: a fixed offset plus the zone
+	start := time.Date(2025, 3, 3, 9, 0, 0, 0, time.FixedZone("", -5*60*60))
+	weekly := []string{"RRULE:FREQ=WEEKLY;BYDAY=MO"}
+
+	tests := []struct {
+		name      string
+		event     Event
+		wantStart string
+		wantEnd   string
+	}{
+		{
+			name:      "recurring with the API's zone",
+			event:     Event{Start: start, End: start.Add(time.Hour), Recurrence: weekly, TimeZone: "America/New_York"},
+			wantStart: "DTSTART;TZID=America/New_York:20250303T090000",
+			wantEnd:   "DTEND;TZID=America/New_York:20250303T100000",
+		},
+		{
+			name:      "recurring in a named zone",
+			event:     Event{Start: start.In(newYork), End: start.Add(time.Hour).In(newYork), Recurrence: weekly},
+			wantStart: "DTSTART;TZID=America/New_York:20250303T090000",
+			wantEnd:   "DTEND;TZID=America/New_York:20250303T100000",
+		},
+		{
+			name:      "recurring with no zone name",
+			event:     Event{Start: start, End: start.Add(time.Hour), Recurrence: weekly},
+			wantStart: "DTSTART:20250303T140000Z",
+			wantEnd:   "DTEND:20250303T150000Z",
+		},
+		{
+			name:      "one-off event",
+			event:     Event{Start: start, End: start.Add(time.Hour), TimeZone: "America/New_York"},
+			wantStart: "DTSTART:20250303T140000Z",
+			wantEnd:   "DTEND:20250303T150000Z",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.event.ID = "e1"
+			tt.event.Summary = "Standup"
+			ics := ExportEventICS(&tt.event, "PUBLISH", "")
+			for _, want := range []string{tt.wantStart, tt.wantEnd} {
+				if !strings.Contains(ics, want+"\r\n") {
+					t.Errorf("missing %q in:\n%s", want, ics)
+				}
+			}
+
+			// Importing the export gives back the same event
+			events, err := ParseICS(strings.NewReader(ics))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != 1 {
+				t.Fatalf("got %d events, want 1", len(events))
+			}
+			got := events[0]
+			if !got.Start.Equal(start) || !got.End.Equal(start.Add(time.Hour)) {
+				t.Errorf("times = %v-%v, want %v-%v", got.Start, got.End, start, start.Add(time.Hour))
+			}
+			if strings.Join(got.Recurrence, ",") != strings.Join(tt.event.Recurrence, ",") {
+				t.Errorf("recurrence = %v, want %v", got.Recurrence, tt.event.Recurrence)
+			}
+		})
+	}
+}
+
+func TestExportFreeBusyICS(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(h, m int) time.Time { return time.Date(2025, 6, 2, h, m, 0, 0, paris) }
+
+	tests := []struct {
+		name      string
+		intervals []Interval
+		want      []string
+	}{
+		{
+			name:      "periods in UTC, sorted",
+			intervals: []Interval{{at(14, 0), at(15, 0)}, {at(9, 0), at(9, 30)}},
+			want:      []string{"FREEBUSY;FBTYPE=BUSY:20250602T070000Z/20250602T073000Z", "FREEBUSY;FBTYPE=BUSY:20250602T120000Z/20250602T130000Z"},
+		},
+		{
+			name:      "overlapping and adjacent intervals merge",
+			intervals: []Interval{{at(9, 0), at(10, 0)}, {at(9, 30), at(10, 30)}, {at(10, 30), at(11, 0)}},
+			want:      []string{"FREEBUSY;FBTYPE=BUSY:20250602T070000Z/20250602T090000Z"},
+		},
+		{
+			name: "no busy time",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ics := ExportFreeBusyICS(tt.intervals, at(0, 0), at(24, 0))
+			lines := strings.Split(strings.TrimSuffix(ics, "\r\n"), "\r\n")
+
+			var periods []string
+			for _, line := range lines {
+				if strings.HasPrefix(line, "FREEBUSY") {
+					periods = append(periods, line)
+				}
+			}
+			if strings.Join(periods, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("periods = %q, want %q", periods, tt.want)
+			}
+
+			for _, want := range []string{"BEGIN:VFREEBUSY", "DTSTART:20250601T220000Z", "DTEND:20250602T220000Z", "END:VFREEBUSY"} {
+				if !slices.Contains(lines, want) {
+					t.Errorf("missing %q in:\n%s", want, ics)
+				}
+			}
+			if lines[0] != "BEGIN:VCALENDAR" || lines[len(lines)-1] != "END:VCALENDAR" {
+				t.Errorf("not wrapped in a VCALENDAR:\n%s", ics)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package calendar
+
+import (
+	"sort"
+	"time"
+)
+
+// WorkingHours bounds the part of each weekday meetings may be scheduled in,
+// as offsets from midnight
+type WorkingHours struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// DefaultWorkingHours is 9:00 to 17:00
+var DefaultWorkingHours = WorkingHours{Start: 9 * time.Hour, End: 17 * time.Hour}
+
+// slotStep aligns candidate start times to the quarter hour
+const slotStep = 15 * time.Minute
+
+// slotBuffer is the free time around a slot that makes it preferable to one
+// squeezed between meetings
+const slotBuffer = 15 * time.Minute
+
+// FindSlots returns candidate meeting times of length duration between from
+// and to that overlap none of busy, on weekdays within hours. The best come
+// first: slots with free time either side ahead of back-to-back ones, then
+// earlier before later.
+func FindSlots(busy []Interval, from, to time.Time, duration time.Duration, hours WorkingHours) []Interval {
+	busy = MergeIntervals(busy)
+
+	type candidate struct {
+		slot  Interval
+		tight int // Sides of the slot that touch a busy block
+	}
+	var candidates []candidate
+
+	for day := startOfDay(from); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		windowStart := latest(clockTime(day, hours.Start), from)
+		windowEnd := earliest(clockTime(day, hours.End), to)
+
+		for _, gap := range freeGaps(busy, windowStart, windowEnd) {
+			for start := alignUp(gap.Start, slotStep); !start.Add(duration).After(gap.End); start = start.Add(slotStep) {
+				end := start.Add(duration)
+				tight := 0
+				if start.Sub(gap.Start) < slotBuffer && isBusyAt(busy, gap.Start.Add(-time.Nanosecond)) {
+					tight++
+				}
+				if gap.End.Sub(end) < slotBuffer && isBusyAt(busy, gap.End) {
+					tight++
+				}
+				candidates = append(candidates, candidate{Interval{Start: start, End: end}, tight})
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].tight < candidates[j].tight
+	})
+	slots := make([]Interval, len(candidates))
+	for i, c := range candidates {
+		slots[i] = c.slot
+	}
+	return slots
+}
+
+// freeGaps returns the parts of [from, to) not covered by the sorted,
+// merged busy intervals
+func freeGaps(busy []Interval, from, to time.Time) []Interval {
+	var gaps []Interval
+	cursor := from
+	for _, b := range busy {
+		if !b.End.After(cursor) {
+			continue
+		}
+		if !b.Start.Before(to) {
+			break
+		}
+		if b.Start.After(cursor) {
+			gaps = append(gaps, Interval{Start: cursor, End: b.Start})
+		}
+		cursor = b.End
+	}
+	if cursor.Before(to) {
+		gaps = append(gaps, Interval{Start: cursor, End: to})
+	}
+	return gaps
+}
+
+// isBusyAt reports whether t falls within one of the busy intervals
+func isBusyAt(busy []Interval, t time.Time) bool {
+	for _, b := range busy {
+		if !t.Before(b.Start) && t.Before(b.End) {
+			return true
+		}
+	}
+	return false
+}
+
+// startOfDay returns midnight at the start of t's day, in t's location
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// clockTime returns the wall-clock time offset past midnight on day's date,
+// so 9:00 stays 9:00 on days a DST change makes 23 or 25 hours long
+func clockTime(day time.Time, offset time.Duration) time.Time {
+	h, m := int(offset/time.Hour), int(offset%time.Hour/time.Minute)
+	return time.Date(day.Year(), day.Month(), day.Day(), h, m, 0, 0, day.Location())
+}
+
+// alignUp rounds t up to the next multiple of step past midnight
+func alignUp(t time.Time, step time.Duration) time.Time {
+	offset := t.Sub(startOfDay(t))
+	if rem := offset % step; rem != 0 {
+		return t.Add(step - rem)
+	}
+	return t
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func earliest(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,88 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestFindSlots(t *testing.T) {
+	cairo, err := time.LoadLocation("Africa/Cairo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	utc := time.UTC
+	at := func(loc *time.Location, y int, m time.Month, d, h, min int) time.Time {
+		return time.Date(y, m, d, h, min, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name      string
+		busy      []Interval
+		from, to  time.Time
+		duration  time.Duration
+		wantFirst time.Time
+		wantLast  time.Time
+		wantCount int
+	}{
+		{
+			name:      "free weekday",
+			from:      at(utc, 2025, 6, 2, 0, 0), // Monday
+			to:        at(utc, 2025, 6, 3, 0, 0),
+			duration:  time.Hour,
+			wantFirst: at(utc, 2025, 6, 2, 9, 0),
+			wantLast:  at(utc, 2025, 6, 2, 16, 0),
+			wantCount: 29,
+		},
+		{
+			name:      "weekend is skipped",
+			from:      at(utc, 2025, 6, 7, 0, 0), // Saturday
+			to:        at(utc, 2025, 6, 9, 0, 0),
+			duration:  time.Hour,
+			wantCount: 0,
+		},
+		{
+			name:      "busy morning",
+			busy:      []Interval{{Start: at(utc, 2025, 6, 2, 8, 0), End: at(utc, 2025, 6, 2, 16, 0)}},
+			from:      at(utc, 2025, 6, 2, 0, 0),
+			to:        at(utc, 2025, 6, 3, 0, 0),
+			duration:  30 * time.Minute,
+			wantFirst: at(utc, 2025, 6, 2, 16, 15), // Clear of the meeting before it
+			wantLast:  at(utc, 2025, 6, 2, 16, 0),
+			wantCount: 3,
+		},
+		{
+			// Clocks went forward at midnight on Friday 28 April 2023, so the
+			// day was 23 hours long
+			name:      "DST change keeps working hours",
+			from:      at(cairo, 2023, 4, 28, 0, 0),
+			to:        at(cairo, 2023, 4, 29, 0, 0),
+			duration:  8 * time.Hour,
+			wantFirst: at(cairo, 2023, 4, 28, 9, 0),
+			wantLast:  at(cairo, 2023, 4, 28, 9, 0),
+			wantCount: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slots := FindSlots(tt.busy, tt.from, tt.to, tt.duration, DefaultWorkingHours)
+			if len(slots) != tt.wantCount {
+				t.Fatalf("got %d slots, want %d: %v", len(slots), tt.wantCount, slots)
+			}
+			if tt.wantCount == 0 {
+				return
+			}
+			if !slots[0].Start.Equal(tt.wantFirst) {
+				t.Errorf("first slot starts %v, want %v", slots[0].Start, tt.wantFirst)
+			}
+			if last := slots[len(slots)-1]; !last.Start.Equal(tt.wantLast) {
+				t.Errorf("last slot starts %v, want %v", last.Start, tt.wantLast)
+			}
+			for _, s := range slots {
+				if s.End.Sub(s.Start) != tt.duration {
+					t.Errorf("slot %v lasts %v, want %v", s, s.End.Sub(s.Start), tt.duration)
+				}
+			}
+		})
+	}
+}
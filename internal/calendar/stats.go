@@ -0,0 +1,94 @@
+package calendar
+
+import (
+	"sort"
+	"time"
+)
+
+// Stats summarizes the meetings in a set of events
+type Stats struct {
+	Meetings         int
+	TotalTime        time.Duration
+	AverageLength    time.Duration
+	BusiestDay       time.Time // Midnight of the day with the most meeting time
+	BusiestDayTime   time.Duration
+	OneOnOnes        int // Meetings with exactly two attendees
+	GroupMeetings    int // Meetings with three or more attendees
+	Solo             int // Events with no other attendees
+	BackToBack       int // Runs of two or more meetings with no gap between them
+	LongestStreak    int // Most meetings in a single back-to-back run
+	DailyMeetingTime map[string]time.Duration
+}
+
+// ComputeStats aggregates meeting statistics over events. All-day events,
+// events shown as free, and cancelled events are not counted as meetings.
+func ComputeStats(events []*Event) Stats {
+	var meetings []*Event
+	for _, e := range events {
+		if e.AllDay || !e.Busy || e.Status == "cancelled" || !e.End.After(e.Start) {
+			continue
+		}
+		meetings = append(meetings, e)
+	}
+	sort.SliceStable(meetings, func(i, j int) bool {
+		return meetings[i].Start.Before(meetings[j].Start)
+	})
+
+	stats := Stats{
+		Meetings:         len(meetings),
+		DailyMeetingTime: make(map[string]time.Duration),
+	}
+
+	var streak int
+	var streakEnd time.Time
+	for _, e := range meetings {
+		length := e.End.Sub(e.Start)
+		stats.TotalTime += length
+		stats.DailyMeetingTime[e.Start.Local().Format("2006-01-02")] += length
+
+		switch {
+		case len(e.Attendees) == 2:
+			stats.OneOnOnes++
+		case len(e.Attendees) > 2:
+			stats.GroupMeetings++
+		default:
+			stats.Solo++
+		}
+
+		// A meeting continues the current streak if it starts before the
+		// previous ones have ended
+		if streak > 0 && !e.Start.After(streakEnd) {
+			streak++
+			if streak == 2 {
+				stats.BackToBack++
+			}
+		} else {
+			streak = 1
+			streakEnd = time.Time{}
+		}
+		if e.End.After(streakEnd) {
+			streakEnd = e.End
+		}
+		if streak > 1 && streak > stats.LongestStreak {
+			stats.LongestStreak = streak
+		}
+	}
+
+	if stats.Meetings > 0 {
+		stats.AverageLength = stats.TotalTime / time.Duration(stats.Meetings)
+	}
+
+	days := make([]string, 0, len(stats.DailyMeetingTime))
+	for day := range stats.DailyMeetingTime {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	for _, day := range days {
+		if d := stats.DailyMeetingTime[day]; d > stats.BusiestDayTime {
+			stats.BusiestDayTime = d
+			stats.BusiestDay, _ = time.ParseInLocation("2006-01-02", day, time.Local)
+		}
+	}
+
+	return stats
+}
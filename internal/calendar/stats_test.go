@@ -0,0 +1,94 @@
+package calendar
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestComputeStats(t *testing.T) {
+	at := func(d, h, m int) time.Time { return time.Date(2025, 6, d, h, m, 0, 0, time.Local) }
+	meeting := func(start, end time.Time, attendees int) *Event {
+		e := &Event{Start: start, End: end, Busy: true}
+		for i := 0; i < attendees; i++ {
+			e.Attendees = append(e.Attendees, "guest@example.com")
+		}
+		return e
+	}
+	free := meeting(at(2, 14, 0), at(2, 15, 0), 2)
+	free.Busy = false
+	allDay := meeting(at(2, 0, 0), at(3, 0, 0), 0)
+	allDay.AllDay = true
+	cancelled := meeting(at(3, 15, 0), at(3, 16, 0), 2)
+	cancelled.Status = "cancelled"
+
+	tests := []struct {
+		name   string
+		events []*Event
+		want   Stats
+	}{
+		{
+			name: "no meetings",
+			want: Stats{DailyMeetingTime: map[string]time.Duration{}},
+		},
+		{
+			name: "two days",
+			events: []*Event{
+				// Listed out of order; the streak is 1:1, group, solo
+				meeting(at(2, 10, 0), at(2, 10, 30), 3),
+				meeting(at(2, 9, 0), at(2, 10, 0), 2),
+				meeting(at(2, 10, 30), at(2, 11, 0), 0),
+				free, allDay, cancelled,
+				meeting(at(3, 9, 0), at(3, 9, 30), 2),
+				meeting(at(3, 11, 0), at(3, 12, 0), 4),
+			},
+			want: Stats{
+				Meetings:       5,
+				TotalTime:      3*time.Hour + 30*time.Minute,
+				AverageLength:  42 * time.Minute,
+				BusiestDay:     at(2, 0, 0),
+				BusiestDayTime: 2 * time.Hour,
+				OneOnOnes:      2,
+				GroupMeetings:  2,
+				Solo:           1,
+				BackToBack:     1,
+				LongestStreak:  3,
+				DailyMeetingTime: map[string]time.Duration{
+					"2025-06-02": 2 * time.Hour,
+					"2025-06-03": 90 * time.Minute,
+				},
+			},
+		},
+		{
+			name: "overlaps count toward a streak",
+			events: []*Event{
+				meeting(at(2, 9, 0), at(2, 11, 0), 2),
+				meeting(at(2, 9, 30), at(2, 10, 0), 2),
+				meeting(at(2, 11, 0), at(2, 11, 30), 2),
+				meeting(at(2, 13, 0), at(2, 13, 30), 2),
+				meeting(at(2, 13, 30), at(2, 14, 0), 2),
+			},
+			want: Stats{
+				Meetings:       5,
+				TotalTime:      4 * time.Hour,
+				AverageLength:  48 * time.Minute,
+				BusiestDay:     at(2, 0, 0),
+				BusiestDayTime: 4 * time.Hour,
+				OneOnOnes:      5,
+				BackToBack:     2,
+				LongestStreak:  3,
+				DailyMeetingTime: map[string]time.Duration{
+					"2025-06-02": 4 * time.Hour,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ComputeStats(tt.events)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("stats = %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
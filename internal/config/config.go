@@ -1,42 +1,223 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
 )
 
 const (
 	configDir       = ".gday"
 	credentialsFile = "credentials.json"
 	tokenFile       = "token.json"
+	cacheDir        = "cache"
+	settingsFile    = "config.json"
+	syncTokensFile  = "sync_tokens.json"
+	scopesFile      = "scopes.json"
+	serveTokenFile  = "serve_token"
 )
 
+// DefaultAccount is the account whose files live directly in ~/.gday
+const DefaultAccount = "default"
+
+// account is the active account, set once from --account or GDAY_ACCOUNT
+var account = DefaultAccount
+
+// credentialsFlag is the credentials path given with --credentials
+var credentialsFlag string
+
+// Environment variables that supply the OAuth client credentials, for CI
+// and containers where 'gday auth setup' can't be run
+const (
+	CredentialsEnv     = "GDAY_CREDENTIALS"      // The credentials JSON itself
+	CredentialsFileEnv = "GDAY_CREDENTIALS_FILE" // A path to the credentials JSON
+)
+
+// accountName matches valid account names, which double as directory names
+var accountName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
 // Config holds the application configuration
 type Config struct {
 	ConfigDir string
 }
 
-// GetConfigDir returns the path to the config directory
-func GetConfigDir() (string, error) {
+// Settings holds user preferences read from config.json
+type Settings struct {
+	// ConfirmThreshold is the item count above which batch operations
+	// prompt; 0 always prompts and a negative value never does
+	ConfirmThreshold *int `json:"confirm_threshold,omitempty"`
+
+	// ProtectedCalendars lists calendar IDs or names that commands refuse
+	// to modify without --force
+	ProtectedCalendars []string `json:"protected_calendars,omitempty"`
+
+	// AttendeeGroups maps a group name to its members' email addresses,
+	// used as --attendees @name
+	AttendeeGroups map[string][]string `json:"attendee_groups,omitempty"`
+
+	// TokenStore is where the OAuth token is kept: "file" (token.json, the
+	// default) or "keyring" (the OS keyring, falling back to the file when
+	// none is available)
+	TokenStore string `json:"token_store,omitempty"`
+
+	// SlackStatus holds the templates for 'gday cal busy --export-slack'
+	SlackStatus *SlackStatus `json:"slack_status,omitempty"`
+}
+
+// SlackStatus holds Go text/template strings for the status shown while in
+// a meeting and while free
+type SlackStatus struct {
+	Busy string `json:"busy,omitempty"`
+	Free string `json:"free,omitempty"`
+}
+
+// SetAccount selects the account whose credentials, token, and settings
+// the other helpers use. "" or "default" selects ~/.gday itself; any other
+// name selects ~/.gday/<name>.
+func SetAccount(name string) error {
+	if name == "" {
+		name = DefaultAccount
+	}
+	if !accountName.MatchString(name) || name == cacheDir {
+		return fmt.Errorf("invalid account name %q: use letters, digits, '.', '_', or '-'", name)
+	}
+	account = name
+	return nil
+}
+
+// Account returns the name of the active account
+func Account() string {
+	return account
+}
+
+// getBaseDir returns ~/.gday, the default account's directory and the
+// parent of every other account's
+func getBaseDir() (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
 	}
-	dir := filepath.Join(home, configDir)
+	return filepath.Join(home, configDir), nil
+}
+
+// GetConfigDir returns the path to the active account's config directory
+func GetConfigDir() (string, error) {
+	dir, err := getBaseDir()
+	if err != nil {
+		return "", err
+	}
+	if account != DefaultAccount {
+		dir = filepath.Join(dir, account)
+	}
 	if err := os.MkdirAll(dir, 0700); err != nil {
 		return "", err
 	}
 	return dir, nil
 }
 
-// GetCredentialsPath returns the path to the OAuth credentials file
+// AccountInfo describes a configured account
+type AccountInfo struct {
+	Name     string
+	LoggedIn bool
+}
+
+// ListAccounts returns every account that has credentials, a token, or
+// settings, default first and the rest sorted by name
+func ListAccounts() ([]AccountInfo, error) {
+	base, err := getBaseDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(base)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var accounts []AccountInfo
+	if hasAccountFiles(base) {
+		accounts = append(accounts, accountInfo(DefaultAccount, base))
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() && e.Name() != cacheDir && accountName.MatchString(e.Name()) && hasAccountFiles(filepath.Join(base, e.Name())) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		accounts = append(accounts, accountInfo(name, filepath.Join(base, name)))
+	}
+	return accounts, nil
+}
+
+// accountInfo describes the account stored in dir
+func accountInfo(name, dir string) AccountInfo {
+	return AccountInfo{Name: name, LoggedIn: accountLoggedIn(name, dir)}
+}
+
+// hasAccountFiles reports whether dir holds credentials, a token, or
+// settings (which a keyring-backed account has in place of token.json)
+func hasAccountFiles(dir string) bool {
+	for _, name := range []string{credentialsFile, tokenFile, settingsFile} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckConfigDir verifies that the config directory is private to the user
+// and writable
+func CheckConfigDir() (string, error) {
+	dir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return dir, err
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		return dir, fmt.Errorf("%s has permissions %04o; it should only be accessible by you (0700)", dir, perm)
+	}
+
+	f, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		return dir, fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return dir, nil
+}
+
+// GetCredentialsPath returns the path to the OAuth credentials file. An
+// account without its own credentials.json shares the default account's,
+// so one OAuth client can serve several Google accounts.
 func GetCredentialsPath() (string, error) {
 	dir, err := GetConfigDir()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(dir, credentialsFile), nil
+	path := filepath.Join(dir, credentialsFile)
+	if _, err := os.Stat(path); os.IsNotExist(err) && account != DefaultAccount {
+		base, err := getBaseDir()
+		if err != nil {
+			return "", err
+		}
+		if _, err := os.Stat(filepath.Join(base, credentialsFile)); err == nil {
+			return filepath.Join(base, credentialsFile), nil
+		}
+	}
+	return path, nil
 }
 
 // GetTokenPath returns the path to the OAuth token file
@@ -48,9 +229,33 @@ func GetTokenPath() (string, error) {
 	return filepath.Join(dir, tokenFile), nil
 }
 
+// SetCredentialsFile makes ReadCredentials read path ahead of any other
+// source; "" restores the default lookup
+func SetCredentialsFile(path string) {
+	credentialsFlag = path
+}
+
+// CredentialsSource describes where ReadCredentials reads the credentials
+// from: the --credentials path, GDAY_CREDENTIALS, the GDAY_CREDENTIALS_FILE
+// path, or the account's credentials.json, in that order of precedence
+func CredentialsSource() (string, error) {
+	switch {
+	case credentialsFlag != "":
+		return credentialsFlag, nil
+	case os.Getenv(CredentialsEnv) != "":
+		return "$" + CredentialsEnv, nil
+	case os.Getenv(CredentialsFileEnv) != "":
+		return os.Getenv(CredentialsFileEnv), nil
+	}
+	return GetCredentialsPath()
+}
+
 // CredentialsExist checks if OAuth credentials have been configured
 func CredentialsExist() bool {
-	path, err := GetCredentialsPath()
+	if credentialsFlag == "" && os.Getenv(CredentialsEnv) != "" {
+		return true
+	}
+	path, err := CredentialsSource()
 	if err != nil {
 		return false
 	}
@@ -58,46 +263,59 @@ func CredentialsExist() bool {
 	return err == nil
 }
 
-// TokenExists checks if a valid token file exists
+// TokenExists checks if a token is stored
 func TokenExists() bool {
-	path, err := GetTokenPath()
-	if err != nil {
-		return false
-	}
-	_, err = os.Stat(path)
+	_, _, err := readTokenData()
 	return err == nil
 }
 
-// ReadCredentials reads the OAuth credentials from file
+// ReadCredentials reads the OAuth credentials from the source named by
+// CredentialsSource
 func ReadCredentials() ([]byte, error) {
-	path, err := GetCredentialsPath()
+	if credentialsFlag == "" {
+		if data := os.Getenv(CredentialsEnv); data != "" {
+			return []byte(data), nil
+		}
+	}
+	path, err := CredentialsSource()
 	if err != nil {
 		return nil, err
 	}
 	return os.ReadFile(path)
 }
 
-// SaveCredentials saves OAuth credentials to file
+// SaveCredentials saves OAuth credentials to the active account's directory
 func SaveCredentials(data []byte) error {
-	path, err := GetCredentialsPath()
+	dir, err := GetConfigDir()
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0600)
+	return os.WriteFile(filepath.Join(dir, credentialsFile), data, 0600)
 }
 
-// ReadToken reads the OAuth token from file
+// ReadToken reads the OAuth token from file, decrypting it if it was saved
+// with GDAY_PASSPHRASE set. A plaintext token is encrypted in place once a
+// passphrase is set.
 func ReadToken() ([]byte, error) {
-	path, err := GetTokenPath()
+	store, data, err := readTokenData()
 	if err != nil {
 		return nil, err
 	}
-	return os.ReadFile(path)
+	if isEncrypted(data) {
+		return decrypt(data, passphrase())
+	}
+	if pass := passphrase(); pass != "" {
+		if sealed, err := encrypt(data, pass); err == nil {
+			store.Save(sealed)
+		}
+	}
+	return data, nil
 }
 
-// SaveToken saves OAuth token to file
+// SaveToken saves the OAuth token to the token store, encrypted if
+// GDAY_PASSPHRASE is set
 func SaveToken(token interface{}) error {
-	path, err := GetTokenPath()
+	store, err := GetTokenStore()
 	if err != nil {
 		return err
 	}
@@ -105,14 +323,252 @@ func SaveToken(token interface{}) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0600)
+	if pass := passphrase(); pass != "" {
+		if data, err = encrypt(data, pass); err != nil {
+			return fmt.Errorf("failed to encrypt token: %w", err)
+		}
+	}
+	return store.Save(data)
+}
+
+// TokenEncrypted reports whether the stored token is encrypted
+func TokenEncrypted() bool {
+	store, err := GetTokenStore()
+	if err != nil {
+		return false
+	}
+	data, err := store.Read()
+	return err == nil && isEncrypted(data)
 }
 
 // DeleteToken removes the cached token
 func DeleteToken() error {
-	path, err := GetTokenPath()
+	store, err := GetTokenStore()
 	if err != nil {
 		return err
 	}
-	return os.Remove(path)
+	return store.Delete()
+}
+
+// ReadScopes returns the OAuth scopes granted at login, or nil if they
+// weren't recorded
+func ReadScopes() ([]string, error) {
+	dir, err := GetConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, scopesFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var scopes []string
+	if err := json.Unmarshal(data, &scopes); err != nil {
+		return nil, err
+	}
+	return scopes, nil
+}
+
+// SaveScopes records the OAuth scopes granted at login; nil removes the record
+func SaveScopes(scopes []string) error {
+	dir, err := GetConfigDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, scopesFile)
+	if scopes == nil {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(scopes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// SaveServeToken writes the bearer token for 'gday serve' so local clients
+// can read it, returning the file's path; "" removes the file
+func SaveServeToken(token string) (string, error) {
+	dir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, serveTokenFile)
+	if token == "" {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return path, err
+		}
+		return path, nil
+	}
+	return path, os.WriteFile(path, []byte(token+"\n"), 0600)
+}
+
+// GetCacheDir returns the path to the response cache directory
+func GetCacheDir() (string, error) {
+	dir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	cache := filepath.Join(dir, cacheDir)
+	if err := os.MkdirAll(cache, 0700); err != nil {
+		return "", err
+	}
+	return cache, nil
+}
+
+// cachePath returns the cache file path for a key
+func cachePath(key string) (string, error) {
+	dir, err := GetCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".json"), nil
+}
+
+// cacheMaxAge is how long a cache entry can go unused before it's pruned
+const cacheMaxAge = 7 * 24 * time.Hour
+
+// ReadCache reads a cached API response stored under key
+func ReadCache(key string) ([]byte, error) {
+	path, err := cachePath(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// Mark the entry as used so pruning keeps it
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return data, nil
+}
+
+// SaveCache stores an API response under key, pruning entries that
+// haven't been used for cacheMaxAge
+func SaveCache(key string, data []byte) error {
+	path, err := cachePath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	pruneCache(filepath.Dir(path), time.Now().Add(-cacheMaxAge))
+	return nil
+}
+
+// pruneCache removes cache files last used before cutoff. Failures are
+// ignored: a stale entry costs disk space, not correctness
+func pruneCache(dir string, cutoff time.Time) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		_ = os.Remove(filepath.Join(dir, e.Name()))
+	}
+}
+
+// GetSettingsPath returns the path to the settings file
+func GetSettingsPath() (string, error) {
+	dir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, settingsFile), nil
+}
+
+// LoadSettings reads config.json, returning empty settings if it doesn't exist
+func LoadSettings() (*Settings, error) {
+	path, err := GetSettingsPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Settings{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var settings Settings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return &settings, nil
+}
+
+// SaveSettings writes settings to config.json
+func SaveSettings(settings *Settings) error {
+	path, err := GetSettingsPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// readSyncTokens reads the map of calendar ID to sync token
+func readSyncTokens() (map[string]string, string, error) {
+	dir, err := GetConfigDir()
+	if err != nil {
+		return nil, "", err
+	}
+	path := filepath.Join(dir, syncTokensFile)
+	tokens := make(map[string]string)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return tokens, path, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, "", err
+	}
+	return tokens, path, nil
+}
+
+// ReadSyncToken returns the stored calendar sync token, or "" if there is none
+func ReadSyncToken(calendarID string) (string, error) {
+	tokens, _, err := readSyncTokens()
+	if err != nil {
+		return "", err
+	}
+	return tokens[calendarID], nil
+}
+
+// SaveSyncToken stores the calendar sync token; an empty token removes it
+func SaveSyncToken(calendarID, token string) error {
+	tokens, path, err := readSyncTokens()
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		delete(tokens, calendarID)
+	} else {
+		tokens[calendarID] = token
+	}
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
 }
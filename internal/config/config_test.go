@@ -0,0 +1,54 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSaveCachePrunesUnusedEntries(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	tests := []struct {
+		name     string
+		age      time.Duration
+		read     bool // Read before the new entry is saved
+		wantKept bool
+	}{
+		{name: "recent entry", age: time.Hour, wantKept: true},
+		{name: "entry just inside the limit", age: cacheMaxAge - time.Hour, wantKept: true},
+		{name: "unused old entry", age: cacheMaxAge + time.Hour, wantKept: false},
+		{name: "old entry read since", age: cacheMaxAge + time.Hour, read: true, wantKept: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SaveCache("old", []byte("{}")); err != nil {
+				t.Fatal(err)
+			}
+			path, err := cachePath("old")
+			if err != nil {
+				t.Fatal(err)
+			}
+			then := time.Now().Add(-tt.age)
+			if err := os.Chtimes(path, then, then); err != nil {
+				t.Fatal(err)
+			}
+			if tt.read {
+				if _, err := ReadCache("old"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := SaveCache("new", []byte("{}")); err != nil {
+				t.Fatal(err)
+			}
+			_, err = ReadCache("old")
+			if kept := err == nil; kept != tt.wantKept {
+				t.Errorf("entry kept = %v, want %v (err %v)", kept, tt.wantKept, err)
+			}
+			if _, err := ReadCache("new"); err != nil {
+				t.Errorf("new entry: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// PassphraseEnv names the environment variable holding the passphrase that
+// encrypts the token at rest
+const PassphraseEnv = "GDAY_PASSPHRASE"
+
+// encryptedHeader starts every encrypted file, so plaintext files written
+// by older versions can still be read
+var encryptedHeader = []byte("gday-encrypted-v1\n")
+
+// scrypt parameters for deriving the AES-256 key (the values recommended
+// for interactive logins)
+const (
+	saltSize = 16
+	scryptN  = 1 << 15
+	scryptR  = 8
+	scryptP  = 1
+	keySize  = 32
+)
+
+var (
+	// ErrPassphraseRequired means the token is encrypted and no passphrase is set
+	ErrPassphraseRequired = errors.New("token is encrypted; set " + PassphraseEnv + " to decrypt it")
+
+	// ErrWrongPassphrase means the token couldn't be decrypted with the passphrase
+	ErrWrongPassphrase = errors.New("unable to decrypt token; check " + PassphraseEnv)
+)
+
+// passphrase returns the passphrase from the environment, or "" if unset
+func passphrase() string {
+	return os.Getenv(PassphraseEnv)
+}
+
+// isEncrypted reports whether data was written by encrypt
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, encryptedHeader)
+}
+
+// encrypt seals data with AES-256-GCM under a key derived from pass with
+// scrypt. The output is the header, salt, nonce, then the ciphertext.
+func encrypt(data []byte, pass string) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(pass, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := append([]byte{}, encryptedHeader...)
+	out = append(out, salt...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, data, encryptedHeader), nil
+}
+
+// decrypt opens data written by encrypt
+func decrypt(data []byte, pass string) ([]byte, error) {
+	if pass == "" {
+		return nil, ErrPassphraseRequired
+	}
+	data = data[len(encryptedHeader):]
+	if len(data) < saltSize {
+		return nil, fmt.Errorf("encrypted token is truncated")
+	}
+	salt, data := data[:saltSize], data[saltSize:]
+
+	gcm, err := newGCM(pass, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted token is truncated")
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+
+	plain, err := gcm.Open(nil, nonce, ciphertext, encryptedHeader)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plain, nil
+}
+
+// newGCM derives the key for pass and salt and returns an AES-GCM cipher
+func newGCM(pass string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(pass), salt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
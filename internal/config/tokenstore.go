@@ -0,0 +1,142 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/zalando/go-keyring"
+)
+
+// Token store names for the token_store setting
+const (
+	TokenStoreFile    = "file"
+	TokenStoreKeyring = "keyring"
+)
+
+// keyringService is the service name gday's tokens are filed under in the
+// OS keyring; the account name is the keyring user
+const keyringService = "gday"
+
+// TokenStore holds the serialized OAuth token. Read returns an error
+// satisfying os.IsNotExist when no token is stored.
+type TokenStore interface {
+	Read() ([]byte, error)
+	Save(data []byte) error
+	Delete() error
+}
+
+// FileStore keeps the token in a file readable only by the user
+type FileStore struct {
+	Path string
+}
+
+// Read returns the token file's contents
+func (f FileStore) Read() ([]byte, error) {
+	return os.ReadFile(f.Path)
+}
+
+// Save writes the token file
+func (f FileStore) Save(data []byte) error {
+	return os.WriteFile(f.Path, data, 0600)
+}
+
+// Delete removes the token file
+func (f FileStore) Delete() error {
+	return os.Remove(f.Path)
+}
+
+// KeyringStore keeps the token in the macOS Keychain, the Secret Service
+// (GNOME Keyring, KWallet), or the Windows Credential Manager
+type KeyringStore struct {
+	User string
+}
+
+// Read returns the token stored in the keyring
+func (k KeyringStore) Read() ([]byte, error) {
+	secret, err := keyring.Get(keyringService, k.User)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil, os.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(secret), nil
+}
+
+// Save stores the token in the keyring
+func (k KeyringStore) Save(data []byte) error {
+	return keyring.Set(keyringService, k.User, string(data))
+}
+
+// Delete removes the token from the keyring
+func (k KeyringStore) Delete() error {
+	err := keyring.Delete(keyringService, k.User)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return os.ErrNotExist
+	}
+	return err
+}
+
+// keyringAvailable reports whether the OS keyring can be used: a lookup
+// either finds the entry or reports it missing, rather than failing
+func keyringAvailable(user string) bool {
+	_, err := keyring.Get(keyringService, user)
+	return err == nil || errors.Is(err, keyring.ErrNotFound)
+}
+
+// GetTokenStore returns the active account's token store: the OS keyring if
+// config.json sets "token_store": "keyring" and a keyring is available,
+// otherwise token.json
+func GetTokenStore() (TokenStore, error) {
+	path, err := GetTokenPath()
+	if err != nil {
+		return nil, err
+	}
+	settings, err := LoadSettings()
+	if err != nil {
+		return nil, err
+	}
+	return tokenStoreFor(account, path, settings), nil
+}
+
+// tokenStoreFor returns the token store for an account given its token
+// file path and settings
+func tokenStoreFor(name, path string, settings *Settings) TokenStore {
+	if settings.TokenStore == TokenStoreKeyring && keyringAvailable(name) {
+		return KeyringStore{User: name}
+	}
+	return FileStore{Path: path}
+}
+
+// readTokenData reads the raw token from the active store. When the store
+// is the keyring and it holds no token yet, a token.json left from file
+// storage is moved into it.
+func readTokenData() (TokenStore, []byte, error) {
+	store, err := GetTokenStore()
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := store.Read()
+	if _, ok := store.(KeyringStore); ok && os.IsNotExist(err) {
+		path, _ := GetTokenPath()
+		file := FileStore{Path: path}
+		if data, err = file.Read(); err == nil {
+			if store.Save(data) == nil {
+				file.Delete()
+			}
+		}
+	}
+	return store, data, err
+}
+
+// accountLoggedIn reports whether the account stored in dir has a token
+func accountLoggedIn(name, dir string) bool {
+	settings := &Settings{}
+	if data, err := os.ReadFile(filepath.Join(dir, settingsFile)); err == nil {
+		json.Unmarshal(data, settings)
+	}
+	_, err := tokenStoreFor(name, filepath.Join(dir, tokenFile), settings).Read()
+	return err == nil
+}
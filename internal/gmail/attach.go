@@ -0,0 +1,167 @@
+package gmail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// attachmentFile is the content of a file to attach
+type attachmentFile struct {
+	Filename string
+	MimeType string
+	Size     int64
+	Open     func() (io.ReadCloser, error)
+}
+
+// fileAttachments describes files on disk to attach, guessing each one's
+// content type from its extension
+func fileAttachments(paths []string) ([]attachmentFile, error) {
+	files := make([]attachmentFile, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read attachment: %w", err)
+		}
+		if info.IsDir() {
+			return nil, fmt.Errorf("attachment %s is a directory", path)
+		}
+		mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		files = append(files, attachmentFile{
+			Filename: filepath.Base(path),
+			MimeType: mimeType,
+			Size:     info.Size(),
+			Open:     func() (io.ReadCloser, error) { return os.Open(path) },
+		})
+	}
+	return files, nil
+}
+
+// dataAttachment describes in-memory content to attach
+func dataAttachment(filename, mimeType string, data []byte) attachmentFile {
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	return attachmentFile{
+		Filename: filename,
+		MimeType: mimeType,
+		Size:     int64(len(data)),
+		Open:     func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil },
+	}
+}
+
+// mixedPart returns the Content-Type and body of a multipart/mixed entity:
+// the readable part followed by each attached file
+func mixedPart(contentType string, body []byte, attachments []attachmentFile) (string, []byte, error) {
+	// Fail before reading anything if the files alone are too big once
+	// base64-encoded
+	var total int64
+	for _, a := range attachments {
+		total += a.Size
+	}
+	if encoded := total / 3 * 4; encoded > MaxMessageSize {
+		return "", nil, fmt.Errorf("%w (attachments are %.1f MB encoded)", ErrMessageTooLarge, float64(encoded)/(1<<20))
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	pw, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {contentType}})
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := pw.Write(body); err != nil {
+		return "", nil, err
+	}
+
+	for _, a := range attachments {
+		if err := writeAttachmentPart(mw, a); err != nil {
+			return "", nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return "", nil, err
+	}
+	return "multipart/mixed; boundary=" + mw.Boundary(), buf.Bytes(), nil
+}
+
+// writeAttachmentPart adds a file as a base64-encoded attachment part,
+// streaming its content rather than reading it whole
+func writeAttachmentPart(mw *multipart.Writer, a attachmentFile) error {
+	r, err := a.Open()
+	if err != nil {
+		return fmt.Errorf("failed to read attachment: %w", err)
+	}
+	defer r.Close()
+
+	pw, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentTypeWithName(a.MimeType, a.Filename)},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename})},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return err
+	}
+
+	lw := &lineWrapper{w: pw, width: 76}
+	enc := base64.NewEncoder(base64.StdEncoding, lw)
+	if _, err := io.Copy(enc, r); err != nil {
+		return fmt.Errorf("failed to read attachment %s: %w", a.Filename, err)
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	_, err = io.WriteString(pw, "\r\n")
+	return err
+}
+
+// contentTypeWithName adds a name parameter to a MIME type, which may
+// already carry parameters such as a charset
+func contentTypeWithName(mimeType, filename string) string {
+	mediaType, params, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		mediaType, params = "application/octet-stream", nil
+	}
+	if params == nil {
+		params = make(map[string]string)
+	}
+	params["name"] = filename
+	return mime.FormatMediaType(mediaType, params)
+}
+
+// lineWrapper inserts a CRLF after every width bytes written through it
+type lineWrapper struct {
+	w     io.Writer
+	width int
+	col   int
+}
+
+func (l *lineWrapper) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if l.col == l.width {
+			if _, err := io.WriteString(l.w, "\r\n"); err != nil {
+				return written, err
+			}
+			l.col = 0
+		}
+		n := min(len(p), l.width-l.col)
+		if _, err := l.w.Write(p[:n]); err != nil {
+			return written, err
+		}
+		written += n
+		l.col += n
+		p = p[n:]
+	}
+	return written, nil
+}
@@ -0,0 +1,188 @@
+package gmail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// batchSize is the most requests sent in one batch. Gmail accepts up to 100,
+// but recommends 50 or fewer to avoid rate limiting.
+const batchSize = 50
+
+// batchRetryDelay is how long to wait before refetching the messages a
+// batch couldn't load because of rate limiting or a server error
+var batchRetryDelay = time.Second
+
+// GetMessagesBatch retrieves messages using Gmail's HTTP batch endpoint, one
+// request per batchSize messages rather than one per message. Messages the
+// batch couldn't load because of rate limiting or a server error are fetched
+// again one at a time. Messages that no longer exist are skipped; any other
+// failure fails the call. Messages are returned in the order of ids.
+func (s *Service) GetMessagesBatch(ctx context.Context, ids []string, includeBody bool) ([]*Message, error) {
+	format := "metadata"
+	if includeBody {
+		format = "full"
+	}
+
+	messages := make([]*Message, 0, len(ids))
+	for start := 0; start < len(ids); start += batchSize {
+		end := min(start+batchSize, len(ids))
+		fetched, retry, err := s.getBatch(ctx, ids[start:end], format)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get messages: %w", err)
+		}
+		if len(retry) > 0 {
+			if err := s.refetch(ctx, retry, format, fetched); err != nil {
+				return nil, err
+			}
+		}
+		for _, id := range ids[start:end] {
+			if msg, ok := fetched[id]; ok {
+				messages = append(messages, parseMessage(msg, includeBody))
+			}
+		}
+	}
+	return messages, nil
+}
+
+// refetch gets the messages a batch couldn't load individually, after a
+// pause, adding them to fetched. The client's transport backs off further
+// if Gmail is still rate limiting.
+func (s *Service) refetch(ctx context.Context, ids []string, format string, fetched map[string]*gmail.Message) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(batchRetryDelay):
+	}
+	for _, id := range ids {
+		msg, err := s.srv.Users.Messages.Get("me", id).Format(format).Context(ctx).Do()
+		if isNotFound(err) {
+			continue // Deleted since it was listed
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get message %s: %w", id, err)
+		}
+		fetched[id] = msg
+	}
+	return nil
+}
+
+// isNotFound reports whether err is the API's 404 Not Found
+func isNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
+
+// getBatch sends one batch request for ids, returning the messages that
+// loaded keyed by ID, and the IDs worth fetching again: those rate limited,
+// hit by a server error, or missing from the response. Messages that no
+// longer exist are left out of both.
+func (s *Service) getBatch(ctx context.Context, ids []string, format string) (map[string]*gmail.Message, []string, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for i, id := range ids {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {"application/http"},
+			"Content-Id":   {"<" + strconv.Itoa(i) + ">"},
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		fmt.Fprintf(pw, "GET /gmail/v1/users/me/messages/%s?format=%s\r\n\r\n", url.PathEscape(id), format)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	endpoint := strings.TrimSuffix(s.srv.BasePath, "/") + "/batch/gmail/v1"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, &body)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, nil, fmt.Errorf("batch request failed: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	_, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || params["boundary"] == "" {
+		return nil, nil, fmt.Errorf("batch response is not multipart: %q", resp.Header.Get("Content-Type"))
+	}
+
+	// Each part wraps one HTTP response, matched to its request by the
+	// "response-N" Content-ID
+	fetched := make(map[string]*gmail.Message, len(ids))
+	answered := make([]bool, len(ids))
+	var retry []string
+	mr := multipart.NewReader(resp.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read batch response: %w", err)
+		}
+
+		contentID := strings.Trim(part.Header.Get("Content-Id"), "<>")
+		i, err := strconv.Atoi(strings.TrimPrefix(contentID, "response-"))
+		if err != nil || i < 0 || i >= len(ids) {
+			continue
+		}
+
+		inner, err := http.ReadResponse(bufio.NewReader(part), req)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read batch response for %s: %w", ids[i], err)
+		}
+		answered[i] = true
+		switch {
+		case inner.StatusCode == http.StatusOK:
+			var msg gmail.Message
+			err = json.NewDecoder(inner.Body).Decode(&msg)
+			inner.Body.Close()
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to parse message %s: %w", ids[i], err)
+			}
+			fetched[ids[i]] = &msg
+		case inner.StatusCode == http.StatusNotFound:
+			inner.Body.Close() // Deleted since it was listed
+		case inner.StatusCode == http.StatusTooManyRequests || inner.StatusCode >= 500:
+			inner.Body.Close()
+			retry = append(retry, ids[i])
+		default:
+			data, _ := io.ReadAll(io.LimitReader(inner.Body, 4096))
+			inner.Body.Close()
+			return nil, nil, fmt.Errorf("failed to get message %s: %s: %s", ids[i], inner.Status, strings.TrimSpace(string(data)))
+		}
+	}
+
+	for i, id := range ids {
+		if !answered[i] {
+			retry = append(retry, id)
+		}
+	}
+	return fetched, retry, nil
+}
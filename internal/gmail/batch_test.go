@@ -0,0 +1,117 @@
+package gmail
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// fakeBatchServer answers batch requests with each message's status from
+// batchStatus (200 if unset, 0 to leave it out of the response), and single
+// message requests with singleStatus
+type fakeBatchServer struct {
+	batchStatus  map[string]int
+	singleStatus map[string]int
+	singleGets   []string
+}
+
+func (f *fakeBatchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if id, ok := strings.CutPrefix(r.URL.Path, "/gmail/v1/users/me/messages/"); ok {
+		f.singleGets = append(f.singleGets, id)
+		if status := f.singleStatus[id]; status != 0 && status != http.StatusOK {
+			http.Error(w, fmt.Sprintf(`{"error":{"code":%d,"message":"failed"}}`, status), status)
+			return
+		}
+		fmt.Fprintf(w, `{"id":%q}`, id)
+		return
+	}
+
+	_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	mr := multipart.NewReader(r.Body, params["boundary"])
+	mw := multipart.NewWriter(w)
+	w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		// The request line is "GET /gmail/v1/users/me/messages/ID?format=F"
+		line, _ := bufio.NewReader(part).ReadString('\n')
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		path, _, _ := strings.Cut(fields[1], "?")
+		id := strings.TrimPrefix(path, "/gmail/v1/users/me/messages/")
+		status, ok := f.batchStatus[id]
+		if !ok {
+			status = http.StatusOK
+		}
+		if status == 0 {
+			continue
+		}
+		pw, _ := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {"application/http"},
+			"Content-Id":   {"<response-" + strings.Trim(part.Header.Get("Content-Id"), "<>") + ">"},
+		})
+		body := fmt.Sprintf(`{"id":%q}`, id)
+		if status != http.StatusOK {
+			body = fmt.Sprintf(`{"error":{"code":%d}}`, status)
+		}
+		fmt.Fprintf(pw, "HTTP/1.1 %d %s\r\nContent-Type: application/json\r\n\r\n%s", status, http.StatusText(status), body)
+	}
+	mw.Close()
+}
+
+func TestGetMessagesBatch(t *testing.T) {
+	batchRetryDelay = 0
+	ids := []string{"a", "b", "c"}
+
+	tests := []struct {
+		name           string
+		batchStatus    map[string]int
+		singleStatus   map[string]int
+		want           []string
+		wantSingleGets []string
+		wantErr        bool
+	}{
+		{name: "all load", want: ids},
+		{name: "rate limited part is refetched", batchStatus: map[string]int{"b": 429}, want: ids, wantSingleGets: []string{"b"}},
+		{name: "server error part is refetched", batchStatus: map[string]int{"a": 503, "c": 500}, want: ids, wantSingleGets: []string{"a", "c"}},
+		{name: "missing part is refetched", batchStatus: map[string]int{"c": 0}, want: ids, wantSingleGets: []string{"c"}},
+		{name: "deleted message is skipped", batchStatus: map[string]int{"b": 404}, want: []string{"a", "c"}},
+		{name: "deleted before the refetch", batchStatus: map[string]int{"b": 429}, singleStatus: map[string]int{"b": 404}, want: []string{"a", "c"}, wantSingleGets: []string{"b"}},
+		{name: "forbidden part fails the call", batchStatus: map[string]int{"b": 403}, wantErr: true},
+		{name: "refetch that still fails fails the call", batchStatus: map[string]int{"b": 429}, singleStatus: map[string]int{"b": 400}, wantErr: true, wantSingleGets: []string{"b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeBatchServer{batchStatus: tt.batchStatus, singleStatus: tt.singleStatus}
+			srv := newTestService(t, fake)
+
+			messages, err := srv.GetMessagesBatch(context.Background(), ids, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(fake.singleGets, ",") != strings.Join(tt.wantSingleGets, ",") {
+				t.Errorf("single gets = %v, want %v", fake.singleGets, tt.wantSingleGets)
+			}
+			if tt.wantErr {
+				return
+			}
+			var got []string
+			for _, m := range messages {
+				got = append(got, m.ID)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("messages = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package gmail
+
+import (
+	"encoding/base64"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/htmlindex"
+	"google.golang.org/api/gmail/v1"
+)
+
+// decodePartBody returns a text part's body as UTF-8: the API's base64url
+// layer is removed, then the declared charset is converted. The API has
+// already undone the part's Content-Transfer-Encoding, so data that looks
+// quoted-printable, such as width=100, is left alone.
+func decodePartBody(part *gmail.MessagePart) (string, error) {
+	data, err := base64.URLEncoding.DecodeString(part.Body.Data)
+	if err != nil {
+		return "", err
+	}
+
+	_, params, _ := mime.ParseMediaType(partHeader(part, "Content-Type"))
+	return toUTF8(data, params["charset"]), nil
+}
+
+// toUTF8 converts data from charset to UTF-8. Data that is already valid
+// UTF-8 with non-ASCII characters is kept as-is, as is data in a charset
+// that isn't recognized.
+func toUTF8(data []byte, charset string) string {
+	switch strings.ToLower(charset) {
+	case "", "utf-8", "utf8", "us-ascii", "ascii":
+		return string(data)
+	}
+	if utf8.Valid(data) && !isASCII(data) {
+		return string(data)
+	}
+
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return string(data)
+	}
+	decoded, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return string(data)
+	}
+	return string(decoded)
+}
+
+// partHeader returns the value of a part's header, or ""
+func partHeader(part *gmail.MessagePart, name string) string {
+	for _, h := range part.Headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+func isASCII(data []byte) bool {
+	for _, b := range data {
+		if b >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package gmail
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// textPart returns a part as the API returns it: data base64url-encoded,
+// with any transfer encoding already removed
+func textPart(data []byte, contentType, transferEncoding string) *gmail.MessagePart {
+	part := &gmail.MessagePart{
+		MimeType: "text/plain",
+		Body:     &gmail.MessagePartBody{Data: base64.URLEncoding.EncodeToString(data)},
+		Headers:  []*gmail.MessagePartHeader{{Name: "Content-Type", Value: contentType}},
+	}
+	if transferEncoding != "" {
+		part.Headers = append(part.Headers, &gmail.MessagePartHeader{Name: "Content-Transfer-Encoding", Value: transferEncoding})
+	}
+	return part
+}
+
+func TestDecodePartBody(t *testing.T) {
+	tests := []struct {
+		name             string
+		data             []byte
+		contentType      string
+		transferEncoding string
+		want             string
+	}{
+		{"utf-8", []byte("caf\xc3\xa9"), "text/plain; charset=utf-8", "", "café"},
+		{"latin-1", []byte("caf\xe9"), "text/plain; charset=ISO-8859-1", "", "café"},
+		{"windows-1252 quotes", []byte("\x93hi\x94"), `text/plain; charset="windows-1252"`, "", "“hi”"},
+		{"latin-1 label that is already utf-8", []byte("caf\xc3\xa9"), "text/plain; charset=iso-8859-1", "", "café"},
+		{"unknown charset", []byte("plain"), "text/plain; charset=x-unknown", "", "plain"},
+		{"no charset", []byte("plain"), "text/plain", "", "plain"},
+		// The API has already decoded quoted-printable, so escapes left in
+		// the data are literal text
+		{"decoded quoted-printable", []byte("caf\xc3\xa9"), "text/plain; charset=utf-8", "quoted-printable", "café"},
+		{"literal =C3=A9", []byte("encode é as =C3=A9"), "text/plain; charset=utf-8", "quoted-printable", "encode é as =C3=A9"},
+		{"html attribute", []byte(`<td width=100>`), "text/html; charset=utf-8", "quoted-printable", `<td width=100>`},
+		{"url query", []byte("https://example.com/?id=AB12"), "text/plain; charset=us-ascii", "quoted-printable", "https://example.com/?id=AB12"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodePartBody(textPart(tt.data, tt.contentType, tt.transferEncoding))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("decodePartBody = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package gmail
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Draft is an unsent message saved in Gmail
+type Draft struct {
+	ID      string
+	Message *Message
+}
+
+// ListDrafts returns up to maxResults drafts, newest first, with their
+// headers but not their bodies
+func (s *Service) ListDrafts(ctx context.Context, maxResults int64) ([]*Draft, error) {
+	resp, err := s.srv.Users.Drafts.List("me").MaxResults(maxResults).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list drafts: %w", err)
+	}
+
+	ids := make([]string, 0, len(resp.Drafts))
+	for _, d := range resp.Drafts {
+		if d.Message != nil {
+			ids = append(ids, d.Message.Id)
+		}
+	}
+	messages, err := s.GetMessagesBatch(ctx, ids, false)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*Message, len(messages))
+	for _, m := range messages {
+		byID[m.ID] = m
+	}
+
+	drafts := make([]*Draft, 0, len(resp.Drafts))
+	for _, d := range resp.Drafts {
+		if d.Message == nil {
+			continue
+		}
+		// A draft deleted since the listing has no message to show
+		if m, ok := byID[d.Message.Id]; ok {
+			drafts = append(drafts, &Draft{ID: d.Id, Message: m})
+		}
+	}
+	return drafts, nil
+}
+
+// GetDraft retrieves a draft with its body
+func (s *Service) GetDraft(ctx context.Context, id string) (*Draft, error) {
+	d, err := s.srv.Users.Drafts.Get("me", id).Format("full").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get draft: %w", err)
+	}
+	draft := &Draft{ID: d.Id}
+	if d.Message != nil {
+		draft.Message = parseMessage(d.Message, true)
+	}
+	return draft, nil
+}
+
+// SendDraft sends a draft as it is, which removes it from the drafts, and
+// returns the sent message
+func (s *Service) SendDraft(ctx context.Context, id string) (*Message, error) {
+	sent, err := s.srv.Users.Drafts.Send("me", &gmail.Draft{Id: id}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to send draft: %w", err)
+	}
+	return parseMessage(sent, false), nil
+}
+
+// DeleteDraft permanently deletes a draft
+func (s *Service) DeleteDraft(ctx context.Context, id string) error {
+	if err := s.srv.Users.Drafts.Delete("me", id).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to delete draft: %w", err)
+	}
+	return nil
+}
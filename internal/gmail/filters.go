@@ -0,0 +1,137 @@
+package gmail
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Filter is a Gmail filter: messages matching its criteria have its actions
+// applied as they arrive. Labels are given by name.
+type Filter struct {
+	ID string
+
+	// Criteria
+	From          string
+	To            string
+	Subject       string
+	Query         string // Gmail search query the message must match
+	NegatedQuery  string // Gmail search query the message must not match
+	HasAttachment bool
+
+	// Actions
+	AddLabels    []string
+	RemoveLabels []string
+	Forward      string
+}
+
+// ListFilters returns the user's filters
+func (s *Service) ListFilters(ctx context.Context) ([]*Filter, error) {
+	resp, err := s.srv.Users.Settings.Filters.List("me").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list filters: %w", err)
+	}
+	if len(resp.Filter) == 0 {
+		return nil, nil
+	}
+
+	names, err := s.labelNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	filters := make([]*Filter, 0, len(resp.Filter))
+	for _, f := range resp.Filter {
+		filters = append(filters, parseFilter(f, names))
+	}
+	return filters, nil
+}
+
+// CreateFilter creates a filter, resolving its label names to IDs, and
+// returns it with its new ID
+func (s *Service) CreateFilter(ctx context.Context, filter *Filter) (*Filter, error) {
+	addIDs, err := s.ResolveLabelIDs(ctx, filter.AddLabels)
+	if err != nil {
+		return nil, err
+	}
+	removeIDs, err := s.ResolveLabelIDs(ctx, filter.RemoveLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &gmail.Filter{
+		Criteria: &gmail.FilterCriteria{
+			From:          filter.From,
+			To:            filter.To,
+			Subject:       filter.Subject,
+			Query:         filter.Query,
+			NegatedQuery:  filter.NegatedQuery,
+			HasAttachment: filter.HasAttachment,
+		},
+		Action: &gmail.FilterAction{
+			AddLabelIds:    addIDs,
+			RemoveLabelIds: removeIDs,
+			Forward:        filter.Forward,
+		},
+	}
+	created, err := s.srv.Users.Settings.Filters.Create("me", f).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create filter: %w", err)
+	}
+
+	result := *filter
+	result.ID = created.Id
+	return &result, nil
+}
+
+// DeleteFilter deletes a filter by ID
+func (s *Service) DeleteFilter(ctx context.Context, id string) error {
+	if err := s.srv.Users.Settings.Filters.Delete("me", id).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to delete filter: %w", err)
+	}
+	return nil
+}
+
+// labelNames maps label IDs to names
+func (s *Service) labelNames(ctx context.Context) (map[string]string, error) {
+	resp, err := s.srv.Users.Labels.List("me").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list labels: %w", err)
+	}
+	names := make(map[string]string, len(resp.Labels))
+	for _, l := range resp.Labels {
+		names[l.Id] = l.Name
+	}
+	return names, nil
+}
+
+// parseFilter converts an API filter, naming its labels; a label that no
+// longer exists keeps its ID
+func parseFilter(f *gmail.Filter, names map[string]string) *Filter {
+	filter := &Filter{ID: f.Id}
+	if c := f.Criteria; c != nil {
+		filter.From = c.From
+		filter.To = c.To
+		filter.Subject = c.Subject
+		filter.Query = c.Query
+		filter.NegatedQuery = c.NegatedQuery
+		filter.HasAttachment = c.HasAttachment
+	}
+	if a := f.Action; a != nil {
+		for _, id := range a.AddLabelIds {
+			filter.AddLabels = append(filter.AddLabels, labelName(id, names))
+		}
+		for _, id := range a.RemoveLabelIds {
+			filter.RemoveLabels = append(filter.RemoveLabels, labelName(id, names))
+		}
+		filter.Forward = a.Forward
+	}
+	return filter
+}
+
+func labelName(id string, names map[string]string) string {
+	if name, ok := names[id]; ok {
+		return name
+	}
+	return id
+}
@@ -1,26 +1,65 @@
 package gmail
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
 	"io"
+	"mime/multipart"
+	"mime/quotedprintable"
 	"net/http"
+	"net/mail"
+	"net/textproto"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
 
+	"github.com/joncooper/gday/internal/config"
 	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 )
 
 // Service wraps the Gmail API service
 type Service struct {
-	srv *gmail.Service
+	srv    *gmail.Service
+	client *http.Client // For requests the generated client can't make, such as batches
+
+	labelIDs map[string]string // Lowercased label name and ID to ID, loaded on first use
+
+	// UploadProgress, if set, is called as a large message uploads, starting
+	// with sent == 0 before any data goes out
+	UploadProgress func(sent, total int64)
+
+	// UploadDone, if set, is called once the send of a message reported
+	// through UploadProgress returns, whether or not it succeeded. The
+	// uploader doesn't always report the last chunk, so this is the only
+	// reliable sign the upload is over.
+	UploadDone func()
 }
 
+// MaxMessageSize is the largest message Gmail accepts, including the
+// base64-encoded attachments
+const MaxMessageSize = 25 << 20
+
+// LargeMessageSize is the size above which messages are sent with a
+// resumable upload that reports progress
+const LargeMessageSize = 5 << 20
+
+// LargeAttachmentSize is the size above which downloading an attachment
+// is worth a warning: the API returns the whole attachment in one response,
+// with no progress to show
+const LargeAttachmentSize = 10 << 20
+
+// ErrMessageTooLarge is returned for messages over MaxMessageSize
+var ErrMessageTooLarge = errors.New("message exceeds Gmail's 25 MB limit")
+
 // Message represents a simplified email message
 type Message struct {
 	ID          string
@@ -35,6 +74,19 @@ type Message struct {
 	Labels      []string
 	Attachments []Attachment
 	IsUnread    bool
+	IsImportant bool   // Gmail marked the message important
+	Priority    string // "high", "normal", or "low" from the sender's headers; "" if unset
+}
+
+// Label represents a Gmail label with its counts
+type Label struct {
+	ID              string
+	Name            string
+	Type            string // "system" or "user"
+	MessagesTotal   int64
+	MessagesUnread  int64
+	BackgroundColor string
+	TextColor       string
 }
 
 // Attachment represents an email attachment
@@ -45,40 +97,92 @@ type Attachment struct {
 	Size     int64
 }
 
+// cachedMessageList is a messages.list response cached with its ETag
+type cachedMessageList struct {
+	ETag     string                      `json:"etag"`
+	Response *gmail.ListMessagesResponse `json:"response"`
+}
+
 // NewService creates a new Gmail service
 func NewService(ctx context.Context, client *http.Client) (*Service, error) {
 	srv, err := gmail.NewService(ctx, option.WithHTTPClient(client))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Gmail service: %w", err)
 	}
-	return &Service{srv: srv}, nil
+	return &Service{srv: srv, client: client}, nil
 }
 
 // ListMessages lists recent emails
 func (s *Service) ListMessages(ctx context.Context, maxResults int64, query string, labelIDs []string) ([]*Message, error) {
-	req := s.srv.Users.Messages.List("me").MaxResults(maxResults)
+	messages, _, err := s.ListMessagesPage(ctx, maxResults, query, labelIDs, "")
+	return messages, err
+}
+
+// maxPageSize is the most messages Gmail returns per list page
+const maxPageSize = 500
+
+// ListMessagesPage lists up to maxResults emails starting at pageToken ("" for
+// the first page), following Gmail's pages as needed. maxResults <= 0 lists
+// every match. The returned token resumes the listing, or is "" at the end.
+func (s *Service) ListMessagesPage(ctx context.Context, maxResults int64, query string, labelIDs []string, pageToken string) ([]*Message, string, error) {
+	var ids []string
+	for {
+		pageSize := int64(maxPageSize)
+		if maxResults > 0 {
+			pageSize = min(maxResults-int64(len(ids)), maxPageSize)
+		}
+		resp, err := s.listPage(ctx, pageSize, query, labelIDs, pageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, m := range resp.Messages {
+			ids = append(ids, m.Id)
+		}
+		pageToken = resp.NextPageToken
+		if pageToken == "" || (maxResults > 0 && int64(len(ids)) >= maxResults) {
+			break
+		}
+	}
+
+	messages, err := s.GetMessagesBatch(ctx, ids, false)
+	if err != nil {
+		return nil, "", err
+	}
+	return messages, pageToken, nil
+}
+
+// listPage fetches one page of message IDs, revalidating a cached copy
+func (s *Service) listPage(ctx context.Context, pageSize int64, query string, labelIDs []string, pageToken string) (*gmail.ListMessagesResponse, error) {
+	req := s.srv.Users.Messages.List("me").MaxResults(pageSize)
 	if query != "" {
 		req = req.Q(query)
 	}
 	if len(labelIDs) > 0 {
 		req = req.LabelIds(labelIDs...)
 	}
+	if pageToken != "" {
+		req = req.PageToken(pageToken)
+	}
 
-	resp, err := req.Do()
-	if err != nil {
-		return nil, fmt.Errorf("failed to list messages: %w", err)
+	// Send the cached ETag so an unchanged listing comes back as 304
+	cacheKey := fmt.Sprintf("messages.list|%d|%s|%s|%s", pageSize, query, strings.Join(labelIDs, ","), pageToken)
+	var cached cachedMessageList
+	if data, err := config.ReadCache(cacheKey); err == nil && json.Unmarshal(data, &cached) == nil && cached.ETag != "" {
+		req = req.IfNoneMatch(cached.ETag)
 	}
 
-	messages := make([]*Message, 0, len(resp.Messages))
-	for _, m := range resp.Messages {
-		msg, err := s.GetMessage(ctx, m.Id, false)
-		if err != nil {
-			continue // Skip messages that fail to load
+	resp, err := req.Context(ctx).Do()
+	if googleapi.IsNotModified(err) && cached.Response != nil {
+		return cached.Response, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+	if etag := resp.Header.Get("Etag"); etag != "" {
+		if data, err := json.Marshal(cachedMessageList{ETag: etag, Response: resp}); err == nil {
+			config.SaveCache(cacheKey, data)
 		}
-		messages = append(messages, msg)
 	}
-
-	return messages, nil
+	return resp, nil
 }
 
 // GetMessage retrieves a single message
@@ -88,7 +192,7 @@ func (s *Service) GetMessage(ctx context.Context, id string, includeBody bool) (
 		format = "full"
 	}
 
-	msg, err := s.srv.Users.Messages.Get("me", id).Format(format).Do()
+	msg, err := s.srv.Users.Messages.Get("me", id).Format(format).Context(ctx).Do()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get message: %w", err)
 	}
@@ -96,9 +200,48 @@ func (s *Service) GetMessage(ctx context.Context, id string, includeBody bool) (
 	return parseMessage(msg, includeBody), nil
 }
 
+// GetRawMessage retrieves the complete RFC822 source of a message
+func (s *Service) GetRawMessage(ctx context.Context, id string) ([]byte, error) {
+	msg, err := s.srv.Users.Messages.Get("me", id).Format("raw").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+
+	raw, err := base64.URLEncoding.DecodeString(msg.Raw)
+	if err != nil {
+		// Gmail sometimes omits the padding
+		if raw, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(msg.Raw, "=")); err != nil {
+			return nil, fmt.Errorf("failed to decode message: %w", err)
+		}
+	}
+	return raw, nil
+}
+
+// Header is a single raw message header
+type Header struct {
+	Name  string
+	Value string
+}
+
+// GetHeaders retrieves all headers of a message in their original order
+func (s *Service) GetHeaders(ctx context.Context, id string) ([]Header, error) {
+	msg, err := s.srv.Users.Messages.Get("me", id).Format("metadata").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+
+	var headers []Header
+	if msg.Payload != nil {
+		for _, h := range msg.Payload.Headers {
+			headers = append(headers, Header{Name: h.Name, Value: h.Value})
+		}
+	}
+	return headers, nil
+}
+
 // GetThread retrieves a thread with all messages
 func (s *Service) GetThread(ctx context.Context, threadID string) ([]*Message, error) {
-	thread, err := s.srv.Users.Threads.Get("me", threadID).Format("full").Do()
+	thread, err := s.srv.Users.Threads.Get("me", threadID).Format("full").Context(ctx).Do()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get thread: %w", err)
 	}
@@ -116,26 +259,35 @@ func (s *Service) SearchMessages(ctx context.Context, query string, maxResults i
 	return s.ListMessages(ctx, maxResults, query, nil)
 }
 
-// SendMessage sends a new email
-func (s *Service) SendMessage(ctx context.Context, to, subject, body string, cc, bcc []string) (*Message, error) {
-	// Build the message
-	var msgBuilder strings.Builder
-	msgBuilder.WriteString(fmt.Sprintf("To: %s\r\n", to))
-	if len(cc) > 0 {
-		msgBuilder.WriteString(fmt.Sprintf("Cc: %s\r\n", strings.Join(cc, ", ")))
+// SendMessage sends a new email built from m
+func (s *Service) SendMessage(ctx context.Context, m *OutgoingMessage) (*Message, error) {
+	raw, err := buildMIME(m)
+	if err != nil {
+		return nil, err
 	}
-	if len(bcc) > 0 {
-		msgBuilder.WriteString(fmt.Sprintf("Bcc: %s\r\n", strings.Join(bcc, ", ")))
+
+	sent, err := s.send(ctx, raw, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to send message: %w", err)
 	}
-	msgBuilder.WriteString(fmt.Sprintf("Subject: %s\r\n", subject))
-	msgBuilder.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
-	msgBuilder.WriteString("\r\n")
-	msgBuilder.WriteString(body)
 
-	rawMsg := base64.URLEncoding.EncodeToString([]byte(msgBuilder.String()))
-	message := &gmail.Message{Raw: rawMsg}
+	return s.GetMessage(ctx, sent.Id, false)
+}
+
+// SendRawMessage sends a complete RFC822 message verbatim. The message must
+// have at least To and Subject headers.
+func (s *Service) SendRawMessage(ctx context.Context, raw []byte) (*Message, error) {
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid message: %w", err)
+	}
+	for _, h := range []string{"To", "Subject"} {
+		if parsed.Header.Get(h) == "" {
+			return nil, fmt.Errorf("invalid message: missing %s header", h)
+		}
+	}
 
-	sent, err := s.srv.Users.Messages.Send("me", message).Do()
+	sent, err := s.send(ctx, raw, "")
 	if err != nil {
 		return nil, fmt.Errorf("failed to send message: %w", err)
 	}
@@ -143,13 +295,297 @@ func (s *Service) SendMessage(ctx context.Context, to, subject, body string, cc,
 	return s.GetMessage(ctx, sent.Id, false)
 }
 
-// ReplyToMessage sends a reply to an existing message
-func (s *Service) ReplyToMessage(ctx context.Context, messageID, body string) (*Message, error) {
-	// Get original message
-	orig, err := s.GetMessage(ctx, messageID, true)
+// send uploads a raw RFC822 message, rejecting it up front if Gmail would.
+// Large messages go up as a resumable media upload so progress can be shown.
+func (s *Service) send(ctx context.Context, raw []byte, threadID string) (*gmail.Message, error) {
+	size := int64(len(raw))
+	if size > MaxMessageSize {
+		return nil, fmt.Errorf("%w (message is %.1f MB)", ErrMessageTooLarge, float64(size)/(1<<20))
+	}
+
+	if size <= LargeMessageSize {
+		message := &gmail.Message{
+			Raw:      base64.URLEncoding.EncodeToString(raw),
+			ThreadId: threadID,
+		}
+		return s.srv.Users.Messages.Send("me", message).Context(ctx).Do()
+	}
+
+	call := s.srv.Users.Messages.Send("me", &gmail.Message{ThreadId: threadID}).
+		Context(ctx).
+		Media(bytes.NewReader(raw), googleapi.ContentType("message/rfc822"))
+	if s.UploadProgress != nil {
+		s.UploadProgress(0, size)
+		call = call.ProgressUpdater(func(current, _ int64) {
+			s.UploadProgress(current, size)
+		})
+		if s.UploadDone != nil {
+			defer s.UploadDone()
+		}
+	}
+	return call.Do()
+}
+
+// priorityHeaders maps a priority to its X-Priority, Importance, and
+// Priority header values. Clients differ in which of these they honor.
+var priorityHeaders = map[string][3]string{
+	"high":   {"1 (Highest)", "High", "urgent"},
+	"normal": {"3 (Normal)", "Normal", "normal"},
+	"low":    {"5 (Lowest)", "Low", "non-urgent"},
+}
+
+// ValidPriority reports whether priority is "", "high", "normal", or "low"
+func ValidPriority(priority string) bool {
+	_, ok := priorityHeaders[priority]
+	return ok || priority == ""
+}
+
+// parsePriority maps an X-Priority, Importance, or Priority header to
+// "high", "normal", or "low", or "" if the value isn't recognized.
+// X-Priority is a number from 1 (highest) to 5 (lowest), optionally
+// followed by a description such as "1 (Highest)".
+func parsePriority(name, value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if strings.EqualFold(name, "x-priority") {
+		if value == "" {
+			return ""
+		}
+		switch value[0] {
+		case '1', '2':
+			return "high"
+		case '3':
+			return "normal"
+		case '4', '5':
+			return "low"
+		}
+		return ""
+	}
+	switch value {
+	case "high", "urgent":
+		return "high"
+	case "normal":
+		return "normal"
+	case "low", "non-urgent":
+		return "low"
+	}
+	return ""
+}
+
+// inviteMimeType is the content type of an OutgoingMessage's Invite part
+const inviteMimeType = "text/calendar; charset=utf-8; method=REQUEST"
+
+// OutgoingMessage describes a message to send or save as a draft. Empty
+// fields are left out of the headers.
+type OutgoingMessage struct {
+	From       string // A From header, usually built by FromHeader; empty uses the primary address
+	To         string
+	Cc         []string
+	Bcc        []string
+	Subject    string
+	InReplyTo  string
+	References string
+	Priority   string
+
+	// With HTML set, the message carries it and Text as
+	// multipart/alternative, with Text generated from the HTML when empty.
+	// HTML must refer to each of Images by cid:.
+	Text   string
+	HTML   string
+	Images []InlineImage
+
+	Attachments []string // File paths
+	// Invite is an iCalendar invitation, attached as a text/calendar;
+	// method=REQUEST part so mail clients offer to RSVP
+	Invite string
+
+	files []attachmentFile // In-memory attachments, such as a forwarded message's
+}
+
+// buildMIME builds the RFC822 source of an outgoing message
+func buildMIME(m *OutgoingMessage) ([]byte, error) {
+	files, err := fileAttachments(m.Attachments)
 	if err != nil {
 		return nil, err
 	}
+	files = append(files, m.files...)
+	if m.Invite != "" {
+		files = append(files, dataAttachment("invite.ics", inviteMimeType, []byte(m.Invite)))
+	}
+
+	var buf bytes.Buffer
+	writeFromHeader(&buf, m.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", encodeAddresses(m.To))
+	if len(m.Cc) > 0 {
+		fmt.Fprintf(&buf, "Cc: %s\r\n", encodeAddresses(strings.Join(m.Cc, ", ")))
+	}
+	if len(m.Bcc) > 0 {
+		fmt.Fprintf(&buf, "Bcc: %s\r\n", encodeAddresses(strings.Join(m.Bcc, ", ")))
+	}
+	fmt.Fprintf(&buf, "Subject: %s\r\n", encodeHeader(m.Subject))
+	if m.InReplyTo != "" {
+		fmt.Fprintf(&buf, "In-Reply-To: %s\r\n", m.InReplyTo)
+	}
+	if m.References != "" {
+		fmt.Fprintf(&buf, "References: %s\r\n", m.References)
+	}
+	if err := writePriorityHeaders(&buf, m.Priority); err != nil {
+		return nil, err
+	}
+	if err := writeBody(&buf, m.Text, m.HTML, m.Images, files); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeFromHeader writes the From header, if one is set; Gmail otherwise
+// fills in the primary address
+func writeFromHeader(w io.Writer, from string) {
+	if from != "" {
+		fmt.Fprintf(w, "From: %s\r\n", encodeAddresses(from))
+	}
+}
+
+// writePriorityHeaders writes the priority headers, if a priority is set
+func writePriorityHeaders(w io.Writer, priority string) error {
+	if priority == "" {
+		return nil
+	}
+	values, ok := priorityHeaders[priority]
+	if !ok {
+		return fmt.Errorf("invalid priority %q (use high, normal, or low)", priority)
+	}
+	fmt.Fprintf(w, "X-Priority: %s\r\n", values[0])
+	fmt.Fprintf(w, "Importance: %s\r\n", values[1])
+	fmt.Fprintf(w, "Priority: %s\r\n", values[2])
+	return nil
+}
+
+// writeBody writes the MIME headers and body of a message: plain text, or
+// text and HTML with any inline images, wrapped in multipart/mixed when
+// files are attached. An HTML body without text gets a plain-text fallback
+// generated from the HTML.
+func writeBody(w io.Writer, text, html string, images []InlineImage, attachments []attachmentFile) error {
+	if html == "" && len(images) > 0 {
+		return fmt.Errorf("inline images need an HTML body")
+	}
+	if text == "" && html != "" {
+		text = htmlToText(html)
+	}
+	if html == "" && len(attachments) == 0 {
+		fmt.Fprintf(w, "Content-Type: text/plain; charset=utf-8\r\n")
+		fmt.Fprintf(w, "\r\n")
+		_, err := io.WriteString(w, text)
+		return err
+	}
+
+	contentType, body, err := bodyPart(text, html, images)
+	if err != nil {
+		return err
+	}
+	if len(attachments) > 0 {
+		if contentType, body, err = mixedPart(contentType, body, attachments); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(w, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(w, "Content-Type: %s\r\n", contentType)
+	fmt.Fprintf(w, "\r\n")
+	_, err = w.Write(body)
+	return err
+}
+
+// bodyPart returns the Content-Type and body of the readable part of a
+// message: plain text, multipart/alternative text and HTML, or
+// multipart/related when the HTML embeds images
+func bodyPart(text, html string, images []InlineImage) (string, []byte, error) {
+	switch {
+	case html == "":
+		return "text/plain; charset=utf-8", []byte(text), nil
+	case len(images) == 0:
+		return alternativePart(text, html)
+	}
+	if err := CheckInlineImages(html, images); err != nil {
+		return "", nil, err
+	}
+	return relatedPart(text, html, images)
+}
+
+// alternativePart returns the Content-Type and body of a
+// multipart/alternative entity carrying a plain-text and an HTML part
+func alternativePart(text, html string) (string, []byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return "", nil, err
+		}
+		qp := quotedprintable.NewWriter(pw)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return "", nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return "", nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return "", nil, err
+	}
+	return "multipart/alternative; boundary=" + mw.Boundary(), body.Bytes(), nil
+}
+
+// GetProfileEmail returns the authenticated user's email address
+func (s *Service) GetProfileEmail(ctx context.Context) (string, error) {
+	profile, err := s.srv.Users.GetProfile("me").Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to get profile: %w", err)
+	}
+	return profile.EmailAddress, nil
+}
+
+// ReplyToMessage sends a reply to an existing message, in its thread. from
+// is a From header as for SendMessage; attachments are file paths.
+func (s *Service) ReplyToMessage(ctx context.Context, messageID, from, body string, cc, bcc, attachments []string) (*Message, error) {
+	raw, threadID, err := s.buildReply(ctx, messageID, from, body, cc, bcc, attachments)
+	if err != nil {
+		return nil, err
+	}
+
+	sent, err := s.send(ctx, raw, threadID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send reply: %w", err)
+	}
+
+	return s.GetMessage(ctx, sent.Id, false)
+}
+
+// CreateReplyDraft saves a reply to an existing message as a draft in its
+// thread, returning the draft ID
+func (s *Service) CreateReplyDraft(ctx context.Context, messageID, from, body string, cc, bcc, attachments []string) (string, error) {
+	raw, threadID, err := s.buildReply(ctx, messageID, from, body, cc, bcc, attachments)
+	if err != nil {
+		return "", err
+	}
+	return s.createDraft(ctx, raw, threadID)
+}
+
+// buildReply builds a reply to a message, addressed to its sender with the
+// In-Reply-To and References headers that keep it in the thread, and
+// returns it with the thread's ID
+func (s *Service) buildReply(ctx context.Context, messageID, from, body string, cc, bcc, attachments []string) ([]byte, string, error) {
+	origMsg, err := s.srv.Users.Messages.Get("me", messageID).Format("metadata").Context(ctx).Do()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get message: %w", err)
+	}
+	orig := parseMessage(origMsg, false)
 
 	// Build reply subject
 	subject := orig.Subject
@@ -158,11 +594,6 @@ func (s *Service) ReplyToMessage(ctx context.Context, messageID, body string) (*
 	}
 
 	// Get references and message-id for threading
-	origMsg, err := s.srv.Users.Messages.Get("me", messageID).Format("full").Do()
-	if err != nil {
-		return nil, err
-	}
-
 	var messageIDHeader, references string
 	for _, h := range origMsg.Payload.Headers {
 		switch strings.ToLower(h.Name) {
@@ -180,40 +611,101 @@ func (s *Service) ReplyToMessage(ctx context.Context, messageID, body string) (*
 		references = messageIDHeader
 	}
 
-	// Build the reply message
-	var msgBuilder strings.Builder
-	msgBuilder.WriteString(fmt.Sprintf("To: %s\r\n", orig.From))
-	msgBuilder.WriteString(fmt.Sprintf("Subject: %s\r\n", subject))
-	msgBuilder.WriteString(fmt.Sprintf("In-Reply-To: %s\r\n", messageIDHeader))
-	msgBuilder.WriteString(fmt.Sprintf("References: %s\r\n", references))
-	msgBuilder.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
-	msgBuilder.WriteString("\r\n")
-	msgBuilder.WriteString(body)
+	raw, err := buildMIME(&OutgoingMessage{
+		From:        from,
+		To:          orig.From,
+		Cc:          cc,
+		Bcc:         bcc,
+		Subject:     subject,
+		InReplyTo:   messageIDHeader,
+		References:  references,
+		Text:        body,
+		Attachments: attachments,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return raw, orig.ThreadID, nil
+}
 
-	rawMsg := base64.URLEncoding.EncodeToString([]byte(msgBuilder.String()))
-	message := &gmail.Message{
-		Raw:      rawMsg,
-		ThreadId: orig.ThreadID,
+// ForwardMessage forwards a message to new recipients with its attachments,
+// below an optional note
+func (s *Service) ForwardMessage(ctx context.Context, messageID, to, extraBody string) (*Message, error) {
+	orig, err := s.GetMessage(ctx, messageID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := orig.Subject
+	lower := strings.ToLower(subject)
+	if !strings.HasPrefix(lower, "fwd:") && !strings.HasPrefix(lower, "fw:") {
+		subject = "Fwd: " + subject
 	}
 
-	sent, err := s.srv.Users.Messages.Send("me", message).Do()
+	var body strings.Builder
+	if extraBody != "" {
+		body.WriteString(extraBody)
+		body.WriteString("\n\n")
+	}
+	body.WriteString("---------- Forwarded message ----------\n")
+	fmt.Fprintf(&body, "From: %s\n", orig.From)
+	fmt.Fprintf(&body, "Date: %s\n", orig.Date.Format("Mon, Jan 2, 2006 at 3:04 PM"))
+	fmt.Fprintf(&body, "Subject: %s\n", orig.Subject)
+	fmt.Fprintf(&body, "To: %s\n", orig.To)
+	body.WriteString("\n")
+	body.WriteString(orig.Body)
+
+	files := make([]attachmentFile, 0, len(orig.Attachments))
+	for _, att := range orig.Attachments {
+		data, err := s.getAttachmentData(ctx, messageID, att.ID)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, dataAttachment(att.Filename, att.MimeType, data))
+	}
+
+	raw, err := buildMIME(&OutgoingMessage{
+		To:      to,
+		Subject: subject,
+		Text:    body.String(),
+		files:   files,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to send reply: %w", err)
+		return nil, err
+	}
+
+	sent, err := s.send(ctx, raw, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to forward message: %w", err)
 	}
 
 	return s.GetMessage(ctx, sent.Id, false)
 }
 
-// DownloadAttachment downloads an attachment to the specified directory
-func (s *Service) DownloadAttachment(ctx context.Context, messageID, attachmentID, filename, outDir string) (string, error) {
-	att, err := s.srv.Users.Messages.Attachments.Get("me", messageID, attachmentID).Do()
+// getAttachmentData fetches and decodes an attachment's content
+func (s *Service) getAttachmentData(ctx context.Context, messageID, attachmentID string) ([]byte, error) {
+	att, err := s.srv.Users.Messages.Attachments.Get("me", messageID, attachmentID).Context(ctx).Do()
 	if err != nil {
-		return "", fmt.Errorf("failed to get attachment: %w", err)
+		return nil, fmt.Errorf("failed to get attachment: %w", err)
 	}
 
 	data, err := base64.URLEncoding.DecodeString(att.Data)
 	if err != nil {
-		return "", fmt.Errorf("failed to decode attachment: %w", err)
+		return nil, fmt.Errorf("failed to decode attachment: %w", err)
+	}
+	return data, nil
+}
+
+// DownloadAttachment downloads an attachment to the specified directory.
+// The file name is sanitized so it can't escape outDir. Unless overwrite is
+// set, an existing file is kept and the download is saved as "name (1).ext"
+// or the next free number instead. The content is decoded as it is written,
+// so the attachment is never held in memory twice; a partially written file
+// is removed if anything fails.
+func (s *Service) DownloadAttachment(ctx context.Context, messageID, attachmentID, filename, outDir string, overwrite bool) (string, error) {
+	att, err := s.srv.Users.Messages.Attachments.Get("me", messageID, attachmentID).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to get attachment: %w", err)
 	}
 
 	// Create output directory if it doesn't exist
@@ -222,17 +714,64 @@ func (s *Service) DownloadAttachment(ctx context.Context, messageID, attachmentI
 	}
 
 	// Write file
+	filename = SanitizeFilename(filename)
 	outPath := filepath.Join(outDir, filename)
-	if err := os.WriteFile(outPath, data, 0644); err != nil {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !overwrite {
+		outPath = UniquePath(outDir, filename)
+		flags = os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	}
+	f, err := os.OpenFile(outPath, flags, 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to write attachment: %w", err)
+	}
+	decoder := base64.NewDecoder(base64.URLEncoding, strings.NewReader(att.Data))
+	_, err = io.Copy(f, decoder)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(outPath)
 		return "", fmt.Errorf("failed to write attachment: %w", err)
 	}
 
 	return outPath, nil
 }
 
+// SanitizeFilename makes a name from an email safe to use as a file name,
+// dropping path separators and control characters
+func SanitizeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r == '/' || r == '\\' || r == ':' || r < 0x20 || r == 0x7f:
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(strings.TrimSpace(name), ".")
+	if name == "" {
+		name = "unnamed"
+	}
+	return name
+}
+
+// UniquePath returns dir/name, or dir/"name (N).ext" with the smallest N
+// that doesn't collide with an existing file
+func UniquePath(dir, name string) string {
+	path := filepath.Join(dir, name)
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for n := 1; ; n++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s (%d)%s", base, n, ext))
+	}
+}
+
 // GetLabels returns all labels
 func (s *Service) GetLabels(ctx context.Context) ([]string, error) {
-	resp, err := s.srv.Users.Labels.List("me").Do()
+	resp, err := s.srv.Users.Labels.List("me").Context(ctx).Do()
 	if err != nil {
 		return nil, fmt.Errorf("failed to list labels: %w", err)
 	}
@@ -244,11 +783,120 @@ func (s *Service) GetLabels(ctx context.Context) ([]string, error) {
 	return labels, nil
 }
 
+// ResolveLabelIDs maps label names (case-insensitive) or IDs to label IDs,
+// returning an error naming any label that doesn't exist
+func (s *Service) ResolveLabelIDs(ctx context.Context, names []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	if s.labelIDs == nil {
+		resp, err := s.srv.Users.Labels.List("me").Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list labels: %w", err)
+		}
+		s.labelIDs = make(map[string]string, 2*len(resp.Labels))
+		for _, l := range resp.Labels {
+			s.labelIDs[strings.ToLower(l.Name)] = l.Id
+			s.labelIDs[strings.ToLower(l.Id)] = l.Id
+		}
+	}
+
+	ids := make([]string, 0, len(names))
+	for _, name := range names {
+		id, ok := s.labelIDs[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("label not found: %s", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// ModifyLabels adds and removes labels, given by name or ID, on a message
+func (s *Service) ModifyLabels(ctx context.Context, messageID string, add, remove []string) error {
+	addIDs, err := s.ResolveLabelIDs(ctx, add)
+	if err != nil {
+		return err
+	}
+	removeIDs, err := s.ResolveLabelIDs(ctx, remove)
+	if err != nil {
+		return err
+	}
+	_, err = s.srv.Users.Messages.Modify("me", messageID, &gmail.ModifyMessageRequest{
+		AddLabelIds:    addIDs,
+		RemoveLabelIds: removeIDs,
+	}).Context(ctx).Do()
+	return err
+}
+
+// maxBatchModify is the most messages one batchModify request may name
+const maxBatchModify = 1000
+
+// BatchModify adds and removes labels (names or IDs) on many messages,
+// with one API call per 1000 messages rather than one per message
+func (s *Service) BatchModify(ctx context.Context, ids []string, add, remove []string) error {
+	addIDs, err := s.ResolveLabelIDs(ctx, add)
+	if err != nil {
+		return err
+	}
+	removeIDs, err := s.ResolveLabelIDs(ctx, remove)
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(ids); start += maxBatchModify {
+		end := min(start+maxBatchModify, len(ids))
+		err := s.srv.Users.Messages.BatchModify("me", &gmail.BatchModifyMessagesRequest{
+			Ids:            ids[start:end],
+			AddLabelIds:    addIDs,
+			RemoveLabelIds: removeIDs,
+		}).Context(ctx).Do()
+		if err != nil {
+			return fmt.Errorf("failed to modify messages: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListLabelsDetailed returns all labels with their type, message counts, and
+// color. The list endpoint omits counts, so each label is fetched individually.
+func (s *Service) ListLabelsDetailed(ctx context.Context) ([]*Label, error) {
+	resp, err := s.srv.Users.Labels.List("me").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list labels: %w", err)
+	}
+
+	labels := make([]*Label, 0, len(resp.Labels))
+	for _, l := range resp.Labels {
+		full, err := s.srv.Users.Labels.Get("me", l.Id).Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get label %s: %w", l.Name, err)
+		}
+		labels = append(labels, parseLabel(full))
+	}
+	return labels, nil
+}
+
+// parseLabel converts a Gmail API label to our Label type
+func parseLabel(l *gmail.Label) *Label {
+	label := &Label{
+		ID:             l.Id,
+		Name:           l.Name,
+		Type:           l.Type,
+		MessagesTotal:  l.MessagesTotal,
+		MessagesUnread: l.MessagesUnread,
+	}
+	if l.Color != nil {
+		label.BackgroundColor = l.Color.BackgroundColor
+		label.TextColor = l.Color.TextColor
+	}
+	return label
+}
+
 // MarkAsRead marks a message as read
 func (s *Service) MarkAsRead(ctx context.Context, messageID string) error {
 	_, err := s.srv.Users.Messages.Modify("me", messageID, &gmail.ModifyMessageRequest{
 		RemoveLabelIds: []string{"UNREAD"},
-	}).Do()
+	}).Context(ctx).Do()
 	return err
 }
 
@@ -256,10 +904,44 @@ func (s *Service) MarkAsRead(ctx context.Context, messageID string) error {
 func (s *Service) MarkAsUnread(ctx context.Context, messageID string) error {
 	_, err := s.srv.Users.Messages.Modify("me", messageID, &gmail.ModifyMessageRequest{
 		AddLabelIds: []string{"UNREAD"},
-	}).Do()
+	}).Context(ctx).Do()
+	return err
+}
+
+// ArchiveMessage removes a message from the inbox
+func (s *Service) ArchiveMessage(ctx context.Context, messageID string) error {
+	_, err := s.srv.Users.Messages.Modify("me", messageID, &gmail.ModifyMessageRequest{
+		RemoveLabelIds: []string{"INBOX"},
+	}).Context(ctx).Do()
+	return err
+}
+
+// UnarchiveMessage moves a message back to the inbox
+func (s *Service) UnarchiveMessage(ctx context.Context, messageID string) error {
+	_, err := s.srv.Users.Messages.Modify("me", messageID, &gmail.ModifyMessageRequest{
+		AddLabelIds: []string{"INBOX"},
+	}).Context(ctx).Do()
+	return err
+}
+
+// TrashMessage moves a message to the trash
+func (s *Service) TrashMessage(ctx context.Context, messageID string) error {
+	_, err := s.srv.Users.Messages.Trash("me", messageID).Context(ctx).Do()
+	return err
+}
+
+// UntrashMessage moves a message out of the trash
+func (s *Service) UntrashMessage(ctx context.Context, messageID string) error {
+	_, err := s.srv.Users.Messages.Untrash("me", messageID).Context(ctx).Do()
 	return err
 }
 
+// PermanentlyDelete immediately and irreversibly deletes a message,
+// bypassing the trash. Requires the full mail scope.
+func (s *Service) PermanentlyDelete(ctx context.Context, messageID string) error {
+	return s.srv.Users.Messages.Delete("me", messageID).Context(ctx).Do()
+}
+
 // parseMessage converts a Gmail API message to our Message type
 func parseMessage(m *gmail.Message, includeBody bool) *Message {
 	msg := &Message{
@@ -269,11 +951,12 @@ func parseMessage(m *gmail.Message, includeBody bool) *Message {
 		Labels:   m.LabelIds,
 	}
 
-	// Check if unread
 	for _, l := range m.LabelIds {
-		if l == "UNREAD" {
+		switch l {
+		case "UNREAD":
 			msg.IsUnread = true
-			break
+		case "IMPORTANT":
+			msg.IsImportant = true
 		}
 	}
 
@@ -291,6 +974,10 @@ func parseMessage(m *gmail.Message, includeBody bool) *Message {
 				if t, err := parseDate(h.Value); err == nil {
 					msg.Date = t
 				}
+			case "x-priority", "importance", "priority":
+				if msg.Priority == "" {
+					msg.Priority = parsePriority(h.Name, h.Value)
+				}
 			}
 		}
 
@@ -309,18 +996,19 @@ func parseMessage(m *gmail.Message, includeBody bool) *Message {
 	return msg
 }
 
-// extractBody extracts the text and HTML body from message payload
+// extractBody extracts the text and HTML body from message payload,
+// decoded to UTF-8
 func extractBody(payload *gmail.MessagePart) (string, string) {
 	var textBody, htmlBody string
 
 	// Check if this part has data
 	if payload.Body != nil && payload.Body.Data != "" {
-		decoded, err := base64.URLEncoding.DecodeString(payload.Body.Data)
+		decoded, err := decodePartBody(payload)
 		if err == nil {
 			if strings.HasPrefix(payload.MimeType, "text/plain") {
-				textBody = string(decoded)
+				textBody = decoded
 			} else if strings.HasPrefix(payload.MimeType, "text/html") {
-				htmlBody = string(decoded)
+				htmlBody = decoded
 			}
 		}
 	}
@@ -386,48 +1074,79 @@ func parseDate(dateStr string) (time.Time, error) {
 	return time.Time{}, fmt.Errorf("unable to parse date: %s", dateStr)
 }
 
-// htmlToText converts HTML to plain text (basic implementation)
-func htmlToText(html string) string {
-	// Remove script and style elements
-	re := regexp.MustCompile(`<script[^>]*>.*?</script>|<style[^>]*>.*?</style>`)
-	text := re.ReplaceAllString(html, "")
+// wrapBase64 breaks base64 data into 76-character lines as MIME requires
+func wrapBase64(data string) string {
+	var b strings.Builder
+	for len(data) > 76 {
+		b.WriteString(data[:76])
+		b.WriteString("\r\n")
+		data = data[76:]
+	}
+	b.WriteString(data)
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// Patterns used by htmlToText. (?s) lets . match newlines, since script
+// and style blocks and comments usually span several lines.
+var (
+	htmlHiddenPattern = regexp.MustCompile(`(?is)<script[^>]*>.*?</script\s*>|<style[^>]*>.*?</style\s*>|<head[^>]*>.*?</head\s*>|<!--.*?-->`)
+	htmlBreakPattern  = regexp.MustCompile(`(?i)<br\s*/?>|</p\s*>|</div\s*>|</li\s*>|</tr\s*>|</h[1-6]\s*>`)
+	htmlTagPattern    = regexp.MustCompile(`(?s)<[^>]+>`)
+	blankLinesPattern = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToText converts HTML to plain text (basic implementation; see
+// RenderHTML for a structured rendering)
+func htmlToText(s string) string {
+	// Remove script and style elements, the head, and comments
+	text := htmlHiddenPattern.ReplaceAllString(s, "")
 
-	// Replace br and p tags with newlines
-	text = regexp.MustCompile(`<br\s*/?>|</p>|</div>`).ReplaceAllString(text, "\n")
+	// Replace line-ending tags with newlines
+	text = htmlBreakPattern.ReplaceAllString(text, "\n")
 
 	// Remove all other HTML tags
-	text = regexp.MustCompile(`<[^>]+>`).ReplaceAllString(text, "")
+	text = htmlTagPattern.ReplaceAllString(text, "")
 
-	// Decode common HTML entities
-	text = strings.ReplaceAll(text, "&nbsp;", " ")
-	text = strings.ReplaceAll(text, "&amp;", "&")
-	text = strings.ReplaceAll(text, "&lt;", "<")
-	text = strings.ReplaceAll(text, "&gt;", ">")
-	text = strings.ReplaceAll(text, "&quot;", "\"")
-	text = strings.ReplaceAll(text, "&#39;", "'")
+	// Decode named and numeric entities (&amp;, &eacute;, &#8217;, &#x2014;)
+	text = html.UnescapeString(text)
+	text = strings.ReplaceAll(text, "\u00a0", " ")
 
 	// Clean up whitespace
-	text = regexp.MustCompile(`\n{3,}`).ReplaceAllString(text, "\n\n")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	text = blankLinesPattern.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
 	text = strings.TrimSpace(text)
 
 	return text
 }
 
-// CreateDraft creates a draft email
-func (s *Service) CreateDraft(ctx context.Context, to, subject, body string) (string, error) {
-	var msgBuilder strings.Builder
-	msgBuilder.WriteString(fmt.Sprintf("To: %s\r\n", to))
-	msgBuilder.WriteString(fmt.Sprintf("Subject: %s\r\n", subject))
-	msgBuilder.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
-	msgBuilder.WriteString("\r\n")
-	msgBuilder.WriteString(body)
+// CreateDraft saves a message built from m as a draft, returning the
+// draft ID
+func (s *Service) CreateDraft(ctx context.Context, m *OutgoingMessage) (string, error) {
+	raw, err := buildMIME(m)
+	if err != nil {
+		return "", err
+	}
+	return s.createDraft(ctx, raw, "")
+}
+
+// createDraft saves a raw RFC822 message as a draft, in threadID's thread
+// if one is given
+func (s *Service) createDraft(ctx context.Context, raw []byte, threadID string) (string, error) {
+	if len(raw) > MaxMessageSize {
+		return "", fmt.Errorf("%w (message is %.1f MB)", ErrMessageTooLarge, float64(len(raw))/(1<<20))
+	}
 
-	rawMsg := base64.URLEncoding.EncodeToString([]byte(msgBuilder.String()))
 	draft := &gmail.Draft{
-		Message: &gmail.Message{Raw: rawMsg},
+		Message: &gmail.Message{
+			Raw:      base64.URLEncoding.EncodeToString(raw),
+			ThreadId: threadID,
+		},
 	}
-
-	created, err := s.srv.Users.Drafts.Create("me", draft).Do()
+	created, err := s.srv.Users.Drafts.Create("me", draft).Context(ctx).Do()
 	if err != nil {
 		return "", fmt.Errorf("failed to create draft: %w", err)
 	}
@@ -0,0 +1,954 @@
+package gmail
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+)
+
+// newTestService returns a Service whose requests go to handler
+func newTestService(t *testing.T, handler http.Handler) *Service {
+	t.Helper()
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	srv, err := gmail.NewService(context.Background(),
+		option.WithHTTPClient(ts.Client()),
+		option.WithEndpoint(ts.URL+"/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Service{srv: srv, client: ts.Client()}
+}
+
+// rawMessage returns an RFC822 message of about size bytes
+func rawMessage(size int) []byte {
+	header := "To: a@example.com\r\nSubject: Hi\r\n\r\n"
+	return []byte(header + strings.Repeat("x", max(size-len(header), 0)))
+}
+
+func TestSendSizeLimits(t *testing.T) {
+	tests := []struct {
+		name         string
+		size         int
+		wantErr      error
+		wantProgress bool
+	}{
+		{"small message goes up in one request", 1 << 10, nil, false},
+		{"large message reports upload progress", LargeMessageSize + 1<<20, nil, true},
+		{"message over the limit is rejected", MaxMessageSize + 1, ErrMessageTooLarge, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests, uploaded := 0, 0
+			srv := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				data, _ := io.ReadAll(r.Body)
+				uploaded += len(data)
+				w.Write([]byte(`{"id":"sent1"}`))
+			}))
+
+			var progress []int64
+			done := 0
+			srv.UploadProgress = func(sent, total int64) { progress = append(progress, sent) }
+			srv.UploadDone = func() { done++ }
+
+			raw := rawMessage(tt.size)
+			sent, err := srv.send(context.Background(), raw, "")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				if requests != 0 {
+					t.Errorf("made %d requests for a rejected message", requests)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sent.Id != "sent1" {
+				t.Errorf("sent ID = %q", sent.Id)
+			}
+
+			if !tt.wantProgress {
+				if len(progress) != 0 || done != 0 {
+					t.Errorf("progress = %v, done = %d for a small message", progress, done)
+				}
+				return
+			}
+			if len(progress) == 0 || progress[0] != 0 {
+				t.Errorf("progress = %v, want it to start at 0", progress)
+			}
+			if done != 1 {
+				t.Errorf("UploadDone called %d times, want 1", done)
+			}
+			if uploaded < len(raw) {
+				t.Errorf("uploaded %d bytes, want at least %d", uploaded, len(raw))
+			}
+		})
+	}
+}
+
+func TestSendCallsUploadDoneOnFailure(t *testing.T) {
+	srv := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"code":500,"message":"backend error"}}`, http.StatusInternalServerError)
+	}))
+	srv.UploadProgress = func(sent, total int64) {}
+	done := 0
+	srv.UploadDone = func() { done++ }
+
+	if _, err := srv.send(context.Background(), rawMessage(LargeMessageSize+1), ""); err == nil {
+		t.Fatal("send succeeded against a failing server")
+	}
+	if done != 1 {
+		t.Errorf("UploadDone called %d times, want 1", done)
+	}
+}
+
+func TestCanceledContext(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	called := false
+	srv := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"list", func() error { _, err := srv.ListMessages(ctx, 10, "", nil); return err }},
+		{"get", func() error { _, err := srv.GetMessage(ctx, "m1", true); return err }},
+		{"thread", func() error { _, err := srv.GetThread(ctx, "t1"); return err }},
+		{"batch get", func() error { _, err := srv.GetMessagesBatch(ctx, []string{"m1", "m2"}, false); return err }},
+		{"labels", func() error { _, err := srv.GetLabels(ctx); return err }},
+		{"modify", func() error { return srv.ArchiveMessage(ctx, "m1") }},
+		{"send", func() error { _, err := srv.SendRawMessage(ctx, rawMessage(100)); return err }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, context.Canceled) {
+				t.Errorf("err = %v, want context.Canceled", err)
+			}
+		})
+	}
+	if called {
+		t.Error("a request reached the server")
+	}
+}
+
+// pagedMailbox is a fake Gmail API holding messages "m1" to "m<total>". It
+// lists at most pageLimit per page, fewer than asked for as Gmail may, and
+// serves message fetches through fakeBatchServer.
+type pagedMailbox struct {
+	fakeBatchServer
+	total, pageLimit int
+	listCalls        int
+}
+
+func (f *pagedMailbox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/gmail/v1/users/me/messages" {
+		f.fakeBatchServer.ServeHTTP(w, r)
+		return
+	}
+	f.listCalls++
+	start, _ := strconv.Atoi(r.URL.Query().Get("pageToken"))
+	size, _ := strconv.Atoi(r.URL.Query().Get("maxResults"))
+	end := min(start+min(size, f.pageLimit), f.total)
+	resp := &gmail.ListMessagesResponse{}
+	for i := start; i < end; i++ {
+		resp.Messages = append(resp.Messages, &gmail.Message{Id: fmt.Sprintf("m%d", i+1)})
+	}
+	if end < f.total {
+		resp.NextPageToken = strconv.Itoa(end)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func TestListMessagesPage(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	tests := []struct {
+		name          string
+		maxResults    int64
+		pageToken     string
+		want          string
+		wantToken     string
+		wantListCalls int
+	}{
+		{name: "first page", maxResults: 3, want: "m1,m2,m3", wantToken: "3", wantListCalls: 2},
+		{name: "resumed", maxResults: 3, pageToken: "3", want: "m4,m5,m6", wantToken: "6", wantListCalls: 2},
+		{name: "last page is short", maxResults: 3, pageToken: "6", want: "m7", wantListCalls: 1},
+		{name: "everything", maxResults: 0, want: "m1,m2,m3,m4,m5,m6,m7", wantListCalls: 4},
+		{name: "more than there are", maxResults: 20, want: "m1,m2,m3,m4,m5,m6,m7", wantListCalls: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &pagedMailbox{total: 7, pageLimit: 2}
+			srv := newTestService(t, fake)
+
+			messages, token, err := srv.ListMessagesPage(context.Background(), tt.maxResults, "", nil, tt.pageToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, m := range messages {
+				ids = append(ids, m.ID)
+			}
+			if got := strings.Join(ids, ","); got != tt.want {
+				t.Errorf("messages = %s, want %s", got, tt.want)
+			}
+			if token != tt.wantToken {
+				t.Errorf("next page token = %q, want %q", token, tt.wantToken)
+			}
+			if fake.listCalls != tt.wantListCalls {
+				t.Errorf("fetched %d pages, want %d", fake.listCalls, tt.wantListCalls)
+			}
+		})
+	}
+}
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "multi-line style",
+			html: "<html><body><style type=\"text/css\">\n  body { color: red; }\n  .x { margin: 0 }\n</style><p>Hello</p></body></html>",
+			want: "Hello",
+		},
+		{
+			name: "multi-line script",
+			html: "<p>Hi</p><SCRIPT>\nvar x = 1;\nalert(x);\n</SCRIPT><p>there</p>",
+			want: "Hi\nthere",
+		},
+		{
+			name: "head and comments",
+			html: "<head>\n<title>Newsletter</title>\n</head><!-- tracking\npixel --><div>Body</div>",
+			want: "Body",
+		},
+		{
+			name: "entities",
+			html: "<p>Fish &amp; chips &eacute;t&eacute; &#8217;s &#x2014; done&nbsp;now</p>",
+			want: "Fish & chips été ’s — done now",
+		},
+		{
+			name: "breaks and blank lines",
+			html: "Line one<br>Line two<br/><br/><br/><br/>Line three",
+			want: "Line one\nLine two\n\nLine three",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := htmlToText(tt.html)
+			if got != tt.want {
+				t.Errorf("htmlToText = %q, want %q", got, tt.want)
+			}
+			for _, leak := range []string{"color", "margin", "alert", "tracking", "Newsletter"} {
+				if strings.Contains(got, leak) {
+					t.Errorf("output leaks %q: %q", leak, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"report.pdf", "report.pdf"},
+		{"../../etc/evil", "_.._etc_evil"},
+		{`..\..\windows\evil.exe`, `_.._windows_evil.exe`},
+		{"/etc/passwd", "_etc_passwd"},
+		{"..", "unnamed"},
+		{"", "unnamed"},
+		{"a\x00b\nc.txt", "a_b_c.txt"},
+		{"C:evil.txt", "C_evil.txt"},
+	}
+	for _, tt := range tests {
+		got := SanitizeFilename(tt.name)
+		if got != tt.want {
+			t.Errorf("SanitizeFilename(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+		if filepath.Base(got) != got {
+			t.Errorf("SanitizeFilename(%q) = %q, which isn't a bare file name", tt.name, got)
+		}
+	}
+}
+
+func TestDownloadAttachment(t *testing.T) {
+	srv := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"data":%q}`, base64.URLEncoding.EncodeToString([]byte("content")))
+	}))
+
+	tests := []struct {
+		name      string
+		existing  []string
+		filename  string
+		overwrite bool
+		want      string
+	}{
+		{name: "new file", filename: "report.pdf", want: "report.pdf"},
+		{name: "collision", existing: []string{"report.pdf"}, filename: "report.pdf", want: "report (1).pdf"},
+		{name: "second collision", existing: []string{"report.pdf", "report (1).pdf"}, filename: "report.pdf", want: "report (2).pdf"},
+		{name: "no extension", existing: []string{"README"}, filename: "README", want: "README (1)"},
+		{name: "overwrite", existing: []string{"report.pdf"}, filename: "report.pdf", overwrite: true, want: "report.pdf"},
+		{name: "path traversal", filename: "../../etc/evil", want: "_.._etc_evil"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.existing {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte("old"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			path, err := srv.DownloadAttachment(context.Background(), "m1", "a1", tt.filename, dir, tt.overwrite)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Join(dir, tt.want); path != want {
+				t.Errorf("saved to %s, want %s", path, want)
+			}
+			if data, _ := os.ReadFile(path); string(data) != "content" {
+				t.Errorf("saved %q, want the attachment", data)
+			}
+			for _, name := range tt.existing {
+				data, _ := os.ReadFile(filepath.Join(dir, name))
+				if name != tt.want && string(data) != "old" {
+					t.Errorf("%s was overwritten", name)
+				}
+			}
+		})
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		headers []*gmail.MessagePartHeader
+		want    string
+	}{
+		{[]*gmail.MessagePartHeader{{Name: "X-Priority", Value: "1"}}, "high"},
+		{[]*gmail.MessagePartHeader{{Name: "X-Priority", Value: "1 (Highest)"}}, "high"},
+		{[]*gmail.MessagePartHeader{{Name: "X-Priority", Value: "2"}}, "high"},
+		{[]*gmail.MessagePartHeader{{Name: "X-Priority", Value: "3"}}, "normal"},
+		{[]*gmail.MessagePartHeader{{Name: "x-priority", Value: "5 (Lowest)"}}, "low"},
+		{[]*gmail.MessagePartHeader{{Name: "Importance", Value: "high"}}, "high"},
+		{[]*gmail.MessagePartHeader{{Name: "Importance", Value: "High"}}, "high"},
+		{[]*gmail.MessagePartHeader{{Name: "Importance", Value: "low"}}, "low"},
+		{[]*gmail.MessagePartHeader{{Name: "Priority", Value: "urgent"}}, "high"},
+		{[]*gmail.MessagePartHeader{{Name: "Priority", Value: "non-urgent"}}, "low"},
+		{[]*gmail.MessagePartHeader{{Name: "X-Priority", Value: "bogus"}}, ""},
+		{[]*gmail.MessagePartHeader{{Name: "Subject", Value: "Hi"}}, ""},
+		// The first recognized header wins
+		{[]*gmail.MessagePartHeader{{Name: "X-Priority", Value: "1"}, {Name: "Importance", Value: "low"}}, "high"},
+		{[]*gmail.MessagePartHeader{{Name: "X-Priority", Value: "?"}, {Name: "Importance", Value: "high"}}, "high"},
+	}
+	for _, tt := range tests {
+		msg := parseMessage(&gmail.Message{Id: "m1", Payload: &gmail.MessagePart{Headers: tt.headers}}, false)
+		if msg.Priority != tt.want {
+			t.Errorf("priority for %s: %s = %q, want %q", tt.headers[0].Name, tt.headers[0].Value, msg.Priority, tt.want)
+		}
+	}
+}
+
+// draftRecorder is a fake Gmail API that serves one message's headers and
+// records the draft created from it
+type draftRecorder struct {
+	headers []*gmail.MessagePartHeader
+	draft   *gmail.Draft
+}
+
+func (f *draftRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		f.draft = &gmail.Draft{}
+		json.NewDecoder(r.Body).Decode(f.draft)
+		fmt.Fprint(w, `{"id":"d1"}`)
+		return
+	}
+	json.NewEncoder(w).Encode(&gmail.Message{Id: "m1", ThreadId: "t1", Payload: &gmail.MessagePart{Headers: f.headers}})
+}
+
+func TestCreateReplyDraft(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []*gmail.MessagePartHeader
+		cc      []string
+		want    []string // Header lines the draft must have
+		notWant []string
+	}{
+		{
+			name: "first reply",
+			headers: []*gmail.MessagePartHeader{
+				{Name: "From", Value: "ana@example.com"},
+				{Name: "Subject", Value: "Lunch"},
+				{Name: "Message-ID", Value: "<a1@example.com>"},
+			},
+			want: []string{
+				"To: ana@example.com",
+				"Subject: Re: Lunch",
+				"In-Reply-To: <a1@example.com>",
+				"References: <a1@example.com>",
+			},
+			notWant: []string{"Cc:"},
+		},
+		{
+			name: "reply further down a thread, with Cc",
+			headers: []*gmail.MessagePartHeader{
+				{Name: "From", Value: "bo@example.com"},
+				{Name: "Subject", Value: "RE: Lunch"},
+				{Name: "Message-Id", Value: "<b2@example.com>"},
+				{Name: "References", Value: "<a1@example.com>"},
+			},
+			cc: []string{"cy@example.com", "di@example.com"},
+			want: []string{
+				"To: bo@example.com",
+				"Cc: cy@example.com, di@example.com",
+				"Subject: RE: Lunch",
+				"In-Reply-To: <b2@example.com>",
+				"References: <a1@example.com> <b2@example.com>",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &draftRecorder{headers: tt.headers}
+			srv := newTestService(t, fake)
+
+			id, err := srv.CreateReplyDraft(context.Background(), "m1", "", "Sounds good", tt.cc, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id != "d1" {
+				t.Errorf("draft ID = %q, want d1", id)
+			}
+			if fake.draft.Message.ThreadId != "t1" {
+				t.Errorf("draft thread = %q, want the original's", fake.draft.Message.ThreadId)
+			}
+			raw, err := base64.URLEncoding.DecodeString(fake.draft.Message.Raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			header, body, _ := strings.Cut(string(raw), "\r\n\r\n")
+			lines := strings.Split(header, "\r\n")
+			for _, want := range tt.want {
+				if !slices.Contains(lines, want) {
+					t.Errorf("missing header %q in:\n%s", want, header)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(header, notWant) {
+					t.Errorf("unexpected %q in:\n%s", notWant, header)
+				}
+			}
+			if !strings.Contains(body, "Sounds good") {
+				t.Errorf("body %q is missing the reply", body)
+			}
+		})
+	}
+}
+
+func TestSendRawMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr string // "" when the message should be sent as-is
+	}{
+		{
+			name: "hand-crafted message",
+			raw: "From: me@example.com\r\nTo: ana@example.com\r\nSubject: Report\r\n" +
+				"Content-Type: text/plain; charset=utf-8\r\nX-Mailer: render-template\r\n\r\nNumbers attached.\r\n",
+		},
+		{name: "missing To", raw: "Subject: Report\r\n\r\nHi\r\n", wantErr: "missing To header"},
+		{name: "missing Subject", raw: "To: ana@example.com\r\n\r\nHi\r\n", wantErr: "missing Subject header"},
+		{name: "not a message", raw: "no headers here", wantErr: "invalid message"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent *gmail.Message
+			srv := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPost {
+					sent = &gmail.Message{}
+					json.NewDecoder(r.Body).Decode(sent)
+					fmt.Fprint(w, `{"id":"sent1"}`)
+					return
+				}
+				fmt.Fprint(w, `{"id":"sent1","threadId":"t1"}`)
+			}))
+
+			msg, err := srv.SendRawMessage(context.Background(), []byte(tt.raw))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				if sent != nil {
+					t.Error("sent an invalid message")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.ID != "sent1" {
+				t.Errorf("message ID = %q, want sent1", msg.ID)
+			}
+			// The message goes up byte for byte, headers and all
+			raw, err := base64.URLEncoding.DecodeString(sent.Raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(raw) != tt.raw {
+				t.Errorf("sent %q, want %q", raw, tt.raw)
+			}
+		})
+	}
+}
+
+// sentRaw decodes the RFC822 source of the message a fake server received
+func sentRaw(t *testing.T, sent *gmail.Message) []byte {
+	t.Helper()
+	if sent == nil {
+		t.Fatal("nothing was sent")
+	}
+	raw, err := base64.URLEncoding.DecodeString(sent.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// sendRecorder is a fake Gmail API that records the message sent to it
+type sendRecorder struct {
+	sent *gmail.Message
+}
+
+func (f *sendRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		f.sent = &gmail.Message{}
+		json.NewDecoder(r.Body).Decode(f.sent)
+	}
+	fmt.Fprint(w, `{"id":"sent1"}`)
+}
+
+func TestSendMessageInvite(t *testing.T) {
+	ics := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nMETHOD:REQUEST\r\nBEGIN:VEVENT\r\nUID:e1\r\nSUMMARY:Coffee\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	notes := filepath.Join(t.TempDir(), "agenda.txt")
+	if err := os.WriteFile(notes, []byte("1. Coffee"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		attachments []string
+		wantParts   []string // Media types of the parts, in order
+	}{
+		{"invite alone", nil, []string{"text/plain", "text/calendar"}},
+		{"invite with an attachment", []string{notes}, []string{"text/plain", "text/plain", "text/calendar"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &sendRecorder{}
+			srv := newTestService(t, fake)
+			_, err := srv.SendMessage(context.Background(), &OutgoingMessage{
+				To:          "ana@example.com, bo@example.com",
+				Subject:     "Invitation: Coffee",
+				Text:        "You're invited",
+				Attachments: tt.attachments,
+				Invite:      ics,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			msg, err := mail.ReadMessage(bytes.NewReader(sentRaw(t, fake.sent)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+			if err != nil || mediaType != "multipart/mixed" {
+				t.Fatalf("content type = %q, %v", msg.Header.Get("Content-Type"), err)
+			}
+			mr := multipart.NewReader(msg.Body, params["boundary"])
+			var parts []string
+			for {
+				part, err := mr.NextPart()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				partType, partParams, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+				parts = append(parts, partType)
+				if partType != "text/calendar" {
+					continue
+				}
+				if partParams["method"] != "REQUEST" {
+					t.Errorf("calendar part method = %q, want REQUEST", partParams["method"])
+				}
+				body, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, part))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !strings.Contains(string(body), "METHOD:REQUEST\r\n") {
+					t.Errorf("calendar part doesn't carry METHOD:REQUEST:\n%s", body)
+				}
+			}
+			if !slices.Equal(parts, tt.wantParts) {
+				t.Errorf("parts = %v, want %v", parts, tt.wantParts)
+			}
+		})
+	}
+}
+
+func TestBuildMIMEPriority(t *testing.T) {
+	tests := []struct {
+		priority string
+		want     map[string]string // "" for a header that must be absent
+		wantErr  bool
+	}{
+		{"high", map[string]string{"X-Priority": "1 (Highest)", "Importance": "High", "Priority": "urgent"}, false},
+		{"normal", map[string]string{"X-Priority": "3 (Normal)", "Importance": "Normal", "Priority": "normal"}, false},
+		{"low", map[string]string{"X-Priority": "5 (Lowest)", "Importance": "Low", "Priority": "non-urgent"}, false},
+		{"", map[string]string{"X-Priority": "", "Importance": "", "Priority": ""}, false},
+		{"urgent", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.priority, func(t *testing.T) {
+			raw, err := buildMIME(&OutgoingMessage{To: "ana@example.com", Subject: "Outage", Text: "Down", Priority: tt.priority})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			msg, err := mail.ReadMessage(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.want {
+				if got := msg.Header.Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildMIMEAlternative(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		html     string
+		wantText string
+	}{
+		{"text and HTML", "Hello, world", "<p>Hello, <b>world</b></p>", "Hello, world"},
+		{"text generated from HTML", "", "<p>Café at <b>noon</b></p>", "Café at noon"},
+		{"long lines survive quoted-printable", strings.Repeat("word ", 40), "<p>" + strings.Repeat("word ", 40) + "</p>", strings.Repeat("word ", 40)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := buildMIME(&OutgoingMessage{To: "ana@example.com", Subject: "Hi", Text: tt.text, HTML: tt.html})
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg, err := mail.ReadMessage(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+			if err != nil || mediaType != "multipart/alternative" {
+				t.Fatalf("content type = %q, %v", msg.Header.Get("Content-Type"), err)
+			}
+
+			// The reader undoes the quoted-printable encoding
+			mr := multipart.NewReader(msg.Body, params["boundary"])
+			var types, bodies []string
+			for {
+				part, err := mr.NextPart()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+				body, err := io.ReadAll(part)
+				if err != nil {
+					t.Fatal(err)
+				}
+				types = append(types, partType)
+				bodies = append(bodies, string(body))
+			}
+			if !slices.Equal(types, []string{"text/plain", "text/html"}) {
+				t.Fatalf("parts = %v, want text/plain then text/html", types)
+			}
+			if strings.TrimSpace(bodies[0]) != strings.TrimSpace(tt.wantText) {
+				t.Errorf("text part = %q, want %q", bodies[0], tt.wantText)
+			}
+			if bodies[1] != tt.html {
+				t.Errorf("HTML part = %q, want %q", bodies[1], tt.html)
+			}
+		})
+	}
+}
+
+// mailbox is a fake Gmail API that keeps sent messages, serving them back
+// with their attachments split out the way Gmail does
+type mailbox struct {
+	t        *testing.T
+	messages map[string]*gmail.Message
+	data     map[string][]byte // Attachment content by ID
+}
+
+func (f *mailbox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPost:
+		sent := &gmail.Message{}
+		json.NewDecoder(r.Body).Decode(sent)
+		id := fmt.Sprintf("m%d", len(f.messages)+1)
+		f.messages[id] = f.parse(id, sentRaw(f.t, sent))
+		fmt.Fprintf(w, `{"id":%q}`, id)
+	case strings.Contains(r.URL.Path, "/attachments/"):
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		fmt.Fprintf(w, `{"data":%q}`, base64.URLEncoding.EncodeToString(f.data[id]))
+	default:
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		json.NewEncoder(w).Encode(f.messages[id])
+	}
+}
+
+// parse splits a multipart/mixed message into Gmail's message parts
+func (f *mailbox) parse(id string, raw []byte) *gmail.Message {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	_, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	payload := &gmail.MessagePart{
+		MimeType: "multipart/mixed",
+		Headers:  []*gmail.MessagePartHeader{{Name: "Subject", Value: msg.Header.Get("Subject")}},
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for i := 1; ; i++ {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.t.Fatal(err)
+		}
+		if part.FileName() == "" {
+			body, _ := io.ReadAll(part)
+			payload.Parts = append(payload.Parts, &gmail.MessagePart{
+				MimeType: "text/plain",
+				Body:     &gmail.MessagePartBody{Data: base64.URLEncoding.EncodeToString(body)},
+			})
+			continue
+		}
+		data, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, part))
+		if err != nil {
+			f.t.Fatal(err)
+		}
+		attID := fmt.Sprintf("%s-a%d", id, i)
+		f.data[attID] = data
+		mediaType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		payload.Parts = append(payload.Parts, &gmail.MessagePart{
+			MimeType: mediaType,
+			Filename: part.FileName(),
+			Body:     &gmail.MessagePartBody{AttachmentId: attID, Size: int64(len(data))},
+		})
+	}
+	return &gmail.Message{Id: id, ThreadId: id, Payload: payload}
+}
+
+func TestAttachmentsRoundTrip(t *testing.T) {
+	binary := make([]byte, 100<<10)
+	for i := range binary {
+		binary[i] = byte(i * 7)
+	}
+	tests := []struct {
+		name  string
+		files map[string][]byte
+	}{
+		{"text and binary", map[string][]byte{"notes.txt": []byte("line one\r\nline two\n"), "photo.jpg": binary}},
+		{"empty file", map[string][]byte{"empty.csv": nil, "report.pdf": []byte("%PDF-1.4")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var paths []string
+			for name, data := range tt.files {
+				path := filepath.Join(dir, name)
+				if err := os.WriteFile(path, data, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				paths = append(paths, path)
+			}
+			fake := &mailbox{t: t, messages: map[string]*gmail.Message{}, data: map[string][]byte{}}
+			srv := newTestService(t, fake)
+
+			sent, err := srv.SendMessage(context.Background(), &OutgoingMessage{
+				To: "ana@example.com", Subject: "Files", Text: "Attached", Attachments: paths,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := srv.GetMessage(context.Background(), sent.ID, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got.Attachments) != len(tt.files) {
+				t.Fatalf("message has %d attachments, want %d", len(got.Attachments), len(tt.files))
+			}
+
+			outDir := filepath.Join(dir, "downloads")
+			for _, att := range got.Attachments {
+				path, err := srv.DownloadAttachment(context.Background(), got.ID, att.ID, att.Filename, outDir, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(data, tt.files[att.Filename]) {
+					t.Errorf("%s came back with %d bytes that differ from the %d sent", att.Filename, len(data), len(tt.files[att.Filename]))
+				}
+			}
+		})
+	}
+}
+
+func TestGetHeaders(t *testing.T) {
+	tests := []struct {
+		name      string
+		headers   [][2]string
+		wantSpam  string
+		wantCount int
+	}{
+		{
+			name: "spam status present",
+			headers: [][2]string{
+				{"Received", "from mx.example.com"},
+				{"X-Spam-Status", "No, score=-0.1 required=5.0"},
+				{"Subject", "Hi"},
+				{"Received", "from relay.example.com"},
+			},
+			wantSpam:  "No, score=-0.1 required=5.0",
+			wantCount: 4,
+		},
+		{
+			name:      "spam status absent",
+			headers:   [][2]string{{"From", "ana@example.com"}, {"Subject", "Hi"}},
+			wantCount: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var format string
+			srv := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				format = r.URL.Query().Get("format")
+				payload := &gmail.MessagePart{}
+				for _, h := range tt.headers {
+					payload.Headers = append(payload.Headers, &gmail.MessagePartHeader{Name: h[0], Value: h[1]})
+				}
+				json.NewEncoder(w).Encode(&gmail.Message{Id: "m1", Payload: payload})
+			}))
+
+			headers, err := srv.GetHeaders(context.Background(), "m1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format != "metadata" {
+				t.Errorf("format = %q, want metadata", format)
+			}
+			if len(headers) != tt.wantCount {
+				t.Fatalf("got %d headers, want %d, including repeats", len(headers), tt.wantCount)
+			}
+			for i, h := range headers {
+				if h.Name != tt.headers[i][0] || h.Value != tt.headers[i][1] {
+					t.Errorf("header %d = %s: %s, want %s: %s", i, h.Name, h.Value, tt.headers[i][0], tt.headers[i][1])
+				}
+			}
+			var spam string
+			for _, h := range headers {
+				if h.Name == "X-Spam-Status" {
+					spam = h.Value
+				}
+			}
+			if spam != tt.wantSpam {
+				t.Errorf("X-Spam-Status = %q, want %q", spam, tt.wantSpam)
+			}
+		})
+	}
+}
+
+func TestListLabelsDetailed(t *testing.T) {
+	full := map[string]*gmail.Label{
+		"INBOX": {Id: "INBOX", Name: "INBOX", Type: "system", MessagesTotal: 1200, MessagesUnread: 7},
+		"SPAM":  {Id: "SPAM", Name: "SPAM", Type: "system", MessagesTotal: 3},
+		"Label_1": {Id: "Label_1", Name: "Receipts", Type: "user", MessagesTotal: 42, MessagesUnread: 2,
+			Color: &gmail.LabelColor{BackgroundColor: "#16a765", TextColor: "#ffffff"}},
+	}
+	var gets []string
+	srv := newTestService(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/labels") {
+			// The list leaves out counts
+			resp := &gmail.ListLabelsResponse{}
+			for _, id := range []string{"INBOX", "SPAM", "Label_1"} {
+				resp.Labels = append(resp.Labels, &gmail.Label{Id: id, Name: full[id].Name, Type: full[id].Type})
+			}
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		gets = append(gets, id)
+		json.NewEncoder(w).Encode(full[id])
+	}))
+
+	labels, err := srv.ListLabelsDetailed(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Label{
+		{ID: "INBOX", Name: "INBOX", Type: "system", MessagesTotal: 1200, MessagesUnread: 7},
+		{ID: "SPAM", Name: "SPAM", Type: "system", MessagesTotal: 3},
+		{ID: "Label_1", Name: "Receipts", Type: "user", MessagesTotal: 42, MessagesUnread: 2, BackgroundColor: "#16a765", TextColor: "#ffffff"},
+	}
+	if len(labels) != len(want) {
+		t.Fatalf("got %d labels, want %d", len(labels), len(want))
+	}
+	for i, l := range labels {
+		if *l != want[i] {
+			t.Errorf("label %d = %+v, want %+v", i, *l, want[i])
+		}
+	}
+	if strings.Join(gets, ",") != "INBOX,SPAM,Label_1" {
+		t.Errorf("fetched labels %v, want each one for its counts", gets)
+	}
+}
@@ -0,0 +1,44 @@
+package gmail
+
+import (
+	"mime"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+)
+
+// encodeHeader returns a header value as RFC 2047 encoded-words if it
+// contains non-ASCII characters, or unchanged if it doesn't. Mostly
+// non-ASCII text uses the more compact B encoding.
+func encodeHeader(value string) string {
+	nonASCII := 0
+	for _, r := range value {
+		if r >= utf8.RuneSelf {
+			nonASCII++
+		}
+	}
+	if nonASCII == 0 {
+		return value
+	}
+	if nonASCII*2 > utf8.RuneCountInString(value) {
+		return mime.BEncoding.Encode("utf-8", value)
+	}
+	return mime.QEncoding.Encode("utf-8", value)
+}
+
+// encodeAddresses encodes non-ASCII display names in a comma-separated
+// address list. Lists that don't parse are returned unchanged.
+func encodeAddresses(list string) string {
+	if encodeHeader(list) == list {
+		return list
+	}
+	addrs, err := mail.ParseAddressList(list)
+	if err != nil {
+		return list
+	}
+	formatted := make([]string, len(addrs))
+	for i, addr := range addrs {
+		formatted[i] = addr.String()
+	}
+	return strings.Join(formatted, ", ")
+}
@@ -0,0 +1,85 @@
+package gmail
+
+import (
+	"mime"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestEncodeHeader(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		wantPrefix string // "" when the value should be left as is
+	}{
+		{"ASCII", "Quarterly report", ""},
+		{"mostly ASCII", "Café meeting notes", "=?utf-8?q?"},
+		{"mostly not ASCII", "日本語の件名", "=?utf-8?b?"},
+		{"emoji", "Launch 🚀", "=?utf-8?q?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := encodeHeader(tt.value)
+			if tt.wantPrefix == "" {
+				if got != tt.value {
+					t.Errorf("encodeHeader(%q) = %q, want it unchanged", tt.value, got)
+				}
+				return
+			}
+			if !strings.HasPrefix(got, tt.wantPrefix) {
+				t.Errorf("encodeHeader(%q) = %q, want a %s encoded word", tt.value, got, tt.wantPrefix)
+			}
+			if strings.ContainsFunc(got, func(r rune) bool { return r > 127 }) {
+				t.Fatalf("encodeHeader(%q) = %q, which isn't ASCII", tt.value, got)
+			}
+			decoded, err := new(mime.WordDecoder).DecodeHeader(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decoded != tt.value {
+				t.Errorf("decoded %q, want %q", decoded, tt.value)
+			}
+		})
+	}
+}
+
+func TestEncodeAddresses(t *testing.T) {
+	tests := []struct {
+		name      string
+		list      string
+		want      string   // Exact output; "" to check only the decoded names
+		wantNames []string // Display names after decoding
+	}{
+		{"ASCII", "Ana <ana@example.com>, bo@example.com", "Ana <ana@example.com>, bo@example.com", nil},
+		{"non-ASCII name", "José Núñez <jose@example.com>", "", []string{"José Núñez"}},
+		{"mixed list", "Zoë <zoe@example.com>, Bo <bo@example.com>, 李雷 <li@example.com>", "", []string{"Zoë", "Bo", "李雷"}},
+		{"doesn't parse", "José <jose@example.com", "José <jose@example.com", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := encodeAddresses(tt.list)
+			if tt.want != "" {
+				if got != tt.want {
+					t.Errorf("encodeAddresses(%q) = %q, want %q", tt.list, got, tt.want)
+				}
+				return
+			}
+			if strings.ContainsFunc(got, func(r rune) bool { return r > 127 }) {
+				t.Errorf("encodeAddresses(%q) = %q, which isn't ASCII", tt.list, got)
+			}
+			addrs, err := mail.ParseAddressList(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(addrs) != len(tt.wantNames) {
+				t.Fatalf("got %d addresses, want %d", len(addrs), len(tt.wantNames))
+			}
+			for i, addr := range addrs {
+				if addr.Name != tt.wantNames[i] {
+					t.Errorf("name %d = %q, want %q", i, addr.Name, tt.wantNames[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package gmail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// InlineImage is an image embedded in an HTML body, which refers to it as
+// cid:ContentID
+type InlineImage struct {
+	ContentID string
+	Filename  string
+	MimeType  string
+	Data      []byte
+}
+
+// LoadInlineImage reads an image file to embed under the given Content-ID
+func LoadInlineImage(contentID, path string) (InlineImage, error) {
+	contentID = strings.TrimPrefix(contentID, "cid:")
+	if contentID == "" {
+		return InlineImage{}, fmt.Errorf("empty Content-ID for %s", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return InlineImage{}, fmt.Errorf("failed to read inline image: %w", err)
+	}
+	mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	if !strings.HasPrefix(mimeType, "image/") {
+		return InlineImage{}, fmt.Errorf("%s is not an image (%s)", path, mimeType)
+	}
+	return InlineImage{
+		ContentID: contentID,
+		Filename:  filepath.Base(path),
+		MimeType:  mimeType,
+		Data:      data,
+	}, nil
+}
+
+var cidRefPattern = regexp.MustCompile(`(?i)cid:([^"'\s)>]+)`)
+
+// ReferencedCIDs returns the Content-IDs an HTML body refers to, in order of
+// first appearance
+func ReferencedCIDs(html string) []string {
+	seen := make(map[string]bool)
+	var cids []string
+	for _, m := range cidRefPattern.FindAllStringSubmatch(html, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			cids = append(cids, m[1])
+		}
+	}
+	return cids
+}
+
+// CheckInlineImages returns an error if the HTML body refers to a cid: that
+// no image provides, or if an image isn't referenced at all
+func CheckInlineImages(html string, images []InlineImage) error {
+	provided := make(map[string]bool, len(images))
+	for _, img := range images {
+		provided[img.ContentID] = true
+	}
+	referenced := make(map[string]bool)
+	for _, cid := range ReferencedCIDs(html) {
+		referenced[cid] = true
+		if !provided[cid] {
+			return fmt.Errorf("HTML body refers to cid:%s but no inline image provides it", cid)
+		}
+	}
+	for _, img := range images {
+		if !referenced[img.ContentID] {
+			return fmt.Errorf("inline image cid:%s is not referenced by the HTML body", img.ContentID)
+		}
+	}
+	return nil
+}
+
+// relatedPart returns the Content-Type and body of a multipart/related
+// entity: the multipart/alternative text and HTML, followed by the images the
+// HTML refers to by Content-ID
+func relatedPart(text, html string, images []InlineImage) (string, []byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	altType, altBody, err := alternativePart(text, html)
+	if err != nil {
+		return "", nil, err
+	}
+	pw, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {altType}})
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := pw.Write(altBody); err != nil {
+		return "", nil, err
+	}
+
+	for _, img := range images {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {contentTypeWithName(img.MimeType, img.Filename)},
+			"Content-Disposition":       {mime.FormatMediaType("inline", map[string]string{"filename": img.Filename})},
+			"Content-ID":                {"<" + img.ContentID + ">"},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return "", nil, err
+		}
+		if _, err := pw.Write([]byte(wrapBase64(base64.StdEncoding.EncodeToString(img.Data)))); err != nil {
+			return "", nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return "", nil, err
+	}
+	return `multipart/related; type="multipart/alternative"; boundary=` + mw.Boundary(), body.Bytes(), nil
+}
@@ -0,0 +1,111 @@
+package gmail
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckInlineImages(t *testing.T) {
+	logo := InlineImage{ContentID: "logo"}
+	chart := InlineImage{ContentID: "chart"}
+	tests := []struct {
+		name    string
+		html    string
+		images  []InlineImage
+		wantErr string
+	}{
+		{"all matched", `<img src="cid:logo"><img src='cid:chart'>`, []InlineImage{logo, chart}, ""},
+		{"referenced twice", `<img src="cid:logo"><img src="cid:logo">`, []InlineImage{logo}, ""},
+		{"no images", `<p>Hi</p>`, nil, ""},
+		{"missing image", `<img src="cid:logo"><img src="cid:chart">`, []InlineImage{logo}, "cid:chart but no inline image"},
+		{"unused image", `<img src="cid:logo">`, []InlineImage{logo, chart}, "cid:chart is not referenced"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckInlineImages(tt.html, tt.images)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRelatedPart(t *testing.T) {
+	dir := t.TempDir()
+	png := []byte("\x89PNG\r\n\x1a\n fake image")
+	for _, name := range []string{"logo.png", "chart.png"} {
+		if err := os.WriteFile(filepath.Join(dir, name), png, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logo, err := LoadInlineImage("cid:logo", filepath.Join(dir, "logo.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chart, err := LoadInlineImage("chart", filepath.Join(dir, "chart.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := `<p>Hi</p><img src="cid:logo"><img src="cid:chart">`
+	if err := CheckInlineImages(html, []InlineImage{logo, chart}); err != nil {
+		t.Fatal(err)
+	}
+
+	contentType, body, err := relatedPart("Hi", html, []InlineImage{logo, chart})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mediaType != "multipart/related" || params["type"] != "multipart/alternative" {
+		t.Fatalf("content type = %q", contentType)
+	}
+
+	// The first part holds the text and HTML; the rest are the images, each
+	// under the Content-ID the HTML refers to
+	tests := []struct {
+		wantType      string
+		wantContentID string
+	}{
+		{"multipart/alternative", ""},
+		{"image/png", "<logo>"},
+		{"image/png", "<chart>"},
+	}
+	mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for i, tt := range tests {
+		part, err := mr.NextPart()
+		if err != nil {
+			t.Fatalf("part %d: %v", i, err)
+		}
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if partType != tt.wantType {
+			t.Errorf("part %d type = %q, want %q", i, partType, tt.wantType)
+		}
+		if got := part.Header.Get("Content-ID"); got != tt.wantContentID {
+			t.Errorf("part %d Content-ID = %q, want %q", i, got, tt.wantContentID)
+		}
+		if tt.wantContentID != "" {
+			disposition, _, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+			if disposition != "inline" {
+				t.Errorf("part %d disposition = %q, want inline", i, disposition)
+			}
+		}
+	}
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Errorf("extra part after the images: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package gmail
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Link is a hyperlink found in a message body
+type Link struct {
+	Text string
+	Href string
+}
+
+// urlPattern matches bare http(s) URLs in plain text
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"'()]+`)
+
+// ExtractLinks returns the distinct links in a message, in order of first
+// appearance. Anchors in the HTML body are used when there is one, keeping
+// their text; otherwise URLs are scanned out of the plain-text body.
+func ExtractLinks(textBody, htmlBody string) []Link {
+	var links []Link
+	index := make(map[string]int)
+	add := func(href, text string) {
+		if i, ok := index[href]; ok {
+			if links[i].Text == "" {
+				links[i].Text = text
+			}
+			return
+		}
+		index[href] = len(links)
+		links = append(links, Link{Text: text, Href: href})
+	}
+
+	if htmlBody != "" {
+		doc, err := html.Parse(strings.NewReader(htmlBody))
+		if err == nil {
+			var walk func(n *html.Node)
+			walk = func(n *html.Node) {
+				if n.Type == html.ElementNode && n.Data == "a" {
+					for _, a := range n.Attr {
+						if a.Key == "href" && isWebLink(a.Val) {
+							add(strings.TrimSpace(a.Val), nodeText(n))
+						}
+					}
+				}
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					walk(c)
+				}
+			}
+			walk(doc)
+			return links
+		}
+	}
+
+	for _, u := range urlPattern.FindAllString(textBody, -1) {
+		add(strings.TrimRight(u, ".,;:!?"), "")
+	}
+	return links
+}
+
+// isWebLink reports whether href points at an http(s) or mailto URL
+func isWebLink(href string) bool {
+	href = strings.ToLower(strings.TrimSpace(href))
+	return strings.HasPrefix(href, "http://") ||
+		strings.HasPrefix(href, "https://") ||
+		strings.HasPrefix(href, "mailto:")
+}
+
+// nodeText returns the whitespace-collapsed text content of a node
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteString(" ")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
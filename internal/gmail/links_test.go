@@ -0,0 +1,66 @@
+package gmail
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExtractLinks(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		html string
+		want []Link
+	}{
+		{
+			name: "anchors keep their text",
+			html: `<p>Please <a href="https://example.com/confirm?t=1">confirm
+				your <b>address</b></a> or <a href="mailto:help@example.com">write to us</a>.</p>`,
+			want: []Link{
+				{Text: "confirm your address", Href: "https://example.com/confirm?t=1"},
+				{Text: "write to us", Href: "mailto:help@example.com"},
+			},
+		},
+		{
+			name: "duplicate anchors are listed once",
+			html: `<a href="https://example.com/a"><img src="logo.png"></a>
+				<a href="https://example.com/b">B</a>
+				<a href="https://example.com/a">Open A</a>`,
+			want: []Link{
+				{Text: "Open A", Href: "https://example.com/a"},
+				{Text: "B", Href: "https://example.com/b"},
+			},
+		},
+		{
+			name: "non-web anchors are skipped",
+			html: `<a href="#top">Top</a><a href="javascript:void(0)">JS</a><a href=" https://example.com ">Site</a>`,
+			want: []Link{{Text: "Site", Href: "https://example.com"}},
+		},
+		{
+			name: "HTML body wins over text body",
+			text: "See https://example.com/text",
+			html: `<a href="https://example.com/html">here</a>`,
+			want: []Link{{Text: "here", Href: "https://example.com/html"}},
+		},
+		{
+			name: "bare URLs in text",
+			text: "Join at https://meet.example.com/abc-defg. Notes (http://example.com/notes), and again https://meet.example.com/abc-defg!",
+			want: []Link{
+				{Href: "https://meet.example.com/abc-defg"},
+				{Href: "http://example.com/notes"},
+			},
+		},
+		{
+			name: "no links",
+			text: "Nothing to click here",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ExtractLinks(tt.text, tt.html)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ExtractLinks() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package gmail
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/mail"
+	"regexp"
+	"time"
+)
+
+// mboxFromLine matches body lines that need escaping in mboxrd format:
+// "From " preceded by any number of '>'
+var mboxFromLine = regexp.MustCompile(`^>*From `)
+
+// WriteMbox appends one message to an mbox in mboxrd format: a "From "
+// separator line naming the sender and date, the message with line endings
+// normalized to LF and "From " lines escaped with '>', then a blank line
+func WriteMbox(w io.Writer, from string, date time.Time, raw []byte) error {
+	sender := "MAILER-DAEMON"
+	if addr, err := mail.ParseAddress(from); err == nil && addr.Address != "" {
+		sender = addr.Address
+	}
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "From %s %s\n", sender, date.UTC().Format("Mon Jan _2 15:04:05 2006"))
+
+	raw = bytes.ReplaceAll(raw, []byte("\r\n"), []byte("\n"))
+	raw = bytes.TrimRight(raw, "\n")
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		if mboxFromLine.Match(line) {
+			bw.WriteByte('>')
+		}
+		bw.Write(line)
+		bw.WriteByte('\n')
+	}
+	bw.WriteByte('\n')
+	return bw.Flush()
+}
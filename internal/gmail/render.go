@@ -0,0 +1,228 @@
+package gmail
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// RenderHTML converts an HTML body to readable plain text for the terminal.
+// Unlike the plain-text fallback, it keeps the document's structure:
+// paragraphs and headings are separated by blank lines, list items get
+// bullets or numbers, links are shown as "text (url)", and blockquotes are
+// prefixed with "> ".
+func RenderHTML(htmlBody string) string {
+	doc, err := html.Parse(strings.NewReader(htmlBody))
+	if err != nil {
+		return htmlToText(htmlBody)
+	}
+	r := &textRenderer{}
+	r.walk(doc)
+	return cleanRendered(r.b.String())
+}
+
+// textRenderer accumulates the text of an HTML tree
+type textRenderer struct {
+	b     strings.Builder
+	lists []int // Next item number for each enclosing list; 0 for bullets
+	pre   int   // Depth inside <pre>, where whitespace is kept
+}
+
+// blockElements start and end on their own lines
+var blockElements = map[string]bool{
+	"div": true, "section": true, "article": true, "header": true, "footer": true,
+	"nav": true, "main": true, "aside": true, "table": true, "tr": true,
+	"ul": true, "ol": true, "dl": true, "dt": true, "dd": true, "form": true,
+	"center": true, "address": true, "figure": true, "figcaption": true,
+}
+
+// paragraphElements are separated from their surroundings by a blank line
+var paragraphElements = map[string]bool{
+	"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// skippedElements have no readable content
+var skippedElements = map[string]bool{
+	"head": true, "script": true, "style": true, "noscript": true, "template": true,
+	"svg": true,
+}
+
+// whitespace matches runs of HTML whitespace, which render as one space
+var whitespace = regexp.MustCompile(`[ \t\r\n\f]+`)
+
+func (r *textRenderer) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		r.text(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		r.children(n)
+		return
+	}
+
+	tag := n.Data
+	switch {
+	case skippedElements[tag]:
+	case tag == "br":
+		r.b.WriteString("\n")
+	case tag == "hr":
+		r.blankLine()
+		r.b.WriteString("---")
+		r.blankLine()
+	case tag == "img":
+		if alt := strings.TrimSpace(attr(n, "alt")); alt != "" {
+			r.text("[" + alt + "]")
+		}
+	case tag == "a":
+		r.link(n)
+	case tag == "ul" || tag == "ol":
+		next := 0
+		if tag == "ol" {
+			next = 1
+		}
+		r.newline()
+		r.lists = append(r.lists, next)
+		r.children(n)
+		r.lists = r.lists[:len(r.lists)-1]
+		r.newline()
+	case tag == "li":
+		r.listItem(n)
+	case tag == "blockquote":
+		r.blockquote(n)
+	case tag == "td" || tag == "th":
+		r.children(n)
+		r.b.WriteString("  ")
+	case tag == "pre":
+		r.blankLine()
+		r.pre++
+		r.children(n)
+		r.pre--
+		r.blankLine()
+	case paragraphElements[tag]:
+		r.blankLine()
+		r.children(n)
+		r.blankLine()
+	case blockElements[tag]:
+		r.newline()
+		r.children(n)
+		r.newline()
+	default:
+		r.children(n)
+	}
+}
+
+func (r *textRenderer) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.walk(c)
+	}
+}
+
+// text writes a text node, collapsing whitespace outside <pre>
+func (r *textRenderer) text(s string) {
+	if r.pre > 0 {
+		r.b.WriteString(s)
+		return
+	}
+	s = whitespace.ReplaceAllString(s, " ")
+	if r.atLineStart() || strings.HasSuffix(r.b.String(), " ") {
+		s = strings.TrimLeft(s, " ")
+	}
+	r.b.WriteString(s)
+}
+
+// link writes an anchor's text followed by its URL, unless the text is the URL
+func (r *textRenderer) link(n *html.Node) {
+	start := r.b.Len()
+	r.children(n)
+	href := strings.TrimSpace(attr(n, "href"))
+	if !isWebLink(href) {
+		return
+	}
+	text := strings.TrimSpace(r.b.String()[start:])
+	switch {
+	case text == "":
+		r.text(href)
+	case text == href || "mailto:"+text == href:
+	default:
+		r.b.WriteString(" (" + href + ")")
+	}
+}
+
+// listItem writes an item with a bullet or its number, indented by depth
+func (r *textRenderer) listItem(n *html.Node) {
+	r.newline()
+	marker := "- "
+	if depth := len(r.lists); depth > 0 {
+		r.b.WriteString(strings.Repeat("  ", depth-1))
+		if next := r.lists[depth-1]; next > 0 {
+			marker = strconv.Itoa(next) + ". "
+			r.lists[depth-1]++
+		}
+	}
+	r.b.WriteString(marker)
+	r.children(n)
+	r.newline()
+}
+
+// blockquote renders its contents separately and prefixes each line
+func (r *textRenderer) blockquote(n *html.Node) {
+	inner := &textRenderer{lists: r.lists, pre: r.pre}
+	inner.children(n)
+	quoted := cleanRendered(inner.b.String())
+	if quoted == "" {
+		return
+	}
+	r.blankLine()
+	for _, line := range strings.Split(quoted, "\n") {
+		r.b.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+	}
+	r.blankLine()
+}
+
+func (r *textRenderer) atLineStart() bool {
+	s := r.b.String()
+	return s == "" || strings.HasSuffix(s, "\n")
+}
+
+// newline ends the current line, if it has any text
+func (r *textRenderer) newline() {
+	if !r.atLineStart() {
+		r.b.WriteString("\n")
+	}
+}
+
+// blankLine ends the current line and leaves an empty one after it
+func (r *textRenderer) blankLine() {
+	r.newline()
+	if s := r.b.String(); s != "" && !strings.HasSuffix(s, "\n\n") {
+		r.b.WriteString("\n")
+	}
+}
+
+// blankLines matches runs of more than one blank line
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// cleanRendered trims trailing spaces from each line and collapses runs of
+// blank lines
+func cleanRendered(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	s = strings.Join(lines, "\n")
+	s = blankLines.ReplaceAllString(s, "\n\n")
+	return strings.TrimSpace(s)
+}
+
+// attr returns the value of an element's attribute, or ""
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
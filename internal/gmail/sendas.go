@@ -0,0 +1,60 @@
+package gmail
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// SendAs is an address the user can send mail from: their primary address
+// or an alias configured in Gmail's settings
+type SendAs struct {
+	Email       string
+	DisplayName string
+	IsPrimary   bool
+	IsDefault   bool // Gmail's default From address
+	Verified    bool // Aliases must be verified before they can be used
+}
+
+// ListSendAs returns the user's send-as addresses
+func (s *Service) ListSendAs(ctx context.Context) ([]*SendAs, error) {
+	resp, err := s.srv.Users.Settings.SendAs.List("me").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list send-as addresses: %w", err)
+	}
+
+	aliases := make([]*SendAs, 0, len(resp.SendAs))
+	for _, a := range resp.SendAs {
+		aliases = append(aliases, &SendAs{
+			Email:       a.SendAsEmail,
+			DisplayName: a.DisplayName,
+			IsPrimary:   a.IsPrimary,
+			IsDefault:   a.IsDefault,
+			Verified:    a.IsPrimary || a.VerificationStatus == "accepted",
+		})
+	}
+	return aliases, nil
+}
+
+// FromHeader validates email against the user's verified send-as addresses
+// and returns the From header for it, including the alias's display name
+func (s *Service) FromHeader(ctx context.Context, email string) (string, error) {
+	aliases, err := s.ListSendAs(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var valid []string
+	for _, a := range aliases {
+		if !a.Verified {
+			continue
+		}
+		if strings.EqualFold(a.Email, email) {
+			addr := mail.Address{Name: a.DisplayName, Address: a.Email}
+			return addr.String(), nil
+		}
+		valid = append(valid, a.Email)
+	}
+	return "", fmt.Errorf("%s isn't a verified send-as address; use one of: %s", email, strings.Join(valid, ", "))
+}
@@ -0,0 +1,77 @@
+package gmail
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// Vacation is the vacation responder (auto-reply) setting
+type Vacation struct {
+	Enabled      bool
+	Subject      string
+	Body         string
+	HTMLBody     string // Sent instead of Body when set
+	ContactsOnly bool   // Only reply to people in the user's contacts
+	DomainOnly   bool   // Only reply to people in the user's domain (Workspace)
+	Start        time.Time
+	End          time.Time // Replies are sent to mail received before End
+}
+
+// GetVacation returns the vacation responder setting
+func (s *Service) GetVacation(ctx context.Context) (*Vacation, error) {
+	v, err := s.srv.Users.Settings.GetVacation("me").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vacation responder: %w", err)
+	}
+
+	vacation := &Vacation{
+		Enabled:      v.EnableAutoReply,
+		Subject:      v.ResponseSubject,
+		Body:         v.ResponseBodyPlainText,
+		HTMLBody:     v.ResponseBodyHtml,
+		ContactsOnly: v.RestrictToContacts,
+		DomainOnly:   v.RestrictToDomain,
+	}
+	if v.StartTime != 0 {
+		vacation.Start = time.UnixMilli(v.StartTime)
+	}
+	if v.EndTime != 0 {
+		vacation.End = time.UnixMilli(v.EndTime)
+	}
+	return vacation, nil
+}
+
+// SetVacation replaces the vacation responder setting. A zero Start or End
+// leaves that end of the window open.
+func (s *Service) SetVacation(ctx context.Context, vacation *Vacation) error {
+	if !vacation.Start.IsZero() && !vacation.End.IsZero() && !vacation.End.After(vacation.Start) {
+		return fmt.Errorf("vacation end must be after its start")
+	}
+
+	v := &gmail.VacationSettings{
+		EnableAutoReply:       vacation.Enabled,
+		ResponseSubject:       vacation.Subject,
+		ResponseBodyPlainText: vacation.Body,
+		ResponseBodyHtml:      vacation.HTMLBody,
+		RestrictToContacts:    vacation.ContactsOnly,
+		RestrictToDomain:      vacation.DomainOnly,
+		// The update replaces the whole setting, so false and empty values
+		// have to be sent too
+		ForceSendFields: []string{"EnableAutoReply", "ResponseSubject", "ResponseBodyPlainText",
+			"ResponseBodyHtml", "RestrictToContacts", "RestrictToDomain"},
+	}
+	if !vacation.Start.IsZero() {
+		v.StartTime = vacation.Start.UnixMilli()
+	}
+	if !vacation.End.IsZero() {
+		v.EndTime = vacation.End.UnixMilli()
+	}
+
+	if _, err := s.srv.Users.Settings.UpdateVacation("me", v).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("failed to update vacation responder: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/tasks/v1"
+)
+
+// DefaultList refers to the user's default task list
+const DefaultList = "@default"
+
+// Service wraps the Google Tasks API service
+type Service struct {
+	srv *tasks.Service
+}
+
+// Task represents a simplified task
+type Task struct {
+	ID        string
+	ListID    string
+	Title     string
+	Notes     string
+	Due       time.Time // Local midnight of the due date; zero if none
+	Completed bool
+}
+
+// TaskList represents a task list
+type TaskList struct {
+	ID    string
+	Title string
+}
+
+// NewService creates a new Tasks service
+func NewService(ctx context.Context, client *http.Client) (*Service, error) {
+	srv, err := tasks.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Tasks service: %w", err)
+	}
+	return &Service{srv: srv}, nil
+}
+
+// ListTaskLists returns the user's task lists
+func (s *Service) ListTaskLists(ctx context.Context) ([]*TaskList, error) {
+	var lists []*TaskList
+	err := s.srv.Tasklists.List().MaxResults(100).Pages(ctx, func(resp *tasks.TaskLists) error {
+		for _, l := range resp.Items {
+			lists = append(lists, &TaskList{ID: l.Id, Title: l.Title})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list task lists: %w", err)
+	}
+	return lists, nil
+}
+
+// ListTasks returns the tasks in a list, ordered by due date with undated
+// tasks last. Completed tasks are included only if showCompleted is set.
+func (s *Service) ListTasks(ctx context.Context, listID string, showCompleted bool) ([]*Task, error) {
+	req := s.srv.Tasks.List(listID).
+		ShowCompleted(showCompleted).
+		ShowHidden(showCompleted).
+		MaxResults(100)
+	return s.collect(ctx, req, listID)
+}
+
+// DueBy returns the incomplete tasks in a list due on or before the given
+// day, including overdue ones
+func (s *Service) DueBy(ctx context.Context, listID string, day time.Time) ([]*Task, error) {
+	// Due dates are stored as midnight UTC, so compare by calendar date
+	y, m, d := day.Date()
+	dueMax := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	req := s.srv.Tasks.List(listID).
+		ShowCompleted(false).
+		DueMax(dueMax.Format(time.RFC3339)).
+		MaxResults(100)
+	return s.collect(ctx, req, listID)
+}
+
+// collect runs a list request across all pages and sorts the result
+func (s *Service) collect(ctx context.Context, req *tasks.TasksListCall, listID string) ([]*Task, error) {
+	var result []*Task
+	err := req.Pages(ctx, func(resp *tasks.Tasks) error {
+		for _, t := range resp.Items {
+			result = append(result, parseTask(t, listID))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tasks: %w", err)
+	}
+	SortByDue(result)
+	return result, nil
+}
+
+// AddTask creates a task; a zero due time leaves it undated
+func (s *Service) AddTask(ctx context.Context, listID, title, notes string, due time.Time) (*Task, error) {
+	t := &tasks.Task{Title: title, Notes: notes}
+	if !due.IsZero() {
+		y, m, d := due.Date()
+		t.Due = time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
+	}
+	created, err := s.srv.Tasks.Insert(listID, t).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create task: %w", err)
+	}
+	return parseTask(created, listID), nil
+}
+
+// CompleteTask marks a task as done
+func (s *Service) CompleteTask(ctx context.Context, listID, taskID string) (*Task, error) {
+	updated, err := s.srv.Tasks.Patch(listID, taskID, &tasks.Task{Status: "completed"}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete task: %w", err)
+	}
+	return parseTask(updated, listID), nil
+}
+
+// SortByDue orders tasks by due date, with undated tasks last
+func SortByDue(list []*Task) {
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i].Due, list[j].Due
+		if a.IsZero() || b.IsZero() {
+			return !a.IsZero() && b.IsZero()
+		}
+		return a.Before(b)
+	})
+}
+
+// parseTask converts an API task to our Task type
+func parseTask(t *tasks.Task, listID string) *Task {
+	task := &Task{
+		ID:        t.Id,
+		ListID:    listID,
+		Title:     t.Title,
+		Notes:     t.Notes,
+		Completed: t.Status == "completed",
+	}
+	if due, err := time.Parse(time.RFC3339, t.Due); err == nil {
+		// The API only keeps the date part; present it as a local date
+		y, m, d := due.UTC().Date()
+		task.Due = time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	}
+	return task
+}
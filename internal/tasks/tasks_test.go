@@ -0,0 +1,140 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/tasks/v1"
+)
+
+// newTestService returns a Service whose requests go to handler
+func newTestService(t *testing.T, handler http.Handler) *Service {
+	t.Helper()
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	srv, err := tasks.NewService(context.Background(),
+		option.WithHTTPClient(ts.Client()),
+		option.WithEndpoint(ts.URL+"/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Service{srv: srv}
+}
+
+// fakeTaskList is a fake Tasks API serving pages of tasks and recording
+// the query of the last list request
+type fakeTaskList struct {
+	pages [][]*tasks.Task
+	query url.Values
+}
+
+func (f *fakeTaskList) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.query = r.URL.Query()
+	page, _ := strconv.Atoi(f.query.Get("pageToken"))
+	resp := &tasks.Tasks{Items: f.pages[page]}
+	if page+1 < len(f.pages) {
+		resp.NextPageToken = strconv.Itoa(page + 1)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func TestListTasks(t *testing.T) {
+	fake := &fakeTaskList{pages: [][]*tasks.Task{
+		{
+			{Id: "t1", Title: "Undated"},
+			{Id: "t2", Title: "Taxes", Due: "2025-06-10T00:00:00.000Z"},
+		},
+		{
+			{Id: "t3", Title: "Groceries", Due: "2025-06-02T00:00:00.000Z", Notes: "milk"},
+			{Id: "t4", Title: "Done already", Due: "2025-06-01T00:00:00.000Z", Status: "completed"},
+		},
+	}}
+	srv := newTestService(t, fake)
+
+	tests := []struct {
+		name          string
+		list          func() ([]*Task, error)
+		want          string
+		wantCompleted string
+		wantDueMax    string
+	}{
+		{
+			name:          "incomplete",
+			list:          func() ([]*Task, error) { return srv.ListTasks(context.Background(), DefaultList, false) },
+			want:          "t4,t3,t2,t1",
+			wantCompleted: "false",
+		},
+		{
+			name:          "with completed",
+			list:          func() ([]*Task, error) { return srv.ListTasks(context.Background(), DefaultList, true) },
+			want:          "t4,t3,t2,t1",
+			wantCompleted: "true",
+		},
+		{
+			name: "due by a day",
+			list: func() ([]*Task, error) {
+				return srv.DueBy(context.Background(), DefaultList, time.Date(2025, 6, 2, 18, 30, 0, 0, time.Local))
+			},
+			want:          "t4,t3,t2,t1",
+			wantCompleted: "false",
+			wantDueMax:    "2025-06-02T00:00:00Z",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := tt.list()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Sorting is by due date with undated last; filtering is the API's job
+			var ids []string
+			for _, task := range list {
+				ids = append(ids, task.ID)
+			}
+			if got := strings.Join(ids, ","); got != tt.want {
+				t.Errorf("tasks = %s, want %s", got, tt.want)
+			}
+			if got := fake.query.Get("showCompleted"); got != tt.wantCompleted {
+				t.Errorf("showCompleted = %q, want %q", got, tt.wantCompleted)
+			}
+			if got := fake.query.Get("dueMax"); got != tt.wantDueMax {
+				t.Errorf("dueMax = %q, want %q", got, tt.wantDueMax)
+			}
+		})
+	}
+}
+
+func TestParseTask(t *testing.T) {
+	tests := []struct {
+		name          string
+		task          *tasks.Task
+		wantDue       time.Time
+		wantCompleted bool
+	}{
+		{"undated", &tasks.Task{Id: "t1"}, time.Time{}, false},
+		{"due date is local", &tasks.Task{Id: "t2", Due: "2025-06-10T00:00:00.000Z"}, time.Date(2025, 6, 10, 0, 0, 0, 0, time.Local), false},
+		{"completed", &tasks.Task{Id: "t3", Status: "completed"}, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseTask(tt.task, DefaultList)
+			if !got.Due.Equal(tt.wantDue) {
+				t.Errorf("due = %v, want %v", got.Due, tt.wantDue)
+			}
+			if got.Completed != tt.wantCompleted {
+				t.Errorf("completed = %v, want %v", got.Completed, tt.wantCompleted)
+			}
+			if got.ListID != DefaultList {
+				t.Errorf("list = %q, want %q", got.ListID, DefaultList)
+			}
+		})
+	}
+}
@@ -7,7 +7,9 @@ import (
 )
 
 func main() {
+	// Commands exit on their own errors, so anything returned here is a
+	// flag or argument parsing failure
 	if err := cmd.Execute(); err != nil {
-		os.Exit(1)
+		os.Exit(cmd.ExitUsage)
 	}
 }